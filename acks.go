@@ -0,0 +1,134 @@
+package bench
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// AckResult is the outcome of the acknowledgement check of tests with
+// concurrent writers. Writes are identified by the counter embedded in unique
+// keys.
+type AckResult struct {
+	Generated  uint64 `json:"generated"`  // distinct generated writes
+	Acked      uint64 `json:"acked"`      // distinct acknowledged writes
+	Unacked    uint64 `json:"unacked"`    // generated writes which were never acknowledged
+	Duplicates uint64 `json:"duplicates"` // acknowledgements of already acknowledged writes
+	Unknown    uint64 `json:"unknown"`    // acknowledged keys which weren't generated
+	Stored     uint64 `json:"stored"`     // keys in the database
+	Missing    uint64 `json:"missing"`    // acknowledged writes not in the database
+	Unexpected uint64 `json:"unexpected"` // keys in the database which weren't acknowledged
+}
+
+// OK reports whether every generated write was acknowledged once and stored.
+func (r AckResult) OK() bool {
+	return r.Unacked == 0 && r.Duplicates == 0 && r.Unknown == 0 && r.Missing == 0 && r.Unexpected == 0
+}
+
+// KeysFunc sets the function used to iterate all keys in the database. It is
+// used by the acknowledgement check.
+func (env *WriteEnv) KeysFunc(fn func(visit func(key []byte)) error) {
+	env.keysFn = fn
+}
+
+// Ack records that the write of key was acknowledged by the database. Tests
+// with concurrent writers call it for every completed write when cfg.CheckAcks
+// is set. It is safe to call from any goroutine.
+func (env *WriteEnv) Ack(key []byte) {
+	if !env.cfg.CheckAcks {
+		return
+	}
+	i := uniqueKeyCounter(key)
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	switch {
+	case !env.generatedKeys.has(i):
+		env.acks.Unknown++
+	case !env.ackedKeys.add(i):
+		env.acks.Duplicates++
+	}
+}
+
+// startAcks resets the acknowledgement check.
+func (env *WriteEnv) startAcks() error {
+	env.acks, env.generatedKeys, env.ackedKeys = AckResult{}, bitset{}, bitset{}
+	if env.cfg.CheckAcks && !env.cfg.UniqueKeys {
+		return errors.New("acknowledgement check requires unique keys")
+	}
+	if env.cfg.CheckAcks && env.cfg.Encoder != nil && env.cfg.Encoder.Key != nil {
+		return errors.New("acknowledgement check doesn't support key encoders")
+	}
+	return nil
+}
+
+// recordAck registers a generated write for the acknowledgement check.
+func (env *WriteEnv) recordAck(key []byte) {
+	if !env.cfg.CheckAcks {
+		return
+	}
+	env.mu.Lock()
+	env.generatedKeys.add(uniqueKeyCounter(key))
+	env.mu.Unlock()
+}
+
+// checkAcks compares the acknowledged writes against the generated ones and
+// against the keys in the database.
+func (env *WriteEnv) checkAcks(result *RunResult) {
+	if !env.cfg.CheckAcks {
+		return
+	}
+	env.mu.Lock()
+	r := env.acks
+	r.Generated, r.Acked = env.generatedKeys.count, env.ackedKeys.count
+	r.Unacked = r.Generated - r.Acked
+	env.mu.Unlock()
+
+	if env.keysFn != nil {
+		begin := time.Now()
+		var stored bitset
+		err := env.keysFn(func(key []byte) {
+			r.Stored++
+			// Keys of earlier runs can share the counter of an acknowledged
+			// key, so every counter must be stored once.
+			if len(key) < 8 || !env.ackedKeys.has(uniqueKeyCounter(key)) || !stored.add(uniqueKeyCounter(key)) {
+				r.Unexpected++
+			}
+		})
+		if err != nil {
+			log.Printf("can't check acknowledged writes: %v", err)
+			return
+		}
+		r.Missing = r.Acked - stored.count
+		log.Printf("checked %d stored keys against acknowledged writes in %v", r.Stored, time.Since(begin))
+	}
+	if !r.OK() {
+		log.Printf("acknowledged writes are inconsistent: %d unacked, %d duplicate acks, %d unknown acks, %d missing, %d unexpected keys",
+			r.Unacked, r.Duplicates, r.Unknown, r.Missing, r.Unexpected)
+	}
+	result.Acks = &r
+}
+
+// bitset is a growable set of integers.
+type bitset struct {
+	words []uint64
+	count uint64
+}
+
+// add inserts i and reports whether it was not in the set before.
+func (s *bitset) add(i uint64) bool {
+	w := i / 64
+	if w >= uint64(len(s.words)) {
+		s.words = append(s.words, make([]uint64, w+1-uint64(len(s.words)))...)
+	}
+	if s.words[w]&(1<<(i%64)) != 0 {
+		return false
+	}
+	s.words[w] |= 1 << (i % 64)
+	s.count++
+	return true
+}
+
+func (s *bitset) has(i uint64) bool {
+	w := i / 64
+	return w < uint64(len(s.words)) && s.words[w]&(1<<(i%64)) != 0
+}
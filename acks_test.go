@@ -0,0 +1,101 @@
+package bench
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+)
+
+func TestCheckAcks(t *testing.T) {
+	tests := []struct {
+		name   string
+		write  func(env *WriteEnv, store map[string]bool, key string, n int)
+		expect AckResult
+	}{
+		{
+			name: "ok",
+			write: func(env *WriteEnv, store map[string]bool, key string, n int) {
+				store[key] = true
+				env.Ack([]byte(key))
+			},
+		},
+		{
+			name: "dropped",
+			write: func(env *WriteEnv, store map[string]bool, key string, n int) {
+				if n != 5 {
+					store[key] = true
+					env.Ack([]byte(key))
+				}
+			},
+			expect: AckResult{Unacked: 1},
+		},
+		{
+			name: "unacked",
+			write: func(env *WriteEnv, store map[string]bool, key string, n int) {
+				store[key] = true
+				if n != 5 {
+					env.Ack([]byte(key))
+				}
+			},
+			expect: AckResult{Unacked: 1, Unexpected: 1},
+		},
+		{
+			name: "duplicate",
+			write: func(env *WriteEnv, store map[string]bool, key string, n int) {
+				store[key] = true
+				env.Ack([]byte(key))
+				if n == 5 {
+					env.Ack([]byte(key))
+				}
+			},
+			expect: AckResult{Duplicates: 1},
+		},
+		{
+			name: "lost",
+			write: func(env *WriteEnv, store map[string]bool, key string, n int) {
+				if n != 5 {
+					store[key] = true
+				}
+				env.Ack([]byte(key))
+			},
+			expect: AckResult{Missing: 1},
+		},
+	}
+	for _, test := range tests {
+		var (
+			out   bytes.Buffer
+			store = make(map[string]bool)
+			cfg   = WriteConfig{Size: 10000, KeySize: 16, DataSize: 100, UniqueKeys: true, CheckAcks: true}
+			env   = NewWriteEnv(&out, cfg)
+			n     = 0
+		)
+		env.KeysFunc(func(visit func(key []byte)) error {
+			keys := make([]string, 0, len(store))
+			for k := range store {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				visit([]byte(k))
+			}
+			return nil
+		})
+		err := env.Run(func(key, value string, lastCall bool) error {
+			n++
+			test.write(env, store, key, n)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := lastResult(t, &out).Acks
+		if got == nil {
+			t.Fatalf("%s: no ack result", test.name)
+		}
+		got.Generated, got.Acked, got.Stored = 0, 0, 0
+		if *got != test.expect {
+			t.Errorf("%s: wrong result %+v, want %+v", test.name, *got, test.expect)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package bench
+
+// RepeatedTest summarizes the repeated runs of a test.
+type RepeatedTest struct {
+	Label   string
+	Runs    int
+	Metrics []MetricAggregate // metrics missing in all runs are left out
+}
+
+// MetricAggregate is a metric of a test over its runs. Throughput is in
+// bytes/s, latencies in nanoseconds.
+type MetricAggregate struct {
+	Metric string
+	MetricStats
+}
+
+// Aggregate groups reports by label and computes the mean, standard deviation
+// and range of throughput and latency percentiles of every test. Unsupported
+// runs are ignored.
+func Aggregate(reports []Report) []RepeatedTest {
+	groups, labels := groupByLabel(reports)
+	aggs := make([]RepeatedTest, 0, len(labels))
+	for _, label := range labels {
+		ta := RepeatedTest{Label: label, Runs: len(groups[label])}
+		for _, m := range compareMetrics {
+			if v := metricValues(groups[label], m.value); len(v) > 0 {
+				ta.Metrics = append(ta.Metrics, MetricAggregate{m.name, metricStats(v)})
+			}
+		}
+		aggs = append(aggs, ta)
+	}
+	return aggs
+}
@@ -0,0 +1,43 @@
+package bench
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAggregate(t *testing.T) {
+	run := func(name string, mbps uint64, p99 time.Duration) Report {
+		ev := Progress{Processed: mbps << 20, Delta: mbps << 20, Duration: time.Second}
+		return Report{
+			Name:   name,
+			Events: []Progress{ev},
+			Result: &RunResult{Latency: &LatencyPercentiles{P99: p99}},
+		}
+	}
+	reports := []Report{
+		run("a", 100, 10*time.Microsecond), run("a", 104, 14*time.Microsecond), run("a", 96, 12*time.Microsecond),
+		{Name: "skipped", Result: &RunResult{Unsupported: "no"}},
+		{Name: "b.2021-01-01-12:00:00", Header: &LogHeader{Test: "b"}},
+	}
+	aggs := Aggregate(reports)
+	if len(aggs) != 2 || aggs[0].Label != "a" || aggs[1].Label != "b" {
+		t.Fatalf("wrong aggregates %+v", aggs)
+	}
+	if aggs[0].Runs != 3 || len(aggs[0].Metrics) != 5 {
+		t.Fatalf("wrong aggregate %+v", aggs[0])
+	}
+	bps := aggs[0].Metrics[0]
+	if bps.Metric != "throughput" || bps.Runs != 3 || bps.Mean != 100<<20 || bps.Min != 96<<20 || bps.Max != 104<<20 {
+		t.Errorf("wrong throughput stats %+v", bps)
+	}
+	if bps.Std != 4<<20 {
+		t.Errorf("wrong throughput std %v", bps.Std)
+	}
+	p99 := aggs[0].Metrics[3]
+	if p99.Metric != "p99" || p99.Mean != float64(12*time.Microsecond) || p99.Min != float64(10*time.Microsecond) {
+		t.Errorf("wrong p99 stats %+v", p99)
+	}
+	if len(aggs[1].Metrics) != 0 {
+		t.Errorf("empty run should have no metrics: %+v", aggs[1])
+	}
+}
@@ -0,0 +1,67 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// BenchmarkName converts the label of a report to a Go benchmark name. Dashes
+// separate words of the name, e.g. batch-100kb-nosync becomes
+// BenchmarkBatch100kbNosync, because benchstat reads a trailing -N as the
+// GOMAXPROCS setting. Tags become sub-benchmark keys like /fs=ext4.
+func BenchmarkName(r *Report) string {
+	var b strings.Builder
+	b.WriteString("Benchmark")
+	for _, word := range strings.FieldsFunc(r.Name, func(c rune) bool { return c == '-' || c == '_' || unicode.IsSpace(c) }) {
+		b.WriteString(strings.ToUpper(word[:1]))
+		b.WriteString(word[1:])
+	}
+	if len(r.Tags) > 0 {
+		for _, kv := range strings.Fields(r.Tags.String()) {
+			b.WriteString("/")
+			b.WriteString(kv)
+		}
+	}
+	return b.String()
+}
+
+// WriteBenchmarkFormat writes the reports as Go benchmark results, which can be
+// compared by golang.org/x/perf/cmd/benchstat. The iteration count is the
+// number of entries processed. Besides ns/op and MB/s, the latency percentiles
+// of the run are written as p50-ns, p99-ns etc. Unsupported and empty runs are
+// left out. System information of the log headers is written as configuration
+// lines.
+func WriteBenchmarkFormat(w io.Writer, reports []Report) error {
+	var config map[string]string
+	for i := range reports {
+		r := &reports[i]
+		if r.Result != nil && r.Result.Unsupported != "" {
+			continue
+		}
+		s := Summarize(*r)
+		if s.TotalTime <= 0 || s.Entries == 0 {
+			continue
+		}
+		if r.Header != nil && r.Header.System != nil {
+			si := r.Header.System
+			c := map[string]string{"goos": si.OS, "goarch": si.Arch, "cpu": si.CPU}
+			for _, k := range []string{"goos", "goarch", "cpu"} {
+				if c[k] != "" && c[k] != config[k] {
+					fmt.Fprintf(w, "%s: %s\n", k, c[k])
+				}
+			}
+			config = c
+		}
+		line := fmt.Sprintf("%s\t%d\t%.1f ns/op\t%.2f MB/s", BenchmarkName(r), s.Entries, s.TotalTime*1e9/float64(s.Entries), s.BPS()/1e6)
+		if r.Result != nil && r.Result.Latency != nil {
+			l := r.Result.Latency
+			line += fmt.Sprintf("\t%d p50-ns\t%d p90-ns\t%d p99-ns\t%d p99.9-ns", l.P50, l.P90, l.P99, l.P999)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
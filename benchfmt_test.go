@@ -0,0 +1,44 @@
+package bench
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestBenchmarkName(t *testing.T) {
+	tests := []struct {
+		r    Report
+		want string
+	}{
+		{Report{Name: "batch-1mb"}, "BenchmarkBatch1mb"},
+		{Report{Name: "batch-100kb-nosync"}, "BenchmarkBatch100kbNosync"},
+		{Report{Name: "concurrent-8", Tags: Tags{"fs": "ext4", "db": "pebble"}}, "BenchmarkConcurrent8/db=pebble/fs=ext4"},
+	}
+	for _, test := range tests {
+		if got := BenchmarkName(&test.r); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.r.Name, got, test.want)
+		}
+	}
+}
+
+func TestWriteBenchmarkFormat(t *testing.T) {
+	reports := []Report{
+		{
+			Name:   "batch-1mb",
+			Header: &LogHeader{System: &SystemInfo{OS: "linux", Arch: "amd64"}},
+			Events: []Progress{{Processed: 2e6, Delta: 2e6, Duration: time.Second, Entries: 1000}},
+			Result: &RunResult{Latency: &LatencyPercentiles{P50: 10, P90: 20, P99: 30, P999: 40}},
+		},
+		{Name: "nobatch", Result: &RunResult{Unsupported: "no"}},
+	}
+	var buf bytes.Buffer
+	if err := WriteBenchmarkFormat(&buf, reports); err != nil {
+		t.Fatal(err)
+	}
+	want := "goos: linux\ngoarch: amd64\n" +
+		"BenchmarkBatch1mb\t1000\t1000000.0 ns/op\t2.00 MB/s\t10 p50-ns\t20 p90-ns\t30 p99-ns\t40 p99.9-ns\n"
+	if buf.String() != want {
+		t.Errorf("wrong output:\n%s", buf.String())
+	}
+}
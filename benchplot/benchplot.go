@@ -0,0 +1,585 @@
+// Package benchplot renders plots of benchmark reports.
+package benchplot
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgimg"
+)
+
+// Types are the supported plot types.
+var Types = []string{"bps", "abstime", "latency", "commitlatency", "family", "compaction", "levels", "levelsize", "spaceamp", "memory", "device"}
+
+// New creates a plot of the given type.
+func New(plotType string, reports []bench.Report) (*plot.Plot, error) {
+	var (
+		plt = plot.New()
+		err error
+	)
+	switch plotType {
+	case "bps":
+		err = plotBPS(plt, reports)
+	case "abstime":
+		err = plotAbsTime(plt, reports)
+	case "latency":
+		err = plotLatency(plt, reports, "entry latency (µs)", toEntryLatencyPlot)
+	case "commitlatency":
+		err = plotLatency(plt, reports, "commit latency (µs)", toCommitLatencyPlot)
+	case "family":
+		err = plotFamilies(plt, reports)
+	case "compaction":
+		err = plotCompaction(plt, reports)
+	case "levels":
+		err = plotLevels(plt, reports, "tables", func(l bench.LevelStats) float64 { return float64(l.Tables) })
+	case "levelsize":
+		plt.Y.Tick.Marker = megabyteTicks{unit: "mb"}
+		err = plotLevels(plt, reports, "level size", func(l bench.LevelStats) float64 { return float64(l.Size) })
+	case "spaceamp":
+		err = plotSpaceAmp(plt, reports)
+	case "memory":
+		err = plotMemory(plt, reports)
+	case "device":
+		err = plotDeviceWrites(plt, reports)
+	default:
+		err = fmt.Errorf("unknown plot type %q", plotType)
+	}
+	return plt, err
+}
+
+// Size is the size of a rendered plot. DPI is the resolution of raster formats,
+// zero selects the default of 96.
+type Size struct {
+	W, H vg.Length
+	DPI  int
+}
+
+// DefaultSize is the size of plots written by the benchmark commands.
+var DefaultSize = Size{W: 15 * vg.Centimeter, H: 10 * vg.Centimeter}
+
+// ParseLength parses a plot dimension like "15cm", "6in", "400pt" or "800px".
+// Pixels are converted at the given DPI, numbers without a unit are in cm.
+func ParseLength(s string, dpi int) (vg.Length, error) {
+	if dpi == 0 {
+		dpi = vgimg.DefaultDPI
+	}
+	units := []struct {
+		suffix string
+		unit   vg.Length
+	}{
+		{"cm", vg.Centimeter}, {"mm", vg.Millimeter}, {"in", vg.Inch},
+		{"pt", vg.Points(1)}, {"px", vg.Inch / vg.Length(dpi)}, {"", vg.Centimeter},
+	}
+	for _, u := range units {
+		if !strings.HasSuffix(s, u.suffix) {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSuffix(s, u.suffix), 64)
+		if err != nil || v <= 0 {
+			break
+		}
+		return vg.Length(v) * u.unit, nil
+	}
+	return 0, fmt.Errorf("invalid length %q", s)
+}
+
+// newCanvas creates a canvas for the format of file. Supported formats are
+// eps, jpg, pdf, png, svg and tiff.
+func newCanvas(w, h vg.Length, dpi int, file string) (vg.CanvasWriterTo, error) {
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(file)), ".")
+	switch format {
+	case "png", "jpg", "jpeg", "tif", "tiff":
+		if dpi == 0 {
+			dpi = vgimg.DefaultDPI
+		}
+		c := vgimg.NewWith(vgimg.UseWH(w, h), vgimg.UseDPI(dpi))
+		switch format {
+		case "png":
+			return vgimg.PngCanvas{Canvas: c}, nil
+		case "tif", "tiff":
+			return vgimg.TiffCanvas{Canvas: c}, nil
+		default:
+			return vgimg.JpegCanvas{Canvas: c}, nil
+		}
+	}
+	return draw.NewFormattedCanvas(w, h, format)
+}
+
+// writeCanvas writes the rendered canvas to file.
+func writeCanvas(c vg.CanvasWriterTo, file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if _, err = c.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Save renders a plot of the given type to file. The format is determined by
+// the file extension.
+func Save(plotType string, reports []bench.Report, size Size, file string) error {
+	plt, err := New(plotType, reports)
+	if err != nil {
+		return err
+	}
+	c, err := newCanvas(size.W, size.H, size.DPI, file)
+	if err != nil {
+		return err
+	}
+	plt.Draw(draw.New(c))
+	return writeCanvas(c, file)
+}
+
+// SaveFacets groups reports by the value of a tag and renders one subplot per
+// group. size is the size of each subplot.
+func SaveFacets(plotType, tag string, reports []bench.Report, size Size, file string) error {
+	groups := make(map[string][]bench.Report)
+	var values []string
+	for _, r := range reports {
+		v, ok := r.Tags[tag]
+		if !ok {
+			v = "(none)"
+		}
+		if groups[v] == nil {
+			values = append(values, v)
+		}
+		groups[v] = append(groups[v], r)
+	}
+	sort.Strings(values)
+
+	cols := int(math.Ceil(math.Sqrt(float64(len(values)))))
+	rows := (len(values) + cols - 1) / cols
+	plots := make([][]*plot.Plot, rows)
+	for i := range plots {
+		plots[i] = make([]*plot.Plot, cols)
+	}
+	for i, v := range values {
+		plt, err := New(plotType, groups[v])
+		if err != nil {
+			return err
+		}
+		plt.Title.Text = tag + "=" + v
+		plots[i/cols][i%cols] = plt
+	}
+
+	c, err := newCanvas(size.W*vg.Length(cols), size.H*vg.Length(rows), size.DPI, file)
+	if err != nil {
+		return err
+	}
+	tiles := draw.Tiles{Rows: rows, Cols: cols, PadX: vg.Millimeter, PadY: vg.Millimeter}
+	canvases := plot.Align(plots, tiles, draw.New(c))
+	for i := range plots {
+		for j, plt := range plots[i] {
+			if plt != nil {
+				plt.Draw(canvases[i][j])
+			}
+		}
+	}
+	return writeCanvas(c, file)
+}
+
+// reduceEvents aggregates progress events so there are ~n total events.
+// This smoothes out the line in the plot. The P99 latency of a group is the
+// highest of its events.
+func reduceEvents(events []bench.Progress, n int) []bench.Progress {
+	group := len(events) / n
+	if group <= 1 || len(events) == 0 {
+		return events
+	}
+	grouped := make([]bench.Progress, 0, n)
+	for i, ev := range events {
+		if i%group == 0 {
+			grouped = append(grouped, bench.Progress{})
+		}
+		end := len(grouped) - 1
+		grouped[end].Delta += ev.Delta
+		grouped[end].Duration += ev.Duration
+		grouped[end].Processed = ev.Processed
+		grouped[end].Time = ev.Time
+		grouped[end].Entries += ev.Entries
+		grouped[end].Commits += ev.Commits
+		if ev.P99 > grouped[end].P99 {
+			grouped[end].P99 = ev.P99
+		}
+	}
+	return grouped
+}
+
+// plotBPS adds BPS vs. database size plots for all reports.
+func plotBPS(plt *plot.Plot, reports []bench.Report) error {
+	plt.X.Tick.Marker = megabyteTicks{unit: "mb"}
+	plt.X.Label.Text = "database size"
+	plt.Y.Label.Text = "speed"
+	plt.Y.Tick.Marker = megabyteTicks{unit: "mb/s"}
+	plt.Legend.Top = true
+	return addPlots(plt, reports, toBPSPlot)
+}
+
+// plotAbsTime adds time/size plots for all reports.
+func plotAbsTime(plt *plot.Plot, reports []bench.Report) error {
+	plt.X.Label.Text = "time (s)"
+	plt.Y.Label.Text = "processed size"
+	plt.Y.Tick.Marker = megabyteTicks{unit: "mb"}
+	return addPlots(plt, reports, toAbsTimePlot)
+}
+
+// plotLatency adds amortized latency vs. database size plots for all reports.
+func plotLatency(plt *plot.Plot, reports []bench.Report, label string, toXY xyFunc) error {
+	plt.X.Tick.Marker = megabyteTicks{unit: "mb"}
+	plt.X.Label.Text = "database size"
+	plt.Y.Label.Text = label
+	plt.Legend.Top = true
+	return addPlots(plt, reports, toXY)
+}
+
+// plotFamilies adds throughput vs. parameter plots for all test families.
+func plotFamilies(plt *plot.Plot, reports []bench.Report) error {
+	plt.X.Label.Text = "parameter"
+	plt.Y.Label.Text = "speed"
+	plt.Y.Tick.Marker = megabyteTicks{unit: "mb/s"}
+	plt.Legend.Top = true
+	families := bench.Families(reports)
+	if len(families) == 0 {
+		log.Printf("Warning: no test families with more than one parameter value")
+	}
+	logScale := true
+	for i, f := range families {
+		xy := make(plotter.XYs, len(f.Points))
+		for j, p := range f.Points {
+			xy[j].X, xy[j].Y = float64(p.Param), p.BPS
+			logScale = logScale && p.Param > 0
+		}
+		l, s, err := plotter.NewLinePoints(xy)
+		if err != nil {
+			return err
+		}
+		l.Color, s.Color = plotutil.Color(i), plotutil.Color(i)
+		plt.Add(l, s)
+		plt.Legend.Add(f.Label(), l, s)
+	}
+	plt.Y.Min = 0
+	if logScale && len(families) > 0 {
+		plt.X.Scale = plot.LogScale{}
+		plt.X.Tick.Marker = plot.LogTicks{}
+	}
+	return nil
+}
+
+// plotCompaction adds compaction write rate vs. time plots for all reports with
+// compaction statistics.
+func plotCompaction(plt *plot.Plot, reports []bench.Report) error {
+	plt.X.Label.Text = "time (s)"
+	plt.Y.Label.Text = "compaction writes"
+	plt.Y.Tick.Marker = megabyteTicks{unit: "mb/s"}
+	plt.Legend.Top = true
+	for i, r := range reports {
+		if len(r.Compactions) < 2 {
+			log.Printf("Warning: report %s has no compaction statistics", r.Name)
+			continue
+		}
+		l, err := plotter.NewLine(compactionPlot(r.Compactions))
+		if err != nil {
+			return err
+		}
+		l.Color = plotutil.Color(i)
+		plt.Add(l)
+		plt.Legend.Add(r.Label(), l)
+	}
+	plt.Y.Min = 0
+	return nil
+}
+
+// compactionPlot plots X = time against Y = bytes written by compactions per
+// second since the previous sample.
+type compactionPlot []bench.CompactionStats
+
+func (p compactionPlot) Len() int {
+	return len(p) - 1
+}
+
+func (p compactionPlot) XY(i int) (float64, float64) {
+	_, prev, _ := p[i].Totals()
+	_, cur, _ := p[i+1].Totals()
+	d := p[i+1].Time - p[i].Time
+	rate := 0.0
+	if d > 0 && cur > prev {
+		rate = float64(cur-prev) / d.Seconds()
+	}
+	return p[i+1].Time.Seconds(), rate
+}
+
+// plotLevels adds a line per level and report, plotting a level statistic
+// against time. Samples taken while writes were paused for level 0 compaction
+// are marked on the level 0 line.
+func plotLevels(plt *plot.Plot, reports []bench.Report, ylabel string, y func(bench.LevelStats) float64) error {
+	plt.X.Label.Text = "time (s)"
+	plt.Y.Label.Text = ylabel
+	plt.Legend.Top = true
+	pausedLegend := false
+	for i, r := range reports {
+		if len(r.Compactions) == 0 {
+			log.Printf("Warning: report %s has no compaction statistics", r.Name)
+			continue
+		}
+		var (
+			levels [][]plotter.XY
+			paused plotter.XYs
+		)
+		for _, c := range r.Compactions {
+			for lvl, l := range c.Levels {
+				if lvl >= len(levels) {
+					levels = append(levels, nil)
+				}
+				xy := plotter.XY{X: c.Time.Seconds(), Y: y(l)}
+				levels[lvl] = append(levels[lvl], xy)
+				if lvl == 0 && c.WritePaused {
+					paused = append(paused, xy)
+				}
+			}
+		}
+		for lvl, xy := range levels {
+			if isZero(xy) {
+				continue // don't clutter the plot with unused levels
+			}
+			l, err := plotter.NewLine(plotter.XYs(xy))
+			if err != nil {
+				return err
+			}
+			l.Color = plotutil.Color(lvl)
+			l.Dashes = plotutil.Dashes(i)
+			plt.Add(l)
+			label := fmt.Sprintf("L%d", lvl)
+			if len(reports) > 1 {
+				label = r.Label() + " " + label
+			}
+			plt.Legend.Add(label, l)
+		}
+		if len(paused) > 0 {
+			s, err := plotter.NewScatter(paused)
+			if err != nil {
+				return err
+			}
+			s.Color = plotutil.Color(0)
+			s.Shape = draw.CrossGlyph{}
+			plt.Add(s)
+			if !pausedLegend {
+				plt.Legend.Add("writes paused", s)
+				pausedLegend = true
+			}
+		}
+	}
+	plt.Y.Min = 0
+	return nil
+}
+
+func isZero(xy []plotter.XY) bool {
+	for _, p := range xy {
+		if p.Y != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// plotSpaceAmp adds space amplification vs. data written plots for all reports
+// with disk usage samples.
+func plotSpaceAmp(plt *plot.Plot, reports []bench.Report) error {
+	plt.X.Tick.Marker = megabyteTicks{unit: "mb"}
+	plt.X.Label.Text = "data written"
+	plt.Y.Label.Text = "space amplification"
+	plt.Legend.Top = true
+	for i, r := range reports {
+		var xy plotter.XYs
+		for _, d := range r.Disk {
+			if amp := d.SpaceAmplification(); amp > 0 {
+				xy = append(xy, plotter.XY{X: float64(d.Processed), Y: amp})
+			}
+		}
+		if len(xy) == 0 {
+			log.Printf("Warning: report %s has no disk usage samples", r.Name)
+			continue
+		}
+		l, err := plotter.NewLine(xy)
+		if err != nil {
+			return err
+		}
+		l.Color = plotutil.Color(i)
+		plt.Add(l)
+		plt.Legend.Add(r.Label(), l)
+	}
+	plt.Y.Min = 0
+	return nil
+}
+
+// plotMemory adds heap size vs. data processed plots for all reports with memory
+// samples.
+func plotMemory(plt *plot.Plot, reports []bench.Report) error {
+	plt.X.Tick.Marker = megabyteTicks{unit: "mb"}
+	plt.X.Label.Text = "data processed"
+	plt.Y.Label.Text = "heap size"
+	plt.Y.Tick.Marker = megabyteTicks{unit: "mb"}
+	plt.Legend.Top = true
+	for i, r := range reports {
+		if len(r.Memory) == 0 {
+			log.Printf("Warning: report %s has no memory samples", r.Name)
+			continue
+		}
+		xy := make(plotter.XYs, len(r.Memory))
+		for j, m := range r.Memory {
+			xy[j].X, xy[j].Y = float64(m.Processed), float64(m.HeapAlloc)
+		}
+		l, err := plotter.NewLine(xy)
+		if err != nil {
+			return err
+		}
+		l.Color = plotutil.Color(i)
+		plt.Add(l)
+		plt.Legend.Add(r.Label(), l)
+	}
+	plt.Y.Min = 0
+	return nil
+}
+
+// plotDeviceWrites adds plots of the bytes written to the database device vs.
+// time for all reports with device samples. Writes after the end of the run
+// are background flushes.
+func plotDeviceWrites(plt *plot.Plot, reports []bench.Report) error {
+	plt.X.Label.Text = "time (s)"
+	plt.Y.Label.Text = "device writes"
+	plt.Y.Tick.Marker = megabyteTicks{unit: "mb"}
+	plt.Legend.Top = true
+	for i, r := range reports {
+		var xy plotter.XYs
+		for _, d := range r.Disk {
+			if d.Device != nil {
+				xy = append(xy, plotter.XY{X: d.Time.Seconds(), Y: float64(d.Device.WriteBytes)})
+			}
+		}
+		if len(xy) == 0 {
+			log.Printf("Warning: report %s has no device samples", r.Name)
+			continue
+		}
+		l, err := plotter.NewLine(xy)
+		if err != nil {
+			return err
+		}
+		l.Color = plotutil.Color(i)
+		plt.Add(l)
+		plt.Legend.Add(r.Label(), l)
+	}
+	plt.Y.Min = 0
+	return nil
+}
+
+type xyFunc func([]bench.Progress) plotter.XYer
+
+func addPlots(plt *plot.Plot, reports []bench.Report, toXY xyFunc) error {
+	for i, r := range reports {
+		if len(r.Events) == 0 {
+			log.Printf("Warning: report %s has 0 progress events", r.Name)
+			continue
+		}
+		evs := reduceEvents(r.Events, 400)
+		l, err := plotter.NewLine(toXY(evs))
+		if err != nil {
+			return err
+		}
+		l.Color = plotutil.Color(i)
+		plt.Add(l)
+		plt.Legend.Add(r.Label(), l)
+	}
+	return nil
+}
+
+// bpsPlot plots X = db size against Y = bytes per second processed
+type bpsPlot []bench.Progress
+
+func toBPSPlot(events []bench.Progress) plotter.XYer {
+	return bpsPlot(events)
+}
+
+func (p bpsPlot) Len() int {
+	return len(p)
+}
+
+func (p bpsPlot) XY(i int) (float64, float64) {
+	x := float64(p[i].Processed)
+	return x, p[i].BPS()
+}
+
+// latencyPlot plots X = db size against Y = amortized latency in microseconds.
+type latencyPlot struct {
+	events  []bench.Progress
+	latency func(bench.Progress) time.Duration
+}
+
+func toEntryLatencyPlot(events []bench.Progress) plotter.XYer {
+	return latencyPlot{events, bench.Progress.EntryLatency}
+}
+
+func toCommitLatencyPlot(events []bench.Progress) plotter.XYer {
+	return latencyPlot{events, bench.Progress.CommitLatency}
+}
+
+func (p latencyPlot) Len() int {
+	return len(p.events)
+}
+
+func (p latencyPlot) XY(i int) (float64, float64) {
+	x := float64(p.events[i].Processed)
+	return x, float64(p.latency(p.events[i])) / float64(time.Microsecond)
+}
+
+// absTimePlot plots X = time against Y = bytes written.
+type absTimePlot []bench.Progress
+
+func toAbsTimePlot(events []bench.Progress) plotter.XYer {
+	for i := range events {
+		if i > 0 {
+			events[i].Duration += events[i-1].Duration
+		}
+	}
+	return absTimePlot(events)
+}
+
+func (p absTimePlot) Len() int {
+	return len(p)
+}
+
+func (p absTimePlot) XY(i int) (float64, float64) {
+	return float64(p[i].Duration / time.Second), float64(p[i].Processed)
+}
+
+// megabyteTicks emits axis labels corresponding to megabytes written.
+type megabyteTicks struct{ unit string }
+
+func (mt megabyteTicks) Ticks(min, max float64) (t []plot.Tick) {
+	const numLabels = 5
+	mag := nextPowerOfTwo(max - min)
+	dist := nextPowerOfTwo(mag / numLabels)
+	for s := nextPowerOfTwo(min); s < max; s += dist {
+		t = append(t, plot.Tick{Value: s, Label: fmt.Sprintf("%.2f %s", s/1024/1024, mt.unit)})
+	}
+	t = append(t, plot.Tick{Value: max, Label: fmt.Sprintf("%.2f %s", max/1024/1024, mt.unit)})
+	return t
+}
+
+func nextPowerOfTwo(f float64) float64 {
+	return math.Pow(2, math.Ceil(math.Log2(f)))
+}
@@ -0,0 +1,37 @@
+package benchplot
+
+import (
+	"testing"
+	"time"
+
+	bench "github.com/fjl/goleveldb-bench"
+)
+
+func TestReduceEvents(t *testing.T) {
+	events := make([]bench.Progress, 1000)
+	for i := range events {
+		events[i] = bench.Progress{
+			Processed: uint64(i+1) * 100,
+			Delta:     100,
+			Duration:  time.Millisecond,
+			Entries:   10,
+			P99:       time.Duration(i%7) * time.Microsecond,
+		}
+	}
+	reduced := reduceEvents(events, 400)
+	if len(reduced) != 500 {
+		t.Fatalf("got %d events, want 500", len(reduced))
+	}
+	for i, ev := range reduced {
+		if ev.Delta != 200 || ev.Entries != 20 || ev.Duration != 2*time.Millisecond {
+			t.Fatalf("event %d: wrong sums %+v", i, ev)
+		}
+		want := events[2*i].P99
+		if p := events[2*i+1].P99; p > want {
+			want = p
+		}
+		if ev.P99 != want {
+			t.Fatalf("event %d: p99 %v, want %v", i, ev.P99, want)
+		}
+	}
+}
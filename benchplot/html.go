@@ -0,0 +1,292 @@
+package benchplot
+
+import (
+	"html/template"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	bench "github.com/fjl/goleveldb-bench"
+)
+
+// htmlChart is a chart of the HTML report. Line charts plot each series
+// against X, bar charts have one bar per category and series.
+type htmlChart struct {
+	Title      string       `json:"title"`
+	XLabel     string       `json:"xlabel"`
+	YLabel     string       `json:"ylabel"`
+	Bars       bool         `json:"bars,omitempty"`
+	LogY       bool         `json:"logy,omitempty"`
+	Categories []string     `json:"categories,omitempty"`
+	Series     []htmlSeries `json:"series"`
+}
+
+type htmlSeries struct {
+	Label string    `json:"label"`
+	X     []float64 `json:"x,omitempty"`
+	Y     []float64 `json:"y"`
+}
+
+// htmlRow is a line of the summary table.
+type htmlRow struct {
+	Label  string
+	Time   string
+	Size   string
+	MBps   string
+	P99    string
+	Result string
+}
+
+// SaveHTML writes a self-contained HTML report of all reports, with interactive
+// charts of throughput over time, latency percentiles and disk size.
+func SaveHTML(reports []bench.Report, file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := WriteHTML(f, reports); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteHTML writes the HTML report of SaveHTML to w.
+func WriteHTML(w io.Writer, reports []bench.Report) error {
+	var (
+		rows    []htmlRow
+		bps     = htmlChart{Title: "Throughput", XLabel: "time (s)", YLabel: "mb/s"}
+		lat     = htmlChart{Title: "Latency percentiles", YLabel: "µs", Bars: true, LogY: true, Categories: []string{"p50", "p90", "p99", "p99.9", "max"}}
+		disk    = htmlChart{Title: "Disk size", XLabel: "time (s)", YLabel: "mb"}
+		charts  []htmlChart
+		hasDisk bool
+	)
+	for _, r := range reports {
+		label := r.Label()
+		rows = append(rows, summaryRow(r))
+		if len(r.Events) > 0 {
+			s := htmlSeries{Label: label}
+			var t time.Duration
+			for _, ev := range reduceEvents(r.Events, 400) {
+				t += ev.Duration
+				if ev.Time > 0 {
+					t = ev.Time
+				}
+				s.X = append(s.X, t.Seconds())
+				s.Y = append(s.Y, ev.BPS()/1024/1024)
+			}
+			bps.Series = append(bps.Series, s)
+		}
+		if r.Result != nil && r.Result.Latency != nil {
+			l := r.Result.Latency
+			s := htmlSeries{Label: label}
+			for _, d := range []time.Duration{l.P50, l.P90, l.P99, l.P999, l.Max} {
+				s.Y = append(s.Y, float64(d)/float64(time.Microsecond))
+			}
+			lat.Series = append(lat.Series, s)
+		}
+		if len(r.Disk) > 0 {
+			s := htmlSeries{Label: label}
+			for _, d := range r.Disk {
+				s.X = append(s.X, d.Time.Seconds())
+				s.Y = append(s.Y, float64(d.Size)/1024/1024)
+			}
+			disk.Series = append(disk.Series, s)
+			hasDisk = true
+		}
+	}
+	charts = append(charts, bps)
+	if len(lat.Series) > 0 {
+		charts = append(charts, lat)
+	}
+	if hasDisk {
+		charts = append(charts, disk)
+	}
+	return htmlTemplate.Execute(w, map[string]interface{}{"Rows": rows, "Charts": charts})
+}
+
+func summaryRow(r bench.Report) htmlRow {
+	row := htmlRow{Label: r.Label()}
+	if r.Result != nil && r.Result.Unsupported != "" {
+		row.Result = "unsupported: " + r.Result.Unsupported
+		return row
+	}
+	s := bench.Summarize(r)
+	row.Time = time.Duration(s.TotalTime * float64(time.Second)).Round(time.Millisecond).String()
+	row.Size = bench.FormatSize(s.TotalSize)
+	if s.TotalTime > 0 {
+		row.MBps = strconv.FormatFloat(s.BPS()/1024/1024, 'f', 3, 64)
+	}
+	if r.Result != nil {
+		if r.Result.Latency != nil {
+			row.P99 = r.Result.Latency.P99.String()
+		}
+		row.Result = r.Result.Error
+	}
+	return row
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>goleveldb-bench report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { padding: 0.3em 0.8em; border-bottom: 1px solid #ddd; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+.chart { position: relative; margin-bottom: 2em; }
+.chart canvas { border: 1px solid #eee; }
+.legend label { margin-right: 1em; cursor: pointer; white-space: nowrap; }
+.legend .swatch { display: inline-block; width: 1em; height: 0.6em; margin-right: 0.3em; }
+.tooltip { position: absolute; pointer-events: none; background: rgba(255,255,255,0.95); border: 1px solid #aaa; padding: 0.3em 0.5em; font-size: 0.85em; display: none; white-space: nowrap; }
+</style>
+</head>
+<body>
+<h1>goleveldb-bench report</h1>
+<table>
+<tr><th>test</th><th>time</th><th>size</th><th>mb/s</th><th>p99</th><th></th></tr>
+{{range .Rows}}<tr><td>{{.Label}}</td><td>{{.Time}}</td><td>{{.Size}}</td><td>{{.MBps}}</td><td>{{.P99}}</td><td>{{.Result}}</td></tr>
+{{end}}</table>
+<div id="charts"></div>
+<script>
+var charts = {{.Charts}};
+var palette = ["#e41a1c", "#377eb8", "#4daf4a", "#984ea3", "#ff7f00", "#a65628", "#f781bf", "#999999", "#1b9e77", "#66a61e"];
+
+function fmt(v) {
+	if (v === 0) return "0";
+	var a = Math.abs(v);
+	if (a >= 1000 || a < 0.01) return v.toPrecision(3);
+	return parseFloat(v.toPrecision(4)).toString();
+}
+
+function ticks(min, max) {
+	var step = Math.pow(10, Math.floor(Math.log10((max - min) / 5)));
+	if ((max - min) / step > 25) step *= 5;
+	else if ((max - min) / step > 10) step *= 2;
+	var t = [];
+	for (var v = Math.ceil(min / step) * step; v <= max + step / 1e6; v += step) t.push(v);
+	return t;
+}
+
+function drawChart(c) {
+	var div = document.createElement("div");
+	div.className = "chart";
+	div.innerHTML = "<h2></h2><div class=legend></div>";
+	div.firstChild.textContent = c.title;
+	var canvas = document.createElement("canvas");
+	canvas.width = 900; canvas.height = 400;
+	div.appendChild(canvas);
+	var tip = document.createElement("div");
+	tip.className = "tooltip";
+	div.appendChild(tip);
+	document.getElementById("charts").appendChild(div);
+
+	var hidden = {}, legend = div.querySelector(".legend");
+	c.series.forEach(function(s, i) {
+		var l = document.createElement("label");
+		l.innerHTML = "<input type=checkbox checked><span class=swatch></span>";
+		l.lastChild.style.background = palette[i % palette.length];
+		l.appendChild(document.createTextNode(s.label));
+		l.firstChild.onchange = function() { hidden[i] = !this.checked; render(); };
+		legend.appendChild(l);
+	});
+
+	var ctx = canvas.getContext("2d"), pad = {l: 70, r: 20, t: 10, b: 45};
+	var W = canvas.width - pad.l - pad.r, H = canvas.height - pad.t - pad.b;
+	var shapes = [];
+	var ty = c.logy ? function(v) { return Math.log10(Math.max(v, 1e-3)); } : function(v) { return v; };
+
+	function render() {
+		var xmin = Infinity, xmax = -Infinity, ymin = c.logy ? Infinity : 0, ymax = -Infinity;
+		c.series.forEach(function(s, i) {
+			if (hidden[i]) return;
+			s.y.forEach(function(y, j) {
+				ymin = Math.min(ymin, ty(y)); ymax = Math.max(ymax, ty(y));
+				if (!c.bars) { xmin = Math.min(xmin, s.x[j]); xmax = Math.max(xmax, s.x[j]); }
+			});
+		});
+		if (c.bars) { xmin = 0; xmax = c.categories.length; }
+		if (c.logy) { ymin = Math.floor(ymin); ymax = Math.ceil(ymax); }
+		if (!isFinite(ymin)) ymin = 0;
+		if (!isFinite(ymax) || ymax <= ymin) ymax = ymin + 1;
+		if (!isFinite(xmin) || xmax <= xmin) { xmin = 0; xmax = 1; }
+		var px = function(x) { return pad.l + (x - xmin) / (xmax - xmin) * W; };
+		var py = function(y) { return pad.t + H - (ty(y) - ymin) / (ymax - ymin) * H; };
+
+		ctx.clearRect(0, 0, canvas.width, canvas.height);
+		ctx.font = "12px sans-serif";
+		ctx.strokeStyle = "#ddd"; ctx.fillStyle = "#222";
+		ctx.textAlign = "right"; ctx.textBaseline = "middle";
+		var yt = c.logy ? ticks(ymin, ymax).filter(function(v) { return v === Math.round(v); }) : ticks(ymin, ymax);
+		yt.forEach(function(v) {
+			var y = pad.t + H - (v - ymin) / (ymax - ymin) * H;
+			ctx.beginPath(); ctx.moveTo(pad.l, y); ctx.lineTo(pad.l + W, y); ctx.stroke();
+			ctx.fillText(fmt(c.logy ? Math.pow(10, v) : v), pad.l - 5, y);
+		});
+		ctx.textAlign = "center"; ctx.textBaseline = "top";
+		if (c.bars) {
+			c.categories.forEach(function(name, k) { ctx.fillText(name, px(k + 0.5), pad.t + H + 5); });
+		} else {
+			ticks(xmin, xmax).forEach(function(v) { ctx.fillText(fmt(v), px(v), pad.t + H + 5); });
+		}
+		ctx.fillText(c.xlabel, pad.l + W / 2, pad.t + H + 25);
+		ctx.save(); ctx.translate(15, pad.t + H / 2); ctx.rotate(-Math.PI / 2);
+		ctx.textBaseline = "middle"; ctx.fillText(c.ylabel, 0, 0); ctx.restore();
+
+		shapes = [];
+		var visible = c.series.filter(function(s, i) { return !hidden[i]; }).length;
+		var slot = 0;
+		c.series.forEach(function(s, i) {
+			if (hidden[i]) return;
+			var color = palette[i % palette.length];
+			ctx.strokeStyle = ctx.fillStyle = color;
+			if (c.bars) {
+				var bw = 0.8 / visible;
+				s.y.forEach(function(y, k) {
+					var x0 = px(k + 0.1 + slot * bw), x1 = px(k + 0.1 + (slot + 1) * bw), y0 = py(y);
+					ctx.fillRect(x0, y0, x1 - x0 - 1, pad.t + H - y0);
+					shapes.push({x0: x0, x1: x1, y0: y0, text: s.label + ", " + c.categories[k] + ": " + fmt(y) + " " + c.ylabel});
+				});
+				slot++;
+				return;
+			}
+			ctx.beginPath();
+			s.y.forEach(function(y, j) {
+				var x = px(s.x[j]), yy = py(y);
+				if (j === 0) ctx.moveTo(x, yy); else ctx.lineTo(x, yy);
+				shapes.push({x: x, y: yy, text: s.label + ": " + fmt(y) + " " + c.ylabel + " at " + fmt(s.x[j]) + " s"});
+			});
+			ctx.stroke();
+		});
+		ctx.strokeStyle = "#222";
+		ctx.strokeRect(pad.l, pad.t, W, H);
+	}
+
+	canvas.onmousemove = function(e) {
+		var r = canvas.getBoundingClientRect(), mx = e.clientX - r.left, my = e.clientY - r.top, best = null, bd = 400;
+		shapes.forEach(function(s) {
+			if (c.bars) {
+				if (mx >= s.x0 && mx < s.x1 && my >= s.y0) best = s;
+				return;
+			}
+			var d = (s.x - mx) * (s.x - mx) + (s.y - my) * (s.y - my);
+			if (d < bd) { bd = d; best = s; }
+		});
+		if (!best) { tip.style.display = "none"; return; }
+		tip.textContent = best.text;
+		tip.style.display = "block";
+		tip.style.left = (canvas.offsetLeft + mx + 12) + "px";
+		tip.style.top = (canvas.offsetTop + my + 12) + "px";
+	};
+	canvas.onmouseleave = function() { tip.style.display = "none"; };
+	render();
+}
+
+charts.forEach(drawChart);
+</script>
+</body>
+</html>
+`))
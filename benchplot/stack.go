@@ -0,0 +1,148 @@
+package benchplot
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// StackMetrics are the metrics SaveStack can plot. All of them are plotted
+// against the time since the start of the run.
+var StackMetrics = []string{"throughput", "p99", "disk", "compaction", "levels", "device"}
+
+// SaveStack renders one panel per metric, stacked on a common time axis, so
+// changes of different metrics can be matched up. The legend is shown in the
+// top panel only. size is the size of each panel.
+func SaveStack(metrics []string, reports []bench.Report, size Size, file string) error {
+	if len(metrics) == 0 {
+		return fmt.Errorf("no metrics to plot")
+	}
+	plots := make([][]*plot.Plot, len(metrics))
+	xmin, xmax := math.Inf(1), math.Inf(-1)
+	for i, m := range metrics {
+		plt := plot.New()
+		var err error
+		switch m {
+		case "throughput":
+			err = plotTimeline(plt, reports, "throughput", megabyteTicks{unit: "mb/s"}, func(ev bench.Progress) (float64, bool) {
+				return ev.BPS(), true
+			})
+		case "p99":
+			err = plotTimeline(plt, reports, "p99 latency (µs)", nil, func(ev bench.Progress) (float64, bool) {
+				return float64(ev.P99) / float64(time.Microsecond), ev.P99 > 0
+			})
+		case "disk":
+			err = plotDiskTime(plt, reports)
+		case "compaction":
+			err = plotCompaction(plt, reports)
+		case "levels":
+			err = plotLevels(plt, reports, "tables", func(l bench.LevelStats) float64 { return float64(l.Tables) })
+		case "device":
+			err = plotDeviceWrites(plt, reports)
+		default:
+			err = fmt.Errorf("unknown metric %q", m)
+		}
+		if err != nil {
+			return err
+		}
+		if i > 0 && m != "levels" {
+			plt.Legend = plot.NewLegend() // the levels legend names levels, not reports
+		}
+		plt.Legend.Top = true
+		if i < len(metrics)-1 {
+			plt.X.Label.Text = ""
+		}
+		xmin, xmax = math.Min(xmin, plt.X.Min), math.Max(xmax, plt.X.Max)
+		plots[i] = []*plot.Plot{plt}
+	}
+	if xmin > xmax {
+		return fmt.Errorf("no data to plot")
+	}
+	for _, row := range plots {
+		row[0].X.Min, row[0].X.Max = xmin, xmax
+	}
+
+	c, err := newCanvas(size.W, size.H*vg.Length(len(metrics)), size.DPI, file)
+	if err != nil {
+		return err
+	}
+	tiles := draw.Tiles{Rows: len(metrics), Cols: 1, PadY: vg.Millimeter}
+	canvases := plot.Align(plots, tiles, draw.New(c))
+	for i := range plots {
+		plots[i][0].Draw(canvases[i][0])
+	}
+	return writeCanvas(c, file)
+}
+
+// plotTimeline adds a line per report plotting a value of the progress events
+// against time.
+func plotTimeline(plt *plot.Plot, reports []bench.Report, ylabel string, ticks plot.Ticker, y func(bench.Progress) (float64, bool)) error {
+	plt.X.Label.Text = "time (s)"
+	plt.Y.Label.Text = ylabel
+	if ticks != nil {
+		plt.Y.Tick.Marker = ticks
+	}
+	for i, r := range reports {
+		var (
+			xy plotter.XYs
+			t  time.Duration
+		)
+		for _, ev := range reduceEvents(r.Events, 400) {
+			t += ev.Duration
+			if ev.Time > 0 {
+				t = ev.Time
+			}
+			if v, ok := y(ev); ok {
+				xy = append(xy, plotter.XY{X: t.Seconds(), Y: v})
+			}
+		}
+		if len(xy) == 0 {
+			log.Printf("Warning: report %s has no %s data", r.Name, ylabel)
+			continue
+		}
+		l, err := plotter.NewLine(xy)
+		if err != nil {
+			return err
+		}
+		l.Color = plotutil.Color(i)
+		plt.Add(l)
+		plt.Legend.Add(r.Label(), l)
+	}
+	plt.Y.Min = 0
+	return nil
+}
+
+// plotDiskTime adds database size vs. time plots for all reports with disk
+// usage samples.
+func plotDiskTime(plt *plot.Plot, reports []bench.Report) error {
+	plt.X.Label.Text = "time (s)"
+	plt.Y.Label.Text = "disk size"
+	plt.Y.Tick.Marker = megabyteTicks{unit: "mb"}
+	for i, r := range reports {
+		if len(r.Disk) == 0 {
+			log.Printf("Warning: report %s has no disk usage samples", r.Name)
+			continue
+		}
+		xy := make(plotter.XYs, len(r.Disk))
+		for j, d := range r.Disk {
+			xy[j].X, xy[j].Y = d.Time.Seconds(), float64(d.Size)
+		}
+		l, err := plotter.NewLine(xy)
+		if err != nil {
+			return err
+		}
+		l.Color = plotutil.Color(i)
+		plt.Add(l)
+		plt.Legend.Add(r.Label(), l)
+	}
+	plt.Y.Min = 0
+	return nil
+}
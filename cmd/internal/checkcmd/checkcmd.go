@@ -0,0 +1,61 @@
+package checkcmd
+
+import (
+	"log"
+	"os"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/fjl/goleveldb-bench/cmd/internal/cmdutil"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// Main runs the manifest check command.
+func Main(name string, args []string) {
+	var (
+		fs           = cmdutil.FlagSet(name, "-manifest <file> <dbdir>")
+		manifestflag = fs.String("manifest", "", "manifest file written by the write benchmark")
+	)
+	fs.Parse(args)
+	if *manifestflag == "" || fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	m, err := bench.ReadManifest(*manifestflag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cmp, err := cmdutil.ComparerByName(m.Comparer)
+	if err != nil {
+		log.Fatal(err)
+	}
+	db, err := leveldb.OpenFile(fs.Arg(0), &opt.Options{ReadOnly: true, ErrorIfMissing: true, Comparer: cmp})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	mismatches, err := m.Check(func(start, limit []byte, fn func(key, value []byte)) error {
+		it := db.NewIterator(&util.Range{Start: start, Limit: limit}, nil)
+		defer it.Release()
+		for it.Next() {
+			fn(it.Key(), it.Value())
+		}
+		return it.Error()
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, r := range mismatches {
+		log.Printf("range %x-%x: %d keys, checksum %x, manifest has %d keys, checksum %x",
+			r.Start, r.Limit, r.FoundKeys, r.FoundChecksum, r.Keys, r.Checksum)
+	}
+	if len(mismatches) > 0 {
+		if !m.UniqueKeys {
+			log.Printf("Warning: manifest was written without -uniquekeys, overwritten keys cause mismatches")
+		}
+		log.Fatalf("%d of %d key ranges don't match the manifest", len(mismatches), len(m.Ranges))
+	}
+	log.Printf("database matches manifest of %q", m.Test)
+}
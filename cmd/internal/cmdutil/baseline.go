@@ -0,0 +1,72 @@
+package cmdutil
+
+import (
+	"flag"
+	"log"
+	"path/filepath"
+
+	bench "github.com/fjl/goleveldb-bench"
+)
+
+// Thresholds defines the -max-throughput-drop and -max-p99-rise flags with the
+// given defaults, in percent. The returned function gives the thresholds after
+// parsing.
+func Thresholds(fs *flag.FlagSet, throughput, p99 float64) func() bench.Thresholds {
+	var (
+		drop = fs.Float64("max-throughput-drop", throughput, "largest acceptable throughput drop against the baseline in percent, 0 to not check")
+		rise = fs.Float64("max-p99-rise", p99, "largest acceptable p99 latency rise against the baseline in percent, 0 to not check")
+	)
+	return func() bench.Thresholds {
+		if *drop < 0 || *rise < 0 {
+			log.Fatal("regression thresholds can't be negative")
+		}
+		return bench.Thresholds{Throughput: *drop / 100, P99: *rise / 100}
+	}
+}
+
+// Baseline defines the -baseline flag and the thresholds of the check. The
+// returned function compares the given logs, written by the tests of this
+// invocation, against the baseline and reports whether they passed. It always
+// passes if no baseline is set.
+func Baseline(fs *flag.FlagSet) func(logs []string) bool {
+	dir := fs.String("baseline", "", "compare the results against the logs in this directory and fail if they regressed")
+	thresholds := Thresholds(fs, 5, 10)
+	return func(logs []string) bool {
+		if *dir == "" {
+			return true
+		}
+		return CheckRegressions(ReadLogDir(*dir), bench.MustReadReports(logs), thresholds())
+	}
+}
+
+// CheckRegressions compares the tests of two sets of logs and logs the metrics
+// which regressed by more than the thresholds. It reports whether none did. The
+// check fails if there are no tests in common.
+func CheckRegressions(baseline, current []bench.Report, th bench.Thresholds) bool {
+	deltas, _, _ := bench.Compare(baseline, current)
+	if len(deltas) == 0 {
+		log.Print("no tests in common with the baseline")
+		return false
+	}
+	ok := true
+	for _, d := range deltas {
+		for _, m := range d.Regressions(th) {
+			log.Printf("REGRESSION %s: %s %s -> %s (%+.2f%%)", d.Label, m.Metric,
+				FormatMetric(m.Metric, m.Before.Mean), FormatMetric(m.Metric, m.After.Mean), 100*m.Change())
+			ok = false
+		}
+	}
+	return ok
+}
+
+// ReadLogDir reads the test logs in dir. Logs in subdirectories are repeated
+// runs.
+func ReadLogDir(dir string) []bench.Report {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	runs, _ := filepath.Glob(filepath.Join(dir, "*", "*.json"))
+	files = append(files, runs...)
+	if len(files) == 0 {
+		log.Fatalf("no test logs in %s", dir)
+	}
+	return bench.MustReadReports(files)
+}
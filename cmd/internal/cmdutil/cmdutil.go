@@ -0,0 +1,181 @@
+// Package cmdutil contains helpers shared by the benchmark commands.
+package cmdutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/fjl/goleveldb-bench/cmd/internal/ldbstore"
+)
+
+// SelfArgs are the arguments which select the running command when the
+// executable is started again. It is set by binaries containing multiple
+// commands.
+var SelfArgs []string
+
+// Self returns a command running the current command again with the given
+// arguments.
+func Self(ctx context.Context, args ...string) (*exec.Cmd, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("can't figure out executable path: %v", err)
+	}
+	args = append(append([]string{}, SelfArgs...), args...)
+	return exec.CommandContext(ctx, exe, args...), nil
+}
+
+// FlagName returns the name of the flag in a command line argument, or the
+// empty string if it isn't a flag.
+func FlagName(arg string) string {
+	if !strings.HasPrefix(arg, "-") {
+		return ""
+	}
+	name := strings.TrimLeft(arg, "-")
+	if i := strings.IndexByte(name, '='); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// FlagSet creates the flag set of a command.
+func FlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		if completing != nil {
+			printCompletions(fs)
+			return
+		}
+		if usage != "" {
+			fmt.Fprintf(fs.Output(), "Usage: %s %s\n", name, usage)
+		} else {
+			fmt.Fprintf(fs.Output(), "Usage of %s:\n", name)
+		}
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// sizeValue is a flag.Value holding a size like "100kb".
+type sizeValue struct {
+	size *uint64
+	text string
+}
+
+func (v *sizeValue) String() string {
+	return v.text
+}
+
+func (v *sizeValue) Set(s string) error {
+	size, err := bench.ParseSize(s)
+	if err != nil {
+		return err
+	}
+	*v.size, v.text = size, s
+	return nil
+}
+
+// Size defines a flag holding a size like "100kb".
+func Size(fs *flag.FlagSet, name, value, usage string) *uint64 {
+	v := &sizeValue{size: new(uint64)}
+	if err := v.Set(value); err != nil {
+		panic("invalid default size " + value)
+	}
+	fs.Var(v, name, usage)
+	return v.size
+}
+
+// Ramdisk mounts a tmpfs of the given size inside dir for the -ramdisk flag
+// and tags runs placed on it. If size is empty, dir is used directly. The
+// returned function unmounts the ramdisk.
+func Ramdisk(dir, size string, dataSize uint64, tags *bench.Tags) (string, func()) {
+	if size == "" {
+		return dir, func() {}
+	}
+	n, err := bench.ParseSize(size)
+	if err != nil {
+		log.Fatal("-ramdisk: ", err)
+	}
+	if n < 2*dataSize {
+		log.Printf("Warning: ramdisk size is less than twice the test data size")
+	}
+	rd, err := bench.OpenRamdisk(dir, n)
+	if err != nil {
+		log.Fatal("-ramdisk: ", err)
+	}
+	if *tags == nil {
+		*tags = make(bench.Tags)
+	}
+	(*tags)[bench.RamdiskTag] = "ramdisk"
+	return rd.Dir, func() { rd.Close() }
+}
+
+// SlowDiskUsage is the usage text of the -slowdisk flag.
+var SlowDiskUsage = "simulate a slower disk: preset (" + strings.Join(ldbstore.SlowPresetNames(), ", ") + ") or settings like read=5ms,write=1ms,sync=10ms,bw=100mb"
+
+// SlowDisk returns the storage wrapper for the -slowdisk flag and tags runs
+// using it. It returns nil if spec is empty.
+func SlowDisk(spec string, tags *bench.Tags) ldbstore.Wrapper {
+	if spec == "" {
+		return nil
+	}
+	cfg, err := ldbstore.ParseSlowConfig(spec)
+	if err != nil {
+		log.Fatal("-slowdisk: ", err)
+	}
+	if *tags == nil {
+		*tags = make(bench.Tags)
+	}
+	(*tags)["slowdisk"] = spec
+	return cfg.Wrapper()
+}
+
+// detectValue is a flag.Value holding a list of detector names.
+type detectValue struct {
+	names *[]string
+	text  string
+}
+
+func (v *detectValue) String() string {
+	return v.text
+}
+
+func (v *detectValue) Set(s string) error {
+	names, err := bench.ParseDetectors(s)
+	if err != nil {
+		return err
+	}
+	*v.names, v.text = names, s
+	return nil
+}
+
+// Detect defines the -detect flag, which selects anomaly detectors.
+func Detect(fs *flag.FlagSet) *[]string {
+	v := &detectValue{names: new([]string)}
+	usage := "comma-separated anomaly detectors to run over the metric stream (all, " + strings.Join(bench.DetectorNames(), ", ") + ")"
+	fs.Var(v, "detect", usage)
+	CompleteValues(fs, "detect", func() []string {
+		return append([]string{"all"}, bench.DetectorNames()...)
+	})
+	return v.names
+}
+
+// Watchdog defines the flags of the memory watchdog. The returned function
+// provides the configuration after parsing, writing heap profiles to
+// profileDir.
+func Watchdog(fs *flag.FlagSet) func(profileDir string) bench.WatchdogConfig {
+	var (
+		limit  = Size(fs, "memlimit", "0", "memory watchdog: maximum RSS of the process (0 = no limit)")
+		slope  = Size(fs, "memslope", "0", "memory watchdog: maximum RSS growth per second over -memwindow (0 = no limit)")
+		window = fs.Duration("memwindow", bench.DefaultWatchdogWindow, "memory watchdog: period over which RSS growth is measured")
+		abort  = fs.Bool("memabort", false, "memory watchdog: abort the run when triggered")
+	)
+	return func(profileDir string) bench.WatchdogConfig {
+		return bench.WatchdogConfig{Limit: *limit, Slope: *slope, Window: *window, Abort: *abort, ProfileDir: profileDir}
+	}
+}
@@ -0,0 +1,107 @@
+package cmdutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/syndtr/goleveldb/leveldb/comparer"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// Uint64Comparer orders keys by the little-endian uint64 in their first eight
+// bytes, like the Uint64Comparator of RocksDB. Keys sharing the number are
+// ordered by the remaining bytes. Shorter keys sort before all others.
+var Uint64Comparer comparer.Comparer = uint64Comparer{}
+
+// comparers are the key comparers selectable by -comparer.
+var comparers = map[string]comparer.Comparer{
+	"bytewise": comparer.DefaultComparer,
+	"uint64":   Uint64Comparer,
+}
+
+// ComparerNames returns the names accepted by -comparer.
+func ComparerNames() (n []string) {
+	for name := range comparers {
+		n = append(n, name)
+	}
+	sort.Strings(n)
+	return n
+}
+
+// FindComparer returns the comparer selected by a -comparer flag value. The
+// empty name selects the default comparer and returns nil.
+func FindComparer(name string) (comparer.Comparer, error) {
+	if name == "" {
+		return nil, nil
+	}
+	if c := comparers[name]; c != nil {
+		return c, nil
+	}
+	return nil, fmt.Errorf("unknown comparer %q", name)
+}
+
+// ComparerByName finds a comparer by the name stored in the database.
+func ComparerByName(name string) (comparer.Comparer, error) {
+	if name == "" {
+		return nil, nil
+	}
+	for _, c := range comparers {
+		if c.Name() == name {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported comparer %q", name)
+}
+
+// CustomComparer reports whether a test opens its database with a comparer other
+// than the default, once the option overrides of flags and sweeps are applied
+// to its own options. The test's options are those in its Options field.
+func CustomComparer(test interface{}, overrides ...func(*opt.Options)) bool {
+	var o opt.Options
+	if v := reflect.ValueOf(test); v.Kind() == reflect.Struct {
+		if f := v.FieldByName("Options"); f.IsValid() {
+			if to, ok := f.Interface().(opt.Options); ok {
+				o = to
+			}
+		}
+	}
+	for _, fn := range overrides {
+		if fn != nil {
+			fn(&o)
+		}
+	}
+	return o.GetComparer().Name() != comparer.DefaultComparer.Name()
+}
+
+type uint64Comparer struct{}
+
+func (uint64Comparer) Name() string {
+	return "goleveldb-bench.Uint64Comparator"
+}
+
+func (uint64Comparer) Compare(a, b []byte) int {
+	if len(a) < 8 || len(b) < 8 {
+		if len(a) >= 8 {
+			return 1
+		} else if len(b) >= 8 {
+			return -1
+		}
+		return bytes.Compare(a, b)
+	}
+	x, y := binary.LittleEndian.Uint64(a), binary.LittleEndian.Uint64(b)
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return bytes.Compare(a[8:], b[8:])
+}
+
+// Separator and Successor don't shorten keys, index blocks hold full keys.
+func (uint64Comparer) Separator(dst, a, b []byte) []byte { return nil }
+
+func (uint64Comparer) Successor(dst, b []byte) []byte { return nil }
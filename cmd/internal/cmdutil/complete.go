@@ -0,0 +1,84 @@
+package cmdutil
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// completing holds the command line words while computing shell completions.
+// The words start after the command name, the last word is being completed.
+var completing []string
+
+// valueCompleters are the completion functions of flag values.
+var valueCompleters = make(map[*flag.FlagSet]map[string]func() []string)
+
+// CompleteValues registers the possible values of a flag for shell completion.
+// Values of flags holding comma-separated lists are completed per element.
+func CompleteValues(fs *flag.FlagSet, name string, values func() []string) {
+	if valueCompleters[fs] == nil {
+		valueCompleters[fs] = make(map[string]func() []string)
+	}
+	valueCompleters[fs][name] = values
+}
+
+// Complete prints shell completions for the given words by running main in
+// completion mode. It exits the process.
+func Complete(main func(name string, args []string), words []string) {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	completing = words
+	// The flag set prints completions instead of usage, then exits.
+	main("", []string{"-h"})
+	os.Exit(0)
+}
+
+// printCompletions prints the completions of the last word for a flag set.
+func printCompletions(fs *flag.FlagSet) {
+	var (
+		cur  = completing[len(completing)-1]
+		prev string
+	)
+	if len(completing) > 1 {
+		prev = completing[len(completing)-2]
+	}
+	if f := lookupFlag(fs, prev); f != nil && !isBoolFlag(f) {
+		if values := valueCompleters[fs][f.Name]; values != nil {
+			elem := cur[strings.LastIndexByte(cur, ',')+1:]
+			list := cur[:len(cur)-len(elem)]
+			PrintMatches(elem, values(), list)
+		}
+		return
+	}
+	if strings.HasPrefix(cur, "-") {
+		var names []string
+		fs.VisitAll(func(f *flag.Flag) { names = append(names, "-"+f.Name) })
+		PrintMatches(cur, names, "")
+	}
+}
+
+// PrintMatches prints all candidates starting with prefix. The output prefix
+// is prepended to every printed candidate.
+func PrintMatches(prefix string, candidates []string, output string) {
+	sort.Strings(candidates)
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			fmt.Println(output + c)
+		}
+	}
+}
+
+func lookupFlag(fs *flag.FlagSet, arg string) *flag.Flag {
+	if !strings.HasPrefix(arg, "-") || strings.Contains(arg, "=") {
+		return nil
+	}
+	return fs.Lookup(strings.TrimLeft(arg, "-"))
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
@@ -0,0 +1,118 @@
+package cmdutil
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	bench "github.com/fjl/goleveldb-bench"
+)
+
+// displayInterval is the time between updates of the status line.
+const displayInterval = 250 * time.Millisecond
+
+// Display defines the -live flag. The returned function starts a status line of
+// the running test, refreshed in place on the terminal. It takes the Live of
+// -metrics-addr, which may be nil, and returns the Live the tests must report
+// to and a function removing the status line. The stop function is nil if the
+// display isn't enabled, tests should log their percentage also in that case.
+func Display(fs *flag.FlagSet) func(*bench.Live) (*bench.Live, func()) {
+	enabled := fs.Bool("live", false, "show throughput, ETA, recent p99 latency and disk size of the running test in a status line on the terminal")
+	return func(live *bench.Live) (*bench.Live, func()) {
+		if !*enabled {
+			return live, nil
+		}
+		if fi, err := os.Stderr.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+			log.Print("-live: stderr is not a terminal, status line disabled")
+			return live, nil
+		}
+		if live == nil {
+			live = bench.NewLive()
+		}
+		s := &statusLine{out: os.Stderr, live: live, stop: make(chan struct{}), done: make(chan struct{})}
+		log.SetOutput(s)
+		go s.loop()
+		return live, s.close
+	}
+}
+
+// statusLine draws the state of the running test on the last line of the
+// terminal. It is also the log output, keeping log messages above the line.
+type statusLine struct {
+	mu   sync.Mutex
+	out  *os.File
+	live *bench.Live
+	text string
+
+	stop, done chan struct{}
+}
+
+func (s *statusLine) loop() {
+	defer close(s.done)
+	tick := time.NewTicker(displayInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			snap := s.live.Snapshot()
+			s.mu.Lock()
+			s.text = formatStatus(&snap)
+			fmt.Fprint(s.out, "\r\033[K", s.text)
+			s.mu.Unlock()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Write prints a log message above the status line.
+func (s *statusLine) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprint(s.out, "\r\033[K")
+	n, err := s.out.Write(p)
+	fmt.Fprint(s.out, s.text)
+	return n, err
+}
+
+func (s *statusLine) close() {
+	close(s.stop)
+	<-s.done
+	s.mu.Lock()
+	fmt.Fprint(s.out, "\r\033[K")
+	s.text = ""
+	s.mu.Unlock()
+	log.SetOutput(os.Stderr)
+}
+
+// formatStatus renders the status line of a snapshot.
+func formatStatus(s *bench.LiveSnapshot) string {
+	if s.Test == "" {
+		return ""
+	}
+	parts := []string{s.Test}
+	if s.Size > 0 {
+		parts = append(parts, fmt.Sprintf("%3d%%", s.Processed*100/s.Size))
+	}
+	parts = append(parts, bench.FormatSize(uint64(s.BPS))+"/s", "elapsed "+s.Elapsed.Round(time.Second).String())
+	if eta := s.Remaining(); eta > 0 {
+		parts = append(parts, "ETA "+eta.Round(time.Second).String())
+	}
+	if p99 := s.RecentP99; p99 > 0 {
+		if p99 > time.Millisecond {
+			p99 = p99.Round(10 * time.Microsecond)
+		}
+		parts = append(parts, "p99 "+p99.String())
+	}
+	if s.Disk != nil {
+		parts = append(parts, "disk "+bench.FormatSize(s.Disk.Size))
+	}
+	if s.Stalls > 0 {
+		parts = append(parts, fmt.Sprintf("%d stalls", s.Stalls))
+	}
+	return strings.Join(parts, "  ")
+}
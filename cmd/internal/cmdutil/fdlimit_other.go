@@ -0,0 +1,10 @@
+//go:build !darwin && !linux
+// +build !darwin,!linux
+
+package cmdutil
+
+import "errors"
+
+func raiseFileLimit() (uint64, error) {
+	return 0, errors.New("file descriptor limit is unknown on this platform")
+}
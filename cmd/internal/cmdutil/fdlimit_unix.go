@@ -0,0 +1,24 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package cmdutil
+
+import "syscall"
+
+// raiseFileLimit raises the soft limit of open files to the hard limit, if it is
+// allowed, and returns the limit.
+func raiseFileLimit() (uint64, error) {
+	var lim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &lim); err != nil {
+		return 0, err
+	}
+	if lim.Cur < lim.Max {
+		raised := lim
+		raised.Cur = raised.Max
+		// On macOS, the hard limit may be unlimited, which can't be set.
+		if err := syscall.Setrlimit(syscall.RLIMIT_NOFILE, &raised); err == nil {
+			lim = raised
+		}
+	}
+	return uint64(lim.Cur), nil
+}
@@ -0,0 +1,216 @@
+package cmdutil
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/syndtr/goleveldb/leveldb/filter"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// gridOptions are the options which can be swept by -grid.
+var gridOptions = map[string]func(o *opt.Options, v string) error{
+	"writebuffer": sizeOption(func(o *opt.Options, n int) { o.WriteBuffer = n }),
+	"blockcache":  sizeOption(func(o *opt.Options, n int) { o.BlockCacheCapacity = n }),
+	"tablesize":   sizeOption(func(o *opt.Options, n int) { o.CompactionTableSize = n }),
+	"levelsize":   sizeOption(func(o *opt.Options, n int) { o.CompactionTotalSize = n }),
+	"openfiles":   intOption(func(o *opt.Options, n int) { o.OpenFilesCacheCapacity = n }),
+	"bloombits": intOption(func(o *opt.Options, n int) {
+		o.Filter = nil
+		if n > 0 {
+			o.Filter = filter.NewBloomFilter(n)
+		}
+	}),
+	"compression": func(o *opt.Options, v string) (err error) {
+		o.Compression, err = compression(v)
+		return err
+	},
+	"comparer": func(o *opt.Options, v string) (err error) {
+		o.Comparer, err = FindComparer(v)
+		return err
+	},
+	"tablemultiplier": floatOption(func(o *opt.Options, x float64) { o.CompactionTableSizeMultiplier = x }),
+	"levelmultiplier": floatOption(func(o *opt.Options, x float64) { o.CompactionTotalSizeMultiplier = x }),
+}
+
+func sizeOption(set func(*opt.Options, int)) func(*opt.Options, string) error {
+	return func(o *opt.Options, v string) error {
+		n, err := bench.ParseSize(v)
+		if err != nil {
+			return err
+		}
+		set(o, int(n))
+		return nil
+	}
+}
+
+func intOption(set func(*opt.Options, int)) func(*opt.Options, string) error {
+	return func(o *opt.Options, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return fmt.Errorf("negative value %d", n)
+		}
+		set(o, n)
+		return nil
+	}
+}
+
+func floatOption(set func(*opt.Options, float64)) func(*opt.Options, string) error {
+	return func(o *opt.Options, v string) error {
+		x, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		if x <= 0 {
+			return fmt.Errorf("multiplier %v is not positive", x)
+		}
+		set(o, x)
+		return nil
+	}
+}
+
+// GridCell is one combination of option values of a grid sweep.
+type GridCell struct {
+	Name    string     // appended to the test name
+	Tags    bench.Tags // the option values
+	Options func(*opt.Options)
+}
+
+// gridDim is an option swept by -grid.
+type gridDim struct {
+	name   string
+	values []string
+}
+
+// gridValue is a flag.Value collecting the dimensions of a grid sweep.
+type gridValue struct {
+	dims []gridDim
+}
+
+func (v *gridValue) String() string {
+	var s []string
+	for _, d := range v.dims {
+		s = append(s, d.name+"="+strings.Join(d.values, ","))
+	}
+	return strings.Join(s, " ")
+}
+
+func (v *gridValue) Set(s string) error {
+	eq := strings.IndexByte(s, '=')
+	if eq < 0 {
+		return fmt.Errorf("missing '=' in %q", s)
+	}
+	d := gridDim{name: strings.TrimSpace(s[:eq])}
+	set := gridOptions[d.name]
+	if set == nil {
+		return fmt.Errorf("unknown option %q, want one of %s", d.name, strings.Join(gridOptionNames(), ", "))
+	}
+	for _, d2 := range v.dims {
+		if d2.name == d.name {
+			return fmt.Errorf("option %s given twice", d.name)
+		}
+	}
+	for _, val := range strings.Split(s[eq+1:], ",") {
+		val = strings.TrimSpace(val)
+		if err := set(new(opt.Options), val); err != nil {
+			return fmt.Errorf("%s: %v", d.name, err)
+		}
+		d.values = append(d.values, val)
+	}
+	v.dims = append(v.dims, d)
+	return nil
+}
+
+// cells returns all combinations of the option values. The last dimension
+// varies fastest.
+func (v *gridValue) cells() []GridCell {
+	if len(v.dims) == 0 {
+		return nil
+	}
+	cells := []GridCell{{Tags: make(bench.Tags)}}
+	for _, d := range v.dims {
+		var next []GridCell
+		for _, c := range cells {
+			for _, val := range d.values {
+				next = append(next, c.with(d.name, val))
+			}
+		}
+		cells = next
+	}
+	return cells
+}
+
+func (c GridCell) with(name, value string) GridCell {
+	tags := make(bench.Tags, len(c.Tags)+1)
+	for k, v := range c.Tags {
+		tags[k] = v
+	}
+	tags[name] = value
+	prev, set := c.Options, gridOptions[name]
+	cell := GridCell{Tags: tags, Name: name + value}
+	if c.Name != "" {
+		cell.Name = c.Name + "-" + cell.Name
+	}
+	cell.Options = func(o *opt.Options) {
+		if prev != nil {
+			prev(o)
+		}
+		set(o, value) // checked by Set
+	}
+	return cell
+}
+
+// Apply adds the cell to the name, tags and database options of a run. The tags
+// and options are replaced by copies, so other runs sharing them are unaffected.
+func (c GridCell) Apply(name *string, tags *bench.Tags, options *[]func(*opt.Options)) {
+	*name += "-" + c.Name
+	cpy := make(bench.Tags, len(*tags)+len(c.Tags))
+	for k, v := range *tags {
+		cpy[k] = v
+	}
+	for k, v := range c.Tags {
+		cpy[k] = v
+	}
+	*tags = cpy
+	o := *options
+	*options = append(o[:len(o):len(o)], c.Options)
+}
+
+// SweepGrid expands every run into one run per combination of option values.
+// apply adds a cell to a copy of the run, usually by calling GridCell.Apply.
+func SweepGrid[R any](runs []R, cells []GridCell, apply func(*R, GridCell)) []R {
+	var out []R
+	for _, r := range runs {
+		for _, c := range cells {
+			sr := r
+			apply(&sr, c)
+			out = append(out, sr)
+		}
+	}
+	return out
+}
+
+func gridOptionNames() (n []string) {
+	for name := range gridOptions {
+		n = append(n, name)
+	}
+	sort.Strings(n)
+	return n
+}
+
+// Grid defines the -grid flag, which runs every test once for each combination
+// of option values. The returned function provides the combinations after
+// parsing, or nil if the flag isn't given.
+func Grid(fs *flag.FlagSet) func() []GridCell {
+	v := new(gridValue)
+	usage := "sweep a database option over comma-separated values, e.g. writebuffer=4mb,64mb (may be repeated to sweep all combinations; " + strings.Join(gridOptionNames(), ", ") + ")"
+	fs.Var(v, "grid", usage)
+	return v.cells
+}
@@ -0,0 +1,111 @@
+package cmdutil
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// influxFlushInterval is the time between posts to an InfluxDB endpoint.
+const influxFlushInterval = time.Second
+
+// Influx defines the -influx flag. The returned function opens the sink of
+// the progress samples after parsing. It returns nil if the flag isn't set,
+// and a function which must be called after the tests to write pending samples.
+func Influx(fs *flag.FlagSet) func() (io.Writer, func()) {
+	dest := fs.String("influx", "", "write progress samples in InfluxDB line protocol to this file, or post them to this URL (e.g. http://localhost:8086/write?db=bench)")
+	return func() (io.Writer, func()) {
+		switch {
+		case *dest == "":
+			return nil, func() {}
+		case strings.HasPrefix(*dest, "http://") || strings.HasPrefix(*dest, "https://"):
+			w := &influxPoster{url: *dest, stop: make(chan struct{}), done: make(chan struct{})}
+			go w.loop()
+			return w, w.close
+		default:
+			f, err := os.OpenFile(*dest, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+			if err != nil {
+				log.Fatal("-influx: ", err)
+			}
+			return f, func() { f.Close() }
+		}
+	}
+}
+
+// influxPoster collects lines and posts them to the InfluxDB write endpoint in
+// the background, so a slow server doesn't delay the test.
+type influxPoster struct {
+	url string
+
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	dropped bool // set after the first failed post
+
+	stop, done chan struct{}
+}
+
+func (w *influxPoster) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.dropped {
+		return len(p), nil
+	}
+	return w.buf.Write(p)
+}
+
+func (w *influxPoster) loop() {
+	defer close(w.done)
+	tick := time.NewTicker(influxFlushInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			w.flush()
+		case <-w.stop:
+			w.flush()
+			return
+		}
+	}
+}
+
+func (w *influxPoster) flush() {
+	w.mu.Lock()
+	body := append([]byte(nil), w.buf.Bytes()...)
+	w.buf.Reset()
+	w.mu.Unlock()
+	if len(body) == 0 {
+		return
+	}
+	if err := w.post(body); err != nil {
+		log.Printf("-influx: %v, dropping further samples", err)
+		w.mu.Lock()
+		w.dropped = true
+		w.mu.Unlock()
+	}
+}
+
+func (w *influxPoster) post(body []byte) error {
+	resp, err := http.Post(w.url, "text/plain; charset=utf-8", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("server returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+func (w *influxPoster) close() {
+	close(w.stop)
+	<-w.done
+}
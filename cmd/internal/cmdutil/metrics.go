@@ -0,0 +1,125 @@
+package cmdutil
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics defines the -metrics-addr flag. The returned function starts the
+// Prometheus endpoint after parsing and returns the Live metrics it exports, or
+// nil if the flag isn't set.
+func Metrics(fs *flag.FlagSet) func() *bench.Live {
+	addr := fs.String("metrics-addr", "", "serve Prometheus metrics of the running test on this address (e.g. localhost:9100)")
+	return func() *bench.Live {
+		if *addr == "" {
+			return nil
+		}
+		l, err := net.Listen("tcp", *addr)
+		if err != nil {
+			log.Fatal("-metrics-addr: ", err)
+		}
+		live := bench.NewLive()
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(
+			liveCollector{live},
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+		log.Printf("serving metrics on http://%s/metrics", l.Addr())
+		go http.Serve(l, mux)
+		return live
+	}
+}
+
+var (
+	testLabels  = []string{"test"}
+	levelLabels = []string{"test", "level"}
+
+	descProcessed = prometheus.NewDesc("ldbbench_processed_bytes_total", "Bytes processed by the running test.", testLabels, nil)
+	descEntries   = prometheus.NewDesc("ldbbench_entries_total", "Entries processed by the running test.", testLabels, nil)
+	descCommits   = prometheus.NewDesc("ldbbench_commits_total", "Commits of the running test.", testLabels, nil)
+	descBPS       = prometheus.NewDesc("ldbbench_throughput_bytes_per_second", "Throughput of the last progress interval.", testLabels, nil)
+	descElapsed   = prometheus.NewDesc("ldbbench_elapsed_seconds", "Time since the start of the running test.", testLabels, nil)
+	descStalls    = prometheus.NewDesc("ldbbench_stalls_total", "Operations slower than -stall.", testLabels, nil)
+	descLatency   = prometheus.NewDesc("ldbbench_latency_seconds", "Latency of single operations since the start of the test.", []string{"test", "quantile"}, nil)
+	descOps       = prometheus.NewDesc("ldbbench_operations_total", "Operations with recorded latency.", testLabels, nil)
+
+	descDiskSize  = prometheus.NewDesc("ldbbench_disk_size_bytes", "Size of the database directory.", testLabels, nil)
+	descDiskFiles = prometheus.NewDesc("ldbbench_disk_files", "Number of files in the database directory.", testLabels, nil)
+
+	descTables          = prometheus.NewDesc("ldbbench_level_tables", "Tables per level.", levelLabels, nil)
+	descLevelSize       = prometheus.NewDesc("ldbbench_level_size_bytes", "Size of the tables per level.", levelLabels, nil)
+	descCompactionRead  = prometheus.NewDesc("ldbbench_compaction_read_bytes_total", "Bytes read by compactions per level.", levelLabels, nil)
+	descCompactionWrite = prometheus.NewDesc("ldbbench_compaction_write_bytes_total", "Bytes written by compactions per level.", levelLabels, nil)
+	descCompactionTime  = prometheus.NewDesc("ldbbench_compaction_seconds_total", "Time spent in compactions per level.", levelLabels, nil)
+	descWriteDelays     = prometheus.NewDesc("ldbbench_write_delays_total", "Writes delayed by the database.", testLabels, nil)
+	descWriteDelay      = prometheus.NewDesc("ldbbench_write_delay_seconds_total", "Total delay of writes.", testLabels, nil)
+)
+
+// liveCollector exports a snapshot of the running test.
+type liveCollector struct{ live *bench.Live }
+
+func (c liveCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		descProcessed, descEntries, descCommits, descBPS, descElapsed, descStalls, descLatency, descOps,
+		descDiskSize, descDiskFiles,
+		descTables, descLevelSize, descCompactionRead, descCompactionWrite, descCompactionTime, descWriteDelays, descWriteDelay,
+	} {
+		ch <- d
+	}
+}
+
+func (c liveCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.live.Snapshot()
+	if s.Test == "" {
+		return
+	}
+	gauge := func(d *prometheus.Desc, v float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v, append([]string{s.Test}, labels...)...)
+	}
+	counter := func(d *prometheus.Desc, v float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v, append([]string{s.Test}, labels...)...)
+	}
+	counter(descProcessed, float64(s.Processed))
+	counter(descEntries, float64(s.Entries))
+	counter(descCommits, float64(s.Commits))
+	gauge(descBPS, s.BPS)
+	gauge(descElapsed, s.Elapsed.Seconds())
+	counter(descStalls, float64(s.Stalls))
+	if p := s.Latency; p != nil {
+		counter(descOps, float64(p.Count))
+		gauge(descLatency, p.P50.Seconds(), "0.5")
+		gauge(descLatency, p.P90.Seconds(), "0.9")
+		gauge(descLatency, p.P99.Seconds(), "0.99")
+		gauge(descLatency, p.P999.Seconds(), "0.999")
+		gauge(descLatency, p.Max.Seconds(), "1")
+	}
+	if d := s.Disk; d != nil {
+		gauge(descDiskSize, float64(d.Size))
+		if d.Files > 0 {
+			gauge(descDiskFiles, float64(d.Files))
+		}
+	}
+	if cs := s.Compaction; cs != nil {
+		for i, l := range cs.Levels {
+			level := strconv.Itoa(i)
+			gauge(descTables, float64(l.Tables), level)
+			gauge(descLevelSize, float64(l.Size), level)
+			counter(descCompactionRead, float64(l.Read), level)
+			counter(descCompactionWrite, float64(l.Write), level)
+			counter(descCompactionTime, l.Duration.Seconds(), level)
+		}
+		counter(descWriteDelays, float64(cs.WriteDelays))
+		counter(descWriteDelay, cs.WriteDelay.Seconds())
+	}
+}
@@ -0,0 +1,187 @@
+package cmdutil
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/syndtr/goleveldb/leveldb/filter"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// LevelDBOptions defines the flags setting database options. The returned
+// function provides the options after parsing, as a function applying them on
+// top of the options of a test, and tags runs with the options which are set.
+// It returns nil if no option flag is set.
+//
+// Options given by single flags take precedence over the options file, which
+// takes precedence over the preset.
+func LevelDBOptions(fs *flag.FlagSet) func(tags *bench.Tags) func(*opt.Options) {
+	var (
+		preset       = fs.String("preset", "", "database options of an application ("+strings.Join(PresetNames(), ", ")+")")
+		presetCache  = fs.Int("presetcache", 512, "memory allowance of -preset in mb, in geth --cache times --cache.database percent")
+		file         = fs.String("options", "", "JSON file with database options, in the format recorded in test logs")
+		writeBuffer  = Size(fs, "writebuffer", "0", "database option: size of the memtable (0 = test default)")
+		blockCache   = Size(fs, "blockcache", "0", "database option: block cache capacity (0 = test default)")
+		tableSize    = Size(fs, "tablesize", "0", "database option: size of level-0 tables, CompactionTableSize (0 = test default)")
+		tableMult    = floatList(fs, "tablemultiplier", "database option: growth of the table size per level, or comma-separated multipliers of every level")
+		totalSize    = Size(fs, "levelsize", "0", "database option: total size of level 1, CompactionTotalSize (0 = test default)")
+		totalMult    = floatList(fs, "levelmultiplier", "database option: growth of the total size per level, or comma-separated multipliers of every level")
+		openFiles    = fs.Int("openfiles", 0, "database option: open files cache capacity (0 = test default)")
+		bloomBits    = fs.Int("bloombits", 0, "database option: bits per key of the bloom filter (0 = test default)")
+		compressflag = fs.String("compression", "", "database option: block compression (none, snappy)")
+		cmpflag      = fs.String("comparer", "", "database option: key comparer ("+strings.Join(ComparerNames(), ", ")+")")
+	)
+	CompleteValues(fs, "compression", func() []string { return []string{"none", "snappy"} })
+	CompleteValues(fs, "preset", PresetNames)
+	CompleteValues(fs, "comparer", ComparerNames)
+	return func(tags *bench.Tags) func(*opt.Options) {
+		set := make(bench.Tags)
+		if *preset != "" {
+			if err := checkPreset(*preset); err != nil {
+				log.Fatal("-preset: ", err)
+			}
+			set["preset"] = *preset
+		}
+		var fileOptions []byte
+		if *file != "" {
+			var err error
+			if fileOptions, err = readOptionsFile(*file); err != nil {
+				log.Fatal("-options: ", err)
+			}
+			set["options"] = filepath.Base(*file)
+		}
+		if *writeBuffer > 0 {
+			set["writebuffer"] = fs.Lookup("writebuffer").Value.String()
+		}
+		if *blockCache > 0 {
+			set["blockcache"] = fs.Lookup("blockcache").Value.String()
+		}
+		if *tableSize > 0 {
+			set["tablesize"] = fs.Lookup("tablesize").Value.String()
+		}
+		if *totalSize > 0 {
+			set["levelsize"] = fs.Lookup("levelsize").Value.String()
+		}
+		if len(tableMult.values) > 0 {
+			set["tablemultiplier"] = tableMult.text
+		}
+		if len(totalMult.values) > 0 {
+			set["levelmultiplier"] = totalMult.text
+		}
+		if *openFiles < 0 {
+			log.Fatal("-openfiles must not be negative")
+		} else if *openFiles > 0 {
+			set["openfiles"] = strconv.Itoa(*openFiles)
+		}
+		if *bloomBits < 0 {
+			log.Fatal("-bloombits must not be negative")
+		} else if *bloomBits > 0 {
+			set["bloombits"] = strconv.Itoa(*bloomBits)
+		}
+		comp, err := compression(*compressflag)
+		if err != nil {
+			log.Fatal("-compression: ", err)
+		} else if *compressflag != "" {
+			set["compression"] = *compressflag
+		}
+		cmp, err := FindComparer(*cmpflag)
+		if err != nil {
+			log.Fatal("-comparer: ", err)
+		} else if cmp != nil {
+			set["comparer"] = *cmpflag
+		}
+		if len(set) == 0 {
+			return nil
+		}
+		if *tags == nil {
+			*tags = make(bench.Tags)
+		}
+		for k, v := range set {
+			(*tags)[k] = v
+		}
+		return func(o *opt.Options) {
+			if *preset != "" {
+				optionPresets[*preset](o, *presetCache)
+			}
+			if fileOptions != nil {
+				// The file was checked when it was read.
+				decodeOptions(fileOptions, o)
+			}
+			if *writeBuffer > 0 {
+				o.WriteBuffer = int(*writeBuffer)
+			}
+			if *blockCache > 0 {
+				o.BlockCacheCapacity = int(*blockCache)
+			}
+			if *tableSize > 0 {
+				o.CompactionTableSize = int(*tableSize)
+			}
+			if *totalSize > 0 {
+				o.CompactionTotalSize = int(*totalSize)
+			}
+			tableMult.apply(&o.CompactionTableSizeMultiplier, &o.CompactionTableSizeMultiplierPerLevel)
+			totalMult.apply(&o.CompactionTotalSizeMultiplier, &o.CompactionTotalSizeMultiplierPerLevel)
+			if *openFiles > 0 {
+				o.OpenFilesCacheCapacity = *openFiles
+			}
+			if *bloomBits > 0 {
+				o.Filter = filter.NewBloomFilter(*bloomBits)
+			}
+			if *compressflag != "" {
+				o.Compression = comp
+			}
+			if cmp != nil {
+				o.Comparer = cmp
+			}
+		}
+	}
+}
+
+// floatListValue is a flag.Value holding a comma-separated list of positive
+// numbers.
+type floatListValue struct {
+	values []float64
+	text   string
+}
+
+func (v *floatListValue) String() string {
+	return v.text
+}
+
+func (v *floatListValue) Set(s string) error {
+	var values []float64
+	for _, f := range strings.Split(s, ",") {
+		x, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return err
+		}
+		if x <= 0 {
+			return fmt.Errorf("multiplier %v is not positive", x)
+		}
+		values = append(values, x)
+	}
+	v.values, v.text = values, s
+	return nil
+}
+
+// apply sets a level multiplier option. A single value sets the multiplier of
+// all levels, a list sets the multipliers of each level.
+func (v *floatListValue) apply(mult *float64, perLevel *[]float64) {
+	switch {
+	case len(v.values) == 1:
+		*mult, *perLevel = v.values[0], nil
+	case len(v.values) > 1:
+		*perLevel = v.values
+	}
+}
+
+func floatList(fs *flag.FlagSet, name, usage string) *floatListValue {
+	v := new(floatListValue)
+	fs.Var(v, name, usage)
+	return v
+}
@@ -0,0 +1,161 @@
+package cmdutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+
+	"github.com/fjl/goleveldb-bench/cmd/internal/kvstore"
+	"github.com/syndtr/goleveldb/leveldb/filter"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// optionsJSON is the JSON encoding of opt.Options, used by options files and
+// the options recorded in test logs. Fields holding interfaces are replaced by
+// names or, for filters, the bits per key of a bloom filter.
+type optionsJSON struct {
+	opt.Options
+	AltFilters      []int  `json:",omitempty"`
+	BlockCacher     string `json:",omitempty"` // lru or none
+	Comparer        string `json:",omitempty"`
+	Compression     string // default, none or snappy
+	Filter          int    `json:",omitempty"`
+	OpenFilesCacher string `json:",omitempty"` // lru or none
+}
+
+// EncodeOptions converts options to their JSON encoding. Filters other than the
+// bloom filter are recorded as zero bits, custom cachers as "custom".
+func EncodeOptions(o *opt.Options) interface{} {
+	return encodeOptions(o)
+}
+
+func encodeOptions(o *opt.Options) *optionsJSON {
+	j := &optionsJSON{
+		Options:         *o,
+		BlockCacher:     cacherName(o.BlockCacher),
+		Compression:     o.Compression.String(),
+		Filter:          kvstore.BloomBits(o.Filter),
+		OpenFilesCacher: cacherName(o.OpenFilesCacher),
+	}
+	for _, f := range o.AltFilters {
+		j.AltFilters = append(j.AltFilters, kvstore.BloomBits(f))
+	}
+	if o.Comparer != nil {
+		j.Comparer = o.Comparer.Name()
+	}
+	return j
+}
+
+// decodeOptions applies an options file on top of o. Only the options present in
+// the file are changed.
+func decodeOptions(data []byte, o *opt.Options) error {
+	var (
+		orig = encodeOptions(o)
+		j    = encodeOptions(o)
+		dec  = json.NewDecoder(bytes.NewReader(data))
+	)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(j); err != nil {
+		return err
+	}
+	res := j.Options
+	var err error
+	if j.BlockCacher != orig.BlockCacher {
+		if res.BlockCacher, err = cacher(j.BlockCacher); err != nil {
+			return fmt.Errorf("BlockCacher: %v", err)
+		}
+	}
+	if j.OpenFilesCacher != orig.OpenFilesCacher {
+		if res.OpenFilesCacher, err = cacher(j.OpenFilesCacher); err != nil {
+			return fmt.Errorf("OpenFilesCacher: %v", err)
+		}
+	}
+	if j.Comparer != orig.Comparer {
+		if res.Comparer, err = ComparerByName(j.Comparer); err != nil {
+			return fmt.Errorf("Comparer: %v", err)
+		}
+	}
+	if res.Compression, err = compression(j.Compression); err != nil {
+		return fmt.Errorf("Compression: %v", err)
+	}
+	if j.Filter != orig.Filter {
+		if res.Filter, err = bloomFilter(j.Filter); err != nil {
+			return fmt.Errorf("Filter: %v", err)
+		}
+	}
+	if !reflect.DeepEqual(j.AltFilters, orig.AltFilters) {
+		res.AltFilters = nil
+		for _, bits := range j.AltFilters {
+			f, err := bloomFilter(bits)
+			if err != nil || f == nil {
+				return fmt.Errorf("AltFilters: invalid bloom filter bits %d", bits)
+			}
+			res.AltFilters = append(res.AltFilters, f)
+		}
+	}
+	*o = res
+	return nil
+}
+
+// readOptionsFile reads an options file and checks that it can be applied.
+func readOptionsFile(file string) ([]byte, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := decodeOptions(data, new(opt.Options)); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return data, nil
+}
+
+func compression(name string) (opt.Compression, error) {
+	switch name {
+	case "", "default":
+		return opt.DefaultCompression, nil
+	case "none":
+		return opt.NoCompression, nil
+	case "snappy":
+		return opt.SnappyCompression, nil
+	default:
+		return 0, fmt.Errorf("unknown compression %q", name)
+	}
+}
+
+func cacherName(c opt.Cacher) string {
+	switch c {
+	case nil:
+		return ""
+	case opt.LRUCacher:
+		return "lru"
+	case opt.NoCacher:
+		return "none"
+	default:
+		return "custom"
+	}
+}
+
+func cacher(name string) (opt.Cacher, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case "lru":
+		return opt.LRUCacher, nil
+	case "none":
+		return opt.NoCacher, nil
+	default:
+		return nil, fmt.Errorf("unknown cacher %q", name)
+	}
+}
+
+func bloomFilter(bits int) (filter.Filter, error) {
+	if bits < 0 {
+		return nil, fmt.Errorf("invalid bloom filter bits %d", bits)
+	}
+	if bits == 0 {
+		return nil, nil
+	}
+	return filter.NewBloomFilter(bits), nil
+}
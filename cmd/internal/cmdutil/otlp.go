@@ -0,0 +1,256 @@
+package cmdutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	bench "github.com/fjl/goleveldb-bench"
+)
+
+// otlpInterval is the time between metric exports.
+const otlpInterval = 10 * time.Second
+
+// otlpLatencyBounds are the bucket bounds of the exported latency histogram.
+var otlpLatencyBounds = []time.Duration{
+	time.Microsecond, 2 * time.Microsecond, 5 * time.Microsecond,
+	10 * time.Microsecond, 20 * time.Microsecond, 50 * time.Microsecond,
+	100 * time.Microsecond, 200 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 20 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 200 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2 * time.Second, 5 * time.Second,
+}
+
+// OTLP defines the -otlp flag. The returned function starts exporting the
+// metrics of the running test to an OpenTelemetry collector after parsing. It
+// takes the Live of the other flags, which may be nil, and returns the Live the
+// tests must report to and a function flushing the last export, which must be
+// called after the tests.
+//
+// Metrics are sent as OTLP over HTTP in the JSON encoding, which collectors
+// accept on their http receiver.
+func OTLP(fs *flag.FlagSet) func(*bench.Live) (*bench.Live, func()) {
+	url := fs.String("otlp", "", "export throughput, latency and stall metrics of the running test to this OTLP/HTTP endpoint (e.g. http://localhost:4318/v1/metrics)")
+	return func(live *bench.Live) (*bench.Live, func()) {
+		if *url == "" {
+			return live, func() {}
+		}
+		if live == nil {
+			live = bench.NewLive()
+		}
+		e := &otlpExporter{url: *url, live: live, stop: make(chan struct{}), done: make(chan struct{})}
+		go e.loop()
+		return live, e.close
+	}
+}
+
+type otlpExporter struct {
+	url  string
+	live *bench.Live
+	err  bool // set after a failed export
+
+	stop, done chan struct{}
+}
+
+func (e *otlpExporter) loop() {
+	defer close(e.done)
+	tick := time.NewTicker(otlpInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			e.export()
+		case <-e.stop:
+			e.export()
+			return
+		}
+	}
+}
+
+func (e *otlpExporter) close() {
+	close(e.stop)
+	<-e.done
+}
+
+func (e *otlpExporter) export() {
+	snap := e.live.Snapshot()
+	if snap.Test == "" {
+		return
+	}
+	body, err := json.Marshal(otlpRequest(&snap, e.live.LatencyBuckets(otlpLatencyBounds), time.Now()))
+	if err != nil {
+		log.Printf("-otlp: can't encode metrics: %v", err) // skipped, the next export retries
+		return
+	}
+	if err := e.post(body); err != nil {
+		if !e.err {
+			log.Printf("-otlp: %v", err) // reported once, exports continue
+		}
+		e.err = true
+		return
+	}
+	e.err = false
+}
+
+func (e *otlpExporter) post(body []byte) error {
+	resp, err := http.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("server returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// The types below are the JSON encoding of the OTLP metrics protocol, reduced
+// to the fields used here. 64 bit integers are encoded as strings.
+type (
+	otlpExportRequest struct {
+		ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+	}
+	otlpResourceMetrics struct {
+		Resource     otlpResource       `json:"resource"`
+		ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeMetrics struct {
+		Scope   otlpScope    `json:"scope"`
+		Metrics []otlpMetric `json:"metrics"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpMetric struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description,omitempty"`
+		Unit        string         `json:"unit,omitempty"`
+		Gauge       *otlpGauge     `json:"gauge,omitempty"`
+		Sum         *otlpSum       `json:"sum,omitempty"`
+		Histogram   *otlpHistogram `json:"histogram,omitempty"`
+	}
+	otlpGauge struct {
+		DataPoints []otlpNumberPoint `json:"dataPoints"`
+	}
+	otlpSum struct {
+		DataPoints             []otlpNumberPoint `json:"dataPoints"`
+		AggregationTemporality int               `json:"aggregationTemporality"`
+		IsMonotonic            bool              `json:"isMonotonic"`
+	}
+	otlpHistogram struct {
+		DataPoints             []otlpHistogramPoint `json:"dataPoints"`
+		AggregationTemporality int                  `json:"aggregationTemporality"`
+	}
+	otlpNumberPoint struct {
+		Attributes        []otlpAttribute `json:"attributes"`
+		StartTimeUnixNano int64           `json:"startTimeUnixNano,string"`
+		TimeUnixNano      int64           `json:"timeUnixNano,string"`
+		AsInt             *int64          `json:"asInt,string,omitempty"`
+		AsDouble          *float64        `json:"asDouble,omitempty"`
+	}
+	otlpHistogramPoint struct {
+		Attributes        []otlpAttribute `json:"attributes"`
+		StartTimeUnixNano int64           `json:"startTimeUnixNano,string"`
+		TimeUnixNano      int64           `json:"timeUnixNano,string"`
+		Count             uint64          `json:"count,string"`
+		BucketCounts      []string        `json:"bucketCounts"`
+		ExplicitBounds    []float64       `json:"explicitBounds"`
+	}
+	otlpAttribute struct {
+		Key   string `json:"key"`
+		Value struct {
+			StringValue string `json:"stringValue"`
+		} `json:"value"`
+	}
+)
+
+// otlpCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE.
+const otlpCumulative = 2
+
+// otlpRequest builds the export request of a snapshot. Sums and the histogram
+// are cumulative since the start of the test.
+func otlpRequest(s *bench.LiveSnapshot, latency []uint64, now time.Time) *otlpExportRequest {
+	var (
+		attrs = otlpAttributes(s)
+		start = now.Add(-s.Elapsed).UnixNano()
+		ts    = now.UnixNano()
+	)
+	intPoint := func(v uint64) []otlpNumberPoint {
+		n := int64(v)
+		return []otlpNumberPoint{{Attributes: attrs, StartTimeUnixNano: start, TimeUnixNano: ts, AsInt: &n}}
+	}
+	sum := func(name, desc, unit string, v uint64) otlpMetric {
+		return otlpMetric{Name: name, Description: desc, Unit: unit, Sum: &otlpSum{
+			DataPoints: intPoint(v), AggregationTemporality: otlpCumulative, IsMonotonic: true,
+		}}
+	}
+	metrics := []otlpMetric{
+		sum("ldbbench.processed", "Bytes processed by the running test.", "By", s.Processed),
+		sum("ldbbench.entries", "Entries processed by the running test.", "{entry}", s.Entries),
+		sum("ldbbench.stalls", "Operations slower than -stall.", "{stall}", s.Stalls),
+	}
+	// JSON has no infinity, which BPS returns for intervals of zero duration.
+	if bps := s.BPS; !math.IsInf(bps, 0) && !math.IsNaN(bps) {
+		metrics = append(metrics, otlpMetric{Name: "ldbbench.throughput", Description: "Throughput of the last progress interval.", Unit: "By/s", Gauge: &otlpGauge{
+			DataPoints: []otlpNumberPoint{{Attributes: attrs, StartTimeUnixNano: start, TimeUnixNano: ts, AsDouble: &bps}},
+		}})
+	}
+	var count uint64
+	counts := make([]string, len(latency))
+	for i, c := range latency {
+		count += c
+		counts[i] = strconv.FormatUint(c, 10)
+	}
+	if count > 0 {
+		bounds := make([]float64, len(otlpLatencyBounds))
+		for i, b := range otlpLatencyBounds {
+			bounds[i] = b.Seconds()
+		}
+		metrics = append(metrics, otlpMetric{
+			Name: "ldbbench.latency", Description: "Latency of single operations.", Unit: "s",
+			Histogram: &otlpHistogram{AggregationTemporality: otlpCumulative, DataPoints: []otlpHistogramPoint{{
+				Attributes: attrs, StartTimeUnixNano: start, TimeUnixNano: ts,
+				Count: count, BucketCounts: counts, ExplicitBounds: bounds,
+			}}},
+		})
+	}
+	return &otlpExportRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource:     otlpResource{Attributes: []otlpAttribute{otlpAttr("service.name", "ldbbench")}},
+		ScopeMetrics: []otlpScopeMetrics{{Scope: otlpScope{Name: "github.com/fjl/goleveldb-bench"}, Metrics: metrics}},
+	}}}
+}
+
+// otlpAttributes are the attributes of all data points: the test name and the
+// tags of the run.
+func otlpAttributes(s *bench.LiveSnapshot) []otlpAttribute {
+	attrs := []otlpAttribute{otlpAttr("test", s.Test)}
+	keys := make([]string, 0, len(s.Tags))
+	for k := range s.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		attrs = append(attrs, otlpAttr(k, s.Tags[k]))
+	}
+	return attrs
+}
+
+func otlpAttr(key, value string) otlpAttribute {
+	a := otlpAttribute{Key: key}
+	a.Value.StringValue = value
+	return a
+}
@@ -0,0 +1,72 @@
+package cmdutil
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+
+	"github.com/syndtr/goleveldb/leveldb/filter"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// optionPresets are the values of -preset. A preset sets options on top of the
+// options of the test. cache is the memory allowance in mb.
+var optionPresets = map[string]func(o *opt.Options, cache int){
+	"geth": gethOptions,
+}
+
+// PresetNames returns the names of the option presets.
+func PresetNames() (n []string) {
+	for name := range optionPresets {
+		n = append(n, name)
+	}
+	sort.Strings(n)
+	return n
+}
+
+func checkPreset(name string) error {
+	if _, ok := optionPresets[name]; !ok {
+		return fmt.Errorf("unknown preset %q", name)
+	}
+	return nil
+}
+
+// gethHandles is the open files allowance of the geth preset, computed once.
+var gethHandles = -1
+
+// gethWarned is set once the geth preset has warned about a missing option.
+var gethWarned bool
+
+// gethOptions sets the options like go-ethereum's ethdb/leveldb.New. geth gives
+// the database half of the file descriptors, raising the limit as far as
+// possible, which is done here as well. DisableSeeksCompaction doesn't exist in
+// all goleveldb versions, including the one in go.mod. It is set if it does, and
+// otherwise the preset warns that it doesn't reproduce geth's compaction.
+func gethOptions(o *opt.Options, cache int) {
+	const minCache, minHandles = 16, 16
+	if gethHandles < 0 {
+		limit, err := raiseFileLimit()
+		if err != nil {
+			log.Printf("can't raise file descriptor limit: %v", err)
+		}
+		gethHandles = int(limit / 2)
+	}
+	handles := gethHandles
+	if cache < minCache {
+		cache = minCache
+	}
+	if handles < minHandles {
+		handles = minHandles
+	}
+	o.Filter = filter.NewBloomFilter(10)
+	o.OpenFilesCacheCapacity = handles
+	o.BlockCacheCapacity = cache / 2 * opt.MiB
+	o.WriteBuffer = cache / 4 * opt.MiB // geth notes that two of these are used internally
+	if f := reflect.ValueOf(o).Elem().FieldByName("DisableSeeksCompaction"); f.IsValid() && f.Kind() == reflect.Bool {
+		f.SetBool(true)
+	} else if !gethWarned {
+		gethWarned = true
+		log.Printf("warning: this goleveldb version has no DisableSeeksCompaction option, the geth preset runs with seek compaction enabled, unlike geth")
+	}
+}
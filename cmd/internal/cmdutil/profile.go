@@ -0,0 +1,78 @@
+package cmdutil
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	_ "net/http/pprof" // registers the /debug/pprof handlers
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// Profile defines the -cpuprofile, -memprofile, -blockprofile and -pprof-addr
+// flags. The returned function starts profiling after parsing. It returns a function that
+// stops profiling and writes the profiles, which must be called before exit.
+func Profile(fs *flag.FlagSet) func() (stop func()) {
+	var (
+		cpu   = fs.String("cpuprofile", "", "write a CPU profile of the test runs to this file")
+		mem   = fs.String("memprofile", "", "write a heap profile to this file after the test runs")
+		block = fs.String("blockprofile", "", "write a goroutine blocking profile of the test runs to this file")
+		addr  = fs.String("pprof-addr", "", "serve net/http/pprof on this address (e.g. localhost:6060) during the test runs")
+	)
+	return func() func() {
+		var cpuFile *os.File
+		if *cpu != "" {
+			var err error
+			if cpuFile, err = os.Create(*cpu); err != nil {
+				log.Fatal("-cpuprofile: ", err)
+			}
+			if err := pprof.StartCPUProfile(cpuFile); err != nil {
+				log.Fatal("-cpuprofile: ", err)
+			}
+		}
+		if *block != "" {
+			runtime.SetBlockProfileRate(1)
+		}
+		var srv *http.Server
+		if *addr != "" {
+			l, err := net.Listen("tcp", *addr)
+			if err != nil {
+				log.Fatal("-pprof-addr: ", err)
+			}
+			log.Printf("serving pprof on http://%s/debug/pprof/", l.Addr())
+			srv = &http.Server{Handler: http.DefaultServeMux}
+			go srv.Serve(l)
+		}
+		return func() {
+			if srv != nil {
+				srv.Close()
+			}
+			if cpuFile != nil {
+				pprof.StopCPUProfile()
+				cpuFile.Close()
+			}
+			if *mem != "" {
+				runtime.GC() // the profile shows the state as of the last GC
+				writeProfile("heap", *mem)
+			}
+			if *block != "" {
+				writeProfile("block", *block)
+				runtime.SetBlockProfileRate(0)
+			}
+		}
+	}
+}
+
+func writeProfile(name, file string) {
+	fd, err := os.Create(file)
+	if err != nil {
+		log.Printf("can't write %s profile: %v", name, err)
+		return
+	}
+	defer fd.Close()
+	if err := pprof.Lookup(name).WriteTo(fd, 0); err != nil {
+		log.Printf("can't write %s profile: %v", name, err)
+	}
+}
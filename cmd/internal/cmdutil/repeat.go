@@ -0,0 +1,83 @@
+package cmdutil
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	bench "github.com/fjl/goleveldb-bench"
+)
+
+// aggregateFile is the file of the aggregated results in the log directory.
+const aggregateFile = "aggregate.txt"
+
+// Repeat defines the -repeat flag. The returned function creates the log
+// directories of the repetitions after parsing: the log directory itself if the
+// tests run once, and a run<n> subdirectory of it for every repetition
+// otherwise, the layout the delta command reads as repeated runs.
+func Repeat(fs *flag.FlagSet) func(logdir string) ([]string, error) {
+	n := fs.Int("repeat", 1, "run each test this many times, on a fresh database each time, and report the mean, stddev and range of the results")
+	return func(logdir string) ([]string, error) {
+		if *n < 1 {
+			return nil, fmt.Errorf("-repeat must be at least 1")
+		}
+		if *n == 1 {
+			return []string{logdir}, os.MkdirAll(logdir, 0755)
+		}
+		dirs := make([]string, *n)
+		for i := range dirs {
+			dirs[i] = filepath.Join(logdir, fmt.Sprintf("run%d", i+1))
+			if err := os.MkdirAll(dirs[i], 0755); err != nil {
+				return nil, err
+			}
+		}
+		return dirs, nil
+	}
+}
+
+// WriteAggregate reads the test logs of all repetitions and prints the
+// aggregated results. They are also written to aggregate.txt in logdir.
+func WriteAggregate(logdir string, dirs []string) error {
+	var files []string
+	for _, dir := range dirs {
+		f, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+		files = append(files, f...)
+	}
+	reports := bench.MustReadReports(files)
+	var buf bytes.Buffer
+	for _, t := range bench.Aggregate(reports) {
+		fmt.Fprintf(&buf, "-- %s (%d runs)\n", t.Label, t.Runs)
+		for _, m := range t.Metrics {
+			fmt.Fprintf(&buf, "%11s: %s +- %s  (min %s, max %s)\n", m.Metric,
+				FormatMetric(m.Metric, m.Mean), FormatMetric(m.Metric, m.Std),
+				FormatMetric(m.Metric, m.Min), FormatMetric(m.Metric, m.Max))
+		}
+	}
+	os.Stdout.Write(buf.Bytes())
+	return ioutil.WriteFile(filepath.Join(logdir, aggregateFile), buf.Bytes(), 0644)
+}
+
+// FormatMetric formats a value of a metric compared between runs. Throughput
+// is in bytes/s, latencies in nanoseconds.
+func FormatMetric(metric string, v float64) string {
+	if metric == "throughput" {
+		return fmt.Sprintf("%.3f mb/s", v/1024/1024)
+	}
+	return roundLatency(time.Duration(v)).String()
+}
+
+// roundLatency keeps about three significant digits.
+func roundLatency(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Millisecond:
+		return d.Round(time.Microsecond)
+	case d >= time.Microsecond:
+		return d.Round(10 * time.Nanosecond)
+	default:
+		return d
+	}
+}
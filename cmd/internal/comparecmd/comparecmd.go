@@ -0,0 +1,199 @@
+// Package comparecmd runs the same benchmark against several storage engines
+// and reports the results side by side.
+package comparecmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/fjl/goleveldb-bench/benchplot"
+	"github.com/fjl/goleveldb-bench/cmd/internal/cmdutil"
+	"github.com/fjl/goleveldb-bench/cmd/internal/kvstore"
+)
+
+// Main runs the compare command.
+func Main(name string, args []string) {
+	var (
+		fs       = cmdutil.FlagSet(name, "[flags] write|read [flags of the command]")
+		dbflag   = fs.String("db", "", "comma-separated storage engines to compare ("+strings.Join(kvstore.Names(), ", ")+")")
+		dirflag  = fs.String("dir", ".", "test database directory")
+		logdir   = fs.String("logdir", ".", "test log output directory")
+		plotType = fs.String("plot", "bps", "type of the per-test plots ("+strings.Join(benchplot.Types, ", ")+"), empty for none")
+	)
+	cmdutil.CompleteValues(fs, "plot", func() []string { return benchplot.Types })
+	fs.Parse(args)
+
+	var engines []string
+	for _, e := range strings.Split(*dbflag, ",") {
+		if e = strings.TrimSpace(e); e == "" {
+			continue
+		}
+		if err := kvstore.Check(e); err != nil {
+			log.Fatal("-db: ", err)
+		}
+		engines = append(engines, e)
+	}
+	if len(engines) < 2 {
+		log.Fatal("-db needs at least two storage engines to compare")
+	}
+	cmd := fs.Args()
+	if len(cmd) == 0 || (cmd[0] != "write" && cmd[0] != "read") {
+		log.Fatal("missing benchmark command, use write or read")
+	}
+	for _, a := range cmd[1:] {
+		if f := cmdutil.FlagName(a); f == "db" || f == "dir" || f == "logdir" {
+			log.Fatalf("-%s must be given to %s", f, name)
+		}
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		log.Fatalf("can't figure out executable path: %v", err)
+	}
+
+	var (
+		anyErr = false
+		start  = time.Now()
+	)
+	for _, e := range engines {
+		log.Printf("== storage engine %s", e)
+		// Every engine starts in a fresh directory.
+		dir := filepath.Join(*dirflag, "compare-"+e)
+		if err := os.RemoveAll(dir); err != nil {
+			log.Fatal(err)
+		}
+		runArgs := append([]string{cmd[0]}, cmd[1:]...)
+		runArgs = append(runArgs,
+			"-db", e,
+			"-dir", dir,
+			"-logdir", filepath.Join(*logdir, e),
+			"-tag", "db="+e,
+		)
+		run := exec.Command(exe, runArgs...)
+		run.Stdout, run.Stderr = os.Stdout, os.Stderr
+		if err := run.Run(); err != nil {
+			log.Printf("storage engine %s: %v", e, err)
+			anyErr = true
+		}
+	}
+
+	tests, err := collect(*logdir, engines, start)
+	if err != nil {
+		log.Fatal(err)
+	}
+	printComparison(tests, engines)
+	if *plotType != "" {
+		if err := plotComparison(*logdir, *plotType, tests); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if anyErr {
+		log.Fatal("one or more engines failed")
+	}
+}
+
+// comparison holds the reports of one test, in engine order.
+type comparison struct {
+	name    string
+	reports []bench.Report
+}
+
+// collect reads the logs of all engines written since start and groups them by
+// test. Older logs in the directories are from earlier comparisons.
+func collect(logdir string, engines []string, start time.Time) ([]*comparison, error) {
+	byName := make(map[string]*comparison)
+	for _, e := range engines {
+		matches, err := filepath.Glob(filepath.Join(logdir, e, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		var files []string
+		for _, f := range matches {
+			if info, err := os.Stat(f); err == nil && !info.ModTime().Before(start) {
+				files = append(files, f)
+			}
+		}
+		for _, r := range bench.MustReadReports(files) {
+			c := byName[r.Name]
+			if c == nil {
+				c = &comparison{name: r.Name}
+				byName[r.Name] = c
+			}
+			c.reports = append(c.reports, r)
+		}
+	}
+	tests := make([]*comparison, 0, len(byName))
+	for _, c := range byName {
+		tests = append(tests, c)
+	}
+	sort.Slice(tests, func(i, j int) bool { return tests[i].name < tests[j].name })
+	return tests, nil
+}
+
+// printComparison prints one line per engine and test. Throughput is relative
+// to the first engine.
+func printComparison(tests []*comparison, engines []string) {
+	width := 0
+	for _, e := range engines {
+		if len(e) > width {
+			width = len(e)
+		}
+	}
+	for _, c := range tests {
+		fmt.Printf("-- %s\n", c.name)
+		// Throughput is compared to the first engine which supports the test.
+		var (
+			base    float64
+			hasBase bool
+		)
+		for _, r := range c.reports {
+			engine := r.Tags["db"]
+			if r.Result != nil && r.Result.Unsupported != "" {
+				fmt.Printf("   %-*s  unsupported: %s\n", width, engine, r.Result.Unsupported)
+				continue
+			}
+			s := bench.Summarize(r)
+			fmt.Printf("   %-*s  %9.3f mb/s (+- %.3f)", width, engine, s.MeanBPS/1024/1024, s.StdBPS/1024/1024)
+			if !hasBase {
+				base, hasBase = s.MeanBPS, true
+			} else if base > 0 {
+				fmt.Printf(" %+7.1f%%", 100*(s.MeanBPS-base)/base)
+			}
+			if s.Entries > 0 {
+				fmt.Printf("  %v/entry", s.EntryLatency())
+			}
+			fmt.Printf("  %.1fs", s.TotalTime)
+			if r.Result != nil && r.Result.Error != "" {
+				fmt.Printf("  error: %s", r.Result.Error)
+			}
+			fmt.Println()
+		}
+	}
+}
+
+// plotComparison renders a plot per test with a series for every engine.
+func plotComparison(logdir, plotType string, tests []*comparison) error {
+	for _, c := range tests {
+		var reports []bench.Report
+		for _, r := range c.reports {
+			if len(r.Events) > 0 {
+				reports = append(reports, r)
+			}
+		}
+		if len(reports) == 0 {
+			continue
+		}
+		file := filepath.Join(logdir, c.name+"-"+plotType+".png")
+		if err := benchplot.Save(plotType, reports, benchplot.DefaultSize, file); err != nil {
+			return fmt.Errorf("%s: %v", c.name, err)
+		}
+		log.Printf("wrote %s", file)
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package crashcmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fjl/goleveldb-bench/cmd/internal/cmdutil"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// Main runs the crash test command.
+func Main(name string, args []string) {
+	// Run the test if we're the writer child process.
+	if len(args) > 0 && args[0] == "-writer" {
+		writer(args[1:])
+		return
+	}
+
+	// Be the front-end otherwise.
+	var (
+		fs        = cmdutil.FlagSet(name, "[flags]")
+		testflag  = fs.String("test", "seq", "tests to run ("+strings.Join(testnames(), ", ")+")")
+		timeflag  = fs.Duration("time", 30*time.Second, "time to wait before terminating the writer process")
+		dirflag   = fs.String("dir", ".", "test database directory")
+		countflag = fs.Uint("count", 1000, "number of test repetitions")
+		run       []string
+	)
+	cmdutil.CompleteValues(fs, "test", testnames)
+	fs.Parse(args)
+	if *countflag < 1 {
+		log.Fatal("-count must be at least 1")
+	}
+
+	for _, t := range strings.Split(*testflag, ",") {
+		if tests[t] == nil {
+			log.Fatalf("unknown test %q", t)
+		}
+		run = append(run, t)
+	}
+	if len(run) == 0 {
+		log.Fatal("no tests to run, use -test to select tests")
+	}
+
+	anyErr := false
+	for _, name := range run {
+		var (
+			total, max time.Duration
+			lost       uint64
+		)
+		for i := uint(1); i <= *countflag; i++ {
+			log.Printf("== running test %q (%d/%d)", name, i, *countflag)
+			res, err := runTest(*dirflag, name, *timeflag)
+			if err != nil {
+				log.Printf("test %q failed: %v", name, err)
+				anyErr = true
+			}
+			total += res.recovery
+			lost += res.lost
+			if res.recovery > max {
+				max = res.recovery
+			}
+		}
+		log.Printf("== %s: recovery time %v mean, %v max, %d acknowledged writes lost",
+			name, total/time.Duration(*countflag), max, lost)
+	}
+	if anyErr {
+		log.Fatal("one ore more tests failed")
+	}
+}
+
+// result is the outcome of a single crash test.
+type result struct {
+	recovery  time.Duration // time to reopen the database
+	acked     int64         // highest acknowledged key index, -1 if none
+	recovered uint64        // number of keys found
+	lost      uint64        // acknowledged keys not found
+}
+
+func runTest(basedir, name string, avgwait time.Duration) (result, error) {
+	dbdir := filepath.Join(basedir, "testdb-crashtest-"+name)
+	if err := os.RemoveAll(dbdir); err != nil && !os.IsNotExist(err) {
+		return result{}, err
+	}
+
+	// Start the writer process and kill it on a randomized timeout. The writer
+	// prints the index of every acknowledged write.
+	ctx, cancel := context.WithTimeout(context.Background(), randomWaitTime(avgwait))
+	defer cancel()
+	writer, err := cmdutil.Self(ctx, "-writer", dbdir, name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	writer.Stderr = os.Stderr
+	stdout, err := writer.StdoutPipe()
+	if err != nil {
+		return result{}, err
+	}
+	if err := writer.Start(); err != nil {
+		return result{}, err
+	}
+	acked := int64(-1)
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if i, err := strconv.ParseInt(scanner.Text(), 10, 64); err == nil {
+			acked = i
+		}
+	}
+	writer.Wait()
+
+	res, err := checkDB(dbdir, acked)
+	if err == nil && res.lost > 0 && tests[name].durable() {
+		err = fmt.Errorf("lost %d acknowledged writes", res.lost)
+	}
+	return res, err
+}
+
+func randomWaitTime(avg time.Duration) time.Duration {
+	wiggle := 500 * time.Millisecond
+	if wiggle > avg {
+		wiggle = avg / 2
+	}
+	r := time.Duration(rand.Int63n(int64(wiggle)))
+	return avg - wiggle/2 + r
+}
+
+// holeWindow is the number of keys checked after the last key found.
+const holeWindow = 20000
+
+// checkDB opens the database and checks whether all keys are present and
+// the correct value is stored for each key. Writes are performed in order, so
+// there must be no missing keys before the last key found.
+func checkDB(dbdir string, acked int64) (result, error) {
+	res := result{acked: acked}
+	begin := time.Now()
+	db, err := leveldb.OpenFile(dbdir, nil)
+	if err != nil {
+		return res, err
+	}
+	defer db.Close()
+	res.recovery = time.Since(begin)
+
+	var (
+		checkErr     error
+		firstMissing = int64(-1)
+		last         = int64(-1)
+	)
+	iterateTestKeys(func(i uint64, k, v []byte) bool {
+		value, err := db.Get(k, nil)
+		if err != nil {
+			if firstMissing < 0 {
+				firstMissing = int64(i)
+			}
+			if int64(i) <= acked {
+				res.lost++
+			}
+			return int64(i) > acked && int64(i)-last > holeWindow
+		}
+		if firstMissing >= 0 {
+			checkErr = fmt.Errorf("key %d is present, but key %d is missing", i, firstMissing)
+		} else if !bytes.Equal(value, v) {
+			checkErr = fmt.Errorf("mismatch for key %x: want %x, found %x", k, v, value)
+		}
+		res.recovered++
+		last = int64(i)
+		return checkErr != nil
+	})
+	log.Printf("  == recovered in %v, database has %d keys, %d acknowledged, %d lost",
+		res.recovery, res.recovered, acked+1, res.lost)
+	return res, checkErr
+}
+
+// iterateTestKeys calls fn with keys and values until it returns true.
+// The keys and values are 32-byte values.
+func iterateTestKeys(fn func(i uint64, k, v []byte) bool) {
+	var n, k, v [32]byte
+	hash := sha1.New()
+	for i := uint64(0); ; i++ {
+		binary.BigEndian.PutUint64(n[:], i)
+		hash.Write(n[:])
+		hash.Sum(k[:0])
+		hash.Write(k[:])
+		hash.Sum(v[:0])
+		if fn(i, k[:], v[:]) {
+			return
+		}
+		hash.Reset()
+	}
+}
+
+// writer is the main function of the child process.
+func writer(args []string) {
+	if len(args) != 2 {
+		log.Fatal("invalid number of arguments")
+	}
+	dbdir, name := args[0], args[1]
+	if err := tests[name].test(dbdir); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// These are the different write modes.
+var tests = map[string]tester{
+	"seq":          seqWrite{sync: true},
+	"seq-nosync":   seqWrite{sync: false},
+	"batch":        batchWrite{sync: true, size: 10000},
+	"batch-nosync": batchWrite{sync: false, size: 10000},
+}
+
+func testnames() (n []string) {
+	for name := range tests {
+		n = append(n, name)
+	}
+	sort.Strings(n)
+	return n
+}
+
+type tester interface {
+	test(dbdir string) error
+	// durable reports whether acknowledged writes must survive a crash.
+	durable() bool
+}
+
+// ack reports that all writes up to key i are done.
+func ack(i uint64) {
+	fmt.Printf("%d\n", i)
+}
+
+type seqWrite struct {
+	sync bool
+}
+
+func (t seqWrite) durable() bool { return t.sync }
+
+func (t seqWrite) test(dbdir string) error {
+	db, err := leveldb.OpenFile(dbdir, nil)
+	if err != nil {
+		return err
+	}
+	wopt := &opt.WriteOptions{Sync: t.sync}
+	iterateTestKeys(func(i uint64, k, v []byte) bool {
+		if err = db.Put(k, v, wopt); err != nil {
+			return true
+		}
+		if i%1000 == 0 {
+			ack(i)
+		}
+		return false
+	})
+	return err
+}
+
+type batchWrite struct {
+	sync bool
+	size int
+}
+
+func (t batchWrite) durable() bool { return t.sync }
+
+func (t batchWrite) test(dbdir string) error {
+	db, err := leveldb.OpenFile(dbdir, nil)
+	if err != nil {
+		return err
+	}
+	var (
+		batch leveldb.Batch
+		wopt  = &opt.WriteOptions{Sync: t.sync}
+	)
+	iterateTestKeys(func(i uint64, k, v []byte) bool {
+		batch.Put(k, v)
+		if batch.Len() == t.size {
+			if err = db.Write(&batch, wopt); err != nil {
+				return true
+			}
+			batch.Reset()
+			ack(i)
+		}
+		return false
+	})
+	return err
+}
@@ -0,0 +1,64 @@
+package deltacmd
+
+import (
+	"fmt"
+	"os"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/fjl/goleveldb-bench/cmd/internal/cmdutil"
+)
+
+// Main runs the log comparison command.
+func Main(name string, args []string) {
+	fs := cmdutil.FlagSet(name, "<before log dir> <after log dir>")
+	thresholds := cmdutil.Thresholds(fs, 0, 0)
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	before, after := cmdutil.ReadLogDir(fs.Arg(0)), cmdutil.ReadLogDir(fs.Arg(1))
+	deltas, onlyBefore, onlyAfter := bench.Compare(before, after)
+	for _, d := range deltas {
+		printDelta(d)
+	}
+	for _, label := range onlyBefore {
+		fmt.Printf("-- %s: only in %s\n", label, fs.Arg(0))
+	}
+	for _, label := range onlyAfter {
+		fmt.Printf("-- %s: only in %s\n", label, fs.Arg(1))
+	}
+	th := thresholds()
+	if th != (bench.Thresholds{}) && !cmdutil.CheckRegressions(before, after, th) {
+		os.Exit(1)
+	}
+}
+
+func printDelta(d bench.TestDelta) {
+	if len(d.Metrics) == 0 {
+		fmt.Printf("-- %s: no results to compare\n", d.Label)
+		return
+	}
+	m := d.Metrics[0]
+	fmt.Printf("-- %s (%d vs. %d runs)\n", d.Label, m.Before.Runs, m.After.Runs)
+	for _, m := range d.Metrics {
+		verdict := ""
+		switch {
+		case m.Before.Runs < 2 || m.After.Runs < 2:
+		case !m.Significant():
+			verdict = "  (within noise)"
+		case m.Improved():
+			verdict = "  improved"
+		default:
+			verdict = "  regressed"
+		}
+		fmt.Printf("%11s: %s -> %s  %+.2f%%%s\n", m.Metric, formatStats(m.Metric, m.Before), formatStats(m.Metric, m.After), 100*m.Change(), verdict)
+	}
+}
+
+func formatStats(metric string, s bench.MetricStats) string {
+	if s.Runs < 2 {
+		return cmdutil.FormatMetric(metric, s.Mean)
+	}
+	return cmdutil.FormatMetric(metric, s.Mean) + " +- " + cmdutil.FormatMetric(metric, s.Std)
+}
@@ -0,0 +1,193 @@
+package kvstore
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+	bench "github.com/fjl/goleveldb-bench"
+)
+
+func init() {
+	Register("badger", openBadger)
+}
+
+// badgerDB is the dgraph-io/badger engine. Badger keeps values in a separate
+// value log, only keys and small values go into the LSM tree.
+type badgerDB struct {
+	db     *badger.DB
+	noSync bool
+}
+
+// openBadger applies the goleveldb options which are set explicitly, badger's
+// defaults are kept otherwise. Bloom filters are always enabled in badger.
+func openBadger(dir string, o Options) (Store, error) {
+	if err := noStorage("badger", o); err != nil {
+		return nil, err
+	}
+	lo := o.LevelDB
+	bo := badger.DefaultOptions(dir).WithLogger(quietLogger{}).WithReadOnly(lo.GetReadOnly())
+	if lo.BlockCacheCapacity > 0 {
+		bo = bo.WithBlockCacheSize(int64(lo.BlockCacheCapacity))
+	}
+	if lo.WriteBuffer > 0 {
+		bo = bo.WithMemTableSize(int64(lo.WriteBuffer))
+	}
+	if lo.CompactionTableSize > 0 {
+		bo = bo.WithBaseTableSize(int64(lo.CompactionTableSize))
+	}
+	db, err := badger.Open(bo)
+	if err != nil {
+		return nil, err
+	}
+	return &badgerDB{db, lo.GetNoSync()}, nil
+}
+
+// sync flushes writes to disk if requested. Badger can only sync all writes,
+// not a single transaction.
+func (b *badgerDB) sync(wo *WriteOptions) error {
+	if wo != nil && wo.Sync && !b.noSync {
+		return b.db.Sync()
+	}
+	return nil
+}
+
+func (b *badgerDB) Put(key, value []byte, wo *WriteOptions) error {
+	err := b.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, value)
+	})
+	if err != nil {
+		return err
+	}
+	return b.sync(wo)
+}
+
+func (b *badgerDB) Get(key []byte) (v []byte, err error) {
+	err = b.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+		v, err = item.ValueCopy(nil)
+		return err
+	})
+	if err == badger.ErrKeyNotFound {
+		err = bench.ErrNotFound
+	}
+	return v, err
+}
+
+func (b *badgerDB) NewBatch() Batch {
+	return &badgerBatch{db: b}
+}
+
+func (b *badgerDB) Iterate(start, limit []byte) Iterator {
+	txn := b.db.NewTransaction(false)
+	return &badgerIterator{txn: txn, it: txn.NewIterator(badger.DefaultIteratorOptions), start: start, limit: limit}
+}
+
+// CompactRange flattens the LSM tree and collects garbage in the value log.
+// Badger can't compact a key range.
+func (b *badgerDB) CompactRange(start, limit []byte) error {
+	if start != nil || limit != nil {
+		return errors.New("badger can't compact a key range")
+	}
+	if err := b.db.Flatten(1); err != nil {
+		return err
+	}
+	for {
+		if err := b.db.RunValueLogGC(0.5); err == badger.ErrNoRewrite {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func (b *badgerDB) Close() error {
+	return b.db.Close()
+}
+
+// badgerBatch applies writes in a badger write batch, which splits them into
+// transactions of the maximum size. Unlike goleveldb batches, large batches are
+// not applied atomically.
+type badgerBatch struct {
+	opBatch
+	db *badgerDB
+}
+
+func (b *badgerBatch) Write(wo *WriteOptions) error {
+	wb := b.db.db.NewWriteBatch()
+	defer wb.Cancel()
+	for _, op := range b.ops {
+		var err error
+		if op.delete {
+			err = wb.Delete(op.key)
+		} else {
+			err = wb.Set(op.key, op.value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := wb.Flush(); err != nil {
+		return err
+	}
+	return b.db.sync(wo)
+}
+
+// badgerIterator adapts badger iterators, which have no upper bound and are
+// positioned at the first key when created.
+type badgerIterator struct {
+	txn          *badger.Txn
+	it           *badger.Iterator
+	start, limit []byte
+	started      bool
+	value        []byte
+	err          error
+}
+
+func (it *badgerIterator) Next() bool {
+	if !it.started {
+		return it.Seek(it.start)
+	}
+	it.it.Next()
+	return it.valid()
+}
+
+func (it *badgerIterator) Seek(key []byte) bool {
+	it.started = true
+	if bytes.Compare(key, it.start) < 0 {
+		key = it.start
+	}
+	it.it.Seek(key)
+	return it.valid()
+}
+
+func (it *badgerIterator) valid() bool {
+	it.value = nil
+	if it.err != nil || !it.it.Valid() {
+		return false
+	}
+	return it.limit == nil || bytes.Compare(it.it.Item().Key(), it.limit) < 0
+}
+
+func (it *badgerIterator) Key() []byte {
+	return it.it.Item().Key()
+}
+
+// Value returns the value of the current key. It is read from the value log on
+// first access.
+func (it *badgerIterator) Value() []byte {
+	if it.value == nil && it.err == nil {
+		it.value, it.err = it.it.Item().ValueCopy(nil)
+	}
+	return it.value
+}
+
+func (it *badgerIterator) Error() error { return it.err }
+
+func (it *badgerIterator) Release() {
+	it.it.Close()
+	it.txn.Discard()
+}
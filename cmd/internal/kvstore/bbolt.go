@@ -0,0 +1,212 @@
+package kvstore
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"time"
+
+	bench "github.com/fjl/goleveldb-bench"
+	bolt "go.etcd.io/bbolt"
+)
+
+func init() {
+	Register("bbolt", openBolt)
+}
+
+var boltBucket = []byte("kv")
+
+// boltDB is the etcd-io/bbolt engine, a B+tree in a single memory mapped file.
+// All keys are stored in one bucket.
+type boltDB struct {
+	db     *bolt.DB
+	noSync bool
+}
+
+// openBolt opens the database file in dir. Commits are not synced unless a
+// write asks for it, like the writes of the LSM engines. This is bbolt's NoSync
+// mode, the file can be corrupted by a crash.
+func openBolt(dir string, o Options) (Store, error) {
+	if err := noStorage("bbolt", o); err != nil {
+		return nil, err
+	}
+	lo := o.LevelDB
+	if !lo.GetReadOnly() {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	db, err := bolt.Open(filepath.Join(dir, "bbolt.db"), 0644, &bolt.Options{
+		Timeout:      time.Second,
+		NoSync:       true,
+		ReadOnly:     lo.GetReadOnly(),
+		FreelistType: bolt.FreelistMapType,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !lo.GetReadOnly() {
+		err = db.Update(func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists(boltBucket)
+			return err
+		})
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return &boltDB{db, lo.GetNoSync()}, nil
+}
+
+// update runs fn in a write transaction and syncs the file if requested.
+func (b *boltDB) update(wo *WriteOptions, fn func(*bolt.Bucket) error) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		return fn(tx.Bucket(boltBucket))
+	})
+	if err != nil {
+		return err
+	}
+	if wo != nil && wo.Sync && !b.noSync {
+		return b.db.Sync()
+	}
+	return nil
+}
+
+func (b *boltDB) Put(key, value []byte, wo *WriteOptions) error {
+	return b.update(wo, func(bk *bolt.Bucket) error {
+		return bk.Put(key, value)
+	})
+}
+
+func (b *boltDB) Get(key []byte) (v []byte, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		bk := tx.Bucket(boltBucket)
+		if bk == nil {
+			return bench.ErrNotFound
+		}
+		// The value is only valid during the transaction.
+		if v = bk.Get(key); v == nil {
+			return bench.ErrNotFound
+		}
+		v = copyBytes(v)
+		return nil
+	})
+	return v, err
+}
+
+func (b *boltDB) NewBatch() Batch {
+	return &boltBatch{db: b}
+}
+
+func (b *boltDB) Iterate(start, limit []byte) Iterator {
+	tx, err := b.db.Begin(false)
+	if err != nil {
+		return &errIterator{err}
+	}
+	it := &boltIterator{tx: tx, start: start, limit: limit}
+	if bk := tx.Bucket(boltBucket); bk != nil {
+		it.c = bk.Cursor()
+	}
+	return it
+}
+
+func (b *boltDB) Close() error {
+	return b.db.Close()
+}
+
+// boltBatch applies all writes in one transaction.
+type boltBatch struct {
+	opBatch
+	db *boltDB
+}
+
+func (b *boltBatch) Write(wo *WriteOptions) error {
+	return b.db.update(wo, func(bk *bolt.Bucket) error {
+		for _, op := range b.ops {
+			var err error
+			if op.delete {
+				err = bk.Delete(op.key)
+			} else {
+				err = bk.Put(op.key, op.value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// boltIterator holds a read transaction open until it is released. Writers
+// which need to grow the memory map wait for it.
+type boltIterator struct {
+	tx           *bolt.Tx
+	c            *bolt.Cursor // nil if the bucket doesn't exist
+	start, limit []byte
+	started      bool
+	key, value   []byte
+}
+
+func (it *boltIterator) Next() bool {
+	if !it.started {
+		return it.Seek(it.start)
+	}
+	it.key, it.value = it.c.Next()
+	return it.valid()
+}
+
+func (it *boltIterator) Seek(key []byte) bool {
+	if it.c == nil {
+		return false
+	}
+	it.started = true
+	if bytes.Compare(key, it.start) < 0 {
+		key = it.start
+	}
+	it.key, it.value = it.c.Seek(key)
+	return it.valid()
+}
+
+// Last moves to the last key before the limit.
+func (it *boltIterator) Last() bool {
+	if it.c == nil {
+		return false
+	}
+	it.started = true
+	if it.limit == nil {
+		it.key, it.value = it.c.Last()
+	} else if k, _ := it.c.Seek(it.limit); k == nil {
+		it.key, it.value = it.c.Last()
+	} else {
+		it.key, it.value = it.c.Prev()
+	}
+	return it.valid()
+}
+
+func (it *boltIterator) Prev() bool {
+	if !it.started {
+		return false
+	}
+	it.key, it.value = it.c.Prev()
+	return it.valid()
+}
+
+func (it *boltIterator) valid() bool {
+	if it.key == nil {
+		return false
+	}
+	if it.limit != nil && bytes.Compare(it.key, it.limit) >= 0 {
+		it.key, it.value = nil, nil
+		return false
+	}
+	if bytes.Compare(it.key, it.start) < 0 {
+		it.key, it.value = nil, nil
+		return false
+	}
+	return true
+}
+
+func (it *boltIterator) Key() []byte   { return it.key }
+func (it *boltIterator) Value() []byte { return it.value }
+func (it *boltIterator) Error() error  { return nil }
+func (it *boltIterator) Release()      { it.tx.Rollback() }
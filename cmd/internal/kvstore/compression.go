@@ -0,0 +1,78 @@
+package kvstore
+
+import "github.com/syndtr/goleveldb/leveldb/opt"
+
+// CompressionNames are the block compression algorithms known to the engines.
+var CompressionNames = []string{"none", "snappy", "zlib", "lz4", "zstd"}
+
+// compressionSupport is the block compression an engine can be configured with.
+type compressionSupport struct {
+	perLevel bool     // levels can use different algorithms
+	names    []string // supported algorithms
+}
+
+// compressionEngines are the engines which take Options.Compression. goleveldb
+// only has a single setting for all levels and only implements snappy.
+var compressionEngines = map[string]compressionSupport{
+	Default: {names: []string{"none", "snappy"}},
+	Memory:  {names: []string{"none", "snappy"}},
+}
+
+// registerCompression declares the block compression supported by an engine.
+// It is meant to be called from init functions, next to Register.
+func registerCompression(name string, perLevel bool, names ...string) {
+	engineMu.Lock()
+	defer engineMu.Unlock()
+	compressionEngines[name] = compressionSupport{perLevel, names}
+}
+
+// CompressionSupport returns the reason why an engine can't run with the given
+// per-level compression, or the empty string if it can.
+func CompressionSupport(name string, levels []string) string {
+	engineMu.Lock()
+	s, ok := compressionEngines[name]
+	engineMu.Unlock()
+	if !ok {
+		return name + " doesn't support setting the compression"
+	}
+	for _, l := range levels {
+		if !containsString(s.names, l) {
+			return name + " doesn't support " + l + " compression"
+		}
+		if l != levels[0] && !s.perLevel {
+			return name + " doesn't support per-level compression"
+		}
+	}
+	return ""
+}
+
+// levelCompression returns the compression of level i. The last entry of
+// levels applies to all remaining levels.
+func levelCompression(levels []string, i int) string {
+	if i < len(levels) {
+		return levels[i]
+	}
+	return levels[len(levels)-1]
+}
+
+// levelDBCompression applies Options.Compression to the goleveldb options.
+func levelDBCompression(o Options) *opt.Options {
+	if len(o.Compression) == 0 {
+		return o.LevelDB
+	}
+	cpy := *o.LevelDB
+	cpy.Compression = opt.SnappyCompression
+	if o.Compression[0] == "none" {
+		cpy.Compression = opt.NoCompression
+	}
+	return &cpy
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,261 @@
+package kvstore
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	bench "github.com/fjl/goleveldb-bench"
+)
+
+func init() {
+	Register("flatfile", openFlatFile)
+}
+
+// flatFile is the baseline engine. Every write is appended to a single log
+// file with one write call, and an index in memory points to the values. Reads
+// are one pread. This is about the least work a store can do on a filesystem,
+// so results of the other engines can be checked against it.
+//
+// Log records are the key and value lengths as 32 bit big endian integers,
+// followed by the key and value. Deletions have a value length of
+// flatDeleted. Space is never reclaimed.
+type flatFile struct {
+	mu     sync.RWMutex
+	f      *os.File
+	end    int64 // size of the log
+	index  map[string]flatEntry
+	noSync bool
+	buf    []byte
+}
+
+type flatEntry struct {
+	offset int64 // of the value
+	size   uint32
+}
+
+const flatDeleted = ^uint32(0)
+
+func openFlatFile(dir string, o Options) (Store, error) {
+	if err := noStorage("flatfile", o); err != nil {
+		return nil, err
+	}
+	lo := o.LevelDB
+	flag := os.O_RDWR | os.O_CREATE
+	if lo.GetReadOnly() {
+		flag = os.O_RDONLY
+	} else if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "flatfile.log"), flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+	db := &flatFile{f: f, index: make(map[string]flatEntry), noSync: lo.GetNoSync()}
+	if err := db.replay(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// replay builds the index from the log. A partial record at the end, left by a
+// crash, is ignored and overwritten by the next write.
+func (db *flatFile) replay() error {
+	r := bufio.NewReaderSize(db.f, 1<<20)
+	var hdr [8]byte
+	for {
+		if _, err := io.ReadFull(r, hdr[:]); err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		klen, vlen := binary.BigEndian.Uint32(hdr[:4]), binary.BigEndian.Uint32(hdr[4:])
+		size := int64(klen)
+		if vlen != flatDeleted {
+			size += int64(vlen)
+		}
+		rec := make([]byte, size)
+		if _, err := io.ReadFull(r, rec); err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		key := string(rec[:klen])
+		if vlen == flatDeleted {
+			delete(db.index, key)
+		} else {
+			db.index[key] = flatEntry{db.end + 8 + int64(klen), vlen}
+		}
+		db.end += 8 + size
+	}
+}
+
+// appendRecord adds a log record to buf.
+func appendRecord(buf, key, value []byte, delete bool) []byte {
+	var hdr [8]byte
+	binary.BigEndian.PutUint32(hdr[:4], uint32(len(key)))
+	if delete {
+		binary.BigEndian.PutUint32(hdr[4:], flatDeleted)
+	} else {
+		binary.BigEndian.PutUint32(hdr[4:], uint32(len(value)))
+	}
+	buf = append(buf, hdr[:]...)
+	buf = append(buf, key...)
+	if !delete {
+		buf = append(buf, value...)
+	}
+	return buf
+}
+
+// write appends the records of ops, which are in db.buf, to the log and applies
+// them to the index. It is called with db.mu held.
+func (db *flatFile) write(ops []batchOp, wo *WriteOptions) error {
+	if _, err := db.f.WriteAt(db.buf, db.end); err != nil {
+		return err
+	}
+	offset := db.end
+	for _, op := range ops {
+		offset += 8 + int64(len(op.key))
+		if op.delete {
+			delete(db.index, string(op.key))
+		} else {
+			db.index[string(op.key)] = flatEntry{offset, uint32(len(op.value))}
+			offset += int64(len(op.value))
+		}
+	}
+	db.end = offset
+	if wo != nil && wo.Sync && !db.noSync {
+		return db.f.Sync()
+	}
+	return nil
+}
+
+func (db *flatFile) Put(key, value []byte, wo *WriteOptions) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.buf = appendRecord(db.buf[:0], key, value, false)
+	return db.write([]batchOp{{key: key, value: value}}, wo)
+}
+
+func (db *flatFile) Get(key []byte) ([]byte, error) {
+	db.mu.RLock()
+	e, ok := db.index[string(key)]
+	db.mu.RUnlock()
+	if !ok {
+		return nil, bench.ErrNotFound
+	}
+	return db.read(e)
+}
+
+func (db *flatFile) read(e flatEntry) ([]byte, error) {
+	v := make([]byte, e.size)
+	if _, err := db.f.ReadAt(v, e.offset); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (db *flatFile) NewBatch() Batch {
+	return &flatBatch{db: db}
+}
+
+// Iterate sorts the keys of the range when it is called. Later writes aren't
+// visible to the iterator.
+func (db *flatFile) Iterate(start, limit []byte) Iterator {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	it := &flatIterator{db: db, pos: -1}
+	for k, e := range db.index {
+		if k < string(start) || limit != nil && k >= string(limit) {
+			continue
+		}
+		it.keys = append(it.keys, k)
+		it.entries = append(it.entries, e)
+	}
+	sort.Sort(it)
+	return it
+}
+
+func (db *flatFile) Close() error {
+	return db.f.Close()
+}
+
+// flatBatch appends all writes with one write call.
+type flatBatch struct {
+	opBatch
+	db *flatFile
+}
+
+func (b *flatBatch) Write(wo *WriteOptions) error {
+	b.db.mu.Lock()
+	defer b.db.mu.Unlock()
+	buf := b.db.buf[:0]
+	for _, op := range b.ops {
+		buf = appendRecord(buf, op.key, op.value, op.delete)
+	}
+	b.db.buf = buf
+	return b.db.write(b.ops, wo)
+}
+
+// flatIterator iterates over a sorted copy of the index.
+type flatIterator struct {
+	db      *flatFile
+	keys    []string
+	entries []flatEntry
+	pos     int
+	key     []byte
+	value   []byte
+	err     error
+}
+
+func (it *flatIterator) Len() int           { return len(it.keys) }
+func (it *flatIterator) Less(i, j int) bool { return it.keys[i] < it.keys[j] }
+
+func (it *flatIterator) Swap(i, j int) {
+	it.keys[i], it.keys[j] = it.keys[j], it.keys[i]
+	it.entries[i], it.entries[j] = it.entries[j], it.entries[i]
+}
+
+func (it *flatIterator) Next() bool { return it.move(it.pos + 1) }
+func (it *flatIterator) Prev() bool { return it.pos >= 0 && it.move(it.pos-1) }
+func (it *flatIterator) Last() bool { return it.move(len(it.keys) - 1) }
+
+func (it *flatIterator) Seek(key []byte) bool {
+	return it.move(sort.SearchStrings(it.keys, string(key)))
+}
+
+func (it *flatIterator) move(pos int) bool {
+	it.key, it.value = nil, nil
+	if it.err != nil {
+		return false
+	}
+	if pos < 0 {
+		it.pos = -1
+		return false
+	}
+	if pos >= len(it.keys) {
+		it.pos = len(it.keys)
+		return false
+	}
+	it.pos = pos
+	it.key = []byte(it.keys[pos])
+	return true
+}
+
+func (it *flatIterator) Key() []byte { return it.key }
+
+// Value reads the value of the current key from the log on first access.
+func (it *flatIterator) Value() []byte {
+	if it.value == nil && it.err == nil && it.key != nil {
+		it.value, it.err = it.db.read(it.entries[it.pos])
+	}
+	return it.value
+}
+
+func (it *flatIterator) Error() error { return it.err }
+func (it *flatIterator) Release()     { it.keys, it.entries = nil, nil }
@@ -0,0 +1,281 @@
+// Package kvstore is the storage engine abstraction of the benchmark commands.
+// Tests are written against Store, and the engine is selected by name, so the
+// same workload can run on different databases.
+package kvstore
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/fjl/goleveldb-bench/cmd/internal/ldbstore"
+	"github.com/syndtr/goleveldb/leveldb/filter"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// Default is the name of the goleveldb engine, which is used when no engine is
+// selected.
+const Default = "leveldb"
+
+// Memory is the name of the engine running goleveldb on memory storage. It
+// measures the CPU cost of goleveldb without disk I/O.
+const Memory = "memory"
+
+// Store is an open database.
+type Store interface {
+	// Put stores a key. wo may be nil.
+	Put(key, value []byte, wo *WriteOptions) error
+	// Get returns the value of a key, or bench.ErrNotFound.
+	Get(key []byte) ([]byte, error)
+	// NewBatch creates an empty write batch.
+	NewBatch() Batch
+	// Iterate returns an iterator over the keys in [start, limit). Nil bounds
+	// are unlimited.
+	Iterate(start, limit []byte) Iterator
+	Close() error
+}
+
+// Batch collects writes which are applied atomically.
+type Batch interface {
+	Put(key, value []byte)
+	Delete(key []byte)
+	Len() int // number of writes in the batch
+	Reset()
+	// Write applies the batch to the store. wo may be nil.
+	Write(wo *WriteOptions) error
+}
+
+// Iterator iterates keys in ascending order. It is positioned before the first
+// key when created.
+type Iterator interface {
+	Next() bool
+	// Seek moves to the first key at or after key.
+	Seek(key []byte) bool
+	Key() []byte
+	Value() []byte
+	Error() error
+	Release()
+}
+
+// ReverseIterator is implemented by iterators which can also move backwards.
+type ReverseIterator interface {
+	Iterator
+	Last() bool
+	Prev() bool
+}
+
+// Compacter is implemented by stores with manual compaction.
+type Compacter interface {
+	// CompactRange compacts the keys in [start, limit). Nil bounds are
+	// unlimited.
+	CompactRange(start, limit []byte) error
+}
+
+// Sizer is implemented by stores which can estimate their size on disk.
+type Sizer interface {
+	// SizeOf returns the estimated size of the keys in [start, limit). Nil
+	// bounds are unlimited.
+	SizeOf(start, limit []byte) (uint64, error)
+}
+
+// StatsReporter is implemented by stores reporting compaction statistics.
+type StatsReporter interface {
+	CompactionStats() (*bench.CompactionStats, error)
+}
+
+// WriteOptions are the options of a single write.
+type WriteOptions struct {
+	Sync         bool // wait until the write is on disk
+	NoWriteMerge bool // goleveldb only
+}
+
+// Options configure an engine when opening a store.
+type Options struct {
+	// LevelDB are the goleveldb options of the test. Other engines apply the
+	// settings they have an equivalent for, like cache sizes and NoSync.
+	LevelDB *opt.Options
+	// Storage are wrappers of the goleveldb storage. Engines not built on
+	// goleveldb storage fail to open when any are given.
+	Storage []ldbstore.Wrapper
+	// Read are the goleveldb options of all reads and iterators. Other engines
+	// ignore them.
+	Read *opt.ReadOptions
+	// Compression is the block compression of each level, by the names in
+	// CompressionNames. The last entry applies to all remaining levels. If set,
+	// it overrides the compression of LevelDB. Engines which can't run it
+	// fail to open, see CompressionSupport.
+	Compression []string
+}
+
+// Engine opens a store in a directory.
+type Engine func(dir string, o Options) (Store, error)
+
+var (
+	engineMu sync.Mutex
+	engines  = map[string]Engine{
+		Default: openLevelDB,
+	}
+)
+
+// Register adds an engine. It is meant to be called from init functions and
+// panics if the name is already taken.
+func Register(name string, e Engine) {
+	engineMu.Lock()
+	defer engineMu.Unlock()
+	if _, ok := engines[name]; ok {
+		panic("storage engine " + name + " already registered")
+	}
+	engines[name] = e
+}
+
+// Names returns the names of all registered engines.
+func Names() []string {
+	engineMu.Lock()
+	defer engineMu.Unlock()
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Check returns an error if the engine isn't registered.
+func Check(name string) error {
+	engineMu.Lock()
+	defer engineMu.Unlock()
+	if _, ok := engines[name]; !ok {
+		return fmt.Errorf("unknown storage engine %q", name)
+	}
+	return nil
+}
+
+// IsLevelDB reports whether the engine is goleveldb, on disk or in memory. All
+// goleveldb options and storage wrappers apply to these engines.
+func IsLevelDB(name string) bool {
+	return name == Default || name == Memory
+}
+
+// TestDir returns the directory of a test database. Databases of other engines
+// are kept apart from goleveldb databases, engines can't open each other's files.
+func TestDir(base, engine, test string) string {
+	if engine != Default {
+		test = engine + "-" + test
+	}
+	return filepath.Join(base, "testdb-"+test)
+}
+
+// Open opens a store using the named engine.
+func Open(name, dir string, o Options) (Store, error) {
+	engineMu.Lock()
+	e, ok := engines[name]
+	engineMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown storage engine %q", name)
+	}
+	if o.LevelDB == nil {
+		o.LevelDB = new(opt.Options)
+	}
+	if len(o.Compression) > 0 {
+		if reason := CompressionSupport(name, o.Compression); reason != "" {
+			return nil, errors.New(reason)
+		}
+	}
+	return e(dir, o)
+}
+
+// noStorage returns an error if storage wrappers are given to an engine which
+// isn't built on goleveldb storage.
+func noStorage(name string, o Options) error {
+	for _, w := range o.Storage {
+		if w != nil {
+			return fmt.Errorf("storage engine %s doesn't support goleveldb storage wrappers", name)
+		}
+	}
+	return nil
+}
+
+// endKey returns a key beyond the last key of the store, for engines which
+// don't accept unlimited ranges. It returns nil if the store is empty.
+func endKey(s Store) ([]byte, error) {
+	it, ok := s.Iterate(nil, nil).(ReverseIterator)
+	if !ok {
+		return nil, fmt.Errorf("can't find the last key")
+	}
+	defer it.Release()
+	if !it.Last() {
+		return nil, it.Error()
+	}
+	return append(append([]byte(nil), it.Key()...), 0), nil
+}
+
+// BloomBits returns the bits per key of a goleveldb bloom filter, or zero for
+// other filters. goleveldb doesn't export the filter type, which is an int
+// holding the bits.
+func BloomBits(f filter.Filter) int {
+	if f == nil || f.Name() != "leveldb.BuiltinBloomFilter" {
+		return 0
+	}
+	if v := reflect.ValueOf(f); v.Kind() == reflect.Int {
+		return int(v.Int())
+	}
+	return 0
+}
+
+// filterBits returns the bits per key of the bloom filter replacing f in engines
+// other than goleveldb.
+func filterBits(f filter.Filter) int {
+	if bits := BloomBits(f); bits > 0 {
+		return bits
+	}
+	return 10
+}
+
+// opBatch collects the writes of a batch for engines which apply them in Write.
+type opBatch struct {
+	ops []batchOp
+}
+
+type batchOp struct {
+	key, value []byte
+	delete     bool
+}
+
+func (b *opBatch) Put(key, value []byte) {
+	b.ops = append(b.ops, batchOp{key: copyBytes(key), value: copyBytes(value)})
+}
+
+func (b *opBatch) Delete(key []byte) {
+	b.ops = append(b.ops, batchOp{key: copyBytes(key), delete: true})
+}
+
+func (b *opBatch) Len() int { return len(b.ops) }
+func (b *opBatch) Reset()   { b.ops = b.ops[:0] }
+
+func copyBytes(b []byte) []byte {
+	return append([]byte(nil), b...)
+}
+
+// quietLogger is the logger of engines. It drops informational messages, which
+// would get in the way of the progress output.
+type quietLogger struct{}
+
+func (quietLogger) Infof(format string, args ...interface{})  {}
+func (quietLogger) Debugf(format string, args ...interface{}) {}
+
+func (quietLogger) Warningf(format string, args ...interface{}) {
+	log.Printf("warning: "+format, args...)
+}
+
+func (quietLogger) Errorf(format string, args ...interface{}) {
+	log.Printf("error: "+format, args...)
+}
+
+func (quietLogger) Fatalf(format string, args ...interface{}) {
+	log.Fatalf(format, args...)
+}
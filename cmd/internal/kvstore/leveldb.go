@@ -0,0 +1,134 @@
+package kvstore
+
+import (
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/fjl/goleveldb-bench/cmd/internal/ldbstore"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+func init() {
+	Register(Memory, openMemory)
+}
+
+// levelDB is the goleveldb engine.
+type levelDB struct {
+	db *ldbstore.DB
+	ro *opt.ReadOptions
+}
+
+func openLevelDB(dir string, o Options) (Store, error) {
+	db, err := ldbstore.Open(dir, levelDBCompression(o), o.Storage...)
+	if err != nil {
+		return nil, err
+	}
+	return &levelDB{db, o.Read}, nil
+}
+
+// openMemory opens goleveldb on memory storage, which isn't kept when the
+// store is closed. The directory isn't used.
+func openMemory(dir string, o Options) (Store, error) {
+	db, err := ldbstore.OpenStorage(storage.NewMemStorage(), levelDBCompression(o), o.Storage...)
+	if err != nil {
+		return nil, err
+	}
+	return &levelDB{db, o.Read}, nil
+}
+
+// LevelDB returns the goleveldb database of a store opened by the goleveldb
+// or memory engine, or nil for other engines.
+func LevelDB(s Store) *leveldb.DB {
+	if l, ok := s.(*levelDB); ok {
+		return l.db.DB
+	}
+	return nil
+}
+
+func levelWriteOptions(wo *WriteOptions) *opt.WriteOptions {
+	if wo == nil {
+		return nil
+	}
+	return &opt.WriteOptions{Sync: wo.Sync, NoWriteMerge: wo.NoWriteMerge}
+}
+
+func (l *levelDB) Put(key, value []byte, wo *WriteOptions) error {
+	return l.db.Put(key, value, levelWriteOptions(wo))
+}
+
+func (l *levelDB) Get(key []byte) ([]byte, error) {
+	v, err := l.db.Get(key, l.ro)
+	if err == leveldb.ErrNotFound {
+		err = bench.ErrNotFound
+	}
+	return v, err
+}
+
+func (l *levelDB) NewBatch() Batch {
+	return &levelBatch{db: l.db.DB}
+}
+
+func (l *levelDB) Iterate(start, limit []byte) Iterator {
+	var r *util.Range
+	if start != nil || limit != nil {
+		r = &util.Range{Start: start, Limit: limit}
+	}
+	return l.db.NewIterator(r, l.ro)
+}
+
+func (l *levelDB) CompactRange(start, limit []byte) error {
+	return l.db.CompactRange(util.Range{Start: start, Limit: limit})
+}
+
+// SizeOf returns the size of the tables overlapping the range. goleveldb
+// doesn't accept a nil limit, it is replaced by the successor of the last key.
+func (l *levelDB) SizeOf(start, limit []byte) (uint64, error) {
+	if limit == nil {
+		var err error
+		if limit, err = endKey(l); err != nil || limit == nil {
+			return 0, err
+		}
+	}
+	sizes, err := l.db.SizeOf([]util.Range{{Start: start, Limit: limit}})
+	if err != nil {
+		return 0, err
+	}
+	return uint64(sizes.Sum()), nil
+}
+
+func (l *levelDB) CompactionStats() (*bench.CompactionStats, error) {
+	var s leveldb.DBStats
+	if err := l.db.Stats(&s); err != nil {
+		return nil, err
+	}
+	c := &bench.CompactionStats{
+		Levels:      make([]bench.LevelStats, len(s.LevelSizes)),
+		WriteDelays: int(s.WriteDelayCount),
+		WriteDelay:  s.WriteDelayDuration,
+		WritePaused: s.WritePaused,
+	}
+	for i := range c.Levels {
+		c.Levels[i] = bench.LevelStats{
+			Tables:   s.LevelTablesCounts[i],
+			Size:     s.LevelSizes[i],
+			Read:     s.LevelRead[i],
+			Write:    s.LevelWrite[i],
+			Duration: s.LevelDurations[i],
+		}
+	}
+	return c, nil
+}
+
+func (l *levelDB) Close() error {
+	return l.db.Close()
+}
+
+type levelBatch struct {
+	leveldb.Batch
+	db *leveldb.DB
+}
+
+func (b *levelBatch) Write(wo *WriteOptions) error {
+	return b.db.Write(&b.Batch, levelWriteOptions(wo))
+}
@@ -0,0 +1,234 @@
+//go:build cgo
+// +build cgo
+
+package kvstore
+
+import (
+	"bytes"
+	"os"
+
+	"github.com/bmatsuo/lmdb-go/lmdb"
+	bench "github.com/fjl/goleveldb-bench"
+)
+
+// LMDB is compiled from the C sources bundled with lmdb-go, the engine is
+// available whenever cgo is.
+func init() {
+	Register("lmdb", openLMDB)
+}
+
+// lmdbMapSize is the size of the memory map, which limits the database size.
+// Only the used part takes up disk space.
+const lmdbMapSize = 1 << 40
+
+// lmdbDB is the LMDB engine, a copy-on-write B+tree in a memory mapped file.
+// Keys are stored in the unnamed root database.
+type lmdbDB struct {
+	env    *lmdb.Env
+	dbi    lmdb.DBI
+	noSync bool
+}
+
+// openLMDB opens the environment in dir. Like with bbolt, commits are not synced
+// unless a write asks for it. In LMDB's NoSync mode, a crash can undo the last
+// transactions, but doesn't corrupt the database.
+func openLMDB(dir string, o Options) (Store, error) {
+	if err := noStorage("lmdb", o); err != nil {
+		return nil, err
+	}
+	lo := o.LevelDB
+	env, err := lmdb.NewEnv()
+	if err != nil {
+		return nil, err
+	}
+	// Read transactions run on goroutines which aren't locked to an OS thread,
+	// so reader slots must not be tied to threads.
+	flags := uint(lmdb.NoSync | lmdb.NoTLS)
+	if lo.GetReadOnly() {
+		flags |= lmdb.Readonly
+	} else if err := os.MkdirAll(dir, 0755); err != nil {
+		env.Close()
+		return nil, err
+	}
+	if err := env.SetMapSize(lmdbMapSize); err != nil {
+		env.Close()
+		return nil, err
+	}
+	if err := env.SetMaxReaders(1024); err != nil {
+		env.Close()
+		return nil, err
+	}
+	if err := env.Open(dir, flags, 0644); err != nil {
+		env.Close()
+		return nil, err
+	}
+	db := &lmdbDB{env: env, noSync: lo.GetNoSync()}
+	open := env.Update
+	if lo.GetReadOnly() {
+		open = env.View
+	}
+	err = open(func(txn *lmdb.Txn) (err error) {
+		db.dbi, err = txn.OpenRoot(0)
+		return err
+	})
+	if err != nil {
+		env.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// update runs fn in a write transaction and syncs the environment if requested.
+func (l *lmdbDB) update(wo *WriteOptions, fn lmdb.TxnOp) error {
+	if err := l.env.Update(fn); err != nil {
+		return err
+	}
+	if wo != nil && wo.Sync && !l.noSync {
+		return l.env.Sync(true)
+	}
+	return nil
+}
+
+func (l *lmdbDB) Put(key, value []byte, wo *WriteOptions) error {
+	return l.update(wo, func(txn *lmdb.Txn) error {
+		return txn.Put(l.dbi, key, value, 0)
+	})
+}
+
+func (l *lmdbDB) Get(key []byte) (v []byte, err error) {
+	err = l.env.View(func(txn *lmdb.Txn) (err error) {
+		v, err = txn.Get(l.dbi, key)
+		return err
+	})
+	if lmdb.IsNotFound(err) {
+		err = bench.ErrNotFound
+	}
+	return v, err
+}
+
+func (l *lmdbDB) NewBatch() Batch {
+	return &lmdbBatch{db: l}
+}
+
+func (l *lmdbDB) Iterate(start, limit []byte) Iterator {
+	txn, err := l.env.BeginTxn(nil, lmdb.Readonly)
+	if err != nil {
+		return &errIterator{err}
+	}
+	// Keys and values point into the memory map while the transaction is open.
+	txn.RawRead = true
+	c, err := txn.OpenCursor(l.dbi)
+	if err != nil {
+		txn.Abort()
+		return &errIterator{err}
+	}
+	return &lmdbIterator{txn: txn, c: c, start: start, limit: limit}
+}
+
+func (l *lmdbDB) Close() error {
+	return l.env.Close()
+}
+
+// lmdbBatch applies all writes in one transaction.
+type lmdbBatch struct {
+	opBatch
+	db *lmdbDB
+}
+
+func (b *lmdbBatch) Write(wo *WriteOptions) error {
+	return b.db.update(wo, func(txn *lmdb.Txn) error {
+		for _, op := range b.ops {
+			var err error
+			if op.delete {
+				if err = txn.Del(b.db.dbi, op.key, nil); lmdb.IsNotFound(err) {
+					err = nil
+				}
+			} else {
+				err = txn.Put(b.db.dbi, op.key, op.value, 0)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// lmdbIterator holds a read transaction open until it is released. Pages freed
+// by writers can't be reused while it is open.
+type lmdbIterator struct {
+	txn          *lmdb.Txn
+	c            *lmdb.Cursor
+	start, limit []byte
+	started      bool
+	key, value   []byte
+	err          error
+}
+
+func (it *lmdbIterator) Next() bool {
+	if !it.started {
+		return it.Seek(it.start)
+	}
+	return it.get(nil, lmdb.Next)
+}
+
+func (it *lmdbIterator) Seek(key []byte) bool {
+	it.started = true
+	if bytes.Compare(key, it.start) < 0 {
+		key = it.start
+	}
+	if len(key) == 0 {
+		return it.get(nil, lmdb.First)
+	}
+	return it.get(key, lmdb.SetRange)
+}
+
+// Last moves to the last key before the limit.
+func (it *lmdbIterator) Last() bool {
+	it.started = true
+	if it.limit != nil && it.err == nil {
+		_, _, err := it.c.Get(it.limit, nil, lmdb.SetRange)
+		if err == nil {
+			return it.get(nil, lmdb.Prev)
+		} else if !lmdb.IsNotFound(err) {
+			it.err = err
+		}
+	}
+	return it.get(nil, lmdb.Last)
+}
+
+func (it *lmdbIterator) Prev() bool {
+	if !it.started {
+		return false
+	}
+	return it.get(nil, lmdb.Prev)
+}
+
+// get moves the cursor and checks that the key is in range.
+func (it *lmdbIterator) get(setkey []byte, op uint) bool {
+	it.key, it.value = nil, nil
+	if it.err != nil {
+		return false
+	}
+	k, v, err := it.c.Get(setkey, nil, op)
+	if err != nil {
+		if !lmdb.IsNotFound(err) {
+			it.err = err
+		}
+		return false
+	}
+	if it.limit != nil && bytes.Compare(k, it.limit) >= 0 || bytes.Compare(k, it.start) < 0 {
+		return false
+	}
+	it.key, it.value = k, v
+	return true
+}
+
+func (it *lmdbIterator) Key() []byte   { return it.key }
+func (it *lmdbIterator) Value() []byte { return it.value }
+func (it *lmdbIterator) Error() error  { return it.err }
+
+func (it *lmdbIterator) Release() {
+	it.c.Close()
+	it.txn.Abort()
+}
@@ -0,0 +1,225 @@
+package kvstore
+
+import (
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/bloom"
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+func init() {
+	Register("pebble", openPebble)
+	registerCompression("pebble", true, "none", "snappy", "zstd")
+}
+
+// pebbleCompression are the block compression algorithms of pebble.
+var pebbleCompression = map[string]pebble.Compression{
+	"none":   pebble.NoCompression,
+	"snappy": pebble.SnappyCompression,
+	"zstd":   pebble.ZstdCompression,
+}
+
+// pebbleDB is the cockroachdb/pebble engine.
+type pebbleDB struct {
+	db     *pebble.DB
+	noSync bool
+}
+
+// pebbleOptions translates the goleveldb options of a test. Filters other than
+// the goleveldb bloom filter are replaced by a 10 bit bloom filter.
+func pebbleOptions(o *opt.Options) *pebble.Options {
+	po := &pebble.Options{
+		ReadOnly:         o.GetReadOnly(),
+		ErrorIfNotExists: o.GetErrorIfMissing(),
+		MemTableSize:     uint64(o.GetWriteBuffer()),
+		Logger:           quietLogger{},
+	}
+	if o.GetFilter() != nil || o.CompactionTableSize > 0 || o.Compression != opt.DefaultCompression {
+		l := pebble.LevelOptions{TargetFileSize: int64(o.CompactionTableSize)}
+		if o.GetFilter() != nil {
+			l.FilterPolicy = bloom.FilterPolicy(filterBits(o.GetFilter()))
+		}
+		if o.Compression != opt.DefaultCompression {
+			l.Compression = pebbleCompression[o.GetCompression().String()]
+		}
+		po.Levels = []pebble.LevelOptions{l}
+	}
+	if o.OpenFilesCacheCapacity > 0 {
+		po.MaxOpenFiles = o.OpenFilesCacheCapacity
+	}
+	return po
+}
+
+func openPebble(dir string, o Options) (Store, error) {
+	if err := noStorage("pebble", o); err != nil {
+		return nil, err
+	}
+	po := pebbleOptions(o.LevelDB)
+	if len(o.Compression) > 0 {
+		// pebble applies the last level's options to all further levels.
+		if len(po.Levels) == 0 {
+			po.Levels = make([]pebble.LevelOptions, 1)
+		}
+		for len(po.Levels) < len(o.Compression) {
+			po.Levels = append(po.Levels, pebble.LevelOptions{FilterPolicy: po.Levels[0].FilterPolicy})
+		}
+		for i := range po.Levels {
+			po.Levels[i].Compression = pebbleCompression[levelCompression(o.Compression, i)]
+		}
+	}
+	po.Cache = pebble.NewCache(int64(o.LevelDB.GetBlockCacheCapacity()))
+	defer po.Cache.Unref()
+	db, err := pebble.Open(dir, po)
+	if err != nil {
+		return nil, err
+	}
+	return &pebbleDB{db, o.LevelDB.GetNoSync()}, nil
+}
+
+// NoSync can't disable syncs of flushes and compactions in pebble, it only
+// applies to writes.
+func (p *pebbleDB) writeOptions(wo *WriteOptions) *pebble.WriteOptions {
+	if wo != nil && wo.Sync && !p.noSync {
+		return pebble.Sync
+	}
+	return pebble.NoSync
+}
+
+func (p *pebbleDB) Put(key, value []byte, wo *WriteOptions) error {
+	return p.db.Set(key, value, p.writeOptions(wo))
+}
+
+func (p *pebbleDB) Get(key []byte) ([]byte, error) {
+	v, closer, err := p.db.Get(key)
+	if err == pebble.ErrNotFound {
+		return nil, bench.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	// The value is only valid until the closer is called.
+	cpy := append([]byte(nil), v...)
+	return cpy, closer.Close()
+}
+
+func (p *pebbleDB) NewBatch() Batch {
+	return &pebbleBatch{b: p.db.NewBatch(), db: p}
+}
+
+func (p *pebbleDB) Iterate(start, limit []byte) Iterator {
+	it, err := p.db.NewIter(&pebble.IterOptions{LowerBound: start, UpperBound: limit})
+	if err != nil {
+		return &errIterator{err}
+	}
+	return &pebbleIterator{it: it}
+}
+
+// bounds returns explicit bounds for a range, which pebble needs. Empty is true
+// if there are no keys in an unlimited range.
+func (p *pebbleDB) bounds(start, limit []byte) (s, l []byte, empty bool, err error) {
+	if start == nil {
+		start = []byte{}
+	}
+	if limit == nil {
+		if limit, err = endKey(p); err != nil || limit == nil {
+			return nil, nil, true, err
+		}
+	}
+	return start, limit, false, nil
+}
+
+func (p *pebbleDB) CompactRange(start, limit []byte) error {
+	start, limit, empty, err := p.bounds(start, limit)
+	if empty {
+		return err
+	}
+	return p.db.Compact(start, limit, true)
+}
+
+func (p *pebbleDB) SizeOf(start, limit []byte) (uint64, error) {
+	start, limit, empty, err := p.bounds(start, limit)
+	if empty {
+		return 0, err
+	}
+	return p.db.EstimateDiskUsage(start, limit)
+}
+
+// CompactionStats reports the pebble level metrics. Pebble doesn't track
+// compaction time per level, and has no write delay counters.
+func (p *pebbleDB) CompactionStats() (*bench.CompactionStats, error) {
+	m := p.db.Metrics()
+	c := &bench.CompactionStats{Levels: make([]bench.LevelStats, len(m.Levels))}
+	for i, l := range m.Levels {
+		c.Levels[i] = bench.LevelStats{
+			Tables: int(l.NumFiles),
+			Size:   l.Size,
+			Read:   int64(l.BytesRead),
+			Write:  int64(l.BytesFlushed + l.BytesCompacted),
+		}
+	}
+	return c, nil
+}
+
+func (p *pebbleDB) Close() error {
+	return p.db.Close()
+}
+
+type pebbleBatch struct {
+	b  *pebble.Batch
+	db *pebbleDB
+}
+
+func (b *pebbleBatch) Put(key, value []byte) { b.b.Set(key, value, nil) }
+func (b *pebbleBatch) Delete(key []byte)     { b.b.Delete(key, nil) }
+func (b *pebbleBatch) Len() int              { return int(b.b.Count()) }
+func (b *pebbleBatch) Reset()                { b.b.Reset() }
+
+func (b *pebbleBatch) Write(wo *WriteOptions) error {
+	return b.b.Commit(b.db.writeOptions(wo))
+}
+
+// pebbleIterator adapts pebble iterators, which must be positioned before the
+// first call to Next.
+type pebbleIterator struct {
+	it      *pebble.Iterator
+	started bool
+}
+
+func (it *pebbleIterator) Next() bool {
+	if !it.started {
+		it.started = true
+		return it.it.First()
+	}
+	return it.it.Next()
+}
+
+func (it *pebbleIterator) Seek(key []byte) bool {
+	it.started = true
+	return it.it.SeekGE(key)
+}
+
+func (it *pebbleIterator) Last() bool {
+	it.started = true
+	return it.it.Last()
+}
+
+func (it *pebbleIterator) Prev() bool {
+	if !it.started {
+		return false
+	}
+	return it.it.Prev()
+}
+
+func (it *pebbleIterator) Key() []byte   { return it.it.Key() }
+func (it *pebbleIterator) Value() []byte { return it.it.Value() }
+func (it *pebbleIterator) Error() error  { return it.it.Error() }
+func (it *pebbleIterator) Release()      { it.it.Close() }
+
+// errIterator is an empty iterator returning an error.
+type errIterator struct{ err error }
+
+func (it *errIterator) Next() bool           { return false }
+func (it *errIterator) Seek(key []byte) bool { return false }
+func (it *errIterator) Key() []byte          { return nil }
+func (it *errIterator) Value() []byte        { return nil }
+func (it *errIterator) Error() error         { return it.err }
+func (it *errIterator) Release()             {}
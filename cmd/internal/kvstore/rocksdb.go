@@ -0,0 +1,256 @@
+//go:build rocksdb
+// +build rocksdb
+
+package kvstore
+
+import (
+	"bytes"
+	"runtime"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/linxGnu/grocksdb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// The RocksDB engine needs librocksdb and cgo, it is only built with the
+// rocksdb build tag.
+func init() {
+	Register("rocksdb", openRocksDB)
+	registerCompression("rocksdb", true, CompressionNames...)
+}
+
+// rocksCompression are the block compression algorithms of RocksDB.
+var rocksCompression = map[string]grocksdb.CompressionType{
+	"none":   grocksdb.NoCompression,
+	"snappy": grocksdb.SnappyCompression,
+	"zlib":   grocksdb.ZLibCompression,
+	"lz4":    grocksdb.LZ4Compression,
+	"zstd":   grocksdb.ZSTDCompression,
+}
+
+// rocksDB is the RocksDB engine, through linxGnu/grocksdb.
+type rocksDB struct {
+	db      *grocksdb.DB
+	opts    *grocksdb.Options
+	bbto    *grocksdb.BlockBasedTableOptions
+	cache   *grocksdb.Cache
+	ro      *grocksdb.ReadOptions
+	wo, wos *grocksdb.WriteOptions // unsynced and synced writes
+}
+
+// rocksOptions translates the goleveldb options of a test. Like for pebble,
+// filters become bloom filters of the same size.
+func rocksOptions(o *opt.Options) (*grocksdb.Options, *grocksdb.BlockBasedTableOptions, *grocksdb.Cache) {
+	cache := grocksdb.NewLRUCache(uint64(o.GetBlockCacheCapacity()))
+	bbto := grocksdb.NewDefaultBlockBasedTableOptions()
+	bbto.SetBlockCache(cache)
+	if o.GetFilter() != nil {
+		bbto.SetFilterPolicy(grocksdb.NewBloomFilter(float64(filterBits(o.GetFilter()))))
+	}
+	opts := grocksdb.NewDefaultOptions()
+	opts.SetBlockBasedTableFactory(bbto)
+	opts.SetCreateIfMissing(!o.GetErrorIfMissing())
+	opts.SetErrorIfExists(o.GetErrorIfExist())
+	opts.SetWriteBufferSize(uint64(o.GetWriteBuffer()))
+	opts.SetInfoLogLevel(grocksdb.ErrorInfoLogLevel)
+	if o.CompactionTableSize > 0 {
+		opts.SetTargetFileSizeBase(uint64(o.CompactionTableSize))
+	}
+	if o.GetCompression() == opt.NoCompression {
+		opts.SetCompression(grocksdb.NoCompression)
+	} else {
+		opts.SetCompression(grocksdb.SnappyCompression)
+	}
+	return opts, bbto, cache
+}
+
+func openRocksDB(dir string, o Options) (Store, error) {
+	if err := noStorage("rocksdb", o); err != nil {
+		return nil, err
+	}
+	opts, bbto, cache := rocksOptions(o.LevelDB)
+	if len(o.Compression) > 0 {
+		levels := make([]grocksdb.CompressionType, opts.GetNumLevels())
+		for i := range levels {
+			levels[i] = rocksCompression[levelCompression(o.Compression, i)]
+		}
+		opts.SetCompressionPerLevel(levels)
+	}
+	var (
+		db  *grocksdb.DB
+		err error
+	)
+	if o.LevelDB.GetReadOnly() {
+		db, err = grocksdb.OpenDbForReadOnly(opts, dir, false)
+	} else {
+		db, err = grocksdb.OpenDb(opts, dir)
+	}
+	if err != nil {
+		opts.Destroy()
+		bbto.Destroy()
+		cache.Destroy()
+		return nil, err
+	}
+	r := &rocksDB{
+		db:    db,
+		opts:  opts,
+		bbto:  bbto,
+		cache: cache,
+		ro:    grocksdb.NewDefaultReadOptions(),
+		wo:    grocksdb.NewDefaultWriteOptions(),
+		wos:   grocksdb.NewDefaultWriteOptions(),
+	}
+	r.wos.SetSync(!o.LevelDB.GetNoSync())
+	return r, nil
+}
+
+func (r *rocksDB) writeOptions(wo *WriteOptions) *grocksdb.WriteOptions {
+	if wo != nil && wo.Sync {
+		return r.wos
+	}
+	return r.wo
+}
+
+func (r *rocksDB) Put(key, value []byte, wo *WriteOptions) error {
+	return r.db.Put(r.writeOptions(wo), key, value)
+}
+
+func (r *rocksDB) Get(key []byte) ([]byte, error) {
+	v, err := r.db.GetBytes(r.ro, key)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, bench.ErrNotFound
+	}
+	return v, nil
+}
+
+func (r *rocksDB) NewBatch() Batch {
+	b := &rocksBatch{b: grocksdb.NewWriteBatch(), db: r}
+	runtime.SetFinalizer(b, func(b *rocksBatch) { b.b.Destroy() })
+	return b
+}
+
+func (r *rocksDB) Iterate(start, limit []byte) Iterator {
+	ro := grocksdb.NewDefaultReadOptions()
+	if start != nil {
+		ro.SetIterateLowerBound(start)
+	}
+	if limit != nil {
+		ro.SetIterateUpperBound(limit)
+	}
+	return &rocksIterator{it: r.db.NewIterator(ro), ro: ro, start: start}
+}
+
+// CompactRange compacts the range synchronously, RocksDB reports no errors.
+func (r *rocksDB) CompactRange(start, limit []byte) error {
+	r.db.CompactRange(grocksdb.Range{Start: start, Limit: limit})
+	return nil
+}
+
+// SizeOf returns the approximate size of the tables overlapping the range. Like
+// in goleveldb, the limit must be a key.
+func (r *rocksDB) SizeOf(start, limit []byte) (uint64, error) {
+	if limit == nil {
+		var err error
+		if limit, err = endKey(r); err != nil || limit == nil {
+			return 0, err
+		}
+	}
+	sizes, err := r.db.GetApproximateSizes([]grocksdb.Range{{Start: start, Limit: limit}})
+	if err != nil {
+		return 0, err
+	}
+	return sizes[0], nil
+}
+
+func (r *rocksDB) Close() error {
+	r.db.Close()
+	r.ro.Destroy()
+	r.wo.Destroy()
+	r.wos.Destroy()
+	r.opts.Destroy()
+	r.bbto.Destroy()
+	r.cache.Destroy()
+	return nil
+}
+
+// rocksBatch wraps a RocksDB write batch. Batches have no Close method, the C
+// memory is freed by a finalizer.
+type rocksBatch struct {
+	b  *grocksdb.WriteBatch
+	db *rocksDB
+}
+
+func (b *rocksBatch) Put(key, value []byte) { b.b.Put(key, value) }
+func (b *rocksBatch) Delete(key []byte)     { b.b.Delete(key) }
+func (b *rocksBatch) Len() int              { return b.b.Count() }
+func (b *rocksBatch) Reset()                { b.b.Clear() }
+
+func (b *rocksBatch) Write(wo *WriteOptions) error {
+	return b.db.db.Write(b.db.writeOptions(wo), b.b)
+}
+
+// rocksIterator adapts RocksDB iterators, which must be positioned before the
+// first call to Next. Keys and values point into C memory, they are valid until
+// the iterator moves.
+type rocksIterator struct {
+	it         *grocksdb.Iterator
+	ro         *grocksdb.ReadOptions
+	start      []byte
+	started    bool
+	key, value []byte
+}
+
+func (it *rocksIterator) Next() bool {
+	if !it.started {
+		it.started = true
+		it.it.SeekToFirst()
+	} else {
+		it.it.Next()
+	}
+	return it.valid()
+}
+
+func (it *rocksIterator) Seek(key []byte) bool {
+	it.started = true
+	if bytes.Compare(key, it.start) < 0 {
+		key = it.start
+	}
+	it.it.Seek(key)
+	return it.valid()
+}
+
+func (it *rocksIterator) Last() bool {
+	it.started = true
+	it.it.SeekToLast()
+	return it.valid()
+}
+
+func (it *rocksIterator) Prev() bool {
+	if !it.started {
+		return false
+	}
+	it.it.Prev()
+	return it.valid()
+}
+
+func (it *rocksIterator) valid() bool {
+	it.key, it.value = nil, nil
+	if !it.it.Valid() {
+		return false
+	}
+	it.key = it.it.Key().Data()
+	it.value = it.it.Value().Data()
+	return true
+}
+
+func (it *rocksIterator) Key() []byte   { return it.key }
+func (it *rocksIterator) Value() []byte { return it.value }
+func (it *rocksIterator) Error() error  { return it.it.Err() }
+
+func (it *rocksIterator) Release() {
+	it.it.Close()
+	it.ro.Destroy()
+}
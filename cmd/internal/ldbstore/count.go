@@ -0,0 +1,193 @@
+package ldbstore
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+)
+
+// IOCounter counts the bytes written to and read from the files of a storage by
+// file type. It is safe for concurrent use and can count for several storages,
+// e.g. when a test reopens the database.
+type IOCounter struct {
+	written, read [4]uint64 // manifest, journal, table, temp
+
+	mu     sync.Mutex
+	tables map[int64]*uint64 // bytes read per table file number
+}
+
+// NewIOCounter creates a counter.
+func NewIOCounter() *IOCounter {
+	return new(IOCounter)
+}
+
+// Wrapper returns a storage wrapper counting into c. The wrapper of a nil
+// counter is nil.
+func (c *IOCounter) Wrapper() Wrapper {
+	if c == nil {
+		return nil
+	}
+	return func(s storage.Storage) storage.Storage { return &countingStorage{s, c} }
+}
+
+// Written returns the bytes written so far.
+func (c *IOCounter) Written() bench.StorageIO {
+	return loadIO(&c.written)
+}
+
+// Read returns the bytes read so far.
+func (c *IOCounter) Read() bench.StorageIO {
+	return loadIO(&c.read)
+}
+
+// LevelReads returns the bytes read so far from the tables of each level, given
+// the level of every table file. Tables missing in levels, e.g. because they
+// were compacted away, aren't counted.
+func (c *IOCounter) LevelReads(levels map[int64]int) []uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var reads []uint64
+	for num, n := range c.tables {
+		level, ok := levels[num]
+		if !ok {
+			continue
+		}
+		for len(reads) <= level {
+			reads = append(reads, 0)
+		}
+		reads[level] += atomic.LoadUint64(n)
+	}
+	return reads
+}
+
+// tableCounter returns the read counter of a table file.
+func (c *IOCounter) tableCounter(num int64) *uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tables == nil {
+		c.tables = make(map[int64]*uint64)
+	}
+	n := c.tables[num]
+	if n == nil {
+		n = new(uint64)
+		c.tables[num] = n
+	}
+	return n
+}
+
+// TableLevels returns the level of every table file of the database.
+func TableLevels(db *leveldb.DB) (map[int64]int, error) {
+	prop, err := db.GetProperty("leveldb.sstables")
+	if err != nil {
+		return nil, err
+	}
+	levels := make(map[int64]int)
+	level := -1
+	for _, line := range strings.Split(prop, "\n") {
+		if strings.HasPrefix(line, "--- level ") {
+			if _, err := fmt.Sscanf(line, "--- level %d ---", &level); err != nil {
+				return nil, fmt.Errorf("invalid sstables line %q", line)
+			}
+			continue
+		}
+		i := strings.IndexByte(line, ':')
+		if i < 0 || level < 0 {
+			continue
+		}
+		num, err := strconv.ParseInt(line[:i], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sstables line %q", line)
+		}
+		levels[num] = level
+	}
+	return levels, nil
+}
+
+func loadIO(n *[4]uint64) bench.StorageIO {
+	return bench.StorageIO{
+		Manifest: atomic.LoadUint64(&n[0]),
+		Journal:  atomic.LoadUint64(&n[1]),
+		Table:    atomic.LoadUint64(&n[2]),
+		Other:    atomic.LoadUint64(&n[3]),
+	}
+}
+
+func typeIndex(t storage.FileType) int {
+	switch t {
+	case storage.TypeManifest:
+		return 0
+	case storage.TypeJournal:
+		return 1
+	case storage.TypeTable:
+		return 2
+	default:
+		return 3
+	}
+}
+
+type countingStorage struct {
+	storage.Storage
+	c *IOCounter
+}
+
+func (s *countingStorage) Open(fd storage.FileDesc) (storage.Reader, error) {
+	r, err := s.Storage.Open(fd)
+	if err != nil {
+		return r, err
+	}
+	cr := &countingReader{Reader: r, n: &s.c.read[typeIndex(fd.Type)]}
+	if fd.Type == storage.TypeTable {
+		cr.table = s.c.tableCounter(fd.Num)
+	}
+	return cr, nil
+}
+
+func (s *countingStorage) Create(fd storage.FileDesc) (storage.Writer, error) {
+	w, err := s.Storage.Create(fd)
+	if err != nil {
+		return w, err
+	}
+	return &countingWriter{w, &s.c.written[typeIndex(fd.Type)]}, nil
+}
+
+type countingReader struct {
+	storage.Reader
+	n     *uint64
+	table *uint64 // nil unless the file is a table
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	r.add(n)
+	return n, err
+}
+
+func (r *countingReader) ReadAt(b []byte, off int64) (int, error) {
+	n, err := r.Reader.ReadAt(b, off)
+	r.add(n)
+	return n, err
+}
+
+func (r *countingReader) add(n int) {
+	atomic.AddUint64(r.n, uint64(n))
+	if r.table != nil {
+		atomic.AddUint64(r.table, uint64(n))
+	}
+}
+
+type countingWriter struct {
+	storage.Writer
+	n *uint64
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.Writer.Write(b)
+	atomic.AddUint64(w.n, uint64(n))
+	return n, err
+}
@@ -0,0 +1,100 @@
+// Package ldbstore contains goleveldb storage wrappers for benchmarks.
+package ldbstore
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	"github.com/syndtr/goleveldb/leveldb/storage"
+)
+
+// ErrInjected is the error returned by injected faults.
+var ErrInjected = errors.New("injected fault")
+
+// FaultConfig configures fault injection. Rates are probabilities per call.
+type FaultConfig struct {
+	WriteRate float64
+	SyncRate  float64
+	// Types are the affected file types. Zero means all files.
+	Types storage.FileType
+	Seed  int64
+}
+
+// FaultStats counts calls to the file writers of a FaultStorage.
+type FaultStats struct {
+	Writes, WriteFaults uint64
+	Syncs, SyncFaults   uint64
+}
+
+// FaultStorage wraps a storage, failing writes and syncs at random.
+type FaultStorage struct {
+	stats FaultStats // first for 64-bit alignment of atomic counters
+	storage.Storage
+	cfg FaultConfig
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewFaultStorage wraps s.
+func NewFaultStorage(s storage.Storage, cfg FaultConfig) *FaultStorage {
+	if cfg.Types == 0 {
+		cfg.Types = storage.TypeAll
+	}
+	return &FaultStorage{Storage: s, cfg: cfg, rng: rand.New(rand.NewSource(cfg.Seed))}
+}
+
+// Stats returns the current counters.
+func (s *FaultStorage) Stats() FaultStats {
+	return FaultStats{
+		Writes:      atomic.LoadUint64(&s.stats.Writes),
+		WriteFaults: atomic.LoadUint64(&s.stats.WriteFaults),
+		Syncs:       atomic.LoadUint64(&s.stats.Syncs),
+		SyncFaults:  atomic.LoadUint64(&s.stats.SyncFaults),
+	}
+}
+
+func (s *FaultStorage) Create(fd storage.FileDesc) (storage.Writer, error) {
+	w, err := s.Storage.Create(fd)
+	if err != nil || fd.Type&s.cfg.Types == 0 {
+		return w, err
+	}
+	return &faultWriter{w, s}, nil
+}
+
+// inject decides whether a call with the given fault rate fails.
+func (s *FaultStorage) inject(rate float64, calls, faults *uint64) bool {
+	atomic.AddUint64(calls, 1)
+	if rate <= 0 {
+		return false
+	}
+	s.mu.Lock()
+	fail := s.rng.Float64() < rate
+	s.mu.Unlock()
+	if fail {
+		atomic.AddUint64(faults, 1)
+	}
+	return fail
+}
+
+type faultWriter struct {
+	storage.Writer
+	s *FaultStorage
+}
+
+func (w *faultWriter) Write(b []byte) (int, error) {
+	if w.s.inject(w.s.cfg.WriteRate, &w.s.stats.Writes, &w.s.stats.WriteFaults) {
+		// Like a failing disk, write part of the data.
+		n, _ := w.Writer.Write(b[:len(b)/2])
+		return n, ErrInjected
+	}
+	return w.Writer.Write(b)
+}
+
+func (w *faultWriter) Sync() error {
+	if w.s.inject(w.s.cfg.SyncRate, &w.s.stats.Syncs, &w.s.stats.SyncFaults) {
+		return ErrInjected
+	}
+	return w.Writer.Sync()
+}
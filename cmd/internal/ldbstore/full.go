@@ -0,0 +1,118 @@
+package ldbstore
+
+import (
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/syndtr/goleveldb/leveldb/storage"
+)
+
+// FullStats counts the bytes stored by a FullStorage.
+type FullStats struct {
+	Used, Peak uint64
+	Rejected   uint64 // writes which failed with ENOSPC
+}
+
+// FullStorage wraps a storage, simulating a disk with limited capacity. Writes
+// fail with ENOSPC once the files created through it hold capacity bytes.
+// Removing a file frees its space.
+type FullStorage struct {
+	storage.Storage
+	capacity uint64
+	mu       sync.Mutex
+	stats    FullStats
+	sizes    map[storage.FileDesc]uint64
+}
+
+// NewFullStorage wraps s.
+func NewFullStorage(s storage.Storage, capacity uint64) *FullStorage {
+	return &FullStorage{Storage: s, capacity: capacity, sizes: make(map[storage.FileDesc]uint64)}
+}
+
+// Stats returns the current counters.
+func (s *FullStorage) Stats() FullStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+func (s *FullStorage) Create(fd storage.FileDesc) (storage.Writer, error) {
+	w, err := s.Storage.Create(fd)
+	if err != nil {
+		return w, err
+	}
+	// Create truncates existing files.
+	s.mu.Lock()
+	s.stats.Used -= s.sizes[fd]
+	s.sizes[fd] = 0
+	s.mu.Unlock()
+	return &fullWriter{w, s, fd}, nil
+}
+
+func (s *FullStorage) Remove(fd storage.FileDesc) error {
+	if err := s.Storage.Remove(fd); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.stats.Used -= s.sizes[fd]
+	delete(s.sizes, fd)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *FullStorage) Rename(oldfd, newfd storage.FileDesc) error {
+	if err := s.Storage.Rename(oldfd, newfd); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.stats.Used -= s.sizes[newfd]
+	if size, ok := s.sizes[oldfd]; ok {
+		s.sizes[newfd] = size
+		delete(s.sizes, oldfd)
+	} else {
+		delete(s.sizes, newfd)
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// reserve claims space for a write of n bytes to fd and returns the number of
+// bytes that fit.
+func (s *FullStorage) reserve(fd storage.FileDesc, n int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	free := uint64(0)
+	if s.stats.Used < s.capacity {
+		free = s.capacity - s.stats.Used
+	}
+	if uint64(n) > free {
+		n = int(free)
+		s.stats.Rejected++
+	}
+	s.sizes[fd] += uint64(n)
+	s.stats.Used += uint64(n)
+	if s.stats.Used > s.stats.Peak {
+		s.stats.Peak = s.stats.Used
+	}
+	return n
+}
+
+type fullWriter struct {
+	storage.Writer
+	s  *FullStorage
+	fd storage.FileDesc
+}
+
+func (w *fullWriter) Write(b []byte) (int, error) {
+	n := w.s.reserve(w.fd, len(b))
+	if n < len(b) {
+		// Like a full disk, store what fits.
+		wn, err := w.Writer.Write(b[:n])
+		if err == nil {
+			err = &os.PathError{Op: "write", Path: w.fd.String(), Err: syscall.ENOSPC}
+		}
+		return wn, err
+	}
+	return w.Writer.Write(b)
+}
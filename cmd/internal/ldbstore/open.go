@@ -0,0 +1,51 @@
+package ldbstore
+
+import (
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+)
+
+// Wrapper wraps the storage of a database.
+type Wrapper func(storage.Storage) storage.Storage
+
+// DB is a database opened by Open.
+type DB struct {
+	*leveldb.DB
+	stor storage.Storage
+}
+
+// Open opens the database in dir, placing it on the storage returned by the
+// wrappers. The first wrapper is applied first.
+func Open(dir string, o *opt.Options, wrappers ...Wrapper) (*DB, error) {
+	stor, err := storage.OpenFile(dir, o.GetReadOnly())
+	if err != nil {
+		return nil, err
+	}
+	return OpenStorage(stor, o, wrappers...)
+}
+
+// OpenStorage is like Open, but opens the database on stor. The storage is
+// closed with the database.
+func OpenStorage(stor storage.Storage, o *opt.Options, wrappers ...Wrapper) (*DB, error) {
+	for _, wrap := range wrappers {
+		if wrap != nil {
+			stor = wrap(stor)
+		}
+	}
+	db, err := leveldb.Open(stor, o)
+	if err != nil {
+		stor.Close()
+		return nil, err
+	}
+	return &DB{db, stor}, nil
+}
+
+// Close closes the database and its storage.
+func (db *DB) Close() error {
+	err := db.DB.Close()
+	if serr := db.stor.Close(); err == nil {
+		err = serr
+	}
+	return err
+}
@@ -0,0 +1,183 @@
+package ldbstore
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+)
+
+// SlowConfig configures the latency and bandwidth of a SlowStorage. Reads
+// continuing where the previous read of the file ended are sequential and only
+// limited by bandwidth, like with readahead. Writes are buffered by the OS, so
+// write latency should usually be zero and only syncs wait for the disk.
+type SlowConfig struct {
+	ReadLatency  time.Duration // per random read
+	WriteLatency time.Duration // per write call
+	SyncLatency  time.Duration // per sync call
+	Bandwidth    uint64        // bytes per second shared by reads and writes, zero is unlimited
+}
+
+// SlowPresets are configurations modeling common kinds of disks.
+var SlowPresets = map[string]SlowConfig{
+	// Spinning disk: seeks dominate reads and syncs.
+	"hdd": {ReadLatency: 8 * time.Millisecond, SyncLatency: 10 * time.Millisecond, Bandwidth: 150 << 20},
+	// Network-attached storage: every call is a round trip.
+	"nas": {ReadLatency: time.Millisecond, SyncLatency: 2 * time.Millisecond, Bandwidth: 100 << 20},
+	// Throttled cloud volume: moderate latency, low bandwidth.
+	"cloud": {ReadLatency: 2 * time.Millisecond, SyncLatency: 5 * time.Millisecond, Bandwidth: 64 << 20},
+}
+
+// SlowPresetNames returns the names of all presets.
+func SlowPresetNames() (n []string) {
+	for name := range SlowPresets {
+		n = append(n, name)
+	}
+	sort.Strings(n)
+	return n
+}
+
+// ParseSlowConfig parses a preset name or a list of settings like
+// "read=5ms,write=1ms,sync=10ms,bw=100mb".
+func ParseSlowConfig(spec string) (SlowConfig, error) {
+	if cfg, ok := SlowPresets[spec]; ok {
+		return cfg, nil
+	}
+	var cfg SlowConfig
+	for _, setting := range strings.Split(spec, ",") {
+		kv := strings.SplitN(strings.TrimSpace(setting), "=", 2)
+		if len(kv) != 2 {
+			return cfg, fmt.Errorf("invalid setting %q (presets: %s)", setting, strings.Join(SlowPresetNames(), ", "))
+		}
+		var err error
+		switch kv[0] {
+		case "read":
+			cfg.ReadLatency, err = time.ParseDuration(kv[1])
+		case "write":
+			cfg.WriteLatency, err = time.ParseDuration(kv[1])
+		case "sync":
+			cfg.SyncLatency, err = time.ParseDuration(kv[1])
+		case "bw":
+			cfg.Bandwidth, err = bench.ParseSize(kv[1])
+		default:
+			err = fmt.Errorf("unknown setting %q", kv[0])
+		}
+		if err != nil {
+			return cfg, err
+		}
+	}
+	return cfg, nil
+}
+
+// SlowStorage wraps a storage, delaying file access to simulate a slower disk.
+type SlowStorage struct {
+	storage.Storage
+	cfg  SlowConfig
+	mu   sync.Mutex
+	busy time.Time // the simulated disk is busy transferring until this time
+}
+
+// NewSlowStorage wraps s.
+func NewSlowStorage(s storage.Storage, cfg SlowConfig) *SlowStorage {
+	return &SlowStorage{Storage: s, cfg: cfg}
+}
+
+// Wrapper returns a storage wrapper for the configuration.
+func (cfg SlowConfig) Wrapper() Wrapper {
+	return func(s storage.Storage) storage.Storage { return NewSlowStorage(s, cfg) }
+}
+
+func (s *SlowStorage) Open(fd storage.FileDesc) (storage.Reader, error) {
+	r, err := s.Storage.Open(fd)
+	if err != nil {
+		return r, err
+	}
+	return &slowReader{Reader: r, s: s, next: -1}, nil
+}
+
+func (s *SlowStorage) Create(fd storage.FileDesc) (storage.Writer, error) {
+	w, err := s.Storage.Create(fd)
+	if err != nil {
+		return w, err
+	}
+	return &slowWriter{w, s}, nil
+}
+
+// delay waits for the latency of a call transferring n bytes. Transfers are
+// serialized, so concurrent calls share the bandwidth.
+func (s *SlowStorage) delay(latency time.Duration, n int) {
+	wait := latency
+	if s.cfg.Bandwidth > 0 && n > 0 {
+		transfer := time.Duration(float64(n) / float64(s.cfg.Bandwidth) * float64(time.Second))
+		s.mu.Lock()
+		now := time.Now()
+		if s.busy.Before(now) {
+			s.busy = now
+		}
+		s.busy = s.busy.Add(transfer)
+		wait += s.busy.Sub(now)
+		s.mu.Unlock()
+	}
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+type slowReader struct {
+	storage.Reader
+	s         *SlowStorage
+	mu        sync.Mutex
+	next      int64 // end of the last ReadAt, -1 before the first one
+	streaming bool  // Read was called since the last Seek
+}
+
+func (r *slowReader) Read(b []byte) (int, error) {
+	// Only the first Read after opening or seeking is random.
+	r.mu.Lock()
+	latency := r.s.cfg.ReadLatency
+	if r.streaming {
+		latency = 0
+	}
+	r.streaming = true
+	r.mu.Unlock()
+	r.s.delay(latency, len(b))
+	return r.Reader.Read(b)
+}
+
+func (r *slowReader) Seek(offset int64, whence int) (int64, error) {
+	r.mu.Lock()
+	r.streaming = false
+	r.mu.Unlock()
+	return r.Reader.Seek(offset, whence)
+}
+
+func (r *slowReader) ReadAt(b []byte, off int64) (int, error) {
+	r.mu.Lock()
+	latency := r.s.cfg.ReadLatency
+	if off == r.next {
+		latency = 0
+	}
+	r.next = off + int64(len(b))
+	r.mu.Unlock()
+	r.s.delay(latency, len(b))
+	return r.Reader.ReadAt(b, off)
+}
+
+type slowWriter struct {
+	storage.Writer
+	s *SlowStorage
+}
+
+func (w *slowWriter) Write(b []byte) (int, error) {
+	w.s.delay(w.s.cfg.WriteLatency, len(b))
+	return w.Writer.Write(b)
+}
+
+func (w *slowWriter) Sync() error {
+	w.s.delay(w.s.cfg.SyncLatency, 0)
+	return w.Writer.Sync()
+}
@@ -0,0 +1,159 @@
+// Package matrixcmd runs benchmarks against several goleveldb versions. For
+// each version, the benchmark binary is rebuilt from source with a copy of
+// go.mod requiring that version, and run with a tag naming the version.
+package matrixcmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/fjl/goleveldb-bench/cmd/internal/cmdutil"
+)
+
+const goleveldbModule = "github.com/syndtr/goleveldb"
+
+// versionTag is the tag holding the goleveldb version of a run.
+const versionTag = "goleveldb"
+
+// Main runs the version matrix command.
+func Main(name string, args []string) {
+	var (
+		fs           = cmdutil.FlagSet(name, "[flags] write|read [flags of the command]")
+		versionsflag = fs.String("versions", "", "comma-separated goleveldb versions: module versions, branches or commits")
+		srcflag      = fs.String("src", ".", "goleveldb-bench source directory")
+		dirflag      = fs.String("dir", ".", "test database directory")
+		logdirflag   = fs.String("logdir", ".", "test log output directory")
+		keepflag     = fs.Bool("keepbin", false, "keep the benchmark binaries in the log directory")
+	)
+	fs.Parse(args)
+
+	var versions []string
+	for _, v := range strings.Split(*versionsflag, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			versions = append(versions, v)
+		}
+	}
+	if len(versions) == 0 {
+		log.Fatal("no versions to run, use -versions to select goleveldb versions")
+	}
+	cmd := fs.Args()
+	if len(cmd) == 0 || (cmd[0] != "write" && cmd[0] != "read") {
+		log.Fatal("missing benchmark command, use write or read")
+	}
+	for _, a := range cmd[1:] {
+		if f := cmdutil.FlagName(a); f == "dir" || f == "logdir" {
+			log.Fatalf("-%s must be given to %s, runs of each version go into a subdirectory", f, name)
+		}
+	}
+	src, err := filepath.Abs(*srcflag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tmp, err := ioutil.TempDir("", "ldbbench-matrix-")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	anyErr := false
+	for _, v := range versions {
+		bin, resolved, err := build(src, filepath.Join(tmp, dirName(v)), v)
+		if err != nil {
+			log.Printf("goleveldb %s: %v", v, err)
+			anyErr = true
+			continue
+		}
+		log.Printf("== goleveldb %s (%s)", v, resolved)
+		sub := "goleveldb-" + dirName(resolved)
+		logdir := filepath.Join(*logdirflag, sub)
+		if *keepflag {
+			if err := keepBinary(bin, logdir); err != nil {
+				log.Printf("can't keep binary: %v", err)
+			}
+		}
+		runArgs := append([]string{cmd[0]}, cmd[1:]...)
+		runArgs = append(runArgs,
+			"-dir", filepath.Join(*dirflag, sub),
+			"-logdir", logdir,
+			"-tag", versionTag+"="+resolved,
+		)
+		run := exec.Command(bin, runArgs...)
+		run.Stdout, run.Stderr = os.Stdout, os.Stderr
+		if err := run.Run(); err != nil {
+			log.Printf("goleveldb %s: %v", v, err)
+			anyErr = true
+		}
+	}
+	if anyErr {
+		os.RemoveAll(tmp)
+		log.Fatal("one or more versions failed")
+	}
+}
+
+// build compiles ldbbench with the given goleveldb version into dir. It returns
+// the binary and the module version the query resolved to.
+func build(src, dir, version string) (bin, resolved string, err error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", "", err
+	}
+	modfile := filepath.Join(dir, "go.mod")
+	for _, f := range []string{"go.mod", "go.sum"} {
+		if err := copyFile(filepath.Join(src, f), filepath.Join(dir, f)); err != nil {
+			return "", "", err
+		}
+	}
+	if _, err := goCmd(src, "get", "-modfile="+modfile, goleveldbModule+"@"+version); err != nil {
+		return "", "", fmt.Errorf("can't select version: %v", err)
+	}
+	out, err := goCmd(src, "list", "-modfile="+modfile, "-m", "-f", "{{.Version}}", goleveldbModule)
+	if err != nil {
+		return "", "", err
+	}
+	resolved = strings.TrimSpace(out)
+	bin = filepath.Join(dir, "ldbbench")
+	if _, err := goCmd(src, "build", "-mod=mod", "-modfile="+modfile, "-o", bin, "./cmd/ldbbench"); err != nil {
+		return "", "", fmt.Errorf("build failed: %v", err)
+	}
+	return bin, resolved, nil
+}
+
+// goCmd runs the go tool in dir. Errors include the output of the command.
+func goCmd(dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("go", args...)
+	cmd.Dir, cmd.Stdout, cmd.Stderr = dir, &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("go %s: %v\n%s", args[0], err, stderr.Bytes())
+	}
+	return stdout.String(), nil
+}
+
+func keepBinary(bin, logdir string) error {
+	if err := os.MkdirAll(logdir, 0755); err != nil {
+		return err
+	}
+	return copyFile(bin, filepath.Join(logdir, "ldbbench"))
+}
+
+func copyFile(from, to string) error {
+	info, err := os.Stat(from)
+	if err != nil {
+		return err
+	}
+	content, err := ioutil.ReadFile(from)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(to, content, info.Mode())
+}
+
+// dirName makes a version usable as a file name.
+func dirName(version string) string {
+	return strings.NewReplacer("/", "_", "@", "_").Replace(version)
+}
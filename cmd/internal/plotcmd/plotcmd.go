@@ -0,0 +1,73 @@
+package plotcmd
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/fjl/goleveldb-bench/benchplot"
+	"github.com/fjl/goleveldb-bench/cmd/internal/cmdutil"
+)
+
+// Main runs the plot command.
+func Main(name string, args []string) {
+	var (
+		fs       = cmdutil.FlagSet(name, "[flags] <log files or directories>")
+		width    = fs.String("width", "15cm", "width of the plot (cm, mm, in, pt or px)")
+		height   = fs.String("height", "10cm", "height of the plot (cm, mm, in, pt or px)")
+		dpi      = fs.Int("dpi", 96, "resolution of png, jpg and tiff images")
+		plotType = fs.String("plot", "bps", "type of plot ("+strings.Join(benchplot.Types, ", ")+")")
+		out      = fs.String("out", "", "output file, the extension selects the format: svg, png, pdf, eps, jpg, tiff, or html for an interactive report of all logs ignoring -plot")
+		facet    = fs.String("facet", "", "tag to group reports into subplots by")
+		stack    = fs.String("stack", "", "comma-separated metrics to plot in panels on a common time axis, instead of -plot ("+strings.Join(benchplot.StackMetrics, ", ")+")")
+	)
+	cmdutil.CompleteValues(fs, "plot", func() []string { return benchplot.Types })
+	fs.Parse(args)
+	if *out == "" {
+		log.Fatal("-out is required")
+	}
+	if *dpi <= 0 {
+		log.Fatal("-dpi must be positive")
+	}
+	size := benchplot.Size{DPI: *dpi}
+	var err error
+	if size.W, err = benchplot.ParseLength(*width, *dpi); err != nil {
+		log.Fatal("-width: ", err)
+	}
+	if size.H, err = benchplot.ParseLength(*height, *dpi); err != nil {
+		log.Fatal("-height: ", err)
+	}
+	reports := bench.MustReadReports(logFiles(fs.Args()))
+	switch {
+	case strings.EqualFold(filepath.Ext(*out), ".html"):
+		err = benchplot.SaveHTML(reports, *out)
+	case *stack != "":
+		err = benchplot.SaveStack(strings.Split(*stack, ","), reports, size, *out)
+	case *facet == "":
+		err = benchplot.Save(*plotType, reports, size, *out)
+	default:
+		err = benchplot.SaveFacets(*plotType, *facet, reports, size, *out)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// logFiles replaces directories in args by the test logs they contain.
+func logFiles(args []string) []string {
+	var files []string
+	for _, arg := range args {
+		if fi, err := os.Stat(arg); err != nil || !fi.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		logs, _ := filepath.Glob(filepath.Join(arg, "*.json"))
+		if len(logs) == 0 {
+			log.Printf("Warning: no test logs in %s", arg)
+		}
+		files = append(files, logs...)
+	}
+	return files
+}
@@ -0,0 +1,760 @@
+package readcmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/fjl/goleveldb-bench/cmd/internal/cmdutil"
+	"github.com/fjl/goleveldb-bench/cmd/internal/kvstore"
+	"github.com/fjl/goleveldb-bench/cmd/internal/ldbstore"
+	"github.com/syndtr/goleveldb/leveldb/filter"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// Main runs the read benchmark command.
+func Main(name string, args []string) {
+	var (
+		fs = cmdutil.FlagSet(name, "[flags]")
+
+		testflag     = fs.String("test", "", "tests to run ("+strings.Join(testnames(), ", ")+")")
+		sizeflag     = cmdutil.Size(fs, "size", "500mb", "total amount of value data to write")
+		datasizeflag = cmdutil.Size(fs, "valuesize", "100b", "size of each value")
+		keysizeflag  = cmdutil.Size(fs, "keysize", "32b", "size of each key")
+		dbflag       = fs.String("db", kvstore.Default, "storage engine ("+strings.Join(kvstore.Names(), ", ")+")")
+		dirflag      = fs.String("dir", ".", "test database directory")
+		logdirflag   = fs.String("logdir", ".", "test log output directory")
+		deletedbflag = fs.Bool("deletedb", false, "delete databases after test run")
+		prefixflag   = fs.Int("prefixes", 3, "number of distinct key prefixes in prefix-scan tests (max 256)")
+		ramdiskflag  = fs.String("ramdisk", "", "place databases on a tmpfs of this size")
+		slowflag     = fs.String("slowdisk", "", cmdutil.SlowDiskUsage)
+		recordflag   = fs.Bool("record", false, "record generated operations to a trace file in the log directory")
+		latlogflag   = fs.Bool("latlog", false, "log the latency of every read to a compressed file in the log directory")
+		formatflag   = fs.String("format", "json", "log format: json, or csv to also write a CSV progress table per test")
+		memflag      = fs.Bool("samplemem", false, "log Go heap size, memory obtained from the OS and GC count whenever progress is logged")
+		intervalflag = fs.String("report-interval", "500kb", "distance between progress log entries, either data processed (e.g. 1mb) or time (e.g. 10s)")
+		entropyflag  = fs.String("entropy", bench.DefaultEntropy, "random source for keys and values ("+strings.Join(bench.EntropyNames(), ", ")+")")
+		cachesflag   = fs.String("blockcaches", "", "comma-separated block cache sizes to run each test with (overrides -blockcache)")
+		nofillflag   = fs.Bool("dontfillcache", false, "read option: don't add blocks read by the test to the block cache")
+		strictflag   = fs.String("readstrict", "", "read option: strict checks of reads ("+strings.Join(readStrictNames(), ", ")+")")
+
+		run []testRun
+		cfg bench.ReadConfig
+	)
+	fs.Var(&cfg.Tags, "tag", "key=value tag attached to test logs (may be repeated)")
+	cmdutil.CompleteValues(fs, "test", testnames)
+	cmdutil.CompleteValues(fs, "entropy", bench.EntropyNames)
+	cmdutil.CompleteValues(fs, "format", func() []string { return []string{"json", "csv"} })
+	cmdutil.CompleteValues(fs, "slowdisk", ldbstore.SlowPresetNames)
+	cmdutil.CompleteValues(fs, "db", kvstore.Names)
+	cmdutil.CompleteValues(fs, "readstrict", readStrictNames)
+	detectflag := cmdutil.Detect(fs)
+	watchdog := cmdutil.Watchdog(fs)
+	levelDBOptions := cmdutil.LevelDBOptions(fs)
+	grid := cmdutil.Grid(fs)
+	profile := cmdutil.Profile(fs)
+	metrics := cmdutil.Metrics(fs)
+	display := cmdutil.Display(fs)
+	otlp := cmdutil.OTLP(fs)
+	repeat := cmdutil.Repeat(fs)
+	baseline := cmdutil.Baseline(fs)
+	influx := cmdutil.Influx(fs)
+	fs.Parse(args)
+
+	for _, t := range bench.ParseTestList(*testflag) {
+		if tests[t] == nil {
+			log.Fatalf("unknown test %q", t)
+		}
+		run = append(run, testRun{name: t, test: tests[t]})
+	}
+	if len(run) == 0 {
+		log.Fatal("no tests to run, use -test to select tests")
+	}
+	cfg.Size, cfg.DataSize, cfg.KeySize = *sizeflag, *datasizeflag, *keysizeflag
+	if _, err := bench.NewEntropy(*entropyflag, 0); err != nil {
+		log.Fatal("-entropy: ", err)
+	}
+	cfg.Entropy = *entropyflag
+	if err := kvstore.Check(*dbflag); err != nil {
+		log.Fatal("-db: ", err)
+	}
+	if dbEngine = *dbflag; dbEngine != kvstore.Default {
+		if *slowflag != "" && !kvstore.IsLevelDB(dbEngine) {
+			log.Fatalf("-slowdisk requires -db %s or %s", kvstore.Default, kvstore.Memory)
+		}
+		if cfg.Tags == nil {
+			cfg.Tags = make(bench.Tags)
+		}
+		cfg.Tags["db"] = dbEngine
+	}
+	if *nofillflag || *strictflag != "" {
+		if !kvstore.IsLevelDB(dbEngine) {
+			log.Fatalf("-dontfillcache and -readstrict require -db %s or %s", kvstore.Default, kvstore.Memory)
+		}
+		var err error
+		if readOptions, err = parseReadOptions(*nofillflag, *strictflag, &cfg.Tags); err != nil {
+			log.Fatal("-readstrict: ", err)
+		}
+	}
+	if *prefixflag < 1 || *prefixflag > 256 {
+		log.Fatal("-prefixes must be between 1 and 256")
+	}
+	prefixCount = *prefixflag
+	cfg.Detectors = *detectflag
+	cfg.Watchdog = watchdog(*logdirflag)
+	cfg.LogPercent = true
+	cfg.SampleMemory = *memflag
+	interval, err := bench.ParseReportInterval(*intervalflag)
+	if err != nil {
+		log.Fatal("-report-interval: ", err)
+	}
+	cfg.ReportInterval = interval
+	latencyLog = *latlogflag
+	switch *formatflag {
+	case "json":
+	case "csv":
+		csvLog = true
+	default:
+		log.Fatalf("unknown -format %q", *formatflag)
+	}
+	flagOptions = levelDBOptions(&cfg.Tags)
+
+	var cacheSizes []string
+	if *cachesflag != "" {
+		var err error
+		if cacheSizes, err = parseSizes(*cachesflag); err != nil {
+			log.Fatal("-blockcaches: ", err)
+		}
+	}
+
+	slowDisk = cmdutil.SlowDisk(*slowflag, &cfg.Tags)
+	dbbase, closeRamdisk := cmdutil.Ramdisk(*dirflag, *ramdiskflag, cfg.Size, &cfg.Tags)
+	defer closeRamdisk()
+
+	for i := range run {
+		run[i].cfg = cfg
+	}
+	if *cachesflag != "" {
+		run = sweepBlockCache(run, cacheSizes)
+	}
+	if cells := grid(); cells != nil {
+		run = cmdutil.SweepGrid(run, cells, func(r *testRun, c cmdutil.GridCell) {
+			c.Apply(&r.name, &r.cfg.Tags, &r.options)
+		})
+	}
+
+	if dbEngine != kvstore.Default {
+		for i := range run {
+			run[i].unsupported = engineSupport(run[i])
+		}
+	}
+
+	logdirs, err := repeat(*logdirflag)
+	if err != nil {
+		log.Fatalf("can't create log dir: %v", err)
+	}
+
+	live, stopOTLP := otlp(metrics())
+	live, stopDisplay := display(live)
+	for i := range run {
+		run[i].cfg.Live = live
+		if stopDisplay != nil {
+			run[i].cfg.LogPercent = false // the status line shows it
+		}
+	}
+	var closeInflux func()
+	influxOut, closeInflux = influx()
+	stopProfile := profile()
+	anyErr := false
+	for _, r := range run {
+		var (
+			name     = r.name
+			dbdir    string
+			createdb bool
+		)
+		// The given dir points to an existent directory, assume it's
+		// a old database for read testing. Memory databases are always
+		// created, as are the databases of repeated runs.
+		if dbEngine != kvstore.Memory && len(logdirs) == 1 && isDir(*dirflag) && fileExist(filepath.Join(*dirflag, "testing.key")) {
+			if strings.Contains(*dirflag, "filter") != strings.Contains(name, "filter") {
+				log.Printf("Skip test %s. Incompatible database", name)
+				continue
+			}
+			dbdir = *dirflag
+			if *ramdiskflag != "" {
+				// The existing database isn't on the ramdisk.
+				r.cfg.Tags = copyTags(r.cfg.Tags, 0)
+				delete(r.cfg.Tags, bench.RamdiskTag)
+			}
+		} else {
+			dbdir, createdb = kvstore.TestDir(dbbase, dbEngine, name), true
+		}
+		for i, logdir := range logdirs {
+			if len(logdirs) > 1 {
+				log.Printf("-- run %d/%d", i+1, len(logdirs))
+				os.RemoveAll(dbdir) // every run starts on a fresh database
+			}
+			if err := os.MkdirAll(dbdir, 0755); err != nil {
+				log.Fatalf("can't create keyfile dir: %v", err)
+			}
+			if err := runTest(logdir, dbdir, r, createdb, *recordflag); err != nil {
+				log.Printf("test %q failed: %v", name, err)
+				anyErr = true
+			}
+		}
+		if *deletedbflag {
+			os.RemoveAll(dbdir)
+		}
+	}
+	stopProfile()
+	if stopDisplay != nil {
+		stopDisplay()
+	}
+	closeInflux()
+	stopOTLP()
+	if len(logdirs) > 1 {
+		if err := cmdutil.WriteAggregate(*logdirflag, logdirs); err != nil {
+			log.Printf("can't write aggregated results: %v", err)
+			anyErr = true
+		}
+	}
+	if anyErr {
+		closeRamdisk()
+		log.Fatal("one ore more tests failed")
+	}
+	if !baseline(testLogs) {
+		closeRamdisk()
+		log.Fatal("results regressed against the baseline")
+	}
+}
+
+// testLogs are the logs written by the runs, for -baseline.
+var testLogs []string
+
+// slowDisk is the storage wrapper set by -slowdisk.
+var slowDisk ldbstore.Wrapper
+
+// storageIO counts the storage reads of the current run. It is nil for engines
+// other than goleveldb.
+var storageIO *ldbstore.IOCounter
+
+// latencyLog enables the read latency logs of -latlog.
+var latencyLog bool
+
+// csvLog enables the CSV progress tables of -format csv.
+var csvLog bool
+
+// influxOut receives the progress samples of -influx.
+var influxOut io.Writer
+
+// dbEngine is the storage engine set by -db.
+var dbEngine = kvstore.Default
+
+// testRun is a single execution of a test.
+type testRun struct {
+	name    string // name of the run, used for log and database names
+	test    Benchmarker
+	cfg     bench.ReadConfig
+	options []func(*opt.Options) // applied to the test's database options
+
+	// If unsupported is set, the run is only logged, not performed.
+	unsupported string
+}
+
+// engineSupport returns the reason why a run can't be performed with a storage
+// engine other than goleveldb, or the empty string if it can. Tests which need
+// a capability missing from the engine are found when the database is open.
+func engineSupport(r testRun) string {
+	if len(r.options) > 0 && !kvstore.IsLevelDB(dbEngine) {
+		return "requires -db " + kvstore.Default
+	}
+	// Other engines would silently order keys bytewise.
+	if !kvstore.IsLevelDB(dbEngine) && cmdutil.CustomComparer(r.test, flagOptions) {
+		return "requires -db " + kvstore.Default
+	}
+	return ""
+}
+
+// unsupported logs a run which can't be performed instead of running it.
+func unsupported(env *bench.ReadEnv, reason string) error {
+	log.Printf("skipping %q: %s", env.Config().TestName, reason)
+	return env.Unsupported(reason)
+}
+
+// readOptions are the options of all reads, set by -dontfillcache and
+// -readstrict.
+var readOptions *opt.ReadOptions
+
+// readStrict are the values of -readstrict. The strict level of the database
+// applies to reads as well unless it is overridden.
+var readStrict = map[string]opt.Strict{
+	"default": 0,
+	"reader":  opt.StrictReader,
+	"none":    opt.StrictOverride,
+}
+
+func readStrictNames() (n []string) {
+	for name := range readStrict {
+		n = append(n, name)
+	}
+	sort.Strings(n)
+	return n
+}
+
+// parseReadOptions creates the read options and tags runs with them.
+func parseReadOptions(dontFill bool, strict string, tags *bench.Tags) (*opt.ReadOptions, error) {
+	ro := &opt.ReadOptions{DontFillCache: dontFill}
+	if strict != "" {
+		level, ok := readStrict[strict]
+		if !ok {
+			return nil, fmt.Errorf("unknown strict level %q", strict)
+		}
+		ro.Strict = level
+	}
+	if *tags == nil {
+		*tags = make(bench.Tags)
+	}
+	if dontFill {
+		(*tags)["dontfillcache"] = "true"
+	}
+	if strict != "" {
+		(*tags)["readstrict"] = strict
+	}
+	return ro, nil
+}
+
+// flagOptions are the database options set by flags.
+var flagOptions func(*opt.Options)
+
+// dbOptions are the database option overrides of the current run. They take
+// precedence over options set by flags.
+var dbOptions []func(*opt.Options)
+
+// copyTags copies t, with room for n more tags.
+func copyTags(t bench.Tags, n int) bench.Tags {
+	cpy := make(bench.Tags, len(t)+n)
+	for k, v := range t {
+		cpy[k] = v
+	}
+	return cpy
+}
+
+// parseSizes splits a comma-separated list of sizes and checks them.
+func parseSizes(list string) ([]string, error) {
+	var sizes []string
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if _, err := bench.ParseSize(s); err != nil {
+			return nil, err
+		}
+		sizes = append(sizes, s)
+	}
+	return sizes, nil
+}
+
+// sweepBlockCache expands every run into one run per block cache size.
+func sweepBlockCache(runs []testRun, sizes []string) []testRun {
+	var out []testRun
+	for _, r := range runs {
+		for _, s := range sizes {
+			size, _ := bench.ParseSize(s)
+			sr := r
+			sr.name = r.name + "-cache" + s
+			sr.cfg.Tags = copyTags(r.cfg.Tags, 1)
+			sr.cfg.Tags["blockcache"] = s
+			sr.options = append(r.options[:len(r.options):len(r.options)], func(o *opt.Options) {
+				o.BlockCacheCapacity = int(size)
+			})
+			out = append(out, sr)
+		}
+	}
+	return out
+}
+
+// openDB opens the test database and records its options in the log. Options
+// set by flags override the options of the test.
+func openDB(dir string, o *opt.Options, env *bench.ReadEnv) (kvstore.Store, error) {
+	env.DatabaseDir(dir)
+	if flagOptions != nil || len(dbOptions) > 0 {
+		cpy := *o
+		if flagOptions != nil {
+			flagOptions(&cpy)
+		}
+		for _, fn := range dbOptions {
+			fn(&cpy)
+		}
+		o = &cpy
+	}
+	if err := env.LogOptions(cmdutil.EncodeOptions(o)); err != nil {
+		return nil, err
+	}
+	db, err := kvstore.Open(dbEngine, dir, kvstore.Options{LevelDB: o, Storage: []ldbstore.Wrapper{storageIO.Wrapper(), slowDisk}, Read: readOptions})
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := db.(kvstore.StatsReporter); ok {
+		env.CompactionStatsFunc(s.CompactionStats)
+	}
+	if ldb := kvstore.LevelDB(db); ldb != nil && storageIO != nil {
+		env.StorageReadFunc(func() (uint64, error) {
+			return storageIO.Read().Total(), nil
+		})
+		env.StorageLevelReadsFunc(func() ([]uint64, error) {
+			levels, err := ldbstore.TableLevels(ldb)
+			if err != nil {
+				return nil, err
+			}
+			return storageIO.LevelReads(levels), nil
+		})
+	}
+	return db, nil
+}
+
+func runTest(logdir, dbdir string, r testRun, createdb, record bool) error {
+	cfg, name := r.cfg, r.name
+	cfg.TestName = name
+	logname := filepath.Join(logdir, name+time.Now().Format(".2006-01-02-15:04:05"))
+	logfile, err := os.Create(logname + ".json")
+	if err != nil {
+		return err
+	}
+	defer logfile.Close()
+	testLogs = append(testLogs, logfile.Name())
+	if r.unsupported != "" {
+		return unsupported(bench.NewReadEnv(logfile, nil, nil, nil, cfg), r.unsupported)
+	}
+
+	var (
+		kw    io.Writer
+		kr    io.Reader
+		reset func()
+		kfile = filepath.Join(dbdir, "testing.key")
+	)
+	if !createdb {
+		keyfile, err := os.Open(kfile)
+		if err != nil {
+			return err
+		}
+		defer keyfile.Close()
+		kr = keyfile
+	} else {
+		keyfile, err := os.Create(kfile)
+		if err != nil {
+			return err
+		}
+		defer keyfile.Close()
+		kw, kr = keyfile, keyfile
+		reset = func() {
+			keyfile.Seek(0, io.SeekStart)
+		}
+	}
+
+	log.Printf("== running %q", name)
+	env := bench.NewReadEnv(logfile, kr, kw, reset, cfg)
+	if record {
+		tracefile, err := os.Create(logname + ".trace")
+		if err != nil {
+			return err
+		}
+		defer tracefile.Close()
+		env.Record(tracefile)
+	}
+	if latencyLog {
+		latfile, err := os.Create(logname + ".lat.gz")
+		if err != nil {
+			return err
+		}
+		defer latfile.Close()
+		env.LatencyLog(latfile)
+	}
+	if csvLog {
+		csvfile, err := os.Create(logname + ".csv")
+		if err != nil {
+			return err
+		}
+		defer csvfile.Close()
+		env.ProgressCSV(csvfile)
+	}
+	if influxOut != nil {
+		env.ProgressInflux(influxOut)
+	}
+	dbOptions = r.options
+	storageIO = nil
+	if kvstore.IsLevelDB(dbEngine) {
+		storageIO = ldbstore.NewIOCounter() // other engines don't take storage wrappers
+	}
+	return r.test.Benchmark(dbdir, env)
+}
+
+type Benchmarker interface {
+	Benchmark(dir string, env *bench.ReadEnv) error
+}
+
+var tests = map[string]Benchmarker{
+	"random-read": randomRead{},
+	"random-read-filter": randomRead{Options: opt.Options{
+		Filter: filter.NewBloomFilter(10),
+	}},
+	"random-read-bigcache": randomRead{Options: opt.Options{
+		BlockCacheCapacity: 100 * opt.MiB,
+	}},
+	"random-read-bigcache-filter": randomRead{Options: opt.Options{
+		BlockCacheCapacity: 100 * opt.MiB,
+		Filter:             filter.NewBloomFilter(10),
+	}},
+	// With few file handles, random reads on a large database reopen table
+	// files all the time.
+	"random-read-openfiles-64": randomRead{Options: opt.Options{
+		OpenFilesCacheCapacity: 64,
+	}},
+	"random-seek":     randomSeek{Nexts: 4},
+	"read-compacting": readCompacting{},
+	"iterate":         iterate{},
+	"iterate-keys":    iterate{KeysOnly: true},
+	"iterate-reverse": iterate{Reverse: true},
+	"prefix-scan":     prefixScan{},
+	"prefix-scan-filter": prefixScan{Options: opt.Options{
+		Filter: filter.NewBloomFilter(10),
+	}},
+}
+
+// prefixCount is the number of key prefixes used by prefix-scan tests.
+var prefixCount = 3
+
+func testnames() (n []string) {
+	for name := range tests {
+		n = append(n, name)
+	}
+	sort.Strings(n)
+	return n
+}
+
+type randomRead struct {
+	Options opt.Options
+}
+
+func (b randomRead) Benchmark(dir string, env *bench.ReadEnv) error {
+	db, err := openDB(dir, &b.Options, env)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return env.Run(func(key, value string, lastCall bool) error {
+		if err := db.Put([]byte(key), []byte(value), nil); err != nil {
+			return err
+		}
+		return nil
+	}, func(key string) error {
+		if value, err := db.Get([]byte(key)); err != nil {
+			return err
+		} else {
+			env.Progress(len(value))
+		}
+		return nil
+	})
+}
+
+// readCompacting is a random read test which compacts the whole database once a
+// quarter of the keys has been read. The compaction is logged as a window, so
+// read latency during compaction can be compared to the rest of the run.
+type readCompacting struct {
+	Options opt.Options
+}
+
+func (b readCompacting) Benchmark(dir string, env *bench.ReadEnv) error {
+	db, err := openDB(dir, &b.Options, env)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	c, ok := db.(kvstore.Compacter)
+	if !ok {
+		return unsupported(env, "storage engine has no manual compaction")
+	}
+
+	var (
+		cfg     = env.Config()
+		trigger = cfg.Size / cfg.DataSize / 4
+		reads   = uint64(0)
+		done    = make(chan error, 1)
+	)
+	if trigger == 0 {
+		trigger = 1 // small databases: compact right at the first read
+	}
+	err = env.Run(func(key, value string, lastCall bool) error {
+		return db.Put([]byte(key), []byte(value), nil)
+	}, func(key string) error {
+		if reads++; reads == trigger {
+			go func() {
+				defer env.Window("compaction")()
+				begin := time.Now()
+				err := c.CompactRange(nil, nil)
+				if err == nil {
+					log.Printf("compacted database in %v", time.Since(begin))
+				}
+				done <- err
+			}()
+		}
+		value, err := db.Get([]byte(key))
+		if err != nil {
+			return err
+		}
+		env.Progress(len(value))
+		return nil
+	})
+	if reads >= trigger {
+		if cerr := <-done; err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// randomSeek positions an iterator near random keys and reads a few entries
+// from there. The seek target is the stored key with its last byte modified,
+// so it usually falls between two keys.
+type randomSeek struct {
+	Options opt.Options
+	Nexts   int // entries read after each seek
+}
+
+func (b randomSeek) Benchmark(dir string, env *bench.ReadEnv) error {
+	if env.Config().KeySize == 0 {
+		return errors.New("seek tests need a -keysize of at least 1")
+	}
+	db, err := openDB(dir, &b.Options, env)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	var it kvstore.Iterator
+	defer func() {
+		if it != nil {
+			it.Release()
+		}
+	}()
+	return env.Run(func(key, value string, lastCall bool) error {
+		return db.Put([]byte(key), []byte(value), nil)
+	}, func(key string) error {
+		if it == nil {
+			it = db.Iterate(nil, nil)
+		}
+		target := []byte(key)
+		target[len(target)-1] ^= 0x80
+		n := 0
+		for ok, i := it.Seek(target), 0; ok && i <= b.Nexts; ok, i = it.Next(), i+1 {
+			n += len(it.Key()) + len(it.Value())
+		}
+		if err := it.Error(); err != nil {
+			return err
+		}
+		env.Progress(n)
+		return nil
+	})
+}
+
+// iterate scans the whole database.
+type iterate struct {
+	Options  opt.Options
+	KeysOnly bool // don't touch values
+	Reverse  bool // iterate from the last key using Prev
+}
+
+func (b iterate) Benchmark(dir string, env *bench.ReadEnv) error {
+	db, err := openDB(dir, &b.Options, env)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if b.Reverse {
+		it := db.Iterate(nil, nil)
+		_, ok := it.(kvstore.ReverseIterator)
+		it.Release()
+		if !ok {
+			return unsupported(env, "storage engine can't iterate in reverse")
+		}
+	}
+	return env.RunScan(func(key, value string, lastCall bool) error {
+		return db.Put([]byte(key), []byte(value), nil)
+	}, func() error {
+		it := db.Iterate(nil, nil)
+		defer it.Release()
+		next := it.Next
+		if b.Reverse {
+			it := it.(kvstore.ReverseIterator) // checked above
+			// The first step moves to the last key.
+			last := false
+			next = func() bool {
+				if !last {
+					last = true
+					return it.Last()
+				}
+				return it.Prev()
+			}
+		}
+		for next() {
+			n := len(it.Key())
+			if !b.KeysOnly {
+				n += len(it.Value())
+			}
+			env.Progress(n)
+		}
+		return it.Error()
+	})
+}
+
+// prefixScan stores keys under a number of one-byte prefixes, similar to the
+// table prefixes used by geth, and iterates each prefix range.
+type prefixScan struct {
+	Options opt.Options
+}
+
+func (b prefixScan) Benchmark(dir string, env *bench.ReadEnv) error {
+	db, err := openDB(dir, &b.Options, env)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return env.RunScan(func(key, value string, lastCall bool) error {
+		k := []byte(key)
+		k[0] = byte(int(k[0]) % prefixCount)
+		return db.Put(k, []byte(value), nil)
+	}, func() error {
+		for p := 0; p < prefixCount; p++ {
+			r := util.BytesPrefix([]byte{byte(p)})
+			it := db.Iterate(r.Start, r.Limit)
+			for it.Next() {
+				env.Progress(len(it.Key()) + len(it.Value()))
+			}
+			it.Release()
+			if err := it.Error(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func fileExist(path string) bool {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false
+	}
+	return !info.IsDir()
+}
+
+func isDir(name string) bool {
+	f, err := os.Stat(name)
+	if err != nil {
+		return false
+	}
+	return f.Mode().IsDir()
+}
@@ -0,0 +1,330 @@
+package statcmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/fjl/goleveldb-bench/cmd/internal/cmdutil"
+	"github.com/gonum/stat"
+)
+
+// Main runs the report command.
+func Main(name string, args []string) {
+	var (
+		fs       = cmdutil.FlagSet(name, "[flags] <log files>")
+		zscore   = fs.Float64("zscore", 3, "z-score above which repeated runs are flagged as outliers")
+		exclude  = fs.Bool("exclude-outliers", false, "exclude outlier runs from aggregates")
+		hints    = fs.Bool("hints", true, "print heuristic interpretations of each run")
+		benchfmt = fs.Bool("benchfmt", false, "print results in Go benchmark format for golang.org/x/perf/cmd/benchstat")
+	)
+	fs.Parse(args)
+	reports := bench.MustReadReports(fs.Args())
+	if *benchfmt {
+		if err := bench.WriteBenchmarkFormat(os.Stdout, reports); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	var (
+		groups = make(map[string][]bench.Summary)
+		labels []string
+	)
+	for _, r := range reports {
+		if r.Result != nil && r.Result.Unsupported != "" {
+			fmt.Printf("-- %s unsupported: %s\n", r.Label(), r.Result.Unsupported)
+			continue
+		}
+		s := bench.Summarize(r)
+		fmt.Printf("-- %s (%d events)", s.Name, s.Events)
+		fmt.Printf(" total time: %.4fs\n", s.TotalTime)
+		if len(s.Tags) > 0 {
+			fmt.Printf("       tags: %s\n", s.Tags)
+		}
+		if r.Header != nil && r.Header.Encoder != "" {
+			fmt.Printf("    encoder: %s\n", r.Header.Encoder)
+		}
+		if r.Header != nil && r.Header.System != nil {
+			fmt.Printf("     system: %s\n", r.Header.System)
+		}
+		fmt.Printf(" total size: %d bytes\n", s.TotalSize)
+		if r.Result != nil {
+			if r.Result.Error != "" {
+				fmt.Printf("      error: %s (partial run)\n", r.Result.Error)
+			}
+			if r.Result.Ops > 0 {
+				fmt.Printf("unique keys: ~%d of %d writes\n", r.Result.UniqueKeys, r.Result.Ops)
+			}
+			if res := r.Result; res.Reads > 0 && res.StorageRead > 0 {
+				fmt.Printf("storage read: %.3f mb, %.0f bytes per read", float64(res.StorageRead)/1024/1024, float64(res.StorageRead)/float64(res.Reads))
+				if amp := res.ReadAmplification(); amp > 0 {
+					fmt.Printf(", read amp %.2fx", amp)
+				}
+				fmt.Println()
+				if len(res.StorageReadLevels) > 0 {
+					fmt.Printf("   by level:")
+					for i, n := range res.StorageReadLevels {
+						if i > 0 {
+							fmt.Print(",")
+						}
+						fmt.Printf(" L%d %.0f bytes/read", i, float64(n)/float64(res.Reads))
+					}
+					fmt.Println()
+				}
+			}
+			if n := r.Result.StallCount(); n > 0 {
+				fmt.Printf("     stalls: %d\n", n)
+				for _, b := range r.Result.Stalls {
+					if b.Count > 0 {
+						fmt.Printf("             %v\n", b)
+					}
+				}
+			}
+			if amp := r.Result.SpaceAmplification(); amp > 0 {
+				fmt.Printf("    on disk: %.3f mb for %.3f mb of keys and values (%.2fx)\n",
+					float64(r.Result.DiskSize)/1024/1024, float64(r.Result.PutBytes)/1024/1024, amp)
+			}
+			if amp := r.Result.WriteAmplification(); amp > 0 {
+				fmt.Printf("  write amp: %.2fx, %.3f mb written (%s)\n",
+					amp, float64(r.Result.StorageWrites.Total())/1024/1024, r.Result.StorageWrites)
+			}
+			if d := r.Result.Device; d != nil {
+				fmt.Printf("  device io: %s", d)
+				if amp := r.Result.DeviceWriteAmplification(); amp > 0 {
+					fmt.Printf(" (%.2fx of keys and values)", amp)
+				}
+				fmt.Println()
+			}
+			if r.Result.Deletes > 0 {
+				fmt.Printf("    deletes: %d\n", r.Result.Deletes)
+			}
+			if n := len(r.Compactions); n > 0 {
+				printCompaction(&r.Compactions[n-1])
+				printPeakTables(&r)
+			}
+			if l := r.Result.Latency; l != nil {
+				fmt.Printf("   per call: p50 %v, p90 %v, p99 %v, p99.9 %v, max %v (%d ops)\n", l.P50, l.P90, l.P99, l.P999, l.Max, l.Count)
+			}
+			if gc := r.Result.GC; gc != nil && gc.Cycles > 0 {
+				fmt.Printf("         gc: %d cycles, %v paused", gc.Cycles, gc.PauseTotal)
+				if s.TotalTime > 0 {
+					fmt.Printf(" (%.2f%% of run time)", 100*gc.PauseTotal.Seconds()/s.TotalTime)
+				}
+				fmt.Printf(", max pause %v\n", gc.MaxPause)
+			}
+			if d := r.Result.Durable; d != nil {
+				fmt.Printf("    durable: %v mean, %v max (%d writes)\n", d.Mean, d.Max, d.Count)
+			}
+			if a := r.Result.Acks; a != nil {
+				fmt.Printf("       acks: %d of %d writes acknowledged, %d keys stored", a.Acked, a.Generated, a.Stored)
+				if !a.OK() {
+					fmt.Printf(", INCONSISTENT: %d unacked, %d duplicate acks, %d unknown acks, %d missing, %d unexpected keys",
+						a.Unacked, a.Duplicates, a.Unknown, a.Missing, a.Unexpected)
+				}
+				fmt.Println()
+			}
+			if r.Result.CountedKeys > 0 {
+				fmt.Printf("counted keys: %d (%+d)\n", r.Result.CountedKeys, r.Result.KeyDiscrepancy)
+			}
+			if res := r.Result; res.Verified+res.Mismatches+res.Missing > 0 {
+				fmt.Printf("   verified: %d values, %d mismatches, %d missing\n", res.Verified, res.Mismatches, res.Missing)
+			}
+		}
+		if len(r.Disk) > 0 {
+			fmt.Printf("  disk size:\n")
+			for _, d := range thinDisk(r.Disk, maxDiskSamples) {
+				fmt.Printf("%10.1fs: %.3f mb", d.Time.Seconds(), float64(d.Size)/1024/1024)
+				if d.Files > 0 {
+					fmt.Printf(" in %d files", d.Files)
+				}
+				if amp := d.SpaceAmplification(); amp > 0 {
+					fmt.Printf(", amplification %.2f", amp)
+				}
+				if d.Estimate > 0 && d.Size > 0 {
+					fmt.Printf(", estimate %.3f mb (%+.1f%%)", float64(d.Estimate)/1024/1024, 100*(float64(d.Estimate)/float64(d.Size)-1))
+				}
+				fmt.Println()
+			}
+		}
+		if len(r.Memory) > 0 {
+			heap, sys, gcs := r.PeakMemory()
+			fmt.Printf("     memory: peak heap %.3f mb, peak sys %.3f mb, %d GCs\n", float64(heap)/1024/1024, float64(sys)/1024/1024, gcs)
+		}
+		printWindows(r)
+		printAnnotations(r)
+		fmt.Printf("  mean mb/s: %.3f (+- %.3f)\n", s.MeanBPS/1024/1024, s.StdBPS/1024/1024)
+		if s.Entries > 0 {
+			fmt.Printf("    latency: %v/entry, %v/commit (%.1f entries/commit)\n",
+				s.EntryLatency(), s.CommitLatency(), float64(s.Entries)/float64(s.Commits))
+			if s.TimerOverhead > 0 {
+				fmt.Printf("  corrected: %v/entry (timer overhead %v)\n", s.CorrectedEntryLatency(), s.TimerOverhead)
+			}
+		}
+		if *hints {
+			for _, h := range bench.Hints(r) {
+				fmt.Printf("       hint: %s\n", h)
+			}
+		}
+
+		label := r.Label()
+		if groups[label] == nil {
+			labels = append(labels, label)
+		}
+		groups[label] = append(groups[label], s)
+	}
+
+	// Aggregate repeated runs of the same test.
+	for _, label := range labels {
+		runs := groups[label]
+		if len(runs) < 2 {
+			continue
+		}
+		bps := make([]float64, len(runs))
+		for i, s := range runs {
+			bps[i] = s.BPS()
+		}
+		outliers := bench.Outliers(bps, *zscore)
+		var included []float64
+		for i, v := range bps {
+			if outliers[i] {
+				fmt.Printf("== %s: run %d is an outlier (%.3f mb/s)", label, i+1, v/1024/1024)
+				if *exclude {
+					fmt.Printf(", excluded from aggregate")
+				}
+				fmt.Println()
+				if *exclude {
+					continue
+				}
+			}
+			included = append(included, v)
+		}
+		mean, std := stat.MeanStdDev(included, nil)
+		fmt.Printf("== %s (%d runs)\n", label, len(included))
+		fmt.Printf("  mean mb/s: %.3f (+- %.3f)\n", mean/1024/1024, std/1024/1024)
+	}
+
+	// Show throughput against the parameter of test families.
+	for _, f := range bench.Families(reports) {
+		fmt.Printf("== %s\n", f.Label())
+		for _, p := range f.Points {
+			fmt.Printf("%11d: %.3f mb/s", p.Param, p.BPS/1024/1024)
+			if p.Runs > 1 {
+				fmt.Printf(" (%d runs)", p.Runs)
+			}
+			fmt.Println()
+		}
+	}
+}
+
+// maxDiskSamples is the number of disk usage samples listed per report.
+const maxDiskSamples = 20
+
+// thinDisk picks about n evenly spaced samples, always including the last one.
+func thinDisk(samples []bench.DiskUsage, n int) []bench.DiskUsage {
+	if len(samples) <= n {
+		return samples
+	}
+	step := (len(samples) + n - 1) / n
+	var out []bench.DiskUsage
+	for i := 0; i < len(samples)-1; i += step {
+		out = append(out, samples[i])
+	}
+	return append(out, samples[len(samples)-1])
+}
+
+// maxWindows is the number of windows listed per name.
+const maxWindows = 10
+
+// printWindows shows the throughput during and outside of measurement windows.
+func printWindows(r bench.Report) {
+	var names []string
+	count := make(map[string]int)
+	for _, w := range r.Windows {
+		if count[w.Name] == 0 {
+			names = append(names, w.Name)
+		}
+		count[w.Name]++
+	}
+	for _, name := range names {
+		in, out := r.WindowBPS(name)
+		fmt.Printf("%11s: %d windows, %.3f mb/s inside", name, count[name], in/1024/1024)
+		if out > 0 {
+			fmt.Printf(", %.3f mb/s outside (%+.1f%%)", out/1024/1024, 100*(in-out)/out)
+		}
+		fmt.Println()
+		if lin, lout := r.WindowLatency(name); lin > 0 {
+			fmt.Printf("%11s  latency %v/entry inside", "", lin)
+			if lout > 0 {
+				fmt.Printf(", %v/entry outside (penalty %.2fx)", lout, float64(lin)/float64(lout))
+			}
+			fmt.Println()
+		}
+		shown := 0
+		for _, w := range r.Windows {
+			if w.Name != name {
+				continue
+			}
+			if shown++; shown > maxWindows {
+				fmt.Printf("%11s  ... %d more\n", "", count[name]-maxWindows)
+				break
+			}
+			fmt.Printf("%10.1fs: %v", w.Time.Seconds(), w.Duration)
+			if len(r.Disk) > 0 {
+				if t, ok := r.ReclaimTime(w); ok {
+					fmt.Printf(", space reclaimed after %v", t)
+				} else {
+					fmt.Printf(", space not reclaimed")
+				}
+			}
+			fmt.Println()
+		}
+	}
+}
+
+// maxAnnotations is the number of detector annotations listed per run.
+const maxAnnotations = 20
+
+// printAnnotations lists the findings of detectors.
+func printAnnotations(r bench.Report) {
+	if len(r.Annotations) == 0 {
+		return
+	}
+	fmt.Printf("annotations: %d\n", len(r.Annotations))
+	for i, a := range r.Annotations {
+		if i == maxAnnotations {
+			fmt.Printf("%11s  ... %d more\n", "", len(r.Annotations)-maxAnnotations)
+			break
+		}
+		fmt.Printf("%10.1fs: %s: %s\n", a.Time.Seconds(), a.Detector, a.Message)
+	}
+}
+
+// printCompaction prints the compaction totals and the number of tables per
+// level of the last compaction statistics sample.
+func printCompaction(c *bench.CompactionStats) {
+	read, write, d := c.Totals()
+	var tables []string
+	for _, l := range c.Levels {
+		tables = append(tables, strconv.Itoa(l.Tables))
+	}
+	fmt.Printf(" compaction: %.3f mb read, %.3f mb written in %v, tables per level %s\n",
+		float64(read)/1024/1024, float64(write)/1024/1024, d.Round(time.Millisecond), strings.Join(tables, "/"))
+}
+
+// printPeakTables prints the highest number of tables per level during the run.
+func printPeakTables(r *bench.Report) {
+	peak, l0 := r.PeakTables()
+	if len(peak) == 0 {
+		return
+	}
+	tables := make([]string, len(peak))
+	for i, n := range peak {
+		tables[i] = strconv.Itoa(n)
+	}
+	fmt.Printf("peak tables: %s per level, level 0 peak at %.1fs\n", strings.Join(tables, "/"), l0.Seconds())
+}
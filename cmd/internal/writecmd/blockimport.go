@@ -0,0 +1,103 @@
+package writecmd
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"time"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/fjl/goleveldb-bench/cmd/internal/kvstore"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// blockImport mimics chain import in geth. Every block is a single batch
+// containing the header, body and receipts, keyed by block number and hash,
+// plus the trie nodes written by the block. The batch is committed with sync
+// and the commit time is recorded as the durable-write latency.
+type blockImport struct {
+	Options opt.Options
+	Nodes   int // trie nodes per block
+
+	// Sizes of block data. Body and receipts sizes vary by +-50%.
+	HeaderSize, BodySize, ReceiptsSize int
+}
+
+// Value size range of trie nodes.
+const (
+	blockNodeMin = 64
+	blockNodeMax = 512
+)
+
+func (b blockImport) configure(cfg *bench.WriteConfig) {
+	// Generated values are trie nodes. Scale the size so -size is the
+	// approximate total amount of data.
+	cfg.KeySize = 32
+	cfg.DataSize, cfg.MaxDataSize = blockNodeMin, blockNodeMax
+	nodes := uint64(b.Nodes * (blockNodeMin + blockNodeMax) / 2)
+	cfg.Size = cfg.Size * nodes / (nodes + uint64(b.HeaderSize+b.BodySize+b.ReceiptsSize))
+	// Block data is stored next to the generated keys.
+	cfg.Transformed = true
+}
+
+// blockKey returns the key of a block data item.
+func blockKey(prefix byte, number uint64, hash []byte) []byte {
+	key := make([]byte, 1+8+len(hash))
+	key[0] = prefix
+	binary.BigEndian.PutUint64(key[1:], number)
+	copy(key[9:], hash)
+	return key
+}
+
+func (b blockImport) Benchmark(dir string, env *bench.WriteEnv) error {
+	db, err := openDB(dir, &b.Options, env)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var (
+		rng    = rand.New(rand.NewSource(1))
+		pool   = make([]byte, 4*(b.BodySize+b.ReceiptsSize))
+		batch  = db.NewBatch()
+		bsize  = 0
+		number = uint64(0)
+		hash   = make([]byte, 32)
+		wopt   = &kvstore.WriteOptions{Sync: true}
+	)
+	rng.Read(pool)
+	// blob returns random data of the given mean size.
+	blob := func(mean int) []byte {
+		size := mean/2 + rng.Intn(mean+1)
+		off := rng.Intn(len(pool) - size)
+		return pool[off : off+size]
+	}
+	return env.Run(func(key, value string, lastCall bool) error {
+		batch.Put([]byte(key), []byte(value))
+		bsize += len(value)
+		if batch.Len() < b.Nodes && !lastCall {
+			return nil
+		}
+		rng.Read(hash)
+		for _, item := range []struct {
+			prefix byte
+			value  []byte
+		}{
+			{'h', pool[:b.HeaderSize]},
+			{'b', blob(b.BodySize)},
+			{'r', blob(b.ReceiptsSize)},
+		} {
+			batch.Put(blockKey(item.prefix, number, hash), item.value)
+			bsize += len(item.value)
+		}
+		number++
+		begin := time.Now()
+		if err := batch.Write(wopt); err != nil {
+			return err
+		}
+		env.DurableWrite(time.Since(begin))
+		env.ProgressBatch(bsize, batch.Len())
+		bsize = 0
+		batch.Reset()
+		return nil
+	})
+}
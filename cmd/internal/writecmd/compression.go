@@ -0,0 +1,44 @@
+package writecmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fjl/goleveldb-bench/cmd/internal/kvstore"
+)
+
+// sweepLevelCompression expands every run into one run per compression
+// configuration. A configuration lists the compression of every level,
+// separated by '/', the last entry applies to all remaining levels.
+// Configurations the storage engine can't run are marked unsupported.
+func sweepLevelCompression(runs []testRun, spec string) ([]testRun, error) {
+	var out []testRun
+	for _, c := range strings.Split(spec, ",") {
+		c = strings.TrimSpace(c)
+		levels := strings.Split(c, "/")
+		for _, l := range levels {
+			if !isCompression(l) {
+				return nil, fmt.Errorf("unknown compression %q", l)
+			}
+		}
+		for _, r := range runs {
+			sr := r
+			sr.name = r.name + "-compress-" + strings.Replace(c, "/", "_", -1)
+			sr.cfg.Tags = copyTags(r.cfg.Tags)
+			sr.cfg.Tags["levelcompression"] = c
+			sr.compression = levels
+			sr.unsupported = kvstore.CompressionSupport(dbEngine, levels)
+			out = append(out, sr)
+		}
+	}
+	return out, nil
+}
+
+func isCompression(name string) bool {
+	for _, n := range kvstore.CompressionNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package writecmd
+
+import (
+	"log"
+	"time"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/fjl/goleveldb-bench/cmd/internal/ldbstore"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+)
+
+// diskCapacity is the simulated disk size of the disk-full test, set by
+// -diskcapacity. Zero means half of -size.
+var diskCapacity uint64
+
+// diskFull performs batch writes on storage that runs out of space. The period
+// from the first rejected file write until the end of the run is logged as
+// window "diskfull". Failed batches are skipped until maxFailedBatches fail in
+// a row. The run then ends with the write error, which is recorded in the
+// result, and the database is reopened on healthy storage.
+type diskFull struct {
+	Options   opt.Options
+	BatchSize int
+}
+
+func (b diskFull) configure(cfg *bench.WriteConfig) {
+	// Failed batches are missing from the database.
+	cfg.Transformed = true
+}
+
+func (diskFull) levelDBOnly() {}
+
+func (b diskFull) Benchmark(dir string, env *bench.WriteEnv) error {
+	capacity := diskCapacity
+	if capacity == 0 {
+		capacity = env.Config().Size / 2
+	}
+	var fs *ldbstore.FullStorage
+	db, err := openWrappedDB(dir, &b.Options, env, func(s storage.Storage) storage.Storage {
+		fs = ldbstore.NewFullStorage(s, capacity)
+		return fs
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("disk capacity %d bytes", capacity)
+
+	var (
+		batch    = db.NewBatch()
+		bsize    = 0
+		begin    = time.Now()
+		batches  = 0
+		failed   = 0
+		failedRn = 0 // consecutive failures
+		endFull  func()
+	)
+	err = env.Run(func(key, value string, lastCall bool) error {
+		batch.Put([]byte(key), []byte(value))
+		bsize += len(value)
+		if bsize < b.BatchSize && !lastCall {
+			return nil
+		}
+		batches++
+		err := batch.Write(nil)
+		if endFull == nil && fs.Stats().Rejected > 0 {
+			log.Printf("disk full after %v, batch %d", time.Since(begin), batches)
+			endFull = env.Window("diskfull")
+		}
+		if err != nil {
+			if failed == 0 {
+				log.Printf("first failed batch %d after %v: %v", batches, time.Since(begin), err)
+			}
+			failed++
+			if failedRn++; failedRn >= maxFailedBatches {
+				return err
+			}
+		} else {
+			failedRn = 0
+			env.ProgressBatch(bsize, batch.Len())
+		}
+		bsize = 0
+		batch.Reset()
+		return nil
+	})
+	if endFull != nil {
+		endFull()
+	}
+	stats := fs.Stats()
+	log.Printf("%d of %d batches failed; %d file writes rejected, peak usage %d bytes",
+		failed, batches, stats.Rejected, stats.Peak)
+	if err != nil {
+		log.Printf("stopped after %d consecutive failures: %v", failedRn, err)
+	}
+	if cerr := db.Close(); cerr != nil {
+		log.Printf("close error: %v", cerr)
+	}
+	return checkReopen(dir)
+}
@@ -0,0 +1,131 @@
+package writecmd
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/fjl/goleveldb-bench/cmd/internal/kvstore"
+	"github.com/fjl/goleveldb-bench/cmd/internal/ldbstore"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+)
+
+// faultRate is the probability of an injected fault per write or sync call
+// in fault tests, set by -faultrate.
+var faultRate = 0.001
+
+// maxFailedBatches is the number of consecutive failed batches after which a
+// fault test assumes the database won't recover.
+const maxFailedBatches = 100
+
+var errStuck = errors.New("database doesn't accept writes anymore")
+
+// faultWrite performs batch writes on storage which fails writes or syncs at
+// random. Failed batches are logged and skipped. After the run, the database
+// is reopened on healthy storage to check whether it recovers.
+type faultWrite struct {
+	Options   opt.Options
+	BatchSize int
+	Sync      bool // sync every batch and inject sync errors
+}
+
+func (b faultWrite) configure(cfg *bench.WriteConfig) {
+	// Failed batches are missing from the database.
+	cfg.Transformed = true
+}
+
+func (faultWrite) levelDBOnly() {}
+
+func (b faultWrite) Benchmark(dir string, env *bench.WriteEnv) error {
+	fcfg := ldbstore.FaultConfig{WriteRate: faultRate, Seed: 1}
+	if b.Sync {
+		fcfg = ldbstore.FaultConfig{SyncRate: faultRate, Seed: 1}
+	}
+	var fs *ldbstore.FaultStorage
+	db, err := openWrappedDB(dir, &b.Options, env, func(s storage.Storage) storage.Storage {
+		fs = ldbstore.NewFaultStorage(s, fcfg)
+		return fs
+	})
+	if err != nil {
+		return err
+	}
+
+	var (
+		batch    = db.NewBatch()
+		bsize    = 0
+		wopt     = &kvstore.WriteOptions{Sync: b.Sync}
+		batches  = 0
+		failed   = 0
+		failedRn = 0 // consecutive failures
+		failures = make(map[string]int)
+	)
+	err = env.Run(func(key, value string, lastCall bool) error {
+		batch.Put([]byte(key), []byte(value))
+		bsize += len(value)
+		if bsize < b.BatchSize && !lastCall {
+			return nil
+		}
+		batches++
+		if err := batch.Write(wopt); err != nil {
+			if failures[err.Error()] == 0 {
+				log.Printf("batch %d failed: %v", batches, err)
+			}
+			failures[err.Error()]++
+			failed++
+			if failedRn++; failedRn >= maxFailedBatches {
+				return errStuck
+			}
+		} else {
+			failedRn = 0
+			env.ProgressBatch(bsize, batch.Len())
+		}
+		bsize = 0
+		batch.Reset()
+		return nil
+	})
+	stats := fs.Stats()
+	log.Printf("injected %d of %d writes, %d of %d syncs failed; %d of %d batches failed",
+		stats.WriteFaults, stats.Writes, stats.SyncFaults, stats.Syncs, failed, batches)
+	for msg, n := range failures {
+		log.Printf("write error (%dx): %s", n, msg)
+	}
+	if err == errStuck {
+		log.Print(err)
+		err = nil
+	}
+	if cerr := db.Close(); cerr != nil {
+		log.Printf("close error: %v", cerr)
+	}
+	if err != nil {
+		return err
+	}
+	return checkReopen(dir)
+}
+
+// checkReopen opens the database on healthy storage and logs the time it took
+// and the number of keys.
+func checkReopen(dir string) error {
+	begin := time.Now()
+	db, err := leveldb.OpenFile(dir, nil)
+	if err != nil {
+		log.Printf("database can't be reopened: %v", err)
+		return nil
+	}
+	defer db.Close()
+	opened := time.Since(begin)
+	it := db.NewIterator(nil, nil)
+	defer it.Release()
+	n := 0
+	for it.Next() {
+		n++
+	}
+	if err := it.Error(); err != nil {
+		log.Printf("database reopened in %v, iteration failed after %d keys: %v", opened, n, err)
+		return nil
+	}
+	log.Printf("database reopened in %v, %d keys", opened, n)
+	return nil
+}
@@ -0,0 +1,100 @@
+package writecmd
+
+import (
+	"encoding/binary"
+	"log"
+	"time"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/fjl/goleveldb-bench/cmd/internal/kvstore"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// freezer models the migration of ancient chain data in geth. Block-sized
+// values are appended under sequential keys, then the same ranges are deleted
+// from the database again, oldest first. The two phases are logged as the
+// "append" and "delete" windows.
+type freezer struct {
+	Options     opt.Options
+	BlockSize   uint64
+	BatchSize   int // bytes per append batch
+	DeleteRange int // blocks per delete batch
+}
+
+func (b freezer) configure(cfg *bench.WriteConfig) {
+	cfg.DataSize, cfg.MaxDataSize = b.BlockSize, 0
+	cfg.SampleDisk = true
+	// Progress covers both phases, so the percentage would be misleading.
+	cfg.LogPercent = false
+	// Generated keys aren't stored, and all blocks are deleted at the end.
+	cfg.Transformed = true
+}
+
+// freezerKey returns the key of block n.
+func freezerKey(n uint64) []byte {
+	key := make([]byte, 9)
+	key[0] = 'b'
+	binary.BigEndian.PutUint64(key[1:], n)
+	return key
+}
+
+func (b freezer) Benchmark(dir string, env *bench.WriteEnv) error {
+	db, err := openDB(dir, &b.Options, env)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var (
+		batch     = db.NewBatch()
+		bsize     = 0
+		blocks    = uint64(0)
+		begin     time.Time
+		endAppend func()
+	)
+	return env.Run(func(key, value string, lastCall bool) error {
+		if endAppend == nil {
+			begin, endAppend = time.Now(), env.Window("append")
+		}
+		batch.Put(freezerKey(blocks), []byte(value))
+		blocks++
+		bsize += len(value)
+		if bsize >= b.BatchSize || lastCall {
+			if err := batch.Write(nil); err != nil {
+				return err
+			}
+			env.ProgressBatch(bsize, batch.Len())
+			bsize = 0
+			batch.Reset()
+		}
+		if !lastCall {
+			return nil
+		}
+		endAppend()
+		log.Printf("appended %d blocks in %v", blocks, time.Since(begin))
+		return b.deleteBlocks(db, env, blocks)
+	})
+}
+
+// deleteBlocks removes blocks [0, n) in ranges of b.DeleteRange.
+func (b freezer) deleteBlocks(db kvstore.Store, env *bench.WriteEnv, n uint64) error {
+	defer env.Window("delete")()
+	begin := time.Now()
+	batch := db.NewBatch()
+	for start := uint64(0); start < n; start += uint64(b.DeleteRange) {
+		end := start + uint64(b.DeleteRange)
+		if end > n {
+			end = n
+		}
+		for i := start; i < end; i++ {
+			batch.Delete(freezerKey(i))
+		}
+		if err := batch.Write(nil); err != nil {
+			return err
+		}
+		env.ProgressBatch(int(end-start)*int(b.BlockSize), batch.Len())
+		batch.Reset()
+	}
+	log.Printf("deleted %d blocks in %v", n, time.Since(begin))
+	return nil
+}
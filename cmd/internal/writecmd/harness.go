@@ -0,0 +1,85 @@
+package writecmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"runtime/pprof"
+
+	bench "github.com/fjl/goleveldb-bench"
+)
+
+// harnessCheckSize is the maximum amount of data written by -checkharness runs.
+const harnessCheckSize = 32 * 1024 * 1024
+
+// harnessGroups classifies profile samples for -checkharness.
+var harnessGroups = map[string][]string{
+	"harness": {"main.", "github.com/fjl/goleveldb-bench"},
+	"engine": {
+		"github.com/syndtr/goleveldb/",
+		"github.com/cockroachdb/pebble",
+		"github.com/dgraph-io/",
+		"go.etcd.io/bbolt",
+		"github.com/linxGnu/grocksdb",
+		"github.com/bmatsuo/lmdb-go",
+		"github.com/fjl/goleveldb-bench/cmd/internal/kvstore.(*flat", // the flatfile engine
+	},
+}
+
+// checkHarness runs a short version of every test under the CPU profiler and
+// reports how much CPU time is spent in the benchmark code rather than the
+// database. It returns false if the check couldn't be performed.
+func checkHarness(dbbase string, runs []testRun, threshold float64) bool {
+	ok := true
+	for _, r := range runs {
+		if r.unsupported != "" {
+			log.Printf("%s: skipping harness check: %s", r.name, r.unsupported)
+			continue
+		}
+		shares, err := profileRun(dbbase, r)
+		if err != nil {
+			log.Printf("%s: harness check failed: %v", r.name, err)
+			ok = false
+			continue
+		}
+		harness := shares["harness"]
+		log.Printf("%s: harness %.1f%%, engine %.1f%%, other %.1f%% of CPU time", r.name,
+			100*harness, 100*shares["engine"], 100*shares[bench.OtherGroup])
+		if harness > threshold {
+			log.Printf("WARNING: %s spends %.1f%% of CPU time in the benchmark harness (threshold %.0f%%)!",
+				r.name, 100*harness, 100*threshold)
+			log.Printf("WARNING: results of %s measure the harness as much as the database.", r.name)
+		}
+	}
+	return ok
+}
+
+func profileRun(dbbase string, r testRun) (map[string]float64, error) {
+	cfg := r.cfg
+	cfg.TestName = r.name
+	if c, ok := r.test.(configurer); ok {
+		c.configure(&cfg)
+	}
+	if cfg.Size > harnessCheckSize {
+		cfg.Size = harnessCheckSize
+	}
+	cfg.LogPercent = false
+	cfg.CountKeys = false
+	dir, err := ioutil.TempDir(dbbase, "harness-check-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	var profile bytes.Buffer
+	if err := pprof.StartCPUProfile(&profile); err != nil {
+		return nil, err
+	}
+	err = r.test.Benchmark(dir, bench.NewWriteEnv(ioutil.Discard, cfg))
+	pprof.StopCPUProfile()
+	if err != nil {
+		return nil, err
+	}
+	return bench.CPUShares(profile.Bytes(), harnessGroups)
+}
@@ -0,0 +1,110 @@
+package writecmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/fjl/goleveldb-bench/cmd/internal/cmdutil"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// lockProbeEnv is the environment variable which turns the benchmark binary
+// into a lock probe. Its value is "ro:<dir>" or "rw:<dir>".
+const lockProbeEnv = "LDB_BENCH_LOCKPROBE"
+
+// lockProbeOpened is the exit status of a lock probe which opened the database.
+const lockProbeOpened = 2
+
+// runLockProbe tries to open the database named by the probe environment
+// variable. It prints the error and exits with status 0 if the database is
+// locked, or with status lockProbeOpened if it could be opened.
+func runLockProbe(spec string) {
+	ro := strings.HasPrefix(spec, "ro:")
+	dir := spec[strings.IndexByte(spec, ':')+1:]
+	db, err := leveldb.OpenFile(dir, &opt.Options{ReadOnly: ro, ErrorIfMissing: true})
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(0)
+	}
+	db.Close()
+	fmt.Println("database opened")
+	os.Exit(lockProbeOpened)
+}
+
+// lockContention performs batch writes while a second process repeatedly tries
+// to open the database, alternating between read-write and read-only mode.
+// Every attempt is logged as a window, so the impact of the attempts on write
+// throughput can be measured.
+type lockContention struct {
+	batchWrite
+	Interval time.Duration
+}
+
+func (lockContention) levelDBOnly() {}
+
+func (b lockContention) Benchmark(dir string, env *bench.WriteEnv) error {
+	var (
+		stop = make(chan struct{})
+		wg   sync.WaitGroup
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		b.probeLoop(dir, env, stop)
+	}()
+	defer wg.Wait()
+	defer close(stop)
+	return b.batchWrite.Benchmark(dir, env)
+}
+
+func (b lockContention) probeLoop(dir string, env *bench.WriteEnv, stop <-chan struct{}) {
+	var (
+		tick     = time.NewTicker(b.Interval)
+		attempts = 0
+		opened   = 0
+		results  = make(map[string]int)
+	)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+		case <-stop:
+			log.Printf("lock probes: %d attempts, %d opened the database", attempts, opened)
+			for msg, n := range results {
+				log.Printf("lock probe result (%dx): %s", n, msg)
+			}
+			return
+		}
+		mode := "rw"
+		if attempts%2 == 1 {
+			mode = "ro"
+		}
+		attempts++
+		cmd, err := cmdutil.Self(context.Background())
+		if err != nil {
+			log.Printf("can't run lock probe: %v", err)
+			return
+		}
+		cmd.Env = append(os.Environ(), lockProbeEnv+"="+mode+":"+dir)
+		end := env.Window("lockprobe")
+		out, err := cmd.Output()
+		end()
+		msg := mode + ": " + strings.TrimSpace(string(out))
+		if err != nil {
+			if exit, ok := err.(*exec.ExitError); !ok || exit.ExitCode() != lockProbeOpened {
+				log.Printf("lock probe failed: %v: %s", err, msg)
+				continue
+			}
+			opened++
+		}
+		results[msg]++
+	}
+}
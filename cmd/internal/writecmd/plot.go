@@ -0,0 +1,39 @@
+package writecmd
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/fjl/goleveldb-bench/benchplot"
+)
+
+// suitePlots are the plots created by -plot.
+var suitePlots = map[string]string{
+	"bps":     "throughput.png",
+	"latency": "latency.png",
+}
+
+// plotSuite renders plots of all test logs into the log directory.
+func plotSuite(logdir string, runs []testRun) error {
+	var files []string
+	for _, r := range runs {
+		file := filepath.Join(logdir, r.name+".json")
+		if _, err := os.Stat(file); err == nil && r.unsupported == "" {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	reports := bench.MustReadReports(files)
+	for plotType, name := range suitePlots {
+		file := filepath.Join(logdir, name)
+		if err := benchplot.Save(plotType, reports, benchplot.DefaultSize, file); err != nil {
+			return err
+		}
+		log.Printf("wrote %s", file)
+	}
+	return nil
+}
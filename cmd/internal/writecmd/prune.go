@@ -0,0 +1,125 @@
+package writecmd
+
+import (
+	"log"
+	"time"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/fjl/goleveldb-bench/cmd/internal/kvstore"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// prune emulates state pruning. The run is split into phases and keys written
+// in phase i get the one-byte prefix i. When phase i starts, all keys of phase
+// i-2 are deleted in the background while writing continues.
+type prune struct {
+	Options opt.Options
+	Phases  int
+}
+
+func (b prune) configure(cfg *bench.WriteConfig) {
+	cfg.SampleDisk = true
+	// Keys are prefixed with the phase and partially deleted.
+	cfg.Transformed = true
+}
+
+func (b prune) Benchmark(dir string, env *bench.WriteEnv) error {
+	db, err := openDB(dir, &b.Options, env)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var (
+		total   = env.Config().Size
+		batch   = db.NewBatch()
+		bsize   = 0
+		written = uint64(0)
+		phase   = 0
+		prunes  = make(chan byte, b.Phases)
+		done    = make(chan error, 1)
+	)
+	go func() {
+		var err error
+		for p := range prunes {
+			if err == nil {
+				err = pruneRange(db, env, p)
+			}
+		}
+		done <- err
+	}()
+
+	err = env.Run(func(key, value string, lastCall bool) error {
+		if p := int(written * uint64(b.Phases) / total); p > phase {
+			phase = p
+			if phase >= 2 {
+				prunes <- byte(phase - 2)
+			}
+		}
+		k := []byte(key)
+		k[0] = byte(phase)
+		batch.Put(k, []byte(value))
+		bsize += len(value)
+		written += uint64(len(value))
+		if bsize >= 100*opt.KiB || lastCall {
+			if err := batch.Write(nil); err != nil {
+				return err
+			}
+			env.ProgressBatch(bsize, batch.Len())
+			bsize = 0
+			batch.Reset()
+		}
+		return nil
+	})
+	close(prunes)
+	if perr := <-done; err == nil {
+		err = perr
+	}
+	return err
+}
+
+// pruneRange deletes all keys with the given prefix. Keys are collected in
+// chunks and the iterator is released before each chunk is deleted, because
+// engines like bbolt can't grow the database while a read transaction is open.
+func pruneRange(db kvstore.Store, env *bench.WriteEnv, prefix byte) error {
+	defer env.Window("prune")()
+	var (
+		begin = time.Now()
+		batch = db.NewBatch()
+		n     = 0
+		r     = util.BytesPrefix([]byte{prefix})
+		start = r.Start
+	)
+	for {
+		keys, err := collectKeys(db, start, r.Limit, 10000)
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			break
+		}
+		for _, k := range keys {
+			batch.Delete(k)
+		}
+		if err := batch.Write(nil); err != nil {
+			return err
+		}
+		n += batch.Len()
+		batch.Reset()
+		start = append(keys[len(keys)-1], 0)
+	}
+	log.Printf("pruned %d keys with prefix %d in %v", n, prefix, time.Since(begin))
+	return nil
+}
+
+// collectKeys returns up to max keys in the given range.
+func collectKeys(db kvstore.Store, start, limit []byte, max int) ([][]byte, error) {
+	var keys [][]byte
+	it := db.Iterate(start, limit)
+	defer it.Release()
+	for len(keys) < max && it.Next() {
+		keys = append(keys, append([]byte(nil), it.Key()...))
+	}
+	return keys, it.Error()
+}
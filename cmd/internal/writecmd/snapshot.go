@@ -0,0 +1,111 @@
+package writecmd
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// Key prefixes of the geth snapshot layout.
+const (
+	snapAccountPrefix = 'a' // + account hash
+	snapStoragePrefix = 'o' // + account hash + slot hash
+)
+
+// snapshot writes a flat state snapshot like geth's snapshot generator. Account
+// entries have 33-byte keys, storage slots have 65-byte keys containing the
+// hash of their account. Entries are written in hash order, i.e. every account
+// is followed by its storage slots. One in five accounts is a contract with an
+// exponentially distributed number of slots, making four slots per account on
+// average.
+type snapshot struct {
+	Options   opt.Options
+	BatchSize int
+}
+
+const (
+	snapContractRatio = 0.2
+	snapMeanSlots     = 20 // per contract
+)
+
+func (b snapshot) configure(cfg *bench.WriteConfig) {
+	// The generated key provides the random part of the hashes.
+	cfg.KeySize = 32
+	cfg.DataSize, cfg.MaxDataSize = 1, 64
+	cfg.Transformed = true
+}
+
+// snapAccounts estimates the number of accounts written by a run. It is at least
+// one, so the account hashes can always be spread.
+func snapAccounts(cfg bench.WriteConfig) uint64 {
+	entrySize := (cfg.DataSize + cfg.MaxDataSize) / 2
+	if entrySize == 0 {
+		entrySize = 1
+	}
+	accounts := cfg.Size / entrySize / uint64(1+snapContractRatio*snapMeanSlots)
+	if accounts == 0 {
+		accounts = 1
+	}
+	return accounts
+}
+
+func (b snapshot) Benchmark(dir string, env *bench.WriteEnv) error {
+	db, err := openDB(dir, &b.Options, env)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var (
+		// Hashes are spread over the key space with room to spare, so the
+		// estimated number of accounts may be exceeded without breaking the order.
+		accounts = snapAccounts(env.Config())
+		step     = math.MaxUint64 / (2*accounts + 1)
+		rng      = rand.New(rand.NewSource(1))
+
+		batch    = db.NewBatch()
+		bsize    = 0
+		account  = uint64(0)
+		slots    = uint64(0) // slots of the current account
+		slot     = uint64(0) // next slot
+		accHash  [32]byte
+		keyBuf   [65]byte
+		slotStep uint64
+	)
+	return env.Run(func(key, value string, lastCall bool) error {
+		var k []byte
+		if slot == slots {
+			// Start the next account.
+			copy(accHash[:], key)
+			binary.BigEndian.PutUint64(accHash[:], account*step)
+			account++
+			slot, slots = 0, 0
+			if rng.Float64() < snapContractRatio {
+				slots = 1 + uint64(rng.ExpFloat64()*(snapMeanSlots-1))
+				slotStep = math.MaxUint64 / slots
+			}
+			k = append(keyBuf[:0], snapAccountPrefix)
+			k = append(k, accHash[:]...)
+		} else {
+			k = append(keyBuf[:0], snapStoragePrefix)
+			k = append(k, accHash[:]...)
+			k = append(k, key...)
+			binary.BigEndian.PutUint64(k[33:], slot*slotStep)
+			slot++
+		}
+		batch.Put(k, []byte(value))
+		bsize += len(value)
+		if bsize >= b.BatchSize || lastCall {
+			if err := batch.Write(nil); err != nil {
+				return err
+			}
+			env.ProgressBatch(bsize, batch.Len())
+			bsize = 0
+			batch.Reset()
+		}
+		return nil
+	})
+}
@@ -0,0 +1,217 @@
+package writecmd
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/fjl/goleveldb-bench/cmd/internal/kvstore"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"golang.org/x/sync/errgroup"
+)
+
+// These tests compare strategies for making writes durable. Every write is
+// acknowledged only after it has been synced, and the time until then is
+// reported as the durable-write latency.
+
+// Settings of the sync tests, set by -syncinterval and -syncwriters.
+var (
+	syncInterval = 10 * time.Millisecond
+	syncWriters  = 64
+)
+
+// syncKey is written by the group syncer to force a journal sync. goleveldb has
+// no explicit sync operation and skips empty batches.
+var syncKey = []byte("goleveldb-bench-sync")
+
+var syncWriteOptions = &kvstore.WriteOptions{Sync: true}
+
+// syncWrite performs every write with Sync set, from concurrent writers.
+type syncWrite struct {
+	Options opt.Options
+}
+
+func (b syncWrite) configure(cfg *bench.WriteConfig) {
+	checkAcks(cfg)
+}
+
+func (b syncWrite) Benchmark(dir string, env *bench.WriteEnv) error {
+	db, err := openDB(dir, &b.Options, env)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return runWriters(env, syncWriters, func(ctx context.Context, k kv) error {
+		begin := time.Now()
+		if err := db.Put([]byte(k.k), []byte(k.v), syncWriteOptions); err != nil {
+			return err
+		}
+		env.DurableWrite(time.Since(begin))
+		env.Ack([]byte(k.k))
+		env.Progress(len(k.v))
+		return nil
+	}, nil)
+}
+
+// syncBatch collects writes into batches, which are written with Sync set.
+// The latency of a write includes the time spent waiting for the batch to fill.
+type syncBatch struct {
+	Options   opt.Options
+	BatchSize int
+}
+
+func (b syncBatch) Benchmark(dir string, env *bench.WriteEnv) error {
+	db, err := openDB(dir, &b.Options, env)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var (
+		batch   = db.NewBatch()
+		bsize   = 0
+		pending []time.Time
+	)
+	return env.Run(func(key, value string, lastCall bool) error {
+		pending = append(pending, time.Now())
+		batch.Put([]byte(key), []byte(value))
+		bsize += len(value)
+		if bsize >= b.BatchSize || lastCall {
+			if err := batch.Write(syncWriteOptions); err != nil {
+				return err
+			}
+			now := time.Now()
+			for _, t := range pending {
+				env.DurableWrite(now.Sub(t))
+			}
+			env.ProgressBatch(bsize, batch.Len())
+			bsize, pending = 0, pending[:0]
+			batch.Reset()
+		}
+		return nil
+	})
+}
+
+// syncGroup performs unsynced writes from concurrent writers. A separate
+// goroutine syncs the journal once per interval and acknowledges all writes
+// which completed before the sync.
+type syncGroup struct {
+	Options opt.Options
+}
+
+func (b syncGroup) Benchmark(dir string, env *bench.WriteEnv) error {
+	db, err := openDB(dir, &b.Options, env)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var (
+		mu      sync.Mutex
+		acked   = make(chan struct{}) // closed by the next sync
+		waiting = 0
+	)
+	// next returns the channel closed when the next sync is done.
+	next := func() chan struct{} {
+		mu.Lock()
+		defer mu.Unlock()
+		waiting++
+		return acked
+	}
+	syncer := func(ctx context.Context, stop <-chan struct{}) error {
+		tick := time.NewTicker(syncInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+			case <-stop:
+				return nil
+			case <-ctx.Done():
+				return nil
+			}
+			mu.Lock()
+			ch, n := acked, waiting
+			if n > 0 {
+				acked, waiting = make(chan struct{}), 0
+			}
+			mu.Unlock()
+			if n == 0 {
+				continue
+			}
+			if err := db.Put(syncKey, nil, syncWriteOptions); err != nil {
+				return err
+			}
+			close(ch)
+		}
+	}
+	return runWriters(env, syncWriters, func(ctx context.Context, k kv) error {
+		begin := time.Now()
+		if err := db.Put([]byte(k.k), []byte(k.v), nil); err != nil {
+			return err
+		}
+		select {
+		case <-next():
+		case <-ctx.Done():
+			return nil
+		}
+		env.DurableWrite(time.Since(begin))
+		env.Progress(len(k.v))
+		return nil
+	}, syncer)
+}
+
+// runWriters runs env.Run, handing writes to n concurrent writer goroutines.
+// Unlike concurrentWrite, all generated writes are performed before the run
+// ends. If bg is non-nil, it runs alongside the writers and is asked to stop
+// when they are done.
+func runWriters(env *bench.WriteEnv, n int, write func(context.Context, kv) error, bg func(ctx context.Context, stop <-chan struct{}) error) error {
+	var (
+		queue   = make(chan kv, n)
+		eg, ctx = errgroup.WithContext(context.Background())
+		writers sync.WaitGroup
+	)
+	writers.Add(n)
+	for i := 0; i < n; i++ {
+		eg.Go(func() error {
+			defer writers.Done()
+			for {
+				select {
+				case k, ok := <-queue:
+					if !ok {
+						return nil
+					}
+					if err := env.TimeWrite(func() error { return write(ctx, k) }); err != nil {
+						return err
+					}
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		})
+	}
+	if bg != nil {
+		stop := make(chan struct{})
+		go func() {
+			writers.Wait()
+			close(stop)
+		}()
+		eg.Go(func() error {
+			return bg(ctx, stop)
+		})
+	}
+
+	env.TimeWrites()
+	return env.Run(func(key, value string, lastCall bool) error {
+		select {
+		case queue <- kv{k: key, v: value}:
+		case <-ctx.Done():
+			lastCall = true
+		}
+		if lastCall {
+			close(queue)
+			return eg.Wait()
+		}
+		return nil
+	})
+}
@@ -0,0 +1,1004 @@
+package writecmd
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/fjl/goleveldb-bench/cmd/internal/cmdutil"
+	"github.com/fjl/goleveldb-bench/cmd/internal/kvstore"
+	"github.com/fjl/goleveldb-bench/cmd/internal/ldbstore"
+	"github.com/syndtr/goleveldb/leveldb/comparer"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"golang.org/x/sync/errgroup"
+)
+
+// Main runs the write benchmark command.
+func Main(name string, args []string) {
+	if spec := os.Getenv(lockProbeEnv); spec != "" {
+		runLockProbe(spec)
+	}
+	var (
+		fs = cmdutil.FlagSet(name, "[flags]")
+
+		testflag      = fs.String("test", "", "tests to run ("+strings.Join(testnames(), ", ")+")")
+		sizeflag      = cmdutil.Size(fs, "size", "500mb", "total amount of value data to write")
+		datasizeflag  = cmdutil.Size(fs, "valuesize", "100b", "size of each value")
+		keysizeflag   = cmdutil.Size(fs, "keysize", "32b", "size of each key")
+		keysweepflag  = fs.String("keysizes", "", "comma-separated key sizes to run each test with (overrides -keysize)")
+		compressflag  = fs.String("levelcompression", "", "comma-separated per-level compression configurations to run each test with, e.g. none,snappy/zstd")
+		dbflag        = fs.String("db", kvstore.Default, "storage engine ("+strings.Join(kvstore.Names(), ", ")+")")
+		dirflag       = fs.String("dir", ".", "test database directory")
+		logdirflag    = fs.String("logdir", ".", "test log output directory")
+		deletedbflag  = fs.Bool("deletedb", false, "delete databases after test run")
+		ramdiskflag   = fs.String("ramdisk", "", "place databases on a tmpfs of this size")
+		slowflag      = fs.String("slowdisk", "", cmdutil.SlowDiskUsage)
+		genflag       = fs.Int("generators", 0, "number of key/value generator goroutines (0 = generate inline, -1 = GOMAXPROCS)")
+		orderedflag   = fs.Bool("ordered", false, "deliver keys/values from generators in deterministic order")
+		uniqueflag    = fs.Bool("uniquekeys", false, "guarantee that every generated key is unique")
+		ackflag       = fs.Bool("checkacks", false, "check the acknowledged writes of concurrent tests after the run (implies -uniquekeys for them)")
+		stallflag     = fs.Duration("stall", 100*time.Millisecond, "log write operations taking longer than this as stalls (0 = disabled)")
+		countflag     = fs.Bool("countkeys", false, "count keys in the database after each test")
+		sampleflag    = fs.Int("countsample", 1, "count only 1/n of the key space with -countkeys")
+		verifyflag    = fs.Bool("verify", false, "read back all written values after each test and check them")
+		blobminflag   = cmdutil.Size(fs, "blobmin", "256kb", "minimum value size of blob tests")
+		blobmaxflag   = cmdutil.Size(fs, "blobmax", "4mb", "maximum value size of blob tests")
+		compactflag   = fs.String("compactevery", "", "compact a rolling key range every time this much data is written")
+		syncflag      = fs.Duration("syncinterval", syncInterval, "interval between syncs of the sync-group test")
+		faultflag     = fs.Float64("faultrate", faultRate, "probability of an injected error per write or sync call in fault tests")
+		capacityflag  = fs.String("diskcapacity", "", "simulated disk size of the disk-full test (default half of -size)")
+		writersflag   = fs.Int("syncwriters", syncWriters, "number of concurrent writers in sync-write and sync-group tests")
+		diskflag      = fs.Bool("sampledisk", false, "sample the database size on disk, its file count and the size estimated by the database whenever progress is logged")
+		memflag       = fs.Bool("samplemem", false, "log Go heap size, memory obtained from the OS and GC count whenever progress is logged")
+		intervalflag  = fs.String("report-interval", "500kb", "distance between progress log entries, either data processed (e.g. 1mb) or time (e.g. 10s)")
+		watchflag     = fs.Duration("diskwatch", 10*time.Second, "sample database size for this long after delete tests")
+		traceflag     = fs.String("trace", "", "trace file for the replay test")
+		realtimeflag  = fs.Bool("realtime", false, "replay trace at original speed")
+		recordflag    = fs.Bool("record", false, "record generated operations to a trace file in the log directory")
+		latlogflag    = fs.Bool("latlog", false, "log the latency of every operation to a compressed file in the log directory")
+		formatflag    = fs.String("format", "json", "log format: json, or csv to also write a CSV progress table per test")
+		manifestflag  = fs.Bool("manifest", false, "write a checksum manifest of each test database to the log directory")
+		plotflag      = fs.Bool("plot", false, "plot throughput and latency of all tests into the log directory")
+		harnessflag   = fs.Bool("checkharness", false, "profile a short run of each test and report the CPU share of the benchmark harness, instead of running the tests")
+		thresholdflag = fs.Float64("harnessmax", 0.2, "harness CPU share above which -checkharness warns")
+		entropyflag   = fs.String("entropy", bench.DefaultEntropy, "random source for keys and values ("+strings.Join(bench.EntropyNames(), ", ")+")")
+
+		run []testRun
+		cfg bench.WriteConfig
+		err error
+	)
+	fs.Var(&cfg.Tags, "tag", "key=value tag attached to test logs (may be repeated)")
+	cmdutil.CompleteValues(fs, "test", completeTests)
+	cmdutil.CompleteValues(fs, "entropy", bench.EntropyNames)
+	cmdutil.CompleteValues(fs, "format", func() []string { return []string{"json", "csv"} })
+	cmdutil.CompleteValues(fs, "slowdisk", ldbstore.SlowPresetNames)
+	cmdutil.CompleteValues(fs, "db", kvstore.Names)
+	detectflag := cmdutil.Detect(fs)
+	watchdog := cmdutil.Watchdog(fs)
+	levelDBOptions := cmdutil.LevelDBOptions(fs)
+	grid := cmdutil.Grid(fs)
+	profile := cmdutil.Profile(fs)
+	metrics := cmdutil.Metrics(fs)
+	display := cmdutil.Display(fs)
+	otlp := cmdutil.OTLP(fs)
+	repeat := cmdutil.Repeat(fs)
+	baseline := cmdutil.Baseline(fs)
+	influx := cmdutil.Influx(fs)
+	fs.Parse(args)
+
+	cfg.Size, cfg.DataSize, cfg.KeySize = *sizeflag, *datasizeflag, *keysizeflag
+	if _, err := bench.NewEntropy(*entropyflag, 0); err != nil {
+		log.Fatal("-entropy: ", err)
+	}
+	cfg.Entropy = *entropyflag
+	if err := kvstore.Check(*dbflag); err != nil {
+		log.Fatal("-db: ", err)
+	}
+	dbEngine = *dbflag
+	if dbEngine != kvstore.Default {
+		if cfg.Tags == nil {
+			cfg.Tags = make(bench.Tags)
+		}
+		cfg.Tags["db"] = dbEngine
+	}
+	if *compactflag != "" {
+		if cfg.CompactEvery, err = bench.ParseSize(*compactflag); err != nil {
+			log.Fatal("-compactevery: ", err)
+		}
+	}
+	blobMin, blobMax = *blobminflag, *blobmaxflag
+	if blobMin > blobMax {
+		log.Fatal("-blobmin is larger than -blobmax")
+	}
+	cfg.SampleDisk = *diskflag
+	cfg.DiskWatch = *watchflag
+	cfg.SampleMemory = *memflag
+	if cfg.ReportInterval, err = bench.ParseReportInterval(*intervalflag); err != nil {
+		log.Fatal("-report-interval: ", err)
+	}
+	syncInterval = *syncflag
+	if faultRate = *faultflag; faultRate < 0 || faultRate > 1 {
+		log.Fatal("-faultrate must be between 0 and 1")
+	}
+	if *capacityflag != "" {
+		if diskCapacity, err = bench.ParseSize(*capacityflag); err != nil {
+			log.Fatal("-diskcapacity: ", err)
+		}
+	}
+	if syncWriters = *writersflag; syncWriters < 1 {
+		log.Fatal("-syncwriters must be at least 1")
+	}
+	cfg.Trace = *traceflag
+	cfg.RealTime = *realtimeflag
+	cfg.Generators = *genflag
+	cfg.Ordered = *orderedflag
+	cfg.UniqueKeys = *uniqueflag
+	ackCheck = *ackflag
+	cfg.StallThreshold = *stallflag
+	cfg.CountKeys = *countflag
+	cfg.CountSample = *sampleflag
+	cfg.Verify = *verifyflag
+	if cfg.Verify && !bench.Reproducible(cfg.Entropy) {
+		log.Fatalf("-verify can't regenerate values with -entropy %s", cfg.Entropy)
+	}
+	cfg.Detectors = *detectflag
+	cfg.Watchdog = watchdog(*logdirflag)
+	if *recordflag && cfg.Generators != 0 && !cfg.Ordered {
+		log.Fatal("-record requires -ordered when using -generators")
+	}
+	cfg.LogPercent = true
+	latencyLog = *latlogflag
+	switch *formatflag {
+	case "json":
+	case "csv":
+		csvLog = true
+	default:
+		log.Fatalf("unknown -format %q", *formatflag)
+	}
+	flagOptions = levelDBOptions(&cfg.Tags)
+
+	if *slowflag != "" && !kvstore.IsLevelDB(dbEngine) {
+		log.Fatalf("-slowdisk requires -db %s or %s", kvstore.Default, kvstore.Memory)
+	}
+	if slow := cmdutil.SlowDisk(*slowflag, &cfg.Tags); slow != nil {
+		dbStorage = append(dbStorage, slow)
+	}
+	dbbase, closeRamdisk := cmdutil.Ramdisk(*dirflag, *ramdiskflag, cfg.Size, &cfg.Tags)
+	defer closeRamdisk()
+
+	for _, t := range bench.ParseTestList(*testflag) {
+		b, err := findTest(t)
+		if err != nil {
+			log.Fatal(err)
+		}
+		run = append(run, testRun{name: t, test: b, cfg: cfg})
+	}
+	if len(run) == 0 {
+		log.Fatal("no tests to run, use -test to select tests")
+	}
+	if *keysweepflag != "" {
+		if run, err = sweepKeySizes(run, *keysweepflag); err != nil {
+			log.Fatal("-keysizes: ", err)
+		}
+	}
+	if *compressflag != "" {
+		if run, err = sweepLevelCompression(run, *compressflag); err != nil {
+			log.Fatal("-levelcompression: ", err)
+		}
+	}
+
+	if cells := grid(); cells != nil {
+		run = cmdutil.SweepGrid(run, cells, func(r *testRun, c cmdutil.GridCell) {
+			c.Apply(&r.name, &r.cfg.Tags, &r.options)
+		})
+	}
+
+	if dbEngine != kvstore.Default {
+		for i := range run {
+			if run[i].unsupported == "" {
+				run[i].unsupported = engineSupport(run[i])
+			}
+		}
+	}
+
+	if *harnessflag {
+		if !checkHarness(dbbase, run, *thresholdflag) {
+			closeRamdisk()
+			os.Exit(1)
+		}
+		return
+	}
+
+	logdirs, err := repeat(*logdirflag)
+	if err != nil {
+		log.Fatalf("can't create log dir: %v", err)
+	}
+
+	live, stopOTLP := otlp(metrics())
+	live, stopDisplay := display(live)
+	for i := range run {
+		run[i].cfg.Live = live
+		if stopDisplay != nil {
+			run[i].cfg.LogPercent = false // the status line shows it
+		}
+	}
+	var closeInflux func()
+	influxOut, closeInflux = influx()
+	stopProfile := profile()
+	anyErr := false
+	for _, r := range run {
+		dbdir := kvstore.TestDir(dbbase, dbEngine, r.name)
+		for i, logdir := range logdirs {
+			if len(logdirs) > 1 {
+				log.Printf("-- run %d/%d", i+1, len(logdirs))
+				os.RemoveAll(dbdir) // every run starts on a fresh database
+			}
+			if err := runTest(logdir, dbdir, r, *recordflag, *manifestflag); err != nil {
+				log.Printf("test %q failed: %v", r.name, err)
+				anyErr = true
+			}
+		}
+		if *deletedbflag {
+			os.RemoveAll(dbdir)
+		}
+	}
+	stopProfile()
+	if stopDisplay != nil {
+		stopDisplay()
+	}
+	closeInflux()
+	stopOTLP()
+	if len(logdirs) > 1 {
+		if err := cmdutil.WriteAggregate(*logdirflag, logdirs); err != nil {
+			log.Printf("can't write aggregated results: %v", err)
+			anyErr = true
+		}
+	}
+	if *plotflag {
+		for _, logdir := range logdirs {
+			if err := plotSuite(logdir, run); err != nil {
+				log.Printf("can't plot results: %v", err)
+				anyErr = true
+			}
+		}
+	}
+	if anyErr {
+		closeRamdisk()
+		log.Fatal("one ore more tests failed")
+	}
+	if !baseline(testLogs) {
+		closeRamdisk()
+		log.Fatal("results regressed against the baseline")
+	}
+}
+
+// testRun is a single execution of a test.
+type testRun struct {
+	name    string // name of the run, used for log and database names
+	test    Benchmarker
+	cfg     bench.WriteConfig
+	options []func(*opt.Options) // applied to the test's database options
+
+	// compression is the per-level block compression of -levelcompression,
+	// nil for the engine default.
+	compression []string
+
+	// If unsupported is set, the run is only logged, not performed.
+	unsupported string
+}
+
+// dbEngine is the storage engine of all runs.
+var dbEngine = kvstore.Default
+
+// levelDBOnly is implemented by tests relying on goleveldb features which the
+// storage engine abstraction doesn't cover.
+type levelDBOnly interface {
+	levelDBOnly()
+}
+
+// engineSupport returns the reason why a run can't be performed with a storage
+// engine other than goleveldb, or the empty string if it can. Option overrides
+// also work in memory, but tests which reopen the database from disk don't.
+func engineSupport(r testRun) string {
+	if _, ok := r.test.(levelDBOnly); ok {
+		return "requires -db " + kvstore.Default
+	}
+	if len(r.options) > 0 && !kvstore.IsLevelDB(dbEngine) {
+		return "requires -db " + kvstore.Default
+	}
+	// Other engines would silently order keys bytewise.
+	if !kvstore.IsLevelDB(dbEngine) && cmdutil.CustomComparer(r.test, flagOptions) {
+		return "requires -db " + kvstore.Default
+	}
+	return ""
+}
+
+// dbOptions are the database option overrides of the current run.
+var dbOptions []func(*opt.Options)
+
+// dbCompression is the per-level block compression of the current run.
+var dbCompression []string
+
+// testLogs are the logs written by the runs, for -baseline.
+var testLogs []string
+
+// flagOptions are the database options set by flags, which apply to all runs.
+// Option overrides of the run take precedence.
+var flagOptions func(*opt.Options)
+
+// latencyLog enables the operation latency logs of -latlog.
+var latencyLog bool
+
+// csvLog enables the CSV progress tables of -format csv.
+var csvLog bool
+
+// influxOut receives the progress samples of -influx.
+var influxOut io.Writer
+
+// storageIO counts the storage writes of the current run. It is nil for engines
+// other than goleveldb.
+var storageIO *ldbstore.IOCounter
+
+// dbStorage are the storage wrappers of all runs.
+var dbStorage []ldbstore.Wrapper
+
+// sweepKeySizes expands every run into one run per key size.
+func sweepKeySizes(runs []testRun, sizes string) ([]testRun, error) {
+	var out []testRun
+	for _, r := range runs {
+		for _, s := range strings.Split(sizes, ",") {
+			s = strings.TrimSpace(s)
+			size, err := bench.ParseSize(s)
+			if err != nil {
+				return nil, err
+			}
+			sr := r
+			sr.name = r.name + "-key" + s
+			sr.cfg.KeySize = size
+			sr.cfg.Tags = copyTags(r.cfg.Tags)
+			sr.cfg.Tags["keysize"] = s
+			out = append(out, sr)
+		}
+	}
+	return out, nil
+}
+
+func copyTags(t bench.Tags) bench.Tags {
+	cpy := make(bench.Tags, len(t))
+	for k, v := range t {
+		cpy[k] = v
+	}
+	return cpy
+}
+
+func runTest(logdir, dbdir string, r testRun, record, manifest bool) error {
+	cfg, name := r.cfg, r.name
+	cfg.TestName = name
+	logfile, err := os.Create(filepath.Join(logdir, name+".json"))
+	if err != nil {
+		return err
+	}
+	defer logfile.Close()
+	testLogs = append(testLogs, logfile.Name())
+	log.Printf("== running %q", name)
+	if c, ok := r.test.(configurer); ok {
+		c.configure(&cfg)
+	}
+	env := bench.NewWriteEnv(logfile, cfg)
+	if r.unsupported != "" {
+		log.Printf("skipping %q: %s", name, r.unsupported)
+		return env.Unsupported(r.unsupported)
+	}
+	dbOptions, dbCompression = r.options, r.compression
+	storageIO = nil
+	if kvstore.IsLevelDB(dbEngine) {
+		storageIO = ldbstore.NewIOCounter() // other engines don't take storage wrappers
+	}
+	if record {
+		tracefile, err := os.Create(filepath.Join(logdir, name+".trace"))
+		if err != nil {
+			return err
+		}
+		defer tracefile.Close()
+		env.Record(tracefile)
+	}
+	if latencyLog {
+		latfile, err := os.Create(filepath.Join(logdir, name+".lat.gz"))
+		if err != nil {
+			return err
+		}
+		defer latfile.Close()
+		env.LatencyLog(latfile)
+	}
+	if csvLog {
+		csvfile, err := os.Create(filepath.Join(logdir, name+".csv"))
+		if err != nil {
+			return err
+		}
+		defer csvfile.Close()
+		env.ProgressCSV(csvfile)
+	}
+	if influxOut != nil {
+		env.ProgressInflux(influxOut)
+	}
+	if manifest {
+		file := filepath.Join(logdir, name+".manifest")
+		mfile, err := os.Create(file)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			// Tests which can't produce a manifest leave the file empty.
+			if info, err := mfile.Stat(); err == nil && info.Size() == 0 {
+				os.Remove(file)
+			}
+			mfile.Close()
+		}()
+		env.Manifest(mfile)
+	}
+	return r.test.Benchmark(dbdir, env)
+}
+
+type Benchmarker interface {
+	Benchmark(dir string, env *bench.WriteEnv) error
+}
+
+// configurer is implemented by tests that need to adjust the configuration.
+type configurer interface {
+	configure(cfg *bench.WriteConfig)
+}
+
+// Value size range of blob tests, set by -blobmin and -blobmax.
+var blobMin, blobMax uint64
+
+var tests = map[string]Benchmarker{
+	"nobatch":        seqWrite{},
+	"nobatch-nosync": seqWrite{Options: opt.Options{NoSync: true}},
+	"batch-100kb":    batchWrite{BatchSize: 100 * opt.KiB},
+	"batch-1mb":      batchWrite{BatchSize: opt.MiB},
+	"batch-5mb":      batchWrite{BatchSize: 5 * opt.MiB},
+	"batch-100kb-wb-512mb-cache-1gb": batchWrite{
+		BatchSize: 100 * 1024,
+		Options: opt.Options{
+			// These settings approximate what geth is doing.
+			BlockCacheCapacity: 1024 * opt.MiB,
+			WriteBuffer:        512 * opt.MiB,
+		},
+	},
+	// Memtable sizes, from the goleveldb default up.
+	"batch-100kb-wb-4mb":   batchWrite{BatchSize: 100 * opt.KiB, Options: opt.Options{WriteBuffer: 4 * opt.MiB}},
+	"batch-100kb-wb-64mb":  batchWrite{BatchSize: 100 * opt.KiB, Options: opt.Options{WriteBuffer: 64 * opt.MiB}},
+	"batch-100kb-wb-256mb": batchWrite{BatchSize: 100 * opt.KiB, Options: opt.Options{WriteBuffer: 256 * opt.MiB}},
+	"batch-100kb-nosync": batchWrite{
+		BatchSize: 100 * 1024,
+		Options:   opt.Options{NoSync: true},
+	},
+	"batch-100kb-wb-512mb-cache-1gb-nosync": batchWrite{
+		BatchSize: 100 * 1024,
+		Options: opt.Options{
+			NoSync:             true,
+			BlockCacheCapacity: 1024 * opt.MiB,
+			WriteBuffer:        512 * opt.MiB,
+		},
+	},
+	"batch-100kb-ctable-64mb": batchWrite{
+		BatchSize: 100 * 1024,
+		Options:   opt.Options{CompactionTableSize: 64 * opt.MiB},
+	},
+	"batch-100kb-ctable-64mb-nosync": batchWrite{
+		BatchSize: 100 * 1024,
+		Options:   opt.Options{NoSync: true, CompactionTableSize: 64 * opt.MiB},
+	},
+	"batch-100kb-ctable-64mb-wb-512mb-cache-1gb": batchWrite{
+		BatchSize: 100 * 1024,
+		Options: opt.Options{
+			BlockCacheCapacity:  1024 * opt.MiB,
+			WriteBuffer:         512 * opt.MiB,
+			CompactionTableSize: 64 * opt.MiB,
+		},
+	},
+	"batch-100kb-notx": batchWrite{
+		BatchSize: 1024 * 1024,
+		Options:   opt.Options{DisableLargeBatchTransaction: true},
+	},
+	"batch-1mb-notx": batchWrite{
+		BatchSize: 1024 * 1024,
+		Options:   opt.Options{DisableLargeBatchTransaction: true},
+	},
+	"batch-5mb-notx": batchWrite{
+		BatchSize: 5 * 1024 * 1024,
+		Options:   opt.Options{DisableLargeBatchTransaction: true},
+	},
+	"concurrent":         concurrentWrite{N: 8},
+	"concurrent-nomerge": concurrentWrite{N: 8, NoWriteMerge: true},
+	"replay":             replay{},
+	"batch-delete":       batchDelete{Deletes: 10000},
+	"prune":              prune{Phases: 8},
+	"lock-contention": lockContention{
+		batchWrite: batchWrite{BatchSize: 100 * opt.KiB},
+		Interval:   500 * time.Millisecond,
+	},
+	"snapshot": snapshot{BatchSize: 100 * opt.KiB},
+	"block-import": blockImport{
+		Nodes:        300,
+		HeaderSize:   540,
+		BodySize:     40 * opt.KiB,
+		ReceiptsSize: 30 * opt.KiB,
+	},
+	"freezer": freezer{
+		BlockSize:   64 * opt.KiB,
+		BatchSize:   opt.MiB,
+		DeleteRange: 1024,
+	},
+	"fault-write": faultWrite{BatchSize: 100 * opt.KiB, Options: opt.Options{NoSync: true}},
+	"fault-sync":  faultWrite{BatchSize: 100 * opt.KiB, Sync: true},
+	"disk-full":   diskFull{BatchSize: 100 * opt.KiB},
+	"sync-write":  syncWrite{},
+	"sync-batch":  syncBatch{BatchSize: 100 * opt.KiB},
+	"sync-group":  syncGroup{},
+	"blob":        blobWrite{batchWrite{BatchSize: 64 * opt.MiB}},
+	"blob-notx": blobWrite{batchWrite{
+		BatchSize: 64 * opt.MiB,
+		Options:   opt.Options{DisableLargeBatchTransaction: true},
+	}},
+	"preimages": preimageWrite{batchWrite{
+		BatchSize: 100 * opt.KiB,
+		Options:   opt.Options{NoSync: true},
+	}},
+	"numeric-keys": numericWrite{batchWrite{BatchSize: 100 * opt.KiB}},
+	"numeric-keys-uint64": numericWrite{batchWrite{
+		BatchSize: 100 * opt.KiB,
+		Options:   opt.Options{Comparer: cmdutil.Uint64Comparer},
+	}},
+	"blob-ctable-64mb": blobWrite{batchWrite{
+		BatchSize: 64 * opt.MiB,
+		Options:   opt.Options{CompactionTableSize: 64 * opt.MiB},
+	}},
+}
+
+func init() {
+	for name, w := range bench.Workloads {
+		tests[name] = workload{Workload: w}
+	}
+}
+
+// testFamily is a parameterized test. Names matching the pattern create a test.
+type testFamily struct {
+	pattern *regexp.Regexp
+	usage   string
+	create  func(match []string) (Benchmarker, error)
+}
+
+var families = []testFamily{
+	{
+		pattern: regexp.MustCompile(`^batch-([0-9]+[kmg]?b)((?:-nosync|-notx)*)$`),
+		usage:   "batch-<size>[-nosync][-notx]",
+		create: func(m []string) (Benchmarker, error) {
+			size, err := bench.ParseSize(m[1])
+			if err != nil {
+				return nil, err
+			}
+			b := batchWrite{BatchSize: int(size)}
+			b.Options.NoSync = strings.Contains(m[2], "-nosync")
+			b.Options.DisableLargeBatchTransaction = strings.Contains(m[2], "-notx")
+			return b, nil
+		},
+	},
+	{
+		pattern: regexp.MustCompile(`^batch-delete-([0-9]+)$`),
+		usage:   "batch-delete-<n>",
+		create: func(m []string) (Benchmarker, error) {
+			n, _ := strconv.Atoi(m[1])
+			if n < 1 {
+				return nil, fmt.Errorf("invalid batch size %d", n)
+			}
+			return batchDelete{Deletes: n}, nil
+		},
+	},
+	{
+		pattern: regexp.MustCompile(`^concurrent-([0-9]+)(-nomerge)?$`),
+		usage:   "concurrent-<n>[-nomerge]",
+		create: func(m []string) (Benchmarker, error) {
+			n, _ := strconv.Atoi(m[1])
+			if n < 1 {
+				return nil, fmt.Errorf("invalid concurrency %d", n)
+			}
+			return concurrentWrite{N: n, NoWriteMerge: m[2] != ""}, nil
+		},
+	},
+}
+
+// findTest returns the named test, either from the tests map or by creating it
+// from a family.
+func findTest(name string) (Benchmarker, error) {
+	if b := tests[name]; b != nil {
+		return b, nil
+	}
+	for _, f := range families {
+		if m := f.pattern.FindStringSubmatch(name); m != nil {
+			return f.create(m)
+		}
+	}
+	return nil, fmt.Errorf("unknown test %q", name)
+}
+
+func testnames() (n []string) {
+	for name := range tests {
+		n = append(n, name)
+	}
+	sort.Strings(n)
+	for _, f := range families {
+		n = append(n, f.usage)
+	}
+	return n
+}
+
+// completeTests returns the test names for shell completion. Families can't
+// be completed.
+func completeTests() (n []string) {
+	for _, name := range testnames() {
+		if !strings.Contains(name, "<") {
+			n = append(n, name)
+		}
+	}
+	return n
+}
+
+// openDB opens the test database with the option overrides of the current run,
+// records the options in the log and registers the database for periodic
+// compaction, key counting, value verification and size measurement.
+func openDB(dir string, o *opt.Options, env *bench.WriteEnv) (kvstore.Store, error) {
+	return openWrappedDB(dir, o, env, nil)
+}
+
+// openWrappedDB is like openDB, but places the database on storage returned by
+// wrap, if not nil. The storage wrappers of the run are applied on top.
+func openWrappedDB(dir string, o *opt.Options, env *bench.WriteEnv, wrap ldbstore.Wrapper) (kvstore.Store, error) {
+	env.DatabaseDir(dir)
+	if len(dbOptions) > 0 || flagOptions != nil {
+		cpy := *o
+		if flagOptions != nil {
+			flagOptions(&cpy)
+		}
+		for _, fn := range dbOptions {
+			fn(&cpy)
+		}
+		o = &cpy
+	}
+	if err := env.LogOptions(cmdutil.EncodeOptions(o)); err != nil {
+		return nil, err
+	}
+	if c := o.GetComparer(); c.Name() != comparer.DefaultComparer.Name() && kvstore.IsLevelDB(dbEngine) {
+		env.KeyOrder(c.Name())
+	}
+	db, err := kvstore.Open(dbEngine, dir, kvstore.Options{
+		LevelDB:     o,
+		Storage:     append([]ldbstore.Wrapper{storageIO.Wrapper(), wrap}, dbStorage...),
+		Compression: dbCompression,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := db.(kvstore.Compacter); ok {
+		env.CompactFunc(c.CompactRange)
+	}
+	if storageIO != nil {
+		env.StorageWriteFunc(storageIO.Written)
+	}
+	env.CountFunc(func(start, limit []byte) (uint64, error) {
+		it := db.Iterate(start, limit)
+		defer it.Release()
+		n := uint64(0)
+		for it.Next() {
+			n++
+		}
+		return n, it.Error()
+	})
+	env.KeysFunc(func(visit func(key []byte)) error {
+		it := db.Iterate(nil, nil)
+		defer it.Release()
+		for it.Next() {
+			visit(it.Key())
+		}
+		return it.Error()
+	})
+	env.GetFunc(db.Get)
+	if s, ok := db.(kvstore.StatsReporter); ok {
+		env.CompactionStatsFunc(s.CompactionStats)
+	}
+	env.SizeFunc(func() (uint64, error) {
+		return bench.DirSize(dir)
+	})
+	env.FileCountFunc(func() (int, error) {
+		return bench.DirFileCount(dir)
+	})
+	if s, ok := db.(kvstore.Sizer); ok {
+		env.EstimateFunc(func() (uint64, error) {
+			return s.SizeOf(nil, nil)
+		})
+	}
+	return db, nil
+}
+
+type seqWrite struct {
+	Options opt.Options
+}
+
+func (b seqWrite) Benchmark(dir string, env *bench.WriteEnv) error {
+	db, err := openDB(dir, &b.Options, env)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return env.Run(func(key, value string, lastCall bool) error {
+		if err := db.Put([]byte(key), []byte(value), nil); err != nil {
+			return err
+		}
+		env.Progress(len(value))
+		return nil
+	})
+}
+
+type batchWrite struct {
+	Options   opt.Options
+	BatchSize int
+}
+
+func (b batchWrite) Benchmark(dir string, env *bench.WriteEnv) error {
+	db, err := openDB(dir, &b.Options, env)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	batch := db.NewBatch()
+	bsize := 0
+	return env.Run(func(key, value string, lastCall bool) error {
+		batch.Put([]byte(key), []byte(value))
+		bsize += len(value)
+		if bsize >= b.BatchSize || lastCall {
+			if err := batch.Write(nil); err != nil {
+				return err
+			}
+			env.ProgressBatch(bsize, batch.Len())
+			bsize = 0
+			batch.Reset()
+		}
+		return nil
+	})
+}
+
+// batchDelete fills the database in 100kb batches, then deletes all keys in
+// batches of the given number of deletes.
+type batchDelete struct {
+	Options opt.Options
+	Deletes int
+}
+
+func (b batchDelete) Benchmark(dir string, env *bench.WriteEnv) error {
+	db, err := openDB(dir, &b.Options, env)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	batch := db.NewBatch()
+	bsize := 0
+	write := func(key, value string, lastCall bool) error {
+		batch.Put([]byte(key), []byte(value))
+		bsize += len(value)
+		if bsize >= 100*opt.KiB || lastCall {
+			if err := batch.Write(nil); err != nil {
+				return err
+			}
+			bsize = 0
+			batch.Reset()
+		}
+		return nil
+	}
+	del := func(key string, valueSize int, lastCall bool) error {
+		batch.Delete([]byte(key))
+		bsize += valueSize
+		if batch.Len() >= b.Deletes || lastCall {
+			if err := batch.Write(nil); err != nil {
+				return err
+			}
+			env.ProgressBatch(bsize, batch.Len())
+			bsize = 0
+			batch.Reset()
+		}
+		return nil
+	}
+	return env.RunDelete(write, del)
+}
+
+// blobWrite is a batch write of large values.
+type blobWrite struct {
+	batchWrite
+}
+
+func (b blobWrite) configure(cfg *bench.WriteConfig) {
+	cfg.DataSize, cfg.MaxDataSize = blobMin, blobMax
+}
+
+// preimageWrite is a batch write of tiny entries like the hash preimages and
+// lookup indexes written by geth.
+type preimageWrite struct {
+	batchWrite
+}
+
+func (b preimageWrite) configure(cfg *bench.WriteConfig) {
+	cfg.KeySize = 32
+	cfg.DataSize, cfg.MaxDataSize = 20, 32
+}
+
+// numericWrite is a batch write of keys holding a little-endian counter in the
+// first eight bytes. The bytewise comparer scatters consecutive keys over the key
+// space, the uint64 comparer keeps them in insertion order.
+type numericWrite struct {
+	batchWrite
+}
+
+var numericKeys = &bench.Encoder{
+	Name: "numeric",
+	Key: func(n uint64, random []byte) []byte {
+		binary.LittleEndian.PutUint64(random, n)
+		return random
+	},
+}
+
+func (b numericWrite) configure(cfg *bench.WriteConfig) {
+	if cfg.KeySize < 8 {
+		cfg.KeySize = 8
+	}
+	cfg.Encoder = numericKeys
+}
+
+type kv struct{ k, v string }
+
+// ackCheck is set by -checkacks.
+var ackCheck bool
+
+// checkAcks enables the acknowledgement check of -checkacks for tests with
+// concurrent writers. It catches writes dropped or duplicated by the test
+// itself, which would invalidate the result.
+func checkAcks(cfg *bench.WriteConfig) {
+	if ackCheck {
+		cfg.UniqueKeys, cfg.CheckAcks = true, true
+	}
+}
+
+type concurrentWrite struct {
+	Options      opt.Options
+	N            int
+	NoWriteMerge bool
+}
+
+func (b concurrentWrite) configure(cfg *bench.WriteConfig) {
+	checkAcks(cfg)
+}
+
+func (b concurrentWrite) Benchmark(dir string, env *bench.WriteEnv) error {
+	db, err := openDB(dir, &b.Options, env)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var (
+		write   = make(chan kv, b.N)
+		wopt    = &kvstore.WriteOptions{NoWriteMerge: b.NoWriteMerge}
+		eg, ctx = errgroup.WithContext(context.Background())
+	)
+	for i := 0; i < b.N; i++ {
+		eg.Go(func() error {
+			// Writers drain the channel, so queued writes aren't lost at the end.
+			for kv := range write {
+				err := env.TimeWrite(func() error {
+					return db.Put([]byte(kv.k), []byte(kv.v), wopt)
+				})
+				if err != nil {
+					return err
+				}
+				env.Ack([]byte(kv.k))
+				env.Progress(len(kv.v))
+			}
+			return nil
+		})
+	}
+
+	env.TimeWrites()
+	return env.Run(func(key, value string, lastCall bool) error {
+		select {
+		case write <- kv{k: key, v: value}:
+		case <-ctx.Done():
+			// A writer failed.
+			lastCall = true
+		}
+		if lastCall {
+			close(write)
+			return eg.Wait()
+		}
+		return nil
+	})
+}
+
+type replay struct {
+	Options opt.Options
+}
+
+func (b replay) Benchmark(dir string, env *bench.WriteEnv) error {
+	db, err := openDB(dir, &b.Options, env)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return env.Replay(func(ev bench.TraceEvent, value []byte) error {
+		switch ev.Op {
+		case bench.TracePut:
+			if err := db.Put(ev.Key, value, nil); err != nil {
+				return err
+			}
+			env.Progress(len(value))
+		case bench.TraceGet:
+			v, err := db.Get(ev.Key)
+			if err != nil && err != bench.ErrNotFound {
+				return err
+			}
+			env.Progress(len(v))
+		default:
+			return fmt.Errorf("unsupported trace operation %v", ev.Op)
+		}
+		return nil
+	})
+}
+
+type workload struct {
+	Options  opt.Options
+	Workload bench.Workload
+}
+
+func (b workload) configure(cfg *bench.WriteConfig) {
+	if b.Workload.ValueSize > 0 {
+		cfg.DataSize, cfg.MaxDataSize = b.Workload.ValueSize, 0
+	}
+}
+
+func (b workload) Benchmark(dir string, env *bench.WriteEnv) error {
+	db, err := openDB(dir, &b.Options, env)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return env.RunWorkload(b.Workload, bench.WorkloadOps{
+		Put: func(key, value []byte) error {
+			return db.Put(key, value, nil)
+		},
+		Get: func(key []byte) ([]byte, error) {
+			v, err := db.Get(key)
+			if err == bench.ErrNotFound {
+				err = nil
+			}
+			return v, err
+		},
+		Scan: func(start []byte, n int) (int, error) {
+			it := db.Iterate(start, nil)
+			defer it.Release()
+			size := 0
+			for i := 0; i < n && it.Next(); i++ {
+				size += len(it.Key()) + len(it.Value())
+			}
+			return size, it.Error()
+		},
+	})
+}
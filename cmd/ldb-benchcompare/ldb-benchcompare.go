@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/fjl/goleveldb-bench/cmd/internal/deltacmd"
+)
+
+func main() {
+	deltacmd.Main(os.Args[0], os.Args[1:])
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/fjl/goleveldb-bench/cmd/internal/cmdutil"
+)
+
+// cleanMain removes test databases created by the benchmark commands and,
+// with -logdir, their test logs, traces, latency logs and progress tables.
+func cleanMain(name string, args []string) {
+	var (
+		fs      = cmdutil.FlagSet(name, "[flags]")
+		dirflag = fs.String("dir", ".", "test database directory")
+		logdir  = fs.String("logdir", "", "test log directory to clean (default: don't remove logs)")
+		dryrun  = fs.Bool("n", false, "print what would be removed without removing it")
+	)
+	fs.Parse(args)
+
+	remove, err := filepath.Glob(filepath.Join(*dirflag, "testdb-*"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *logdir != "" {
+		logs, err := testLogFiles(*logdir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		remove = append(remove, logs...)
+	}
+	failed := false
+	for _, m := range remove {
+		log.Printf("removing %s", m)
+		if *dryrun {
+			continue
+		}
+		if err := os.RemoveAll(m); err != nil {
+			log.Print(err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// logCompanions are the suffixes of the files written next to a test log.
+var logCompanions = []string{".trace", ".lat.gz", ".csv"}
+
+// testLogFiles returns the test logs in dir and the files written along with
+// them. JSON files without a log header are left alone, and so are traces and
+// other files which don't belong to one of the logs.
+func testLogFiles(dir string) ([]string, error) {
+	logs, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, file := range logs {
+		if _, err := bench.ReadHeader(file); err != nil {
+			log.Printf("keeping %s: %v", file, err)
+			continue
+		}
+		files = append(files, file)
+		base := strings.TrimSuffix(file, ".json")
+		for _, suffix := range logCompanions {
+			if _, err := os.Stat(base + suffix); err == nil {
+				files = append(files, base+suffix)
+			}
+		}
+	}
+	return files, nil
+}
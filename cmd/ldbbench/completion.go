@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fjl/goleveldb-bench/cmd/internal/cmdutil"
+)
+
+// Completion scripts. They call the hidden __complete command, which prints
+// the candidates for the word being completed. %[1]s is the binary name.
+var completionScripts = map[string]string{
+	"bash": `_%[1]s() {
+	local IFS=$'\n'
+	COMPREPLY=($(%[1]s __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+	if [ ${#COMPREPLY[@]} -eq 0 ]; then
+		COMPREPLY=($(compgen -f -- "${COMP_WORDS[COMP_CWORD]}"))
+	fi
+}
+complete -F _%[1]s %[1]s
+`,
+	"zsh": `#compdef %[1]s
+_%[1]s() {
+	local out
+	out="$(%[1]s __complete "${words[@]:1:$((CURRENT-1))}" 2>/dev/null)"
+	if [[ -n $out ]]; then
+		local -a c
+		c=("${(@f)out}")
+		compadd -a c
+	else
+		_files
+	fi
+}
+compdef _%[1]s %[1]s
+`,
+	"fish": `function __%[1]s_complete
+	set -l tokens (commandline -opc) (commandline -ct)
+	%[1]s __complete $tokens[2..-1] 2>/dev/null
+end
+complete -c %[1]s -f -a '(__%[1]s_complete)'
+`,
+}
+
+// completionMain prints a shell completion script.
+func completionMain(name string, args []string) {
+	fs := cmdutil.FlagSet(name, "bash|zsh|fish")
+	fs.Parse(args)
+	script, ok := completionScripts[fs.Arg(0)]
+	if !ok || fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	fmt.Printf(script, binaryName())
+}
+
+// completeMain prints completions for the given command line words.
+func completeMain(words []string) {
+	if len(words) <= 1 {
+		var prefix string
+		if len(words) == 1 {
+			prefix = words[0]
+		}
+		var names []string
+		for _, c := range commands {
+			names = append(names, c.name)
+		}
+		cmdutil.PrintMatches(prefix, names, "")
+		return
+	}
+	for _, c := range commands {
+		if c.name == words[0] {
+			cmdutil.Complete(c.main, words[1:])
+		}
+	}
+}
+
+// binaryName returns the name the binary was started as, for completion
+// scripts. It must be a valid shell identifier.
+func binaryName() string {
+	name := exeName()
+	if strings.ContainsAny(name, " \t'\"$") {
+		return "ldbbench"
+	}
+	return name
+}
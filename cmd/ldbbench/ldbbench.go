@@ -0,0 +1,78 @@
+// Command ldbbench contains all benchmark commands as subcommands.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/fjl/goleveldb-bench/cmd/internal/checkcmd"
+	"github.com/fjl/goleveldb-bench/cmd/internal/cmdutil"
+	"github.com/fjl/goleveldb-bench/cmd/internal/comparecmd"
+	"github.com/fjl/goleveldb-bench/cmd/internal/crashcmd"
+	"github.com/fjl/goleveldb-bench/cmd/internal/deltacmd"
+	"github.com/fjl/goleveldb-bench/cmd/internal/diffcmd"
+	"github.com/fjl/goleveldb-bench/cmd/internal/matrixcmd"
+	"github.com/fjl/goleveldb-bench/cmd/internal/plotcmd"
+	"github.com/fjl/goleveldb-bench/cmd/internal/readcmd"
+	"github.com/fjl/goleveldb-bench/cmd/internal/statcmd"
+	"github.com/fjl/goleveldb-bench/cmd/internal/writecmd"
+)
+
+type command struct {
+	name, usage string
+	main        func(name string, args []string)
+}
+
+var commands = []command{
+	{"wizard", "answer a few questions and run matching benchmarks", wizardMain},
+	{"write", "run write benchmarks (ldb-writebench)", writecmd.Main},
+	{"read", "run read benchmarks (ldb-readbench)", readcmd.Main},
+	{"report", "print statistics of test logs (ldb-benchstat)", statcmd.Main},
+	{"plot", "plot test logs (ldb-benchplot)", plotcmd.Main},
+	{"delta", "compare the test logs of two log directories (ldb-benchcompare)", deltacmd.Main},
+	{"diff", "compare the contents of two databases (ldb-diff)", diffcmd.Main},
+	{"check", "validate a database against a manifest", checkcmd.Main},
+	{"compare", "run benchmarks against several storage engines", comparecmd.Main},
+	{"matrix", "run benchmarks against several goleveldb versions", matrixcmd.Main},
+	{"crash", "run crash recovery tests (ldb-crashtest)", crashcmd.Main},
+	{"clean", "remove test databases and logs", cleanMain},
+	{"completion", "print a shell completion script (bash, zsh, fish)", completionMain},
+}
+
+func main() {
+	exe := exeName()
+	if len(os.Args) < 2 {
+		usage(exe)
+		os.Exit(2)
+	}
+	name := os.Args[1]
+	if name == "__complete" {
+		completeMain(os.Args[2:])
+		return
+	}
+	for _, c := range commands {
+		if c.name == name {
+			cmdutil.SelfArgs = []string{name}
+			c.main(exe+" "+name, os.Args[2:])
+			return
+		}
+	}
+	if name != "help" && name != "-h" && name != "-help" {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", name)
+	}
+	usage(exe)
+	os.Exit(2)
+}
+
+func exeName() string {
+	return filepath.Base(os.Args[0])
+}
+
+func usage(exe string) {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", exe)
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for the flags of a command.\n", exe)
+}
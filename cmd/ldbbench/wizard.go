@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	bench "github.com/fjl/goleveldb-bench"
+	"github.com/fjl/goleveldb-bench/cmd/internal/cmdutil"
+	"github.com/fjl/goleveldb-bench/cmd/internal/statcmd"
+	"github.com/fjl/goleveldb-bench/cmd/internal/writecmd"
+)
+
+// wizardStyle is a workload style offered by the wizard.
+type wizardStyle struct {
+	name, description string
+	tests             []string
+}
+
+var wizardStyles = []wizardStyle{
+	{"geth", "Ethereum node: block import, state snapshot, preimages", []string{"block-import", "snapshot", "preimages"}},
+	{"bulk", "bulk loading: large batches without sync", []string{"batch-100kb-nosync", "batch-1mb", "nobatch-nosync"}},
+	{"sync", "durable writes: every write is synced", []string{"sync-write", "sync-batch", "sync-group"}},
+	{"mixed", "mixed reads and writes (YCSB A and B)", []string{"ycsb-a", "ycsb-b"}},
+}
+
+const (
+	// calibrationSize is the amount of data written to estimate throughput.
+	calibrationSize = "64mb"
+	// wizardBudgetShare is the share of the time budget used for the test
+	// runs. Throughput drops as the database grows, so the estimate is
+	// conservative.
+	wizardBudgetShare = 0.5
+	// minWizardSize is the smallest test size the wizard generates.
+	minWizardSize = 16 * 1024 * 1024
+)
+
+// wizardMain asks a few questions and runs the matching write benchmarks.
+func wizardMain(name string, args []string) {
+	var (
+		fs  = cmdutil.FlagSet(name, "[flags]")
+		yes = fs.Bool("y", false, "run the generated configuration without asking")
+	)
+	fs.Parse(args)
+	in := bufio.NewReader(os.Stdin)
+
+	fmt.Println("This wizard runs write benchmarks on a disk of your choice.")
+	dir := ask(in, "Directory on the disk to test", ".")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Fatal(err)
+	}
+	budget := askDuration(in, "Time budget", 10*time.Minute)
+	fmt.Println("Workload styles:")
+	for i, s := range wizardStyles {
+		fmt.Printf("  %d) %-6s %s\n", i+1, s.name, s.description)
+	}
+	style := askStyle(in)
+
+	// Estimate throughput with a short run to pick the test size.
+	fmt.Printf("Measuring throughput with a %s run...\n", calibrationSize)
+	bps, err := calibrate(dir)
+	if err != nil {
+		log.Fatal("calibration failed: ", err)
+	}
+	size := uint64(budget.Seconds() * bps * wizardBudgetShare / float64(len(style.tests)))
+	if size < minWizardSize {
+		size = minWizardSize
+	}
+	logdir := filepath.Join(dir, "ldbbench-"+time.Now().Format("20060102-150405"))
+	runArgs := []string{
+		"-test", strings.Join(style.tests, ","),
+		"-size", fmt.Sprintf("%dmb", size/1024/1024),
+		"-dir", dir,
+		"-logdir", logdir,
+		"-deletedb",
+		"-plot",
+	}
+	fmt.Printf("Measured %.1f mb/s. The configuration is:\n\n", bps/1024/1024)
+	fmt.Printf("    %s write %s\n\n", exeName(), strings.Join(runArgs, " "))
+	if !*yes && !askYes(in, "Run it now?") {
+		return
+	}
+
+	cmdutil.SelfArgs = []string{"write"}
+	writecmd.Main(exeName()+" write", runArgs)
+	logs, _ := filepath.Glob(filepath.Join(logdir, "*.json"))
+	fmt.Printf("\nResults are in %s.\n\n", logdir)
+	statcmd.Main(exeName()+" report", logs)
+}
+
+// calibrate runs a short batch write test in dir and returns its throughput.
+func calibrate(dir string) (float64, error) {
+	logdir, err := ioutil.TempDir("", "ldbbench-calibrate-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(logdir)
+	cmdutil.SelfArgs = []string{"write"}
+	writecmd.Main(exeName()+" write", []string{
+		"-test", "batch-100kb", "-size", calibrationSize,
+		"-dir", dir, "-logdir", logdir, "-deletedb",
+	})
+	reports := bench.MustReadReports([]string{filepath.Join(logdir, "batch-100kb.json")})
+	bps := bench.Summarize(reports[0]).BPS()
+	if bps <= 0 {
+		return 0, fmt.Errorf("no throughput measured")
+	}
+	return bps, nil
+}
+
+func ask(in *bufio.Reader, question, def string) string {
+	fmt.Printf("%s [%s]: ", question, def)
+	line, err := in.ReadString('\n')
+	if err != nil && line == "" {
+		// Non-interactive input ended, use the default.
+		fmt.Println()
+		return def
+	}
+	if line = strings.TrimSpace(line); line == "" {
+		return def
+	}
+	return line
+}
+
+func askDuration(in *bufio.Reader, question string, def time.Duration) time.Duration {
+	for {
+		d, err := time.ParseDuration(ask(in, question, def.String()))
+		if err == nil && d > 0 {
+			return d
+		}
+		fmt.Println("Please enter a duration like 10m or 1h.")
+	}
+}
+
+func askStyle(in *bufio.Reader) wizardStyle {
+	for {
+		answer := ask(in, "Workload style", wizardStyles[0].name)
+		for i, s := range wizardStyles {
+			if answer == s.name || answer == strconv.Itoa(i+1) {
+				return s
+			}
+		}
+		fmt.Println("Please enter the number or name of a style.")
+	}
+}
+
+func askYes(in *bufio.Reader, question string) bool {
+	answer := strings.ToLower(ask(in, question, "y"))
+	return answer == "y" || answer == "yes"
+}
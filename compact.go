@@ -0,0 +1,81 @@
+package bench
+
+import (
+	"log"
+	"time"
+)
+
+// compactWindows is the number of key ranges cycled through by periodic compaction.
+const compactWindows = 16
+
+// CompactFunc sets the function used for periodic compaction of key ranges.
+// Compaction is enabled by setting cfg.CompactEvery.
+func (env *WriteEnv) CompactFunc(fn func(start, limit []byte) error) {
+	env.compactFn = fn
+}
+
+// startCompactor launches the background compaction goroutine.
+func (env *WriteEnv) startCompactor() {
+	env.lastCompact, env.compactWindow = 0, 0
+	if env.cfg.CompactEvery == 0 || env.compactFn == nil {
+		return
+	}
+	if env.keyOrder != "" {
+		log.Printf("periodic compaction disabled: key windows need bytewise key order")
+		return
+	}
+	env.compactCh = make(chan int, 1)
+	env.compactDone = make(chan struct{})
+	go env.compactLoop(env.compactCh, env.compactDone)
+}
+
+// stopCompactor waits for the last compaction to finish.
+func (env *WriteEnv) stopCompactor() {
+	if env.compactCh == nil {
+		return
+	}
+	close(env.compactCh)
+	<-env.compactDone
+	env.compactCh = nil
+}
+
+// maybeCompact triggers compaction of the next key window if enough data has
+// been written since the last one. It is called by Progress with env.mu held.
+// Triggers are dropped while a compaction is running.
+func (env *WriteEnv) maybeCompact() {
+	if env.compactCh == nil || env.written-env.lastCompact < env.cfg.CompactEvery {
+		return
+	}
+	env.lastCompact = env.written
+	select {
+	case env.compactCh <- env.compactWindow:
+		env.compactWindow = (env.compactWindow + 1) % compactWindows
+	default:
+	}
+}
+
+func (env *WriteEnv) compactLoop(windows <-chan int, done chan<- struct{}) {
+	defer close(done)
+	for w := range windows {
+		start, limit := compactRange(w)
+		begin := time.Now()
+		if err := env.compactFn(start, limit); err != nil {
+			log.Printf("compaction of window %d failed: %v", w, err)
+			continue
+		}
+		log.Printf("compacted window %d/%d in %v", w+1, compactWindows, time.Since(begin))
+	}
+}
+
+// compactRange returns the key range of window w. Keys are random, so the
+// first key byte is split evenly between windows.
+func compactRange(w int) (start, limit []byte) {
+	const step = 256 / compactWindows
+	if w > 0 {
+		start = []byte{byte(w * step)}
+	}
+	if w < compactWindows-1 {
+		limit = []byte{byte((w + 1) * step)}
+	}
+	return start, limit
+}
@@ -0,0 +1,200 @@
+package bench
+
+import (
+	"math"
+
+	"github.com/gonum/stat"
+)
+
+// MetricStats is the mean, standard deviation and range of a metric over
+// repeated runs of a test.
+type MetricStats struct {
+	Mean, Std float64
+	Min, Max  float64
+	Runs      int
+}
+
+func metricStats(values []float64) MetricStats {
+	s := MetricStats{Runs: len(values)}
+	switch len(values) {
+	case 0:
+		return s
+	case 1:
+		s.Mean = values[0]
+	default:
+		s.Mean, s.Std = stat.MeanStdDev(values, nil)
+	}
+	s.Min, s.Max = values[0], values[0]
+	for _, v := range values[1:] {
+		s.Min, s.Max = math.Min(s.Min, v), math.Max(s.Max, v)
+	}
+	return s
+}
+
+// MetricDelta compares a metric of a test between two sets of runs.
+// Throughput is in bytes/s, latencies in nanoseconds.
+type MetricDelta struct {
+	Metric        string
+	Before, After MetricStats
+	LowerIsBetter bool
+}
+
+// Change returns the relative change of the mean.
+func (d MetricDelta) Change() float64 {
+	if d.Before.Mean == 0 {
+		return 0
+	}
+	return (d.After.Mean - d.Before.Mean) / d.Before.Mean
+}
+
+// Significant reports whether the means differ by more than the sum of the
+// standard deviations. Changes between single runs are never significant
+// because their variation is unknown.
+func (d MetricDelta) Significant() bool {
+	if d.Before.Runs < 2 || d.After.Runs < 2 {
+		return false
+	}
+	return math.Abs(d.After.Mean-d.Before.Mean) > d.Before.Std+d.After.Std
+}
+
+// Improved reports whether the change is an improvement.
+func (d MetricDelta) Improved() bool {
+	return (d.After.Mean < d.Before.Mean) == d.LowerIsBetter
+}
+
+// TestDelta compares the runs of a test in two sets of logs.
+type TestDelta struct {
+	Label   string
+	Metrics []MetricDelta // metrics missing in either set are left out
+}
+
+// compareMetrics are the metrics compared by Compare.
+var compareMetrics = []struct {
+	name  string
+	lower bool
+	value func(r *Report) (float64, bool)
+}{
+	{"throughput", false, func(r *Report) (float64, bool) {
+		s := Summarize(*r)
+		return s.BPS(), s.TotalTime > 0
+	}},
+	{"p50", true, latencyMetric(func(l *LatencyPercentiles) float64 { return float64(l.P50) })},
+	{"p90", true, latencyMetric(func(l *LatencyPercentiles) float64 { return float64(l.P90) })},
+	{"p99", true, latencyMetric(func(l *LatencyPercentiles) float64 { return float64(l.P99) })},
+	{"p99.9", true, latencyMetric(func(l *LatencyPercentiles) float64 { return float64(l.P999) })},
+}
+
+func latencyMetric(fn func(*LatencyPercentiles) float64) func(*Report) (float64, bool) {
+	return func(r *Report) (float64, bool) {
+		if r.Result == nil || r.Result.Latency == nil {
+			return 0, false
+		}
+		return fn(r.Result.Latency), true
+	}
+}
+
+// Compare matches the reports of two sets of logs by label and compares the
+// throughput and latency percentiles of every test in both. Reports with the
+// same label are repeated runs. Unsupported runs are ignored. The labels of
+// tests found only in one of the sets are returned in onlyBefore and onlyAfter.
+func Compare(before, after []Report) (deltas []TestDelta, onlyBefore, onlyAfter []string) {
+	b, blabels := groupByLabel(before)
+	a, alabels := groupByLabel(after)
+	for _, label := range blabels {
+		if a[label] == nil {
+			onlyBefore = append(onlyBefore, label)
+			continue
+		}
+		td := TestDelta{Label: label}
+		for _, m := range compareMetrics {
+			bv, av := metricValues(b[label], m.value), metricValues(a[label], m.value)
+			if len(bv) == 0 || len(av) == 0 {
+				continue
+			}
+			td.Metrics = append(td.Metrics, MetricDelta{
+				Metric:        m.name,
+				Before:        metricStats(bv),
+				After:         metricStats(av),
+				LowerIsBetter: m.lower,
+			})
+		}
+		deltas = append(deltas, td)
+	}
+	for _, label := range alabels {
+		if b[label] == nil {
+			onlyAfter = append(onlyAfter, label)
+		}
+	}
+	return deltas, onlyBefore, onlyAfter
+}
+
+func groupByLabel(reports []Report) (map[string][]*Report, []string) {
+	var (
+		groups = make(map[string][]*Report)
+		labels []string
+	)
+	for i := range reports {
+		r := &reports[i]
+		if r.Result != nil && r.Result.Unsupported != "" {
+			continue
+		}
+		label := testLabel(*r)
+		if groups[label] == nil {
+			labels = append(labels, label)
+		}
+		groups[label] = append(groups[label], r)
+	}
+	return groups, labels
+}
+
+// testLabel is the label of a report, named after the test in the log header
+// rather than the log file. Read test logs have the start time in the file name.
+func testLabel(r Report) string {
+	if r.Header != nil && r.Header.Test != "" {
+		r.Name = r.Header.Test
+	}
+	return r.Label()
+}
+
+func metricValues(reports []*Report, value func(*Report) (float64, bool)) []float64 {
+	var values []float64
+	for _, r := range reports {
+		if v, ok := value(r); ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// Thresholds are the largest acceptable relative changes for the worse, e.g.
+// 0.05 for 5%. Zero disables the check of a metric.
+type Thresholds struct {
+	Throughput float64 // drop of the throughput
+	P99        float64 // rise of the p99 latency
+}
+
+// Regressions returns the metrics of the test which got worse by more than the
+// thresholds allow. If both sides have repeated runs, changes within noise are
+// not regressions.
+func (d TestDelta) Regressions(th Thresholds) []MetricDelta {
+	var regressed []MetricDelta
+	for _, m := range d.Metrics {
+		var limit float64
+		switch m.Metric {
+		case "throughput":
+			limit = th.Throughput
+		case "p99":
+			limit = th.P99
+		}
+		if limit == 0 || m.Improved() {
+			continue
+		}
+		if m.Before.Runs > 1 && m.After.Runs > 1 && !m.Significant() {
+			continue
+		}
+		if math.Abs(m.Change()) > limit {
+			regressed = append(regressed, m)
+		}
+	}
+	return regressed
+}
@@ -0,0 +1,104 @@
+package bench
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCompare(t *testing.T) {
+	run := func(name string, mbps uint64, p99 time.Duration) Report {
+		ev := Progress{Processed: mbps << 20, Delta: mbps << 20, Duration: time.Second}
+		return Report{
+			Name:   name,
+			Events: []Progress{ev},
+			Result: &RunResult{Latency: &LatencyPercentiles{P50: p99 / 2, P90: p99 / 2, P99: p99, P999: p99}},
+		}
+	}
+	before := []Report{
+		run("a", 100, 10*time.Microsecond), run("a", 102, 11*time.Microsecond), run("a", 98, 9*time.Microsecond),
+		run("gone", 1, time.Millisecond),
+	}
+	after := []Report{
+		run("a", 150, 10*time.Microsecond), run("a", 151, 12*time.Microsecond), run("a", 149, 8*time.Microsecond),
+		run("new", 1, time.Millisecond),
+	}
+	deltas, onlyBefore, onlyAfter := Compare(before, after)
+	if !reflect.DeepEqual(onlyBefore, []string{"gone"}) || !reflect.DeepEqual(onlyAfter, []string{"new"}) {
+		t.Errorf("wrong unmatched tests: before %v, after %v", onlyBefore, onlyAfter)
+	}
+	if len(deltas) != 1 || deltas[0].Label != "a" || len(deltas[0].Metrics) != 5 {
+		t.Fatalf("wrong deltas %+v", deltas)
+	}
+	bps, p99 := deltas[0].Metrics[0], deltas[0].Metrics[3]
+	if bps.Before.Runs != 3 || bps.After.Runs != 3 {
+		t.Errorf("wrong run counts %d, %d", bps.Before.Runs, bps.After.Runs)
+	}
+	if c := bps.Change(); c < 0.49 || c > 0.51 {
+		t.Errorf("wrong throughput change %v", c)
+	}
+	if !bps.Significant() || !bps.Improved() {
+		t.Errorf("throughput change should be a significant improvement: %+v", bps)
+	}
+	if p99.Metric != "p99" || p99.Change() != 0 || p99.Significant() {
+		t.Errorf("p99 shouldn't change: %+v", p99)
+	}
+}
+
+func TestCompareSingleRun(t *testing.T) {
+	d := MetricDelta{Before: metricStats([]float64{100}), After: metricStats([]float64{200})}
+	if d.Before.Std != 0 || d.Change() != 1 {
+		t.Errorf("wrong stats %+v", d)
+	}
+	if d.Significant() {
+		t.Error("single runs can't be significant")
+	}
+}
+
+func TestCompareReadLogs(t *testing.T) {
+	// Read test logs are named after the test and the start time.
+	run := func(file string) Report {
+		ev := Progress{Processed: 1 << 20, Delta: 1 << 20, Duration: time.Second}
+		return Report{Name: file, Header: &LogHeader{Test: "random-read"}, Events: []Progress{ev}}
+	}
+	before := []Report{run("random-read.2020-01-01-10:00:00")}
+	after := []Report{run("random-read.2020-01-02-10:00:00")}
+	deltas, onlyBefore, onlyAfter := Compare(before, after)
+	if len(onlyBefore) != 0 || len(onlyAfter) != 0 {
+		t.Errorf("unmatched tests: before %v, after %v", onlyBefore, onlyAfter)
+	}
+	if len(deltas) != 1 || deltas[0].Label != "random-read" {
+		t.Fatalf("wrong deltas %+v", deltas)
+	}
+}
+
+func TestRegressions(t *testing.T) {
+	stats := func(values ...float64) MetricStats { return metricStats(values) }
+	d := TestDelta{Label: "a", Metrics: []MetricDelta{
+		{Metric: "throughput", Before: stats(100), After: stats(90)},
+		{Metric: "p50", Before: stats(10), After: stats(20), LowerIsBetter: true},
+		{Metric: "p99", Before: stats(10), After: stats(10.5), LowerIsBetter: true},
+	}}
+	if r := d.Regressions(Thresholds{}); len(r) != 0 {
+		t.Errorf("zero thresholds shouldn't report regressions: %+v", r)
+	}
+	r := d.Regressions(Thresholds{Throughput: 0.05, P99: 0.1})
+	if len(r) != 1 || r[0].Metric != "throughput" {
+		t.Errorf("wrong regressions %+v", r)
+	}
+	r = d.Regressions(Thresholds{Throughput: 0.2, P99: 0.01})
+	if len(r) != 1 || r[0].Metric != "p99" {
+		t.Errorf("wrong regressions %+v", r)
+	}
+
+	// Repeated runs: the drop is within noise.
+	d.Metrics = []MetricDelta{{Metric: "throughput", Before: stats(100, 120), After: stats(90, 110)}}
+	if r := d.Regressions(Thresholds{Throughput: 0.05}); len(r) != 0 {
+		t.Errorf("change within noise reported as regression: %+v", r)
+	}
+	// Improvements are never regressions.
+	d.Metrics = []MetricDelta{{Metric: "throughput", Before: stats(100), After: stats(200)}}
+	if r := d.Regressions(Thresholds{Throughput: 0.05}); len(r) != 0 {
+		t.Errorf("improvement reported as regression: %+v", r)
+	}
+}
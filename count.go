@@ -0,0 +1,63 @@
+package bench
+
+import (
+	"log"
+	"time"
+)
+
+// CountFunc sets the function used to count keys at the end of a run. The
+// function must count all keys in the range [start, limit), a nil limit means
+// no upper bound. Counting is enabled by setting cfg.CountKeys.
+func (env *WriteEnv) CountFunc(fn func(start, limit []byte) (uint64, error)) {
+	env.countFn = fn
+}
+
+// countKeys counts the keys in the database. If cfg.CountSample is larger than
+// one, only 1/CountSample of the key space is iterated and the result is
+// extrapolated. This works because generated keys are uniformly distributed.
+// Without bytewise key order, all keys are counted.
+func (env *WriteEnv) countKeys() (uint64, error) {
+	var limit []byte
+	sample := env.cfg.CountSample
+	if sample > 256 {
+		sample = 256
+	}
+	if env.keyOrder != "" {
+		sample = 1
+	}
+	if sample > 1 {
+		limit = []byte{byte(256 / sample)}
+	}
+	begin := time.Now()
+	n, err := env.countFn(nil, limit)
+	if err != nil {
+		return 0, err
+	}
+	if sample > 1 {
+		n = n * 256 / uint64(256/sample)
+	}
+	log.Printf("counted %d keys in %v", n, time.Since(begin))
+	return n, nil
+}
+
+// verifyCount adds the key count to the run result.
+func (env *WriteEnv) verifyCount(result *RunResult) {
+	if !env.cfg.CountKeys || env.countFn == nil {
+		return
+	}
+	n, err := env.countKeys()
+	if err != nil {
+		log.Printf("can't count keys: %v", err)
+		return
+	}
+	// Delete tests remove all written keys.
+	expected := result.UniqueKeys
+	if result.Deletes > 0 {
+		expected = 0
+	}
+	result.CountedKeys = n
+	result.KeyDiscrepancy = int64(n) - int64(expected)
+	if result.KeyDiscrepancy != 0 && (env.cfg.CountSample <= 1 || env.keyOrder != "") {
+		log.Printf("database has %d keys, expected %d", n, expected)
+	}
+}
@@ -0,0 +1,57 @@
+package bench
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// progressCSVHeader are the columns of the CSV progress table. Times are in
+// seconds, sizes in bytes.
+var progressCSVHeader = []string{"time", "processed", "delta", "duration", "entries", "commits", "bps"}
+
+// progressCSV writes progress events as a CSV table.
+type progressCSV struct {
+	w   *csv.Writer
+	rec []string
+}
+
+func newProgressCSV(w io.Writer) (*progressCSV, error) {
+	c := &progressCSV{w: csv.NewWriter(w), rec: make([]string, len(progressCSVHeader))}
+	return c, c.w.Write(progressCSVHeader)
+}
+
+// add writes a row. It can be called on a nil table, which does nothing.
+func (c *progressCSV) add(p Progress) {
+	if c == nil {
+		return
+	}
+	c.rec[0] = strconv.FormatFloat(p.Time.Seconds(), 'f', 6, 64)
+	c.rec[1] = strconv.FormatUint(p.Processed, 10)
+	c.rec[2] = strconv.FormatUint(p.Delta, 10)
+	c.rec[3] = strconv.FormatFloat(p.Duration.Seconds(), 'f', 6, 64)
+	c.rec[4] = strconv.FormatUint(p.Entries, 10)
+	c.rec[5] = strconv.FormatUint(p.Commits, 10)
+	c.rec[6] = strconv.FormatFloat(p.BPS(), 'f', 0, 64)
+	c.w.Write(c.rec)
+}
+
+func (c *progressCSV) flush() error {
+	if c == nil {
+		return nil
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ProgressCSV enables writing progress events to w as a CSV table, in addition
+// to the log. It must be called before Run.
+func (env *WriteEnv) ProgressCSV(w io.Writer) {
+	env.csvOut = w
+}
+
+// ProgressCSV enables writing progress events to w as a CSV table, in addition
+// to the log. It must be called before Run.
+func (env *ReadEnv) ProgressCSV(w io.Writer) {
+	env.csvOut = w
+}
@@ -0,0 +1,48 @@
+package bench
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestProgressCSV(t *testing.T) {
+	var (
+		out bytes.Buffer
+		cfg = WriteConfig{Size: 4 << 20, KeySize: 8, DataSize: 128}
+		env = NewWriteEnv(ioutil.Discard, cfg)
+	)
+	env.ProgressCSV(&out)
+	err := env.Run(func(key, value string, lastCall bool) error {
+		env.Progress(len(value))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) < 2 {
+		t.Fatalf("got %d rows, want header and progress", len(rows))
+	}
+	if !reflect.DeepEqual(rows[0], progressCSVHeader) {
+		t.Errorf("wrong header %q", rows[0])
+	}
+	var last uint64
+	for _, row := range rows[1:] {
+		processed, err := strconv.ParseUint(row[1], 10, 64)
+		if err != nil {
+			t.Fatalf("bad processed column %q: %v", row[1], err)
+		}
+		if processed <= last {
+			t.Errorf("processed %d after %d", processed, last)
+		}
+		last = processed
+	}
+}
@@ -0,0 +1,136 @@
+package bench
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// statsInterval is the time between samples of the compaction statistics.
+const statsInterval = time.Second
+
+// CompactionStats is a sample of the compaction statistics of the database.
+// Counters are totals since the database was opened.
+type CompactionStats struct {
+	Time   time.Duration `json:"time"` // since the start of the run
+	Levels []LevelStats  `json:"levels"`
+
+	WriteDelays int           `json:"writedelays,omitempty"` // number of delayed writes
+	WriteDelay  time.Duration `json:"writedelay,omitempty"`  // total delay of writes
+	WritePaused bool          `json:"writepaused,omitempty"` // writes are paused for level-0 compaction
+}
+
+// LevelStats are the compaction statistics of a level. Read and Write are the
+// bytes read and written by compactions into the level.
+type LevelStats struct {
+	Tables   int           `json:"tables"`
+	Size     int64         `json:"size"`
+	Read     int64         `json:"read"`
+	Write    int64         `json:"write"`
+	Duration time.Duration `json:"duration"` // time spent in compactions
+}
+
+// Totals returns the compaction reads, writes and time of all levels.
+func (s *CompactionStats) Totals() (read, write int64, d time.Duration) {
+	for _, l := range s.Levels {
+		read += l.Read
+		write += l.Write
+		d += l.Duration
+	}
+	return read, write, d
+}
+
+// statsSampler logs compaction statistics while a run is going on.
+type statsSampler struct {
+	fn     func() (*CompactionStats, error)
+	stop   chan struct{}
+	done   chan struct{}
+	failed bool // an error was logged
+}
+
+// start launches the sampling goroutine. The now function returns the time since
+// the start of the run, log writes the sample. Both are called with mu held.
+func (s *statsSampler) start(mu *sync.Mutex, now func() time.Duration, log func(CompactionStats)) {
+	if s.fn == nil {
+		return
+	}
+	s.stop, s.done, s.failed = make(chan struct{}), make(chan struct{}), false
+	go func() {
+		defer close(s.done)
+		tick := time.NewTicker(statsInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				s.sample(mu, now, log)
+			case <-s.stop:
+				// Take a final sample, so the log ends with the totals.
+				s.sample(mu, now, log)
+				return
+			}
+		}
+	}()
+}
+
+func (s *statsSampler) sample(mu *sync.Mutex, now func() time.Duration, logfn func(CompactionStats)) {
+	mu.Lock()
+	fn := s.fn // tests reopening the database replace it
+	mu.Unlock()
+	st, err := fn()
+	if err != nil {
+		if !s.failed {
+			log.Printf("can't get compaction statistics: %v", err)
+			s.failed = true
+		}
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	st.Time = now()
+	logfn(*st)
+}
+
+func (s *statsSampler) stopSampling() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+	s.stop = nil
+}
+
+// CompactionStatsFunc sets the function returning the compaction statistics of
+// the database, which are logged once per second.
+func (env *WriteEnv) CompactionStatsFunc(fn func() (*CompactionStats, error)) {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	env.stats.fn = fn
+}
+
+// CompactionStatsFunc sets the function returning the compaction statistics of
+// the database, which are logged once per second.
+func (env *ReadEnv) CompactionStatsFunc(fn func() (*CompactionStats, error)) {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	env.stats.fn = fn
+}
+
+// PeakTables returns the highest number of tables of each level seen in the
+// compaction statistics samples of the report, and the time the level 0 peak
+// was sampled. Level 0 pile-ups usually explain write stalls.
+func (r *Report) PeakTables() (tables []int, l0 time.Duration) {
+	for _, c := range r.Compactions {
+		for i, l := range c.Levels {
+			if i >= len(tables) {
+				tables = append(tables, 0)
+			}
+			if l.Tables > tables[i] {
+				tables[i] = l.Tables
+				if i == 0 {
+					l0 = c.Time
+				}
+			}
+		}
+	}
+	return tables, l0
+}
@@ -0,0 +1,57 @@
+package bench
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// RunDelete fills the database like Run, then deletes all written keys again.
+// Only the delete phase is measured: the del function should perform the
+// delete and call Progress with the size of the deleted value once it is
+// committed. Afterwards, the database size is sampled for cfg.DiskWatch to
+// show when space is reclaimed.
+func (env *WriteEnv) RunDelete(write func(key, value string, lastCall bool) error, del func(key string, valueSize int, lastCall bool) error) (err error) {
+	if env.cfg.Generators != 0 {
+		return errors.New("delete tests don't support the generator pool")
+	}
+	if !Reproducible(env.cfg.Entropy) {
+		return fmt.Errorf("delete tests can't regenerate keys with entropy source %q", env.cfg.Entropy)
+	}
+	if err := env.start(); err != nil {
+		return err
+	}
+	defer func() { env.finish(err) }()
+
+	log.Printf("filling database")
+	err = env.generate(func(key, value []byte, end bool) error {
+		env.recordPut(key, value)
+		return write(string(key), string(value), end)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Regenerate the same keys for deletion.
+	if err := env.resetRand(); err != nil {
+		return err
+	}
+	env.mu.Lock()
+	env.startTime = mononow()
+	env.lastTime = env.startTime
+	env.mu.Unlock()
+	env.sampleDisk()
+	err = env.generate(func(key, value []byte, end bool) error {
+		env.deletes++
+		begin := mononow()
+		err := del(string(key), len(value), end)
+		env.opDone("delete", begin)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	env.sampleDisk()
+	env.watchDisk()
+	return nil
+}
@@ -0,0 +1,301 @@
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A Detector analyzes the metric stream of a run while it is logged. Observe is
+// called for every metric, calls are serialized. Messages passed to annotate
+// are logged as annotations at the current position of the run.
+type Detector interface {
+	Observe(m Metric, annotate func(msg string))
+}
+
+// Metric is an element of the metric stream. Exactly one of Progress, Stall,
+// Disk and Memory is set.
+type Metric struct {
+	Time   time.Duration // since the start of the run
+	Offset uint64        // bytes processed
+
+	Progress *Progress
+	Stall    *Stall
+	Disk     *DiskUsage
+	Memory   *MemorySample
+}
+
+// MemorySample is a sample of the process memory, taken once per second while
+// detectors are active.
+type MemorySample struct {
+	HeapAlloc uint64 // bytes of allocated heap objects
+	Sys       uint64 // bytes obtained from the OS by the Go runtime
+	RSS       uint64 // resident set size, Sys where it can't be measured
+}
+
+// Annotation is a finding of a detector.
+type Annotation struct {
+	Detector string        `json:"detector"`
+	Time     time.Duration `json:"time"`
+	Offset   uint64        `json:"offset"`
+	Message  string        `json:"message"`
+}
+
+var (
+	detectorMu  sync.Mutex
+	detectorFns = map[string]func() Detector{
+		"stall":     func() Detector { return new(stallDetector) },
+		"cliff":     func() Detector { return new(cliffDetector) },
+		"memgrowth": func() Detector { return new(memGrowthDetector) },
+	}
+)
+
+// RegisterDetector adds a detector which can be enabled by name. The function is
+// called once per run to create a fresh detector. RegisterDetector is meant to
+// be called from init functions, it panics if the name is already taken.
+func RegisterDetector(name string, fn func() Detector) {
+	detectorMu.Lock()
+	defer detectorMu.Unlock()
+	if _, ok := detectorFns[name]; ok || name == "all" {
+		panic("detector " + name + " already registered")
+	}
+	detectorFns[name] = fn
+}
+
+// DetectorNames returns the names of all registered detectors.
+func DetectorNames() []string {
+	detectorMu.Lock()
+	defer detectorMu.Unlock()
+	names := make([]string, 0, len(detectorFns))
+	for name := range detectorFns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseDetectors parses a comma-separated list of detector names. "all" selects
+// every registered detector.
+func ParseDetectors(list string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		switch name = strings.TrimSpace(name); {
+		case name == "":
+		case name == "all":
+			names = append(names, DetectorNames()...)
+		default:
+			detectorMu.Lock()
+			_, ok := detectorFns[name]
+			detectorMu.Unlock()
+			if !ok {
+				return nil, fmt.Errorf("unknown detector %q", name)
+			}
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// detectorSet runs the detectors of a run. All methods can be called on a nil
+// set, which does nothing. The caller must hold the lock of the environment
+// when calling observe.
+type detectorSet struct {
+	names []string
+	dets  []Detector
+	out   *json.Encoder
+	wd    *watchdog
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func newDetectorSet(names []string, wd WatchdogConfig, test string, out *json.Encoder) (*detectorSet, error) {
+	if len(names) == 0 && !wd.enabled() {
+		return nil, nil
+	}
+	d := &detectorSet{out: out}
+	detectorMu.Lock()
+	defer detectorMu.Unlock()
+	for _, name := range names {
+		fn, ok := detectorFns[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown detector %q", name)
+		}
+		d.names = append(d.names, name)
+		d.dets = append(d.dets, fn())
+	}
+	if wd.enabled() {
+		d.wd = newWatchdog(wd, test)
+		d.names = append(d.names, "watchdog")
+		d.dets = append(d.dets, d.wd)
+	}
+	return d, nil
+}
+
+// err returns ErrWatchdog once the memory watchdog has aborted the run.
+func (d *detectorSet) err() error {
+	if d != nil && d.wd != nil && atomic.LoadInt32(&d.wd.aborted) != 0 {
+		return ErrWatchdog
+	}
+	return nil
+}
+
+// observe feeds m to all detectors and logs their annotations.
+func (d *detectorSet) observe(m Metric) {
+	if d == nil {
+		return
+	}
+	for i, det := range d.dets {
+		det.Observe(m, func(msg string) {
+			writeAnnotation(d.out, Annotation{Detector: d.names[i], Time: m.Time, Offset: m.Offset, Message: msg})
+		})
+	}
+}
+
+// startMemory samples the heap once per second. The position function is
+// called with mu held and returns the current time and offset of the run.
+func (d *detectorSet) startMemory(mu *sync.Mutex, position func() (time.Duration, uint64)) {
+	if d == nil {
+		return
+	}
+	d.stop, d.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(d.done)
+		tick := time.NewTicker(time.Second)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				var ms runtime.MemStats
+				runtime.ReadMemStats(&ms)
+				sample := MemorySample{HeapAlloc: ms.HeapAlloc, Sys: ms.Sys, RSS: ms.Sys}
+				if rss, ok := readRSS(); ok {
+					sample.RSS = rss
+				}
+				mu.Lock()
+				t, offset := position()
+				d.observe(Metric{Time: t, Offset: offset, Memory: &sample})
+				mu.Unlock()
+			case <-d.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (d *detectorSet) stopMemory() {
+	if d == nil || d.stop == nil {
+		return
+	}
+	close(d.stop)
+	<-d.done
+	d.stop = nil
+}
+
+// Settings of the built-in detectors.
+const (
+	stallBurst       = 5 // stalls within stallBurstWindow forming a burst
+	stallBurstWindow = time.Second
+	stallQuiet       = 5 * time.Second // time without stalls ending a burst
+
+	cliffPeriod  = 2 * time.Second // throughput measurement period
+	cliffWarmup  = 1               // periods before the peak is tracked
+	cliffDrop    = 0.5             // fraction of the peak signaling a cliff
+	cliffRecover = 0.75
+
+	memWarmup = 5 // samples before the baseline is taken
+	memGrowth = 2 // growth factor reported
+)
+
+// stallDetector reports bursts of stalls.
+type stallDetector struct {
+	recent  []Stall
+	times   []time.Duration
+	inBurst bool
+}
+
+func (d *stallDetector) Observe(m Metric, annotate func(string)) {
+	if n := len(d.times); d.inBurst && n > 0 && m.Time-d.times[n-1] > stallQuiet {
+		d.inBurst = false
+	}
+	if m.Stall == nil {
+		return
+	}
+	d.recent, d.times = append(d.recent, *m.Stall), append(d.times, m.Time)
+	for len(d.times) > 0 && m.Time-d.times[0] > stallBurstWindow {
+		d.recent, d.times = d.recent[1:], d.times[1:]
+	}
+	if len(d.recent) >= stallBurst && !d.inBurst {
+		d.inBurst = true
+		var longest time.Duration
+		for _, s := range d.recent {
+			if s.Duration > longest {
+				longest = s.Duration
+			}
+		}
+		annotate(fmt.Sprintf("stall burst: %d stalls within %v, longest %v", len(d.recent), stallBurstWindow, longest))
+	}
+}
+
+// cliffDetector reports when the throughput falls below half of its peak, and
+// when it recovers. Throughput is measured over periods of cliffPeriod.
+type cliffDetector struct {
+	period     Progress
+	periods    int
+	peak       float64
+	inCliff    bool
+	cliffStart time.Duration
+}
+
+func (d *cliffDetector) Observe(m Metric, annotate func(string)) {
+	if m.Progress == nil {
+		return
+	}
+	d.period.Delta += m.Progress.Delta
+	d.period.Duration += m.Progress.Duration
+	if d.period.Duration < cliffPeriod {
+		return
+	}
+	bps := d.period.BPS()
+	d.period = Progress{}
+	if d.periods++; d.periods <= cliffWarmup {
+		return
+	}
+	if bps > d.peak {
+		d.peak = bps
+	}
+	switch {
+	case !d.inCliff && bps < cliffDrop*d.peak:
+		d.inCliff, d.cliffStart = true, m.Time
+		annotate(fmt.Sprintf("throughput fell to %.1f mb/s, peak was %.1f mb/s", bps/1024/1024, d.peak/1024/1024))
+	case d.inCliff && bps > cliffRecover*d.peak:
+		d.inCliff = false
+		annotate(fmt.Sprintf("throughput recovered to %.1f mb/s after %v", bps/1024/1024, m.Time-d.cliffStart))
+	}
+}
+
+// memGrowthDetector reports every doubling of the heap after the warmup.
+type memGrowthDetector struct {
+	samples   int
+	level     uint64
+	levelTime time.Duration
+}
+
+func (d *memGrowthDetector) Observe(m Metric, annotate func(string)) {
+	if m.Memory == nil {
+		return
+	}
+	heap := m.Memory.HeapAlloc
+	if d.samples++; d.samples <= memWarmup {
+		d.level, d.levelTime = heap, m.Time
+		return
+	}
+	if heap >= memGrowth*d.level {
+		annotate(fmt.Sprintf("heap grew from %s to %s in %v", FormatSize(d.level), FormatSize(heap), m.Time-d.levelTime))
+		d.level, d.levelTime = heap, m.Time
+	}
+}
@@ -0,0 +1,139 @@
+package bench
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// observeAll feeds metrics to a detector and returns its annotations.
+func observeAll(d Detector, metrics []Metric) []string {
+	var msgs []string
+	for _, m := range metrics {
+		d.Observe(m, func(msg string) { msgs = append(msgs, msg) })
+	}
+	return msgs
+}
+
+func TestCliffDetector(t *testing.T) {
+	var (
+		metrics []Metric
+		now     time.Duration
+	)
+	add := func(n int, mbps float64) {
+		for _, ev := range steadyEvents(n, mbps, 0) {
+			ev := ev
+			now += ev.Duration
+			metrics = append(metrics, Metric{Time: now, Progress: &ev})
+		}
+	}
+	add(64, 8) // four periods of 2s
+	add(40, 2) // ten periods
+	add(64, 8)
+	want := []string{
+		"throughput fell to 2.0 mb/s, peak was 8.0 mb/s",
+		"throughput recovered to 8.0 mb/s after 20s",
+	}
+	if msgs := observeAll(new(cliffDetector), metrics); !reflect.DeepEqual(msgs, want) {
+		t.Errorf("wrong annotations %q", msgs)
+	}
+}
+
+func TestStallDetector(t *testing.T) {
+	var metrics []Metric
+	stall := func(at time.Duration, d time.Duration) {
+		metrics = append(metrics, Metric{Time: at, Stall: &Stall{Duration: d}})
+	}
+	// Four stalls aren't a burst.
+	for i := 0; i < 4; i++ {
+		stall(time.Duration(i)*100*time.Millisecond, 150*time.Millisecond)
+	}
+	// Stalls spread out over more than a second aren't either.
+	for i := 0; i < 5; i++ {
+		stall(10*time.Second+time.Duration(i)*400*time.Millisecond, 150*time.Millisecond)
+	}
+	// A burst is only reported once.
+	for i := 0; i < 10; i++ {
+		stall(20*time.Second+time.Duration(i)*100*time.Millisecond, time.Duration(i+1)*100*time.Millisecond)
+	}
+	want := []string{"stall burst: 5 stalls within 1s, longest 500ms"}
+	if msgs := observeAll(new(stallDetector), metrics); !reflect.DeepEqual(msgs, want) {
+		t.Errorf("wrong annotations %q", msgs)
+	}
+}
+
+func TestMemGrowthDetector(t *testing.T) {
+	var metrics []Metric
+	for i, heap := range []uint64{50, 80, 100, 100, 100, 120, 150, 199, 200, 300, 399, 400} {
+		metrics = append(metrics, Metric{
+			Time:   time.Duration(i+1) * time.Second,
+			Memory: &MemorySample{HeapAlloc: heap << 20},
+		})
+	}
+	want := []string{
+		"heap grew from 100mb to 200mb in 4s",
+		"heap grew from 200mb to 400mb in 3s",
+	}
+	if msgs := observeAll(new(memGrowthDetector), metrics); !reflect.DeepEqual(msgs, want) {
+		t.Errorf("wrong annotations %q", msgs)
+	}
+}
+
+type countingDetector struct{ events int }
+
+func (d *countingDetector) Observe(m Metric, annotate func(string)) {
+	if m.Progress != nil {
+		if d.events++; d.events%10 == 0 {
+			annotate("ten more events")
+		}
+	}
+}
+
+func TestCustomDetector(t *testing.T) {
+	RegisterDetector("test-counting", func() Detector { return new(countingDetector) })
+	names, err := ParseDetectors("test-counting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		out bytes.Buffer
+		cfg = WriteConfig{Size: 10 << 20, KeySize: 8, DataSize: 100, Detectors: names}
+		env = NewWriteEnv(&out, cfg)
+	)
+	err = env.Run(func(key, value string, lastCall bool) error {
+		env.Progress(len(value))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events, annotations int
+	dec := json.NewDecoder(&out)
+	for {
+		var e logEntry
+		if err := dec.Decode(&e); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case e.Annotation != nil:
+			annotations++
+			if e.Annotation.Detector != "test-counting" || e.Annotation.Offset == 0 {
+				t.Errorf("wrong annotation %+v", e.Annotation)
+			}
+		case e.Header == nil && e.Result == nil:
+			events++
+		}
+	}
+	if events < 10 || annotations != events/10 {
+		t.Errorf("got %d annotations for %d events", annotations, events)
+	}
+	if _, err := ParseDetectors("test-counting,unknown"); err == nil {
+		t.Error("no error for unknown detector")
+	}
+}
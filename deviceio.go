@@ -0,0 +1,118 @@
+package bench
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DeviceIO are the I/O counters of the block device holding the database, as
+// reported by the operating system. They include all I/O of the device, not
+// only that of the database.
+type DeviceIO struct {
+	Device     string `json:"device"`
+	Reads      uint64 `json:"reads"`  // completed read requests
+	Writes     uint64 `json:"writes"` // completed write requests
+	ReadBytes  uint64 `json:"readbytes"`
+	WriteBytes uint64 `json:"writebytes"`
+
+	ReadTime  time.Duration `json:"readtime"`  // total time of reads
+	WriteTime time.Duration `json:"writetime"` // total time of writes
+	IOTime    time.Duration `json:"iotime"`    // time the device was busy
+	QueueTime time.Duration `json:"queuetime"` // time requests were queued, summed over requests
+}
+
+func (d DeviceIO) sub(o DeviceIO) DeviceIO {
+	return DeviceIO{
+		Device:     d.Device,
+		Reads:      d.Reads - o.Reads,
+		Writes:     d.Writes - o.Writes,
+		ReadBytes:  d.ReadBytes - o.ReadBytes,
+		WriteBytes: d.WriteBytes - o.WriteBytes,
+		ReadTime:   d.ReadTime - o.ReadTime,
+		WriteTime:  d.WriteTime - o.WriteTime,
+		IOTime:     d.IOTime - o.IOTime,
+		QueueTime:  d.QueueTime - o.QueueTime,
+	}
+}
+
+func (d DeviceIO) String() string {
+	mb := func(n uint64) float64 { return float64(n) / 1024 / 1024 }
+	return fmt.Sprintf("%s: read %.3f mb in %d requests, wrote %.3f mb in %d requests, busy %v, queue time %v",
+		d.Device, mb(d.ReadBytes), d.Reads, mb(d.WriteBytes), d.Writes, d.IOTime, d.QueueTime)
+}
+
+// DeviceWriteAmplification returns the ratio of bytes written to the device to the
+// key and value bytes written. It returns zero if either is unknown.
+func (r *RunResult) DeviceWriteAmplification() float64 {
+	if r.Device == nil || r.PutBytes == 0 {
+		return 0
+	}
+	return float64(r.Device.WriteBytes) / float64(r.PutBytes)
+}
+
+// diskstatsSector is the unit of the sector counts in /proc/diskstats, which is
+// independent of the sector size of the device.
+const diskstatsSector = 512
+
+// parseDiskstats finds the counters of a device in /proc/diskstats.
+func parseDiskstats(r io.Reader, major, minor uint64) (DeviceIO, bool) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) < 14 || f[0] != strconv.FormatUint(major, 10) || f[1] != strconv.FormatUint(minor, 10) {
+			continue
+		}
+		var v [14]uint64
+		for i := 3; i < 14; i++ {
+			n, err := strconv.ParseUint(f[i], 10, 64)
+			if err != nil {
+				return DeviceIO{}, false
+			}
+			v[i] = n
+		}
+		ms := func(n uint64) time.Duration { return time.Duration(n) * time.Millisecond }
+		return DeviceIO{
+			Device:     f[2],
+			Reads:      v[3],
+			ReadBytes:  v[5] * diskstatsSector,
+			ReadTime:   ms(v[6]),
+			Writes:     v[7],
+			WriteBytes: v[9] * diskstatsSector,
+			WriteTime:  ms(v[10]),
+			IOTime:     ms(v[12]),
+			QueueTime:  ms(v[13]),
+		}, true
+	}
+	return DeviceIO{}, false
+}
+
+// deviceCounter measures the device I/O of a run.
+type deviceCounter struct {
+	dir   string
+	start DeviceIO
+	ok    bool
+}
+
+// begin reads the counters at the start of the run. The device is found
+// again at the end because the database directory may not exist yet.
+func (c *deviceCounter) begin(dir string) {
+	c.dir = dir
+	c.start, c.ok = readDeviceIO(dir)
+}
+
+// since returns the I/O since begin, or nil if it isn't known.
+func (c *deviceCounter) since() *DeviceIO {
+	if !c.ok {
+		return nil
+	}
+	end, ok := readDeviceIO(c.dir)
+	if !ok || end.Device != c.start.Device {
+		return nil
+	}
+	d := end.sub(c.start)
+	return &d
+}
@@ -0,0 +1,35 @@
+package bench
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// readDeviceIO reads the I/O counters of the block device holding dir from
+// /proc/diskstats. Filesystems without a block device, like tmpfs, have none.
+func readDeviceIO(dir string) (DeviceIO, bool) {
+	var st syscall.Stat_t
+	for {
+		if err := syscall.Stat(dir, &st); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return DeviceIO{}, false
+		}
+		dir = parent // dir not created yet
+	}
+	dev := uint64(st.Dev)
+	major := (dev>>8)&0xfff | (dev>>32)&^0xfff
+	minor := dev&0xff | (dev>>12)&^0xff
+	if major == 0 {
+		return DeviceIO{}, false // no block device
+	}
+	f, err := os.Open("/proc/diskstats")
+	if err != nil {
+		return DeviceIO{}, false
+	}
+	defer f.Close()
+	return parseDiskstats(f, major, minor)
+}
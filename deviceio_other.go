@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package bench
+
+// readDeviceIO is only supported on Linux.
+func readDeviceIO(dir string) (DeviceIO, bool) {
+	return DeviceIO{}, false
+}
@@ -0,0 +1,42 @@
+package bench
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDiskstats(t *testing.T) {
+	stats := `   7       0 loop0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0
+ 254       0 vda 13014 4324 1571394 8517 7853349 231506 275247240 623898 0 197832 700106 178403 0 292812112 40531 1521931 27158
+ 254       1 vda1 12000 4000 1500000 8000 7000000 200000 270000000 600000 0 190000 690000
+`
+	d, ok := parseDiskstats(strings.NewReader(stats), 254, 1)
+	if !ok {
+		t.Fatal("device not found")
+	}
+	want := DeviceIO{
+		Device:     "vda1",
+		Reads:      12000,
+		ReadBytes:  1500000 * 512,
+		ReadTime:   8 * time.Second,
+		Writes:     7000000,
+		WriteBytes: 270000000 * 512,
+		WriteTime:  600 * time.Second,
+		IOTime:     190 * time.Second,
+		QueueTime:  690 * time.Second,
+	}
+	if d != want {
+		t.Errorf("wrong counters\ngot  %+v\nwant %+v", d, want)
+	}
+	if _, ok := parseDiskstats(strings.NewReader(stats), 254, 2); ok {
+		t.Error("found missing device")
+	}
+
+	end := want
+	end.WriteBytes += 4096
+	end.QueueTime += time.Millisecond
+	if diff := end.sub(want); diff.WriteBytes != 4096 || diff.QueueTime != time.Millisecond || diff.Reads != 0 || diff.Device != "vda1" {
+		t.Errorf("wrong difference %+v", diff)
+	}
+}
@@ -0,0 +1,233 @@
+package bench
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// diskSampleInterval is the time between disk usage samples after the run.
+// During the run, the disk is sampled whenever progress is reported.
+const diskSampleInterval = time.Second
+
+// DiskUsage is a sample of the database size on disk.
+type DiskUsage struct {
+	Time time.Duration `json:"time"` // since the start of the measured phase
+	Size uint64        `json:"size"` // bytes
+
+	// Processed is the number of bytes written when the sample was taken.
+	Processed uint64 `json:"processed,omitempty"`
+
+	// PutBytes is the number of key and value bytes written when the sample
+	// was taken, like RunResult.PutBytes.
+	PutBytes uint64 `json:"putbytes,omitempty"`
+
+	// Files is the number of files in the database directory, zero if unknown.
+	Files int `json:"files,omitempty"`
+
+	// Estimate is the size of the whole key range estimated by the database,
+	// zero if unknown.
+	Estimate uint64 `json:"estimate,omitempty"`
+
+	// Device is the I/O of the database device since the start of the
+	// measured phase, nil if unknown.
+	Device *DeviceIO `json:"device,omitempty"`
+}
+
+// SizeFunc sets the function used to measure the database size on disk.
+func (env *WriteEnv) SizeFunc(fn func() (uint64, error)) {
+	env.sizeFn = fn
+}
+
+// EstimateFunc sets the function returning the size estimate of the database,
+// which is sampled along with the size on disk.
+func (env *WriteEnv) EstimateFunc(fn func() (uint64, error)) {
+	env.estimateFn = fn
+}
+
+// FileCountFunc sets the function returning the number of database files,
+// which is sampled along with the size on disk.
+func (env *WriteEnv) FileCountFunc(fn func() (int, error)) {
+	env.filesFn = fn
+}
+
+// DirSize returns the disk space used by all files in a directory tree, like du.
+// Engines preallocating sparse files would appear much larger otherwise.
+func DirSize(dir string) (uint64, error) {
+	var size uint64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			// Database files disappear during compaction.
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += allocatedSize(info)
+		}
+		return nil
+	})
+	return size, err
+}
+
+// DirFileCount returns the number of regular files in a directory tree.
+func DirFileCount(dir string) (int, error) {
+	var n int
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// measureDiskSize adds the database size on disk at the end of the run to the
+// result. The database is still open, so the size includes data which is only
+// in the write-ahead log.
+func (env *WriteEnv) measureDiskSize(result *RunResult) {
+	if env.sizeFn == nil {
+		return
+	}
+	size, err := env.sizeFn()
+	if err != nil {
+		log.Printf("can't measure database size: %v", err)
+		return
+	}
+	result.DiskSize = size
+}
+
+// SpaceAmplification returns the ratio of the database size on disk to the key
+// and value bytes written at the time of the sample, the same measure as
+// RunResult.SpaceAmplification. It returns zero if either is unknown.
+func (d DiskUsage) SpaceAmplification() float64 {
+	if d.PutBytes == 0 || d.Size == 0 {
+		return 0
+	}
+	return float64(d.Size) / float64(d.PutBytes)
+}
+
+// SpaceAmplification returns the ratio of the database size on disk to the key
+// and value bytes written. It returns zero if either is unknown.
+func (r *RunResult) SpaceAmplification() float64 {
+	if r.PutBytes == 0 || r.DiskSize == 0 {
+		return 0
+	}
+	return float64(r.DiskSize) / float64(r.PutBytes)
+}
+
+// sampleDisk logs the current database size.
+func (env *WriteEnv) sampleDisk() uint64 {
+	if env.sizeFn == nil {
+		return 0
+	}
+	size, err := env.sizeFn()
+	if err != nil {
+		log.Printf("can't measure database size: %v", err)
+		return 0
+	}
+	var estimate uint64
+	if env.estimateFn != nil {
+		if estimate, err = env.estimateFn(); err != nil {
+			log.Printf("can't estimate database size: %v", err)
+		}
+	}
+	var files int
+	if env.filesFn != nil {
+		if files, err = env.filesFn(); err != nil {
+			log.Printf("can't count database files: %v", err)
+		}
+	}
+	device := env.device.since()
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	d := DiskUsage{
+		Time:      mononow() - env.startTime,
+		Size:      size,
+		Processed: env.written,
+		PutBytes:  atomic.LoadUint64(&env.putBytes),
+		Files:     files,
+		Estimate:  estimate,
+		Device:    device,
+	}
+	writeDiskUsage(env.out, d)
+	env.cfg.Live.disk(d)
+	env.detectors.observe(Metric{Time: d.Time, Offset: env.written, Disk: &d})
+	return size
+}
+
+// startDiskSampler launches a goroutine sampling the database size while the
+// run is going on. This is enabled by cfg.SampleDisk.
+func (env *WriteEnv) startDiskSampler() {
+	if !env.cfg.SampleDisk || env.sizeFn == nil {
+		return
+	}
+	env.diskStop = make(chan struct{})
+	env.diskDone = make(chan struct{})
+	env.diskTick = make(chan struct{}, 1)
+	go func() {
+		defer close(env.diskDone)
+		env.sampleDisk()
+		for {
+			select {
+			case <-env.diskTick:
+				env.sampleDisk()
+			case <-env.diskStop:
+				return
+			}
+		}
+	}()
+}
+
+// triggerDiskSample requests a disk sample from the sampler. Requests are
+// dropped while a sample is being taken, so slow directory walks don't hold up
+// the writer. It is called with env.mu held.
+func (env *WriteEnv) triggerDiskSample() {
+	select {
+	case env.diskTick <- struct{}{}:
+	default:
+	}
+}
+
+// stopDiskSampler ends sampling and watches the database size after the run.
+func (env *WriteEnv) stopDiskSampler() {
+	if env.diskStop == nil {
+		return
+	}
+	close(env.diskStop)
+	<-env.diskDone
+	env.mu.Lock()
+	env.diskStop, env.diskTick = nil, nil
+	env.mu.Unlock()
+	env.sampleDisk()
+	env.watchDisk()
+}
+
+// watchDisk samples the database size for cfg.DiskWatch, then compacts the
+// whole database and takes a final sample.
+func (env *WriteEnv) watchDisk() {
+	if env.sizeFn == nil {
+		return
+	}
+	for end := mononow() + env.cfg.DiskWatch; mononow() < end; {
+		time.Sleep(diskSampleInterval)
+		env.sampleDisk()
+	}
+	if env.compactFn == nil {
+		return
+	}
+	begin := time.Now()
+	if err := env.compactFn(nil, nil); err != nil {
+		log.Printf("compaction failed: %v", err)
+		return
+	}
+	size := env.sampleDisk()
+	log.Printf("compacted database to %d bytes in %v", size, time.Since(begin))
+}
@@ -0,0 +1,92 @@
+package bench
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSampleDiskEstimate(t *testing.T) {
+	var (
+		out bytes.Buffer
+		cfg = WriteConfig{Size: 1 << 20, KeySize: 8, DataSize: 100, SampleDisk: true}
+		env = NewWriteEnv(&out, cfg)
+	)
+	env.SizeFunc(func() (uint64, error) { return 2000, nil })
+	env.EstimateFunc(func() (uint64, error) { return 1500, nil })
+	err := env.Run(func(key, value string, lastCall bool) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples := testReport(t, &out).Disk
+	if len(samples) == 0 {
+		t.Fatal("no disk samples")
+	}
+	for _, d := range samples {
+		if d.Size != 2000 || d.Estimate != 1500 {
+			t.Errorf("wrong sample %+v", d)
+		}
+	}
+}
+
+func TestResultDiskSize(t *testing.T) {
+	var (
+		out bytes.Buffer
+		cfg = WriteConfig{Size: 1000, KeySize: 8, DataSize: 100}
+		env = NewWriteEnv(&out, cfg)
+	)
+	env.SizeFunc(func() (uint64, error) { return 2160, nil })
+	err := env.Run(func(key, value string, lastCall bool) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := lastResult(t, &out)
+	if result.PutBytes != 1080 || result.DiskSize != 2160 {
+		t.Errorf("wrong result: %d put bytes, disk size %d", result.PutBytes, result.DiskSize)
+	}
+	if amp := result.SpaceAmplification(); amp != 2 {
+		t.Errorf("wrong space amplification %v", amp)
+	}
+}
+
+func TestSampleDiskProgress(t *testing.T) {
+	var (
+		out bytes.Buffer
+		cfg = WriteConfig{Size: 4 << 20, KeySize: 8, DataSize: 128, SampleDisk: true}
+		env = NewWriteEnv(&out, cfg)
+	)
+	env.SizeFunc(func() (uint64, error) { return 8 << 20, nil })
+	env.FileCountFunc(func() (int, error) { return 3, nil })
+	err := env.Run(func(key, value string, lastCall bool) error {
+		env.Progress(len(value))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples := testReport(t, &out).Disk
+	if len(samples) < 2 {
+		t.Fatalf("got %d disk samples, want at least 2", len(samples))
+	}
+	for _, d := range samples {
+		if d.Files != 3 {
+			t.Errorf("wrong file count in sample %+v", d)
+		}
+	}
+	last := samples[len(samples)-1]
+	if last.Processed != cfg.Size {
+		t.Errorf("last sample has %d bytes processed, want %d", last.Processed, cfg.Size)
+	}
+	if last.PutBytes != cfg.Size/cfg.DataSize*(cfg.KeySize+cfg.DataSize) {
+		t.Errorf("last sample has %d key and value bytes, want %d", last.PutBytes, cfg.Size/cfg.DataSize*(cfg.KeySize+cfg.DataSize))
+	}
+	if amp, want := last.SpaceAmplification(), float64(8<<20)/float64(last.PutBytes); amp != want {
+		t.Errorf("wrong space amplification %v, want %v", amp, want)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package bench
+
+import "os"
+
+// allocatedSize returns the size of a file. Allocated blocks are only known on
+// Unix systems.
+func allocatedSize(info os.FileInfo) uint64 {
+	return uint64(info.Size())
+}
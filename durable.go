@@ -0,0 +1,41 @@
+package bench
+
+import "time"
+
+// LatencyStats summarizes operation latencies.
+type LatencyStats struct {
+	Count uint64        `json:"count"`
+	Mean  time.Duration `json:"mean"`
+	Max   time.Duration `json:"max"`
+}
+
+// latencyTally accumulates latencies for LatencyStats.
+type latencyTally struct {
+	count uint64
+	sum   time.Duration
+	max   time.Duration
+}
+
+func (t *latencyTally) add(d time.Duration) {
+	t.count++
+	t.sum += d
+	if d > t.max {
+		t.max = d
+	}
+}
+
+func (t *latencyTally) stats() *LatencyStats {
+	if t.count == 0 {
+		return nil
+	}
+	return &LatencyStats{Count: t.count, Mean: t.sum / time.Duration(t.count), Max: t.max}
+}
+
+// DurableWrite records the time from the start of a write until it was
+// acknowledged as durable, i.e. synced to disk. Tests comparing sync
+// strategies call it for every write.
+func (env *WriteEnv) DurableWrite(latency time.Duration) {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	env.durable.add(latency)
+}
@@ -0,0 +1,31 @@
+package bench
+
+// Encoder transforms generated keys and values into domain-specific payloads,
+// e.g. RLP-encoded structures, so embedded users can benchmark the encodings of
+// their application. The functions receive the index of the pair in the run and
+// the generated random bytes, which they may modify in place or replace. The
+// returned slice must remain valid until the next call. A nil function keeps
+// the generated bytes.
+//
+// Encoders must be deterministic, verification regenerates the pairs. The
+// amount of data written by a run is still measured in generated value bytes.
+type Encoder struct {
+	Name  string                               `json:"name"`
+	Key   func(n uint64, random []byte) []byte `json:"-"`
+	Value func(n uint64, random []byte) []byte `json:"-"`
+}
+
+// encode applies the encoder to a generated pair. It can be called on a nil
+// encoder, which returns the pair unchanged.
+func (e *Encoder) encode(n uint64, key, value []byte) ([]byte, []byte) {
+	if e == nil {
+		return key, value
+	}
+	if e.Key != nil {
+		key = e.Key(n, key)
+	}
+	if e.Value != nil {
+		value = e.Value(n, value)
+	}
+	return key, value
+}
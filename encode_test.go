@@ -0,0 +1,56 @@
+package bench
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEncoder(t *testing.T) {
+	enc := &Encoder{
+		Name: "account",
+		Key: func(n uint64, random []byte) []byte {
+			return []byte(fmt.Sprintf("acct-%08d", n))
+		},
+		Value: func(n uint64, random []byte) []byte {
+			// A list header followed by the random payload.
+			return append([]byte{0xc0 + byte(len(random))}, random...)
+		},
+	}
+	for _, generators := range []int{0, 2} {
+		var (
+			out   bytes.Buffer
+			store = make(map[string]string)
+			cfg   = WriteConfig{Size: 5000, KeySize: 8, DataSize: 20, Generators: generators, Ordered: true, Verify: true, Encoder: enc}
+			env   = NewWriteEnv(&out, cfg)
+		)
+		env.GetFunc(func(key []byte) ([]byte, error) {
+			v, ok := store[string(key)]
+			if !ok {
+				return nil, ErrNotFound
+			}
+			return []byte(v), nil
+		})
+		err := env.Run(func(key, value string, lastCall bool) error {
+			if !strings.HasPrefix(key, "acct-") || len(value) != 21 || value[0] != 0xc0+20 {
+				return fmt.Errorf("pair not encoded: %q %x", key, value)
+			}
+			store[key] = value
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("generators=%d: %v", generators, err)
+		}
+		if len(store) != 250 {
+			t.Errorf("generators=%d: stored %d keys, want 250", generators, len(store))
+		}
+		result := lastResult(t, bytes.NewReader(out.Bytes()))
+		if result.Verified != 250 || result.Mismatches+result.Missing > 0 {
+			t.Errorf("generators=%d: wrong verification result %+v", generators, result)
+		}
+		if !strings.Contains(out.String(), `"encoder":"account"`) {
+			t.Errorf("generators=%d: encoder not in header", generators)
+		}
+	}
+}
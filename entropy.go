@@ -0,0 +1,119 @@
+package bench
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+	"math/rand"
+	"sort"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// DefaultEntropy is the entropy source used when none is configured.
+const DefaultEntropy = "math"
+
+// entropySources contains the available random sources for key/value generation.
+var entropySources = map[string]func(seed int64) io.Reader{
+	"math":   func(seed int64) io.Reader { return rand.New(rand.NewSource(seed)) },
+	"pcg":    func(seed int64) io.Reader { return &uint64Reader{next: newPCG(uint64(seed)).next} },
+	"crypto": func(seed int64) io.Reader { return crand.Reader },
+	"xxhash": func(seed int64) io.Reader { return &uint64Reader{next: (&xxhashCounter{seed: uint64(seed)}).next} },
+}
+
+// NewEntropy creates the named random source. Sources other than "crypto" are
+// deterministic for a given seed.
+func NewEntropy(name string, seed int64) (io.Reader, error) {
+	if name == "" {
+		name = DefaultEntropy
+	}
+	fn := entropySources[name]
+	if fn == nil {
+		return nil, fmt.Errorf("unknown entropy source %q", name)
+	}
+	return fn(seed), nil
+}
+
+// Reproducible reports whether the named entropy source produces the same
+// sequence for the same seed. Only then can keys and values be regenerated
+// after the run.
+func Reproducible(name string) bool {
+	return name != "crypto"
+}
+
+// EntropyNames returns the names of all available entropy sources.
+func EntropyNames() (n []string) {
+	for name := range entropySources {
+		n = append(n, name)
+	}
+	sort.Strings(n)
+	return n
+}
+
+// pcg is a PCG-XSL-RR 128/64 generator.
+type pcg struct {
+	hi, lo uint64
+}
+
+const (
+	pcgMulHi = 2549297995355413924
+	pcgMulLo = 4865540595714422341
+	pcgIncHi = 6364136223846793005
+	pcgIncLo = 1442695040888963407
+)
+
+func newPCG(seed uint64) *pcg {
+	return &pcg{hi: seed, lo: seed ^ 0x9e3779b97f4a7c15}
+}
+
+func (p *pcg) next() uint64 {
+	// state = state * mul + inc
+	hi, lo := bits.Mul64(p.lo, pcgMulLo)
+	hi += p.hi*pcgMulLo + p.lo*pcgMulHi
+	lo, c := bits.Add64(lo, pcgIncLo, 0)
+	hi, _ = bits.Add64(hi, pcgIncHi, c)
+	p.lo, p.hi = lo, hi
+	return bits.RotateLeft64(hi^lo, -int(hi>>58))
+}
+
+// xxhashCounter produces bytes by hashing an incrementing counter.
+type xxhashCounter struct {
+	seed, n uint64
+	buf     [16]byte
+}
+
+func (x *xxhashCounter) next() uint64 {
+	binary.LittleEndian.PutUint64(x.buf[:8], x.seed)
+	binary.LittleEndian.PutUint64(x.buf[8:], x.n)
+	x.n++
+	return xxhash.Sum64(x.buf[:])
+}
+
+// uint64Reader turns a uint64 generator into a byte stream. Unused bytes of a
+// generated value are kept for the next Read, so the output does not depend on
+// how reads are split.
+type uint64Reader struct {
+	next func() uint64
+	buf  [8]byte
+	pos  int
+}
+
+func (r *uint64Reader) Read(b []byte) (int, error) {
+	n := len(b)
+	if r.pos > 0 {
+		c := copy(b, r.buf[r.pos:])
+		r.pos = (r.pos + c) % 8
+		b = b[c:]
+	}
+	for len(b) >= 8 {
+		binary.LittleEndian.PutUint64(b, r.next())
+		b = b[8:]
+	}
+	if len(b) > 0 {
+		binary.LittleEndian.PutUint64(r.buf[:], r.next())
+		r.pos = copy(b, r.buf[:])
+	}
+	return n, nil
+}
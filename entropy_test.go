@@ -0,0 +1,54 @@
+package bench
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEntropyDeterministic(t *testing.T) {
+	for _, name := range EntropyNames() {
+		if !Reproducible(name) {
+			continue
+		}
+		a, _ := NewEntropy(name, 1)
+		b, _ := NewEntropy(name, 1)
+		bufa, bufb := make([]byte, 37), make([]byte, 37)
+		a.Read(bufa)
+		b.Read(bufb)
+		if !bytes.Equal(bufa, bufb) {
+			t.Errorf("%s: output differs for same seed", name)
+		}
+	}
+}
+
+func TestEntropySplitReads(t *testing.T) {
+	for _, name := range EntropyNames() {
+		if !Reproducible(name) {
+			continue
+		}
+		a, _ := NewEntropy(name, 1)
+		b, _ := NewEntropy(name, 1)
+		whole := make([]byte, 64)
+		a.Read(whole)
+		split := make([]byte, 64)
+		for _, r := range [][2]int{{0, 3}, {3, 13}, {13, 14}, {14, 40}, {40, 64}} {
+			b.Read(split[r[0]:r[1]])
+		}
+		if !bytes.Equal(whole, split) {
+			t.Errorf("%s: split reads produce different output", name)
+		}
+	}
+}
+
+func BenchmarkEntropy(b *testing.B) {
+	for _, name := range EntropyNames() {
+		b.Run(name, func(b *testing.B) {
+			src, _ := NewEntropy(name, 1)
+			buf := make([]byte, 100)
+			b.SetBytes(int64(len(buf)))
+			for i := 0; i < b.N; i++ {
+				src.Read(buf)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package bench
+
+import "strings"
+
+// ParseTestList splits a comma-separated list of test names and performs brace
+// expansion on each element, e.g. "batch-{1mb,5mb}{,-nosync}" expands to
+// batch-1mb, batch-1mb-nosync, batch-5mb, batch-5mb-nosync.
+func ParseTestList(s string) []string {
+	var (
+		out   []string
+		depth int
+		start int
+	)
+	add := func(item string) {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, ExpandBraces(item)...)
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				add(s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	add(s[start:])
+	return out
+}
+
+// ExpandBraces performs shell-style brace expansion. Unbalanced braces are
+// left as is.
+func ExpandBraces(s string) []string {
+	open := strings.IndexByte(s, '{')
+	if open < 0 {
+		return []string{s}
+	}
+	var (
+		alts  []string
+		depth int
+		start = open + 1
+		close = -1
+	)
+	for i := open; i < len(s) && close < 0; i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				alts = append(alts, s[start:i])
+				close = i
+			}
+		case ',':
+			if depth == 1 {
+				alts = append(alts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if close < 0 {
+		return []string{s}
+	}
+	var out []string
+	for _, a := range alts {
+		out = append(out, ExpandBraces(s[:open]+a+s[close+1:])...)
+	}
+	return out
+}
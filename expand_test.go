@@ -0,0 +1,34 @@
+package bench
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTestList(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"nobatch", []string{"nobatch"}},
+		{"nobatch, batch-1mb", []string{"nobatch", "batch-1mb"}},
+		{"batch-{100kb,1mb}", []string{"batch-100kb", "batch-1mb"}},
+		{
+			"batch-{100kb,1mb,5mb}{,-nosync}",
+			[]string{
+				"batch-100kb", "batch-100kb-nosync",
+				"batch-1mb", "batch-1mb-nosync",
+				"batch-5mb", "batch-5mb-nosync",
+			},
+		},
+		{"a{b,c{d,e}},f", []string{"ab", "acd", "ace", "f"}},
+		{"a{b", []string{"a{b"}},
+		{"", nil},
+	}
+	for _, test := range tests {
+		got := ParseTestList(test.in)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %q, want %q", test.in, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package bench
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// familyParamRE matches a test name component carrying a numeric parameter,
+// like "100" in batch-100 or "key32b" in sweeps over key sizes.
+var familyParamRE = regexp.MustCompile(`(?i)^([a-z]*?)([0-9]+(?:[kmg]?b)?)$`)
+
+// Family is a group of reports of the same parameterized test, e.g. all runs
+// of batch-<size> with the same suffix and tags.
+type Family struct {
+	Pattern string // test name with the parameter replaced by *
+	Tags    Tags
+	Points  []FamilyPoint // sorted by parameter
+}
+
+// FamilyPoint is the aggregate throughput of all runs with one parameter value.
+type FamilyPoint struct {
+	Param uint64
+	Runs  int
+	BPS   float64 // mean overall throughput of the runs
+}
+
+// SplitFamily splits a test name into a pattern and the numeric parameter. The
+// parameter is a dash-separated component ending in a number or size. If there
+// are several, the one added by a sweep is taken, as identified by the tags of
+// the run. Otherwise, it is the first.
+func SplitFamily(name string, tags Tags) (pattern string, param uint64, ok bool) {
+	pattern, param, _, _, ok = splitFamily(name, tags)
+	return pattern, param, ok
+}
+
+// splitFamily is SplitFamily, but also returns the parameter as written in the
+// name and the letters preceding it in its component, like "cache" in cache8mb.
+func splitFamily(name string, tags Tags) (pattern string, param uint64, text, prefix string, ok bool) {
+	parts := strings.Split(name, "-")
+	found := -1
+	for i, p := range parts {
+		m := familyParamRE.FindStringSubmatch(p)
+		if m == nil {
+			continue
+		}
+		v, err := ParseSize(m[2])
+		if err != nil {
+			continue
+		}
+		sweep := sweepTag(tags, m[2], m[1]) != ""
+		if found < 0 || sweep {
+			found, param, text, prefix = i, v, m[2], m[1]
+		}
+		if sweep {
+			break
+		}
+	}
+	if found < 0 {
+		return "", 0, "", "", false
+	}
+	parts[found] = prefix + "*"
+	return strings.Join(parts, "-"), param, text, prefix, true
+}
+
+// sweepTag returns the key of the tag holding a parameter added by a sweep, or
+// the empty string if there is none. Sweeps name runs after their tag key, e.g.
+// cache8mb for blockcache=8mb, so the tag is the one with the parameter as value
+// whose key contains the prefix. Parameters without a prefix don't come from
+// sweeps.
+func sweepTag(tags Tags, text, prefix string) string {
+	if prefix == "" {
+		return ""
+	}
+	prefix = strings.ToLower(prefix)
+	for k, v := range tags {
+		if v == text && strings.Contains(strings.ToLower(k), prefix) {
+			return k
+		}
+	}
+	return ""
+}
+
+// withoutParam removes the tag holding the family parameter, which sweeps add to
+// every run. Runs of one sweep would end up in different families otherwise.
+func withoutParam(tags Tags, text, prefix string) Tags {
+	drop := sweepTag(tags, text, prefix)
+	var out Tags
+	for k, v := range tags {
+		if drop != "" && k == drop {
+			continue
+		}
+		if out == nil {
+			out = make(Tags)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// Families groups reports by test family. Only families with at least two
+// distinct parameter values are returned.
+func Families(reports []Report) []Family {
+	type run struct {
+		param uint64
+		bps   float64
+	}
+	var (
+		runs = make(map[string][]run)
+		fams = make(map[string]*Family)
+		keys []string
+	)
+	for _, r := range reports {
+		name := r.Name
+		if r.Header != nil && r.Header.Test != "" {
+			name = r.Header.Test
+		}
+		pattern, param, text, prefix, ok := splitFamily(name, r.Tags)
+		if !ok || len(r.Events) == 0 {
+			continue
+		}
+		tags := withoutParam(r.Tags, text, prefix)
+		key := pattern + " " + tags.String()
+		if fams[key] == nil {
+			fams[key] = &Family{Pattern: pattern, Tags: tags}
+			keys = append(keys, key)
+		}
+		runs[key] = append(runs[key], run{param, Summarize(r).BPS()})
+	}
+	sort.Strings(keys)
+
+	var result []Family
+	for _, key := range keys {
+		f := fams[key]
+		byParam := make(map[uint64]*FamilyPoint)
+		for _, r := range runs[key] {
+			p := byParam[r.param]
+			if p == nil {
+				p = &FamilyPoint{Param: r.param}
+				byParam[r.param] = p
+			}
+			p.Runs++
+			p.BPS += r.bps
+		}
+		if len(byParam) < 2 {
+			continue
+		}
+		for _, p := range byParam {
+			p.BPS /= float64(p.Runs)
+			f.Points = append(f.Points, *p)
+		}
+		sort.Slice(f.Points, func(i, j int) bool { return f.Points[i].Param < f.Points[j].Param })
+		result = append(result, *f)
+	}
+	return result
+}
+
+// Label returns the pattern and tags of the family.
+func (f Family) Label() string {
+	if len(f.Tags) == 0 {
+		return f.Pattern
+	}
+	return f.Pattern + " (" + f.Tags.String() + ")"
+}
@@ -0,0 +1,81 @@
+package bench
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSplitFamily(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    Tags
+		pattern string
+		param   uint64
+		ok      bool
+	}{
+		{"batch-100", nil, "batch-*", 100, true},
+		{"batch-1kb-nosync", nil, "batch-*-nosync", 1024, true},
+		{"concurrent-8-nomerge", nil, "concurrent-*-nomerge", 8, true},
+		{"nobatch-key16b", Tags{"keysize": "16b"}, "nobatch-key*", 16, true},
+		{"batch-100kb-key16b", Tags{"keysize": "16b"}, "batch-100kb-key*", 16, true},
+		{"batch-100kb-writebuffer4mb", Tags{"writebuffer": "4mb"}, "batch-100kb-writebuffer*", 4 << 20, true},
+		{"batch-100kb-key16b", nil, "batch-*-key16b", 100 * 1024, true},
+		{"ycsb-a", nil, "", 0, false},
+		{"nobatch", nil, "", 0, false},
+	}
+	for _, test := range tests {
+		pattern, param, ok := SplitFamily(test.name, test.tags)
+		if pattern != test.pattern || param != test.param || ok != test.ok {
+			t.Errorf("%s: got (%q, %d, %v), want (%q, %d, %v)", test.name,
+				pattern, param, ok, test.pattern, test.param, test.ok)
+		}
+	}
+}
+
+func TestFamilies(t *testing.T) {
+	report := func(name string, bytesPerSecond uint64) Report {
+		ev := Progress{Processed: bytesPerSecond, Delta: bytesPerSecond, Duration: time.Second}
+		return Report{Name: name, Events: []Progress{ev}}
+	}
+	reports := []Report{
+		report("batch-1000", 300),
+		report("batch-100", 100),
+		report("batch-100", 200),
+		report("concurrent-8", 10), // only one parameter value
+		report("nobatch", 50),
+	}
+	want := []Family{{
+		Pattern: "batch-*",
+		Points: []FamilyPoint{
+			{Param: 100, Runs: 2, BPS: 150},
+			{Param: 1000, Runs: 1, BPS: 300},
+		},
+	}}
+	if got := Families(reports); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong families:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestFamiliesSweepTags(t *testing.T) {
+	report := func(name, cache string, bytesPerSecond uint64) Report {
+		ev := Progress{Processed: bytesPerSecond, Delta: bytesPerSecond, Duration: time.Second}
+		return Report{Name: name, Tags: Tags{"blockcache": cache, "fs": "ext4", "limit": "8mb"}, Events: []Progress{ev}}
+	}
+	reports := []Report{
+		report("random-read-cache8mb", "8mb", 100),
+		report("random-read-cache64mb", "64mb", 200),
+	}
+	// The limit tag has the value of a parameter, but doesn't belong to the sweep.
+	want := []Family{{
+		Pattern: "random-read-cache*",
+		Tags:    Tags{"fs": "ext4", "limit": "8mb"},
+		Points: []FamilyPoint{
+			{Param: 8 * 1024 * 1024, Runs: 1, BPS: 100},
+			{Param: 64 * 1024 * 1024, Runs: 1, BPS: 200},
+		},
+	}}
+	if got := Families(reports); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong families:\ngot  %+v\nwant %+v", got, want)
+	}
+}
@@ -0,0 +1,10 @@
+package bench
+
+import "time"
+
+// GCStats summarizes the garbage collections during a run.
+type GCStats struct {
+	Cycles     uint64        `json:"cycles"`
+	PauseTotal time.Duration `json:"pausetotal"` // stop-the-world time
+	MaxPause   time.Duration `json:"maxpause"`
+}
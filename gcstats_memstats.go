@@ -0,0 +1,40 @@
+//go:build !go1.17
+// +build !go1.17
+
+package bench
+
+import (
+	"runtime"
+	"time"
+)
+
+// gcSnapshot is the state of the GC counters at some time.
+type gcSnapshot struct {
+	ms runtime.MemStats
+}
+
+func readGC() gcSnapshot {
+	var s gcSnapshot
+	runtime.ReadMemStats(&s.ms)
+	return s
+}
+
+// since returns the GC statistics between start and s. The maximum pause only
+// covers the last 256 collections.
+func (s gcSnapshot) since(start gcSnapshot) *GCStats {
+	st := &GCStats{
+		Cycles:     uint64(s.ms.NumGC - start.ms.NumGC),
+		PauseTotal: time.Duration(s.ms.PauseTotalNs - start.ms.PauseTotalNs),
+	}
+	n := st.Cycles
+	if n > uint64(len(s.ms.PauseNs)) {
+		n = uint64(len(s.ms.PauseNs))
+	}
+	for i := uint64(0); i < n; i++ {
+		p := time.Duration(s.ms.PauseNs[(uint64(s.ms.NumGC)-1-i)%uint64(len(s.ms.PauseNs))])
+		if p > st.MaxPause {
+			st.MaxPause = p
+		}
+	}
+	return st
+}
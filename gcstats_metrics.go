@@ -0,0 +1,66 @@
+//go:build go1.17
+// +build go1.17
+
+package bench
+
+import (
+	"math"
+	"runtime/metrics"
+	"time"
+)
+
+// gcPauseMetric is the histogram of GC pauses. Go 1.22 renamed it.
+var gcPauseMetric = func() string {
+	for _, d := range metrics.All() {
+		if d.Name == "/sched/pauses/total/gc:seconds" {
+			return d.Name
+		}
+	}
+	return "/gc/pauses:seconds"
+}()
+
+// gcSnapshot is the state of the GC counters at some time.
+type gcSnapshot struct {
+	cycles  uint64
+	counts  []uint64  // pause histogram
+	buckets []float64 // bucket boundaries in seconds
+}
+
+func readGC() gcSnapshot {
+	samples := []metrics.Sample{{Name: "/gc/cycles/total:gc-cycles"}, {Name: gcPauseMetric}}
+	metrics.Read(samples)
+	var s gcSnapshot
+	if samples[0].Value.Kind() == metrics.KindUint64 {
+		s.cycles = samples[0].Value.Uint64()
+	}
+	if samples[1].Value.Kind() == metrics.KindFloat64Histogram {
+		h := samples[1].Value.Float64Histogram()
+		s.counts, s.buckets = h.Counts, h.Buckets
+	}
+	return s
+}
+
+// since returns the GC statistics between start and s. Pause times are taken
+// from the histogram, so they are only accurate to the bucket size.
+func (s gcSnapshot) since(start gcSnapshot) *GCStats {
+	st := &GCStats{Cycles: s.cycles - start.cycles}
+	for i, n := range s.counts {
+		if i < len(start.counts) {
+			n -= start.counts[i]
+		}
+		if n == 0 {
+			continue
+		}
+		lo, hi := s.buckets[i], s.buckets[i+1]
+		mid := (lo + hi) / 2
+		switch {
+		case math.IsInf(lo, -1):
+			mid = hi
+		case math.IsInf(hi, 1):
+			mid, hi = lo, lo
+		}
+		st.PauseTotal += time.Duration(float64(n) * mid * float64(time.Second))
+		st.MaxPause = time.Duration(hi * float64(time.Second))
+	}
+	return st
+}
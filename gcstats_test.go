@@ -0,0 +1,19 @@
+package bench
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestGCStats(t *testing.T) {
+	start := readGC()
+	runtime.GC()
+	runtime.GC()
+	st := readGC().since(start)
+	if st.Cycles < 2 {
+		t.Errorf("got %d GC cycles, want at least 2", st.Cycles)
+	}
+	if st.PauseTotal <= 0 || st.MaxPause <= 0 {
+		t.Errorf("wrong pauses: total %v, max %v", st.PauseTotal, st.MaxPause)
+	}
+}
@@ -0,0 +1,165 @@
+package bench
+
+import (
+	"io"
+	"runtime"
+	"sync"
+)
+
+// genChunkOps is the number of key/value pairs generated per chunk by the
+// generator pool.
+const genChunkOps = 1024
+
+// genChunk is a block of generated keys and values. Chunk i is always generated
+// from an entropy source seeded with seed+i, so the output of the pool does not
+// depend on the number of workers when chunks are delivered in order.
+type genChunk struct {
+	index uint64
+	n     int
+	data  []byte
+}
+
+// genConfig is the configuration of the generator pool.
+type genConfig struct {
+	entropy            string
+	seed               int64
+	keySize, valueSize int
+	total              uint64 // total number of pairs
+	workers            int    // negative means GOMAXPROCS
+	ordered            bool
+	unique             bool // put a unique counter into every key
+}
+
+// generator produces keys and values on multiple goroutines.
+type generator struct {
+	genConfig
+	chunks uint64
+
+	outs    []chan *genChunk // one per worker when ordered, shared otherwise
+	quit    chan struct{}
+	wg      sync.WaitGroup
+	free    sync.Pool
+	errOnce sync.Once
+	err     error
+
+	// consumer state
+	cur     *genChunk
+	pos     int
+	nextOut int
+}
+
+// newGenerator starts a pool of workers generating key/value pairs.
+func newGenerator(cfg genConfig) (*generator, error) {
+	// Check entropy source before starting.
+	if _, err := NewEntropy(cfg.entropy, cfg.seed); err != nil {
+		return nil, err
+	}
+	workers, ordered := cfg.workers, cfg.ordered
+	if workers < 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers == 0 {
+		workers = 1
+	}
+	g := &generator{
+		genConfig: cfg,
+		chunks:    (cfg.total + genChunkOps - 1) / genChunkOps,
+		quit:      make(chan struct{}),
+	}
+	if ordered {
+		g.outs = make([]chan *genChunk, workers)
+		for i := range g.outs {
+			g.outs[i] = make(chan *genChunk, 2)
+		}
+	} else {
+		g.outs = []chan *genChunk{make(chan *genChunk, 2*workers)}
+	}
+	var active sync.WaitGroup
+	active.Add(workers)
+	for i := 0; i < workers; i++ {
+		out := g.outs[0]
+		if ordered {
+			out = g.outs[i]
+		}
+		g.wg.Add(1)
+		go g.work(uint64(i), uint64(workers), out, &active, ordered)
+	}
+	if !ordered {
+		go func() {
+			active.Wait()
+			close(g.outs[0])
+		}()
+	}
+	return g, nil
+}
+
+func (g *generator) work(first, step uint64, out chan *genChunk, active *sync.WaitGroup, ordered bool) {
+	defer g.wg.Done()
+	defer active.Done()
+	if ordered {
+		defer close(out)
+	}
+	pairSize := g.keySize + g.valueSize
+	for index := first; index < g.chunks; index += step {
+		n := genChunkOps
+		if rem := g.total - index*genChunkOps; rem < genChunkOps {
+			n = int(rem)
+		}
+		c, _ := g.free.Get().(*genChunk)
+		if c == nil || cap(c.data) < n*pairSize {
+			c = &genChunk{data: make([]byte, genChunkOps*pairSize)}
+		}
+		c.index, c.n, c.data = index, n, c.data[:n*pairSize]
+		src, _ := NewEntropy(g.entropy, g.seed+int64(index))
+		if _, err := io.ReadFull(src, c.data); err != nil {
+			g.errOnce.Do(func() { g.err = err })
+			return
+		}
+		if g.unique {
+			// Chunks are disjoint ranges of the counter.
+			for i := 0; i < n; i++ {
+				putUniqueKey(c.data[i*pairSize:], index*genChunkOps+uint64(i))
+			}
+		}
+		select {
+		case out <- c:
+		case <-g.quit:
+			return
+		}
+	}
+}
+
+// next returns the next key/value pair. The returned slices are only valid
+// until the next call.
+func (g *generator) next() (key, value []byte, ok bool) {
+	for g.cur == nil || g.pos == g.cur.n {
+		if g.cur != nil {
+			g.free.Put(g.cur)
+			g.cur = nil
+		}
+		c, ok := <-g.outs[g.nextOut]
+		if !ok {
+			return nil, nil, false
+		}
+		g.nextOut = (g.nextOut + 1) % len(g.outs)
+		g.cur, g.pos = c, 0
+	}
+	offset := g.pos * (g.keySize + g.valueSize)
+	g.pos++
+	key = g.cur.data[offset : offset+g.keySize]
+	value = g.cur.data[offset+g.keySize : offset+g.keySize+g.valueSize]
+	return key, value, true
+}
+
+// counter returns the index of the pair last returned by next in the generated
+// sequence.
+func (g *generator) counter() uint64 {
+	return g.cur.index*genChunkOps + uint64(g.pos-1)
+}
+
+// stop terminates all workers.
+func (g *generator) stop() error {
+	close(g.quit)
+	g.wg.Wait()
+	return g.err
+}
@@ -0,0 +1,55 @@
+package bench
+
+import (
+	"bytes"
+	"testing"
+)
+
+func collectGenerator(t *testing.T, workers int, ordered bool, total uint64) [][]byte {
+	g, err := newGenerator(genConfig{
+		entropy: "pcg", seed: 1, keySize: 8, valueSize: 16,
+		total: total, workers: workers, ordered: ordered,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out [][]byte
+	for {
+		k, v, ok := g.next()
+		if !ok {
+			break
+		}
+		out = append(out, append(copyBytes(k), v...))
+	}
+	if err := g.stop(); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestGeneratorOrdered(t *testing.T) {
+	const total = 5*genChunkOps + 17
+	want := collectGenerator(t, 1, true, total)
+	if len(want) != total {
+		t.Fatalf("got %d pairs, want %d", len(want), total)
+	}
+	for _, workers := range []int{2, 3, 8} {
+		got := collectGenerator(t, workers, true, total)
+		if len(got) != len(want) {
+			t.Fatalf("%d workers: got %d pairs, want %d", workers, len(got), len(want))
+		}
+		for i := range got {
+			if !bytes.Equal(got[i], want[i]) {
+				t.Fatalf("%d workers: pair %d differs", workers, i)
+			}
+		}
+	}
+}
+
+func TestGeneratorUnordered(t *testing.T) {
+	const total = 3*genChunkOps + 1
+	got := collectGenerator(t, 4, false, total)
+	if len(got) != total {
+		t.Fatalf("got %d pairs, want %d", len(got), total)
+	}
+}
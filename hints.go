@@ -0,0 +1,139 @@
+package bench
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Thresholds of the report hints.
+const (
+	hintMinTime      = 10 * time.Second // runs shorter than this don't reach a steady state
+	hintMinEvents    = 20               // minimum events for throughput trends
+	hintDropRatio    = 0.5              // throughput drops below this fraction of the start
+	hintStallShare   = 0.1              // share of the run spent in stalls
+	hintVariation    = 1.0              // coefficient of variation of event throughput
+	hintTimerShare   = 0.25             // share of the entry latency spent in the timer
+	hintSlowDurable  = 5 * time.Millisecond
+	hintWindowRatio  = 0.5 // window throughput below this fraction of the rest
+	hintEarlyPercent = 10  // percentage of events forming the start of the run
+)
+
+// Hints returns heuristic interpretations of a report. Each hint names a pattern
+// in the metrics, its usual cause and what to try. Hints are educated guesses,
+// not diagnoses.
+func Hints(r Report) []string {
+	var (
+		s     = Summarize(r)
+		hints []string
+	)
+	add := func(format string, args ...interface{}) {
+		hints = append(hints, fmt.Sprintf(format, args...))
+	}
+	writes := r.Result != nil && r.Result.Ops > 0
+
+	if r.Result != nil {
+		if r.Result.Error != "" {
+			add("run ended early after %s: all numbers describe a partial run", FormatSize(s.TotalSize))
+		}
+		if n := r.Result.Mismatches + r.Result.Missing; n > 0 {
+			add("%d values are wrong or missing: the database lost or corrupted writes", n)
+		}
+		if a := r.Result.Acks; a != nil && !a.OK() {
+			add("acknowledged writes don't match the generated ones: the test dropped or duplicated writes, or the database wasn't empty, so the results are invalid")
+		}
+	}
+	if s.Events > 0 && s.TotalTime < hintMinTime.Seconds() {
+		add("run took only %.1fs: too short for compaction to reach a steady state; increase -size", s.TotalTime)
+	}
+	if offset, before, after, ok := throughputDrop(r.Events); ok {
+		cause := "the working set likely outgrew the caches"
+		if writes {
+			cause = "likely compaction-bound; consider a larger write buffer"
+		}
+		add("throughput halves after ~%s (%.1f to %.1f mb/s): %s",
+			FormatSize(offset), before/1024/1024, after/1024/1024, cause)
+	}
+	if share := stallShare(r, s); share > hintStallShare {
+		add("writes stalled for %.0f%% of the run: compaction can't keep up with level 0; "+
+			"consider higher level-0 triggers or a larger write buffer", 100*share)
+	}
+	if s.Events >= hintMinEvents && s.MeanBPS > 0 && s.StdBPS/s.MeanBPS > hintVariation {
+		add("throughput varies strongly (deviation %.0f%% of the mean): look for pauses in the "+
+			"latency plot and compare several runs", 100*s.StdBPS/s.MeanBPS)
+	}
+	if l := s.EntryLatency(); s.TimerOverhead > 0 && l > 0 && float64(s.TimerOverhead) > hintTimerShare*float64(l) {
+		add("timer overhead is %.0f%% of the entry latency: the harness dominates; "+
+			"use larger batches or check with -checkharness", 100*float64(s.TimerOverhead)/float64(l))
+	}
+	if r.Result != nil && r.Result.Durable != nil && r.Result.Durable.Mean > hintSlowDurable {
+		add("durable writes take %v on average: syncs are slow on this disk; "+
+			"batching or group commit amortizes them", r.Result.Durable.Mean)
+	}
+	seen := make(map[string]bool)
+	for _, w := range r.Windows {
+		if seen[w.Name] {
+			continue
+		}
+		seen[w.Name] = true
+		if in, out := r.WindowBPS(w.Name); in > 0 && out > 0 && in < hintWindowRatio*out {
+			add("throughput drops by %.0f%% during %s windows: the background work competes "+
+				"with foreground operations", 100*(1-in/out), w.Name)
+		}
+	}
+	return hints
+}
+
+// throughputDrop finds the first slow event from which the throughput of the
+// rest of the run stays below hintDropRatio of the throughput at the start.
+func throughputDrop(events []Progress) (offset uint64, before, after float64, ok bool) {
+	if len(events) < hintMinEvents {
+		return 0, 0, 0, false
+	}
+	early := len(events) * hintEarlyPercent / 100
+	before = sumEvents(events[:early]).BPS()
+	// Go backwards to compute the throughput of every suffix.
+	var (
+		rest  Progress
+		found = -1
+	)
+	suffix := make([]float64, len(events))
+	for i := len(events) - 1; i >= early; i-- {
+		rest.Delta += events[i].Delta
+		rest.Duration += events[i].Duration
+		suffix[i] = rest.BPS()
+	}
+	limit := hintDropRatio * before
+	for i := early; i < len(events)-early; i++ {
+		if events[i].BPS() < limit && suffix[i] < limit {
+			found = i
+			break
+		}
+	}
+	if found < 0 {
+		return 0, 0, 0, false
+	}
+	ev := events[found]
+	return ev.Processed - ev.Delta, before, suffix[found], true
+}
+
+func sumEvents(events []Progress) (sum Progress) {
+	for _, ev := range events {
+		sum.Delta += ev.Delta
+		sum.Duration += ev.Duration
+	}
+	return sum
+}
+
+// stallShare returns the fraction of the run spent in stalled operations.
+func stallShare(r Report, s Summary) float64 {
+	if s.TotalTime == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, st := range r.Stalls {
+		total += st.Duration
+	}
+	// Stalls of concurrent writers overlap, so their sum can exceed the run time.
+	return math.Min(total.Seconds()/s.TotalTime, 1)
+}
@@ -0,0 +1,79 @@
+package bench
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// steadyEvents returns n events of 1mb, written at the given throughput.
+func steadyEvents(n int, mbps float64, processed uint64) []Progress {
+	events := make([]Progress, n)
+	for i := range events {
+		processed += 1 << 20
+		events[i] = Progress{
+			Processed: processed,
+			Delta:     1 << 20,
+			Duration:  time.Duration(float64(time.Second) / mbps),
+			Entries:   1000,
+			Commits:   10,
+		}
+	}
+	return events
+}
+
+func hasHint(hints []string, prefix string) bool {
+	for _, h := range hints {
+		if strings.HasPrefix(h, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestHintsHealthy(t *testing.T) {
+	r := Report{Events: steadyEvents(100, 5, 0), Result: &RunResult{Ops: 100000}}
+	if hints := Hints(r); len(hints) > 0 {
+		t.Errorf("unexpected hints: %q", hints)
+	}
+}
+
+func TestHintsThroughputDrop(t *testing.T) {
+	events := steadyEvents(40, 5, 0)
+	events = append(events, steadyEvents(60, 2, 40<<20)...)
+	r := Report{Events: events, Result: &RunResult{Ops: 100000}}
+	hints := Hints(r)
+	if !hasHint(hints, "throughput halves after ~40mb (5.0 to 2.0 mb/s): likely compaction-bound") {
+		t.Errorf("missing throughput hint: %q", hints)
+	}
+	// A drop at the very end is noise.
+	events = steadyEvents(97, 5, 0)
+	events = append(events, steadyEvents(3, 1, 97<<20)...)
+	if hints := Hints(Report{Events: events}); len(hints) > 0 {
+		t.Errorf("unexpected hints: %q", hints)
+	}
+}
+
+func TestHintsStalls(t *testing.T) {
+	r := Report{
+		Events: steadyEvents(100, 5, 0), // 20s
+		Stalls: []Stall{{Offset: 10 << 20, Duration: 3 * time.Second}},
+	}
+	if hints := Hints(r); !hasHint(hints, "writes stalled for 15% of the run") {
+		t.Errorf("missing stall hint: %q", hints)
+	}
+}
+
+func TestHintsPartialRun(t *testing.T) {
+	r := Report{
+		Events: steadyEvents(5, 5, 0),
+		Result: &RunResult{Ops: 100, Error: "no space left on device"},
+	}
+	hints := Hints(r)
+	if !hasHint(hints, "run ended early after 5mb") {
+		t.Errorf("missing error hint: %q", hints)
+	}
+	if !hasHint(hints, "run took only 1.0s") {
+		t.Errorf("missing short run hint: %q", hints)
+	}
+}
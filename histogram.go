@@ -0,0 +1,155 @@
+package bench
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// LatencyPercentiles summarizes the latencies of individual operations.
+type LatencyPercentiles struct {
+	Count uint64        `json:"count"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+	P999  time.Duration `json:"p999"`
+	Max   time.Duration `json:"max"`
+}
+
+// histSubBits is the precision of the latency histogram. Every power of two is
+// split into 2^histSubBits buckets, so recorded values are accurate to 1%.
+const histSubBits = 7
+
+const histSubBuckets = 1 << histSubBits
+
+// histogram records durations in the manner of HdrHistogram. Values below
+// 2*histSubBuckets nanoseconds have their own bucket, larger values are grouped
+// by their power of two and the leading histSubBits bits below it.
+type histogram struct {
+	counts []uint64
+	count  uint64
+	max    time.Duration
+}
+
+func histIndex(v uint64) int {
+	if v < 2*histSubBuckets {
+		return int(v)
+	}
+	shift := uint(bits.Len64(v) - histSubBits - 1)
+	return int(shift+1)*histSubBuckets + int(v>>shift) - histSubBuckets
+}
+
+// histValue returns the largest value of a bucket.
+func histValue(i int) uint64 {
+	if i < 2*histSubBuckets {
+		return uint64(i)
+	}
+	shift := uint(i/histSubBuckets - 1)
+	m := uint64(i%histSubBuckets + histSubBuckets)
+	return (m+1)<<shift - 1
+}
+
+func (h *histogram) add(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	i := histIndex(uint64(d))
+	if i >= len(h.counts) {
+		counts := make([]uint64, i+1)
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+	h.counts[i]++
+	h.count++
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// percentile returns the latency below which q percent of the operations are.
+func (h *histogram) percentile(q float64) time.Duration {
+	target := uint64(math.Ceil(q / 100 * float64(h.count)))
+	if target == 0 {
+		target = 1
+	}
+	var sum uint64
+	for i, c := range h.counts {
+		if sum += c; sum >= target {
+			if v := time.Duration(histValue(i)); v < h.max {
+				return v
+			}
+			break
+		}
+	}
+	return h.max
+}
+
+func (h *histogram) percentiles() *LatencyPercentiles {
+	if h.count == 0 {
+		return nil
+	}
+	return &LatencyPercentiles{
+		Count: h.count,
+		P50:   h.percentile(50),
+		P90:   h.percentile(90),
+		P99:   h.percentile(99),
+		P999:  h.percentile(99.9),
+		Max:   h.max,
+	}
+}
+
+// buckets returns the number of values in the ranges divided by the given
+// ascending bounds: counts[i] are the values up to bounds[i] and above the
+// previous bound, the last count is of the values above all bounds.
+func (h *histogram) buckets(bounds []time.Duration) []uint64 {
+	counts := make([]uint64, len(bounds)+1)
+	b := 0
+	for i, c := range h.counts {
+		for b < len(bounds) && time.Duration(histValue(i)) > bounds[b] {
+			b++
+		}
+		counts[b] += c
+	}
+	return counts
+}
+
+// reset clears the histogram, keeping its buckets allocated.
+func (h *histogram) reset() {
+	for i := range h.counts {
+		h.counts[i] = 0
+	}
+	h.count, h.max = 0, 0
+}
+
+// intervalLatency collects the latencies between two progress events. It is
+// safe for concurrent use.
+type intervalLatency struct {
+	mu sync.Mutex
+	h  histogram
+}
+
+func (l *intervalLatency) add(d time.Duration) {
+	l.mu.Lock()
+	l.h.add(d)
+	l.mu.Unlock()
+}
+
+func (l *intervalLatency) reset() {
+	l.mu.Lock()
+	l.h.reset()
+	l.mu.Unlock()
+}
+
+// p99 returns the 99th percentile of the recorded latencies and starts the next
+// interval. It returns zero if there were no operations.
+func (l *intervalLatency) p99() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.h.count == 0 {
+		return 0
+	}
+	p := l.h.percentile(99)
+	l.h.reset()
+	return p
+}
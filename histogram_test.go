@@ -0,0 +1,81 @@
+package bench
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistogramPercentiles(t *testing.T) {
+	var h histogram
+	for i := 1; i <= 1000; i++ {
+		h.add(time.Duration(i) * time.Microsecond)
+	}
+	h.add(3 * time.Second)
+	p := h.percentiles()
+	check := func(name string, got, want time.Duration) {
+		t.Helper()
+		if got < want || float64(got-want) > 0.01*float64(want) {
+			t.Errorf("%s: got %v, want %v within 1%%", name, got, want)
+		}
+	}
+	check("p50", p.P50, 501*time.Microsecond)
+	check("p90", p.P90, 901*time.Microsecond)
+	check("p99", p.P99, 991*time.Microsecond)
+	check("p99.9", p.P999, 1000*time.Microsecond)
+	if p.Max != 3*time.Second || p.Count != 1001 {
+		t.Errorf("wrong max %v or count %d", p.Max, p.Count)
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	for _, v := range []uint64{0, 1, 255, 256, 257, 1000, 123456789, 1 << 40} {
+		i := histIndex(v)
+		if max := histValue(i); max < v {
+			t.Errorf("value %d: bucket %d ends at %d", v, i, max)
+		}
+		if i > 0 && histValue(i-1) >= v {
+			t.Errorf("value %d: previous bucket %d ends at %d", v, i-1, histValue(i-1))
+		}
+	}
+}
+
+func TestIntervalLatency(t *testing.T) {
+	var l intervalLatency
+	if p := l.p99(); p != 0 {
+		t.Errorf("p99 %v without operations", p)
+	}
+	for i := 1; i <= 100; i++ {
+		l.add(time.Duration(i) * time.Microsecond)
+	}
+	if p := l.p99(); p < 98*time.Microsecond || p > 100*time.Microsecond {
+		t.Errorf("wrong p99 %v", p)
+	}
+	l.add(time.Millisecond)
+	if p := l.p99(); p != time.Millisecond {
+		t.Errorf("p99 %v of second interval includes the first", p)
+	}
+}
+
+func TestHistogramBucketCounts(t *testing.T) {
+	var h histogram
+	for i := 1; i <= 100; i++ {
+		h.add(time.Duration(i) * time.Millisecond)
+	}
+	bounds := []time.Duration{10 * time.Millisecond, 50 * time.Millisecond, 90 * time.Millisecond}
+	counts := h.buckets(bounds)
+	want := []uint64{10, 40, 40, 10}
+	for i := range want {
+		// Bucket boundaries are accurate to 1%, values near a bound may be
+		// counted in the next range.
+		if d := int64(counts[i]) - int64(want[i]); d < -1 || d > 1 {
+			t.Fatalf("wrong counts %v, want %v", counts, want)
+		}
+	}
+	var sum uint64
+	for _, c := range counts {
+		sum += c
+	}
+	if sum != 100 {
+		t.Errorf("counts %v don't add up to 100", counts)
+	}
+}
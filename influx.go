@@ -0,0 +1,84 @@
+package bench
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// influxMeasurement is the measurement of progress samples in InfluxDB.
+const influxMeasurement = "ldbbench_progress"
+
+var influxEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// progressInflux writes progress events in InfluxDB line protocol. Every line
+// is tagged with the test name and the tags of the run. Timestamps are the wall
+// clock time of the sample.
+type progressInflux struct {
+	w      io.Writer
+	prefix []byte // measurement and tags
+	start  time.Time
+	buf    []byte
+}
+
+func newProgressInflux(w io.Writer, test string, tags Tags, start time.Time) *progressInflux {
+	prefix := influxMeasurement + ",test=" + influxEscaper.Replace(test)
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		if k != "test" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys) // Influx recommends sorted tags
+	for _, k := range keys {
+		prefix += "," + influxEscaper.Replace(k) + "=" + influxEscaper.Replace(tags[k])
+	}
+	return &progressInflux{w: w, prefix: []byte(prefix), start: start}
+}
+
+// add writes a line. It can be called on nil, which does nothing.
+func (c *progressInflux) add(p Progress) error {
+	if c == nil {
+		return nil
+	}
+	b := append(c.buf[:0], c.prefix...)
+	b = append(b, " processed="...)
+	b = strconv.AppendUint(b, p.Processed, 10)
+	b = append(b, "i,delta="...)
+	b = strconv.AppendUint(b, p.Delta, 10)
+	b = append(b, "i,duration="...)
+	b = strconv.AppendFloat(b, p.Duration.Seconds(), 'f', -1, 64)
+	b = append(b, ",entries="...)
+	b = strconv.AppendUint(b, p.Entries, 10)
+	b = append(b, "i,commits="...)
+	b = strconv.AppendUint(b, p.Commits, 10)
+	b = append(b, "i,bps="...)
+	b = strconv.AppendFloat(b, p.BPS(), 'f', 0, 64)
+	if p.P99 > 0 {
+		b = append(b, ",p99="...)
+		b = strconv.AppendInt(b, int64(p.P99), 10)
+		b = append(b, 'i')
+	}
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, c.start.Add(p.Time).UnixNano(), 10)
+	b = append(b, '\n')
+	c.buf = b
+	_, err := c.w.Write(b)
+	return err
+}
+
+// ProgressInflux enables writing progress events to w in InfluxDB line
+// protocol, in addition to the log. Every event is written with a single call
+// to Write. It must be called before Run.
+func (env *WriteEnv) ProgressInflux(w io.Writer) {
+	env.influxOut = w
+}
+
+// ProgressInflux enables writing progress events to w in InfluxDB line
+// protocol, in addition to the log. Every event is written with a single call
+// to Write. It must be called before Run.
+func (env *ReadEnv) ProgressInflux(w io.Writer) {
+	env.influxOut = w
+}
@@ -0,0 +1,26 @@
+package bench
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestProgressInflux(t *testing.T) {
+	var buf bytes.Buffer
+	start := time.Unix(1600000000, 0)
+	c := newProgressInflux(&buf, "batch 100kb", Tags{"fs": "ext4", "machine": "a,b"}, start)
+	c.add(Progress{Processed: 200, Delta: 100, Duration: time.Second / 2, Time: time.Second, Entries: 10, Commits: 1})
+	c.add(Progress{Processed: 300, Delta: 100, Duration: time.Second, Time: 2 * time.Second, P99: time.Millisecond})
+	want := `ldbbench_progress,test=batch\ 100kb,fs=ext4,machine=a\,b processed=200i,delta=100i,duration=0.5,entries=10i,commits=1i,bps=200 1600000001000000000
+ldbbench_progress,test=batch\ 100kb,fs=ext4,machine=a\,b processed=300i,delta=100i,duration=1,entries=0i,commits=0i,bps=100,p99=1000000i 1600000002000000000
+`
+	if buf.String() != want {
+		t.Errorf("wrong output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	var nilInflux *progressInflux
+	if err := nilInflux.add(Progress{}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,58 @@
+package bench
+
+import (
+	"fmt"
+	"time"
+)
+
+// emitInterval is the default amount of data between progress events.
+const emitInterval = 500 * 1024
+
+// ReportInterval is the distance between progress events of a run. It is
+// either an amount of data processed or a time span. The zero value logs
+// progress every 500kb.
+type ReportInterval struct {
+	Bytes uint64        `json:"bytes,omitempty"`
+	Time  time.Duration `json:"time,omitempty"`
+}
+
+// ParseReportInterval parses an interval like "10s" or "1mb".
+func ParseReportInterval(s string) (ReportInterval, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		if d <= 0 {
+			return ReportInterval{}, fmt.Errorf("invalid report interval %q", s)
+		}
+		return ReportInterval{Time: d}, nil
+	}
+	n, err := ParseSize(s)
+	if err != nil || n == 0 {
+		return ReportInterval{}, fmt.Errorf("invalid report interval %q", s)
+	}
+	return ReportInterval{Bytes: n}, nil
+}
+
+func (i ReportInterval) String() string {
+	if i.Time > 0 {
+		return i.Time.String()
+	}
+	return FormatSize(i.bytes())
+}
+
+func (i ReportInterval) bytes() uint64 {
+	if i.Bytes == 0 {
+		return emitInterval
+	}
+	return i.Bytes
+}
+
+// due reports whether a progress event should be logged after processing dw
+// bytes in time d since the last event.
+func (i ReportInterval) due(dw uint64, d time.Duration) bool {
+	if dw == 0 {
+		return false
+	}
+	if i.Time > 0 {
+		return d >= i.Time
+	}
+	return dw > i.bytes()
+}
@@ -0,0 +1,71 @@
+package bench
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestParseReportInterval(t *testing.T) {
+	tests := []struct {
+		input string
+		want  ReportInterval
+	}{
+		{"1s", ReportInterval{Time: time.Second}},
+		{"100ms", ReportInterval{Time: 100 * time.Millisecond}},
+		{"1mb", ReportInterval{Bytes: 1024 * 1024}},
+		{"4096", ReportInterval{Bytes: 4096}},
+	}
+	for _, test := range tests {
+		got, err := ParseReportInterval(test.input)
+		if err != nil {
+			t.Errorf("%q: %v", test.input, err)
+		} else if got != test.want {
+			t.Errorf("%q: got %+v, want %+v", test.input, got, test.want)
+		}
+	}
+	for _, input := range []string{"", "0s", "0", "-1s", "1 minute"} {
+		if _, err := ParseReportInterval(input); err == nil {
+			t.Errorf("%q: expected error", input)
+		}
+	}
+}
+
+func TestReportIntervalDue(t *testing.T) {
+	var def ReportInterval
+	if def.due(emitInterval, time.Hour) || !def.due(emitInterval+1, 0) {
+		t.Error("wrong default interval")
+	}
+	timed := ReportInterval{Time: time.Second}
+	if timed.due(1<<30, time.Second-1) || !timed.due(1, time.Second) {
+		t.Error("wrong timed interval")
+	}
+	if timed.due(0, time.Hour) {
+		t.Error("event due without progress")
+	}
+}
+
+// This test checks that a run with a time interval logs all data written, even
+// when it is shorter than the interval.
+func TestReportIntervalFlush(t *testing.T) {
+	var (
+		out bytes.Buffer
+		cfg = WriteConfig{Size: 1 << 20, KeySize: 8, DataSize: 128, ReportInterval: ReportInterval{Time: time.Hour}}
+		env = NewWriteEnv(&out, cfg)
+	)
+	err := env.Run(func(key, value string, lastCall bool) error {
+		env.Progress(len(value))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := testReport(t, &out).Events
+	if len(events) != 1 {
+		t.Fatalf("got %d progress events, want 1", len(events))
+	}
+	if ev := events[0]; ev.Processed != cfg.Size || ev.Time == 0 || ev.Time != ev.Duration {
+		t.Errorf("wrong final event %+v", ev)
+	}
+}
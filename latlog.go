@@ -0,0 +1,70 @@
+package bench
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"strconv"
+	"time"
+)
+
+// latencyLogHeader is the first line of a latency log.
+const latencyLogHeader = "time\top\tlatency\n"
+
+// latencyLog streams the latency of every operation to a gzip-compressed file.
+// Lines hold the start time of the operation since the start of the run, the
+// name of the operation and its latency, tab-separated, with times in
+// nanoseconds. Each run appends a gzip member starting with the header line.
+type latencyLog struct {
+	gz  *gzip.Writer
+	w   *bufio.Writer
+	buf []byte
+}
+
+func newLatencyLog(w io.Writer) (*latencyLog, error) {
+	gz, err := gzip.NewWriterLevel(w, gzip.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	l := &latencyLog{gz: gz, w: bufio.NewWriterSize(gz, 64*1024)}
+	_, err = l.w.WriteString(latencyLogHeader)
+	return l, err
+}
+
+// add writes a record. It can be called on a nil log, which does nothing.
+func (l *latencyLog) add(t time.Duration, op string, d time.Duration) {
+	if l == nil {
+		return
+	}
+	b := strconv.AppendInt(l.buf[:0], int64(t), 10)
+	b = append(b, '\t')
+	b = append(b, op...)
+	b = append(b, '\t')
+	b = strconv.AppendInt(b, int64(d), 10)
+	b = append(b, '\n')
+	l.w.Write(b)
+	l.buf = b
+}
+
+// close writes buffered records and ends the gzip member.
+func (l *latencyLog) close() error {
+	if l == nil {
+		return nil
+	}
+	if err := l.w.Flush(); err != nil {
+		return err
+	}
+	return l.gz.Close()
+}
+
+// LatencyLog enables logging the latency of every operation to w, compressed
+// with gzip. It must be called before Run.
+func (env *WriteEnv) LatencyLog(w io.Writer) {
+	env.latOut = w
+}
+
+// LatencyLog enables logging the latency of every read to w, compressed with
+// gzip. It must be called before Run.
+func (env *ReadEnv) LatencyLog(w io.Writer) {
+	env.latOut = w
+}
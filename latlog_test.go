@@ -0,0 +1,51 @@
+package bench
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLatencyLog(t *testing.T) {
+	var (
+		out, lat bytes.Buffer
+		cfg      = WriteConfig{Size: 5000, KeySize: 8, DataSize: 20}
+		env      = NewWriteEnv(&out, cfg)
+	)
+	env.LatencyLog(&lat)
+	err := env.Run(func(key, value string, lastCall bool) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(&lat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(content), latencyLogHeader) {
+		t.Fatalf("missing header: %q", content)
+	}
+	s := bufio.NewScanner(bytes.NewReader(content[len(latencyLogHeader):]))
+	lines := 0
+	for ; s.Scan(); lines++ {
+		f := strings.Split(s.Text(), "\t")
+		if len(f) != 3 || f[1] != "put" {
+			t.Fatalf("invalid line %q", s.Text())
+		}
+		for _, n := range []string{f[0], f[2]} {
+			if _, err := strconv.ParseInt(n, 10, 64); err != nil {
+				t.Fatalf("invalid line %q: %v", s.Text(), err)
+			}
+		}
+	}
+	if lines != 250 {
+		t.Errorf("got %d records, want 250", lines)
+	}
+}
@@ -0,0 +1,134 @@
+package bench
+
+import (
+	"sync"
+	"time"
+)
+
+// Live collects the metrics of the running test for live monitoring. Set it in
+// WriteConfig.Live or ReadConfig.Live. Its methods are safe for concurrent use,
+// and a nil Live ignores all updates.
+type Live struct {
+	mu    sync.Mutex
+	start time.Duration
+	snap  LiveSnapshot
+	lat   histogram
+	// recent collects the latencies since the last progress event.
+	recent histogram
+}
+
+// LiveSnapshot is the state of the running test.
+type LiveSnapshot struct {
+	Test    string
+	Tags    Tags
+	Elapsed time.Duration // since the start of the test
+	Size    uint64        // data the test processes in total
+
+	Processed, Entries, Commits uint64
+	BPS                         float64 // of the last progress interval
+	Stalls                      uint64
+
+	Latency    *LatencyPercentiles // nil before the first operation
+	RecentP99  time.Duration       // of the last progress interval
+	Compaction *CompactionStats    // last sample, if any
+	Disk       *DiskUsage          // last sample, if any
+}
+
+// NewLive creates an empty Live.
+func NewLive() *Live {
+	return new(Live)
+}
+
+// Snapshot returns the current state.
+func (l *Live) Snapshot() LiveSnapshot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := l.snap
+	if s.Test != "" {
+		s.Elapsed = mononow() - l.start
+	}
+	s.Latency = l.lat.percentiles()
+	return s
+}
+
+// LatencyBuckets returns the number of operations of the running test whose
+// latency is in each of the ranges divided by the ascending bounds. The last
+// count is of the latencies above all bounds.
+func (l *Live) LatencyBuckets(bounds []time.Duration) []uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lat.buckets(bounds)
+}
+
+// Remaining estimates the time until the test is done from the throughput of
+// the last progress interval. It returns zero if there is no estimate.
+func (s *LiveSnapshot) Remaining() time.Duration {
+	if s.BPS <= 0 || s.Processed >= s.Size {
+		return 0
+	}
+	return time.Duration(float64(s.Size-s.Processed) / s.BPS * float64(time.Second))
+}
+
+// reset starts a new test.
+func (l *Live) reset(test string, tags Tags, size uint64) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.start = mononow()
+	l.snap = LiveSnapshot{Test: test, Tags: tags, Size: size}
+	l.lat = histogram{}
+	l.recent = histogram{}
+}
+
+func (l *Live) latency(d time.Duration) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.lat.add(d)
+	l.recent.add(d)
+	l.mu.Unlock()
+}
+
+func (l *Live) progress(p Progress, entries, commits uint64) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.snap.Processed, l.snap.Entries, l.snap.Commits = p.Processed, entries, commits
+	l.snap.BPS = p.BPS()
+	if l.recent.count > 0 {
+		l.snap.RecentP99 = l.recent.percentile(99)
+		l.recent.reset()
+	}
+}
+
+func (l *Live) stall() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.snap.Stalls++
+	l.mu.Unlock()
+}
+
+func (l *Live) compaction(c CompactionStats) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.snap.Compaction = &c
+	l.mu.Unlock()
+}
+
+func (l *Live) disk(d DiskUsage) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.snap.Disk = &d
+	l.mu.Unlock()
+}
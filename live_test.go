@@ -0,0 +1,49 @@
+package bench
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestLiveSnapshot(t *testing.T) {
+	var (
+		live = NewLive()
+		cfg  = WriteConfig{Size: 4 << 20, KeySize: 8, DataSize: 128, TestName: "test", Live: live}
+		env  = NewWriteEnv(ioutil.Discard, cfg)
+	)
+	if s := live.Snapshot(); s.Test != "" || s.Latency != nil {
+		t.Fatalf("snapshot before the run: %+v", s)
+	}
+	err := env.Run(func(key, value string, lastCall bool) error {
+		env.Progress(len(value))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := live.Snapshot()
+	if s.Test != "test" {
+		t.Errorf("wrong test name %q", s.Test)
+	}
+	if s.Processed == 0 || s.Processed > cfg.Size || s.Entries != s.Processed/cfg.DataSize {
+		t.Errorf("wrong progress: %d bytes, %d entries", s.Processed, s.Entries)
+	}
+	if s.Latency == nil || s.Latency.Count != cfg.Size/cfg.DataSize {
+		t.Errorf("wrong latency %+v", s.Latency)
+	}
+	if s.Size != cfg.Size || s.RecentP99 == 0 {
+		t.Errorf("wrong size %d or recent p99 %v", s.Size, s.RecentP99)
+	}
+}
+
+func TestLiveRemaining(t *testing.T) {
+	s := LiveSnapshot{Size: 300 << 20, Processed: 100 << 20, BPS: 10 << 20}
+	if r := s.Remaining(); r != 20*time.Second {
+		t.Errorf("wrong remaining time %v", r)
+	}
+	s.Processed = s.Size
+	if r := s.Remaining(); r != 0 {
+		t.Errorf("remaining time %v after the end", r)
+	}
+}
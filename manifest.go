@@ -0,0 +1,188 @@
+package bench
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// manifestVersion is the version of the manifest format.
+const manifestVersion = 1
+
+// Manifest describes the contents of a database written by a benchmark run.
+// The key space is split into 256 ranges by the first byte of the key. For
+// every range, the manifest holds the number of keys and a checksum of all
+// entries, which doesn't depend on the order of writes. A manifest can validate
+// a database without regenerating the keys and values of the run.
+//
+// Overwritten keys are counted twice, so the manifest only matches if every
+// key was written once. This is guaranteed with cfg.UniqueKeys.
+//
+// Comparer is the name of the database's key comparer if it doesn't order keys
+// bytewise. The database must be opened with this comparer, and its ranges
+// aren't contiguous in key order.
+type Manifest struct {
+	Version    int             `json:"version"`
+	Test       string          `json:"test,omitempty"`
+	Comparer   string          `json:"comparer,omitempty"`
+	UniqueKeys bool            `json:"uniquekeys"`
+	Ranges     []ManifestRange `json:"ranges"`
+}
+
+// ManifestRange is the key range [Start, Limit) of a manifest. Limit is nil
+// for the last range.
+type ManifestRange struct {
+	Start    []byte `json:"start"`
+	Limit    []byte `json:"limit,omitempty"`
+	Keys     uint64 `json:"keys"`
+	Checksum uint64 `json:"checksum"`
+}
+
+// ReadManifest reads a manifest file.
+func ReadManifest(file string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return decodeManifest(data)
+}
+
+func decodeManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if m.Version != manifestVersion {
+		return nil, fmt.Errorf("unsupported manifest version %d", m.Version)
+	}
+	return &m, nil
+}
+
+// ManifestMismatch is a range of the database which doesn't match the manifest.
+type ManifestMismatch struct {
+	ManifestRange
+	FoundKeys     uint64
+	FoundChecksum uint64
+}
+
+// Check validates a database against the manifest. The iterate function must
+// call fn for every entry in the key range [start, limit), where a nil limit
+// means no upper bound. Check returns all ranges which don't match.
+//
+// If the manifest has a comparer, the database is iterated once as a whole and
+// entries are assigned to ranges by their first byte.
+func (m *Manifest) Check(iterate func(start, limit []byte, fn func(key, value []byte)) error) ([]ManifestMismatch, error) {
+	found := make([]ManifestRange, len(m.Ranges))
+	if m.Comparer != "" {
+		err := iterate(nil, nil, func(key, value []byte) {
+			if i := rangeIndex(key); i < len(found) {
+				found[i].Keys++
+				found[i].Checksum += entryChecksum(key, value)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		for i, r := range m.Ranges {
+			err := iterate(r.Start, r.Limit, func(key, value []byte) {
+				found[i].Keys++
+				found[i].Checksum += entryChecksum(key, value)
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	var mismatches []ManifestMismatch
+	for i, r := range m.Ranges {
+		if f := found[i]; f.Keys != r.Keys || f.Checksum != r.Checksum {
+			mismatches = append(mismatches, ManifestMismatch{r, f.Keys, f.Checksum})
+		}
+	}
+	return mismatches, nil
+}
+
+// rangeIndex returns the index of the range holding key. Ranges are keyed by
+// the first byte, the empty key is counted in the first range.
+func rangeIndex(key []byte) int {
+	if len(key) == 0 {
+		return 0
+	}
+	return int(key[0])
+}
+
+// entryChecksum hashes a key/value pair. Checksums of a range are added up.
+func entryChecksum(key, value []byte) uint64 {
+	var (
+		d   = xxhash.New()
+		buf [8]byte
+	)
+	binary.BigEndian.PutUint64(buf[:], uint64(len(key)))
+	d.Write(buf[:])
+	d.Write(key)
+	d.Write(value)
+	return d.Sum64()
+}
+
+// manifestBuilder accumulates the manifest during writes.
+type manifestBuilder struct {
+	m Manifest
+}
+
+func newManifestBuilder(cfg WriteConfig, comparer string) *manifestBuilder {
+	b := &manifestBuilder{m: Manifest{
+		Version:    manifestVersion,
+		Test:       cfg.TestName,
+		Comparer:   comparer,
+		UniqueKeys: cfg.UniqueKeys,
+		Ranges:     make([]ManifestRange, 256),
+	}}
+	for i := range b.m.Ranges {
+		b.m.Ranges[i].Start = []byte{byte(i)}
+		if i < 255 {
+			b.m.Ranges[i].Limit = []byte{byte(i + 1)}
+		}
+	}
+	return b
+}
+
+func (b *manifestBuilder) add(key, value []byte) {
+	r := &b.m.Ranges[rangeIndex(key)]
+	r.Keys++
+	r.Checksum += entryChecksum(key, value)
+}
+
+// Manifest enables writing a manifest of the database contents to w at the end
+// of the run. It must be called before Run.
+func (env *WriteEnv) Manifest(w io.Writer) {
+	env.manifestW = w
+}
+
+// KeyOrder sets the name of the database's key comparer if it doesn't order keys
+// bytewise. The comparer is recorded in the manifest. Key counting and periodic
+// compaction work on ranges of the first key byte, so keys are always counted
+// in full and periodic compaction is disabled.
+func (env *WriteEnv) KeyOrder(comparer string) {
+	env.keyOrder = comparer
+}
+
+// writeManifest writes the manifest after the run.
+func (env *WriteEnv) writeManifest(result *RunResult) {
+	if env.manifest == nil {
+		return
+	}
+	defer func() { env.manifest = nil }()
+	if !env.storesGenerated(result) {
+		log.Printf("can't write manifest: test doesn't store generated values")
+		return
+	}
+	if err := json.NewEncoder(env.manifestW).Encode(&env.manifest.m); err != nil {
+		log.Printf("can't write manifest: %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package bench
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	var (
+		manifest bytes.Buffer
+		store    = make(map[string]string)
+		cfg      = WriteConfig{Size: 100000, KeySize: 16, DataSize: 100, UniqueKeys: true}
+		env      = NewWriteEnv(new(bytes.Buffer), cfg)
+	)
+	env.Manifest(&manifest)
+	err := env.Run(func(key, value string, lastCall bool) error {
+		store[key] = value
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := decodeManifest(manifest.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iterate := func(start, limit []byte, fn func(key, value []byte)) error {
+		var keys []string
+		for k := range store {
+			if k >= string(start) && (limit == nil || k < string(limit)) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fn([]byte(k), []byte(store[k]))
+		}
+		return nil
+	}
+	if mm, err := m.Check(iterate); err != nil || len(mm) != 0 {
+		t.Fatalf("intact store doesn't match: %d mismatches, err %v", len(mm), err)
+	}
+
+	// Change one value and remove a key in another range.
+	var changed, removed string
+	for k := range store {
+		if changed == "" {
+			changed = k
+		} else if k[0] != changed[0] {
+			removed = k
+			break
+		}
+	}
+	store[changed] += "x"
+	delete(store, removed)
+	mm, err := m.Check(iterate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mm) != 2 {
+		t.Fatalf("got %d mismatches, want 2", len(mm))
+	}
+	for _, r := range mm {
+		switch r.Start[0] {
+		case removed[0]:
+			if r.FoundKeys != r.Keys-1 {
+				t.Errorf("range %x: found %d keys, want %d", r.Start, r.FoundKeys, r.Keys-1)
+			}
+		case changed[0]:
+			if r.FoundKeys != r.Keys || r.FoundChecksum == r.Checksum {
+				t.Errorf("range %x: change not detected", r.Start)
+			}
+		default:
+			t.Errorf("unexpected mismatch in range %x", r.Start)
+		}
+	}
+}
+
+func TestManifestComparer(t *testing.T) {
+	var (
+		manifest bytes.Buffer
+		store    = make(map[string]string)
+		cfg      = WriteConfig{Size: 10000, KeySize: 16, DataSize: 100, UniqueKeys: true}
+		env      = NewWriteEnv(new(bytes.Buffer), cfg)
+	)
+	env.Manifest(&manifest)
+	env.KeyOrder("test.Comparator")
+	err := env.Run(func(key, value string, lastCall bool) error {
+		store[key] = value
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := decodeManifest(manifest.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Comparer != "test.Comparator" {
+		t.Fatalf("wrong comparer %q in manifest", m.Comparer)
+	}
+
+	// The database can only be iterated as a whole, in no particular order.
+	iterate := func(start, limit []byte, fn func(key, value []byte)) error {
+		if start != nil || limit != nil {
+			t.Fatalf("range iteration [%x, %x) without bytewise order", start, limit)
+		}
+		for k, v := range store {
+			fn([]byte(k), []byte(v))
+		}
+		return nil
+	}
+	if mm, err := m.Check(iterate); err != nil || len(mm) != 0 {
+		t.Fatalf("intact store doesn't match: %d mismatches, err %v", len(mm), err)
+	}
+	for k := range store {
+		delete(store, k)
+		break
+	}
+	if mm, err := m.Check(iterate); err != nil || len(mm) != 1 {
+		t.Fatalf("got %d mismatches, want 1 (err %v)", len(mm), err)
+	}
+}
@@ -0,0 +1,48 @@
+package bench
+
+import (
+	"encoding/json"
+	"runtime"
+	"time"
+)
+
+// MemoryUsage is a sample of the Go runtime memory statistics, taken whenever
+// progress is logged.
+type MemoryUsage struct {
+	Time      time.Duration `json:"time"`      // since the start of the run
+	Processed uint64        `json:"processed"` // bytes processed
+	HeapAlloc uint64        `json:"heapalloc"` // bytes of allocated heap objects
+	Sys       uint64        `json:"sys"`       // bytes obtained from the OS
+	NumGC     uint32        `json:"numgc"`     // completed GC cycles
+}
+
+// sampleMemory logs the current memory statistics. ReadMemStats stops the
+// world briefly, which is why sampling must be enabled explicitly.
+func sampleMemory(enc *json.Encoder, t time.Duration, processed uint64) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	writeMemoryUsage(enc, MemoryUsage{
+		Time:      t,
+		Processed: processed,
+		HeapAlloc: ms.HeapAlloc,
+		Sys:       ms.Sys,
+		NumGC:     ms.NumGC,
+	})
+}
+
+// PeakMemory returns the largest heap and sys size across all memory samples of
+// the report and the number of GC cycles between the first and last sample.
+func (r *Report) PeakMemory() (heap, sys uint64, gcs uint32) {
+	for _, m := range r.Memory {
+		if m.HeapAlloc > heap {
+			heap = m.HeapAlloc
+		}
+		if m.Sys > sys {
+			sys = m.Sys
+		}
+	}
+	if n := len(r.Memory); n > 0 {
+		gcs = r.Memory[n-1].NumGC - r.Memory[0].NumGC
+	}
+	return heap, sys, gcs
+}
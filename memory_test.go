@@ -0,0 +1,59 @@
+package bench
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSampleMemory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bench-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		out bytes.Buffer
+		cfg = WriteConfig{Size: 4 << 20, KeySize: 8, DataSize: 128, SampleMemory: true}
+		env = NewWriteEnv(&out, cfg)
+	)
+	err = env.Run(func(key, value string, lastCall bool) error {
+		env.Progress(len(value))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "test.json")
+	if err := ioutil.WriteFile(file, out.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := MustReadReports([]string{file})[0]
+	if len(r.Memory) == 0 || len(r.Memory) != len(r.Events) {
+		t.Fatalf("got %d memory samples for %d progress events", len(r.Memory), len(r.Events))
+	}
+	for i, m := range r.Memory {
+		if m.Processed != r.Events[i].Processed {
+			t.Errorf("sample %d: processed %d, want %d", i, m.Processed, r.Events[i].Processed)
+		}
+	}
+	if heap, sys, _ := r.PeakMemory(); heap == 0 || sys < heap {
+		t.Errorf("wrong peak memory: heap %d, sys %d", heap, sys)
+	}
+}
+
+func TestPeakMemory(t *testing.T) {
+	r := Report{Memory: []MemoryUsage{
+		{HeapAlloc: 10, Sys: 100, NumGC: 3},
+		{HeapAlloc: 30, Sys: 100, NumGC: 5},
+		{HeapAlloc: 20, Sys: 120, NumGC: 9},
+	}}
+	heap, sys, gcs := r.PeakMemory()
+	if heap != 30 || sys != 120 || gcs != 6 {
+		t.Errorf("got heap %d, sys %d, %d GCs", heap, sys, gcs)
+	}
+}
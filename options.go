@@ -0,0 +1,39 @@
+package bench
+
+// LogOptions records the options the database of the run is opened with. o is
+// encoded as JSON. Options logged before the run has started are written after
+// the log header.
+func (env *WriteEnv) LogOptions(o interface{}) error {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	if env.startTime == 0 {
+		env.options = o
+		return nil
+	}
+	return writeOptions(env.out, o)
+}
+
+func (env *WriteEnv) writePendingOptions() error {
+	if env.options == nil {
+		return nil
+	}
+	return writeOptions(env.out, env.options)
+}
+
+// LogOptions records the database options like WriteEnv.LogOptions.
+func (env *ReadEnv) LogOptions(o interface{}) error {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	if env.startTime == 0 {
+		env.options = o
+		return nil
+	}
+	return writeOptions(env.log, o)
+}
+
+func (env *ReadEnv) writePendingOptions() error {
+	if env.options == nil {
+		return nil
+	}
+	return writeOptions(env.log, env.options)
+}
@@ -0,0 +1,217 @@
+package bench
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"strings"
+)
+
+// OtherGroup is the CPUShares group of samples matching no other group.
+const OtherGroup = "other"
+
+// CPUShares attributes the CPU time of a pprof CPU profile to groups of
+// functions, given as lists of name prefixes where the longest match wins.
+// Every sample is attributed to the group of the innermost stack frame matching
+// any prefix, so time spent in the runtime or in system calls counts for the
+// calling package. Samples without a matching frame belong to OtherGroup. The
+// result contains the fraction of CPU time per group.
+func CPUShares(profile []byte, groups map[string][]string) (map[string]float64, error) {
+	p, err := parseProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+	// Resolve the group of every function once.
+	funcGroup := make(map[uint64]string, len(p.funcs))
+	for id, nameIdx := range p.funcs {
+		if nameIdx >= uint64(len(p.strings)) {
+			return nil, errors.New("invalid function name in profile")
+		}
+		name, best := p.strings[nameIdx], 0
+		for g, prefixes := range groups {
+			for _, prefix := range prefixes {
+				if len(prefix) > best && strings.HasPrefix(name, prefix) {
+					funcGroup[id], best = g, len(prefix)
+				}
+			}
+		}
+	}
+
+	var (
+		total  float64
+		shares = make(map[string]float64)
+	)
+	for _, s := range p.samples {
+		group := OtherGroup
+	stack:
+		for _, loc := range s.locations {
+			for _, fn := range p.locations[loc] {
+				if g, ok := funcGroup[fn]; ok {
+					group = g
+					break stack
+				}
+			}
+		}
+		shares[group] += float64(s.value)
+		total += float64(s.value)
+	}
+	if total == 0 {
+		return nil, errors.New("profile has no samples")
+	}
+	for g := range shares {
+		shares[g] /= total
+	}
+	return shares, nil
+}
+
+// profileData is the subset of a pprof profile used by CPUShares.
+type profileData struct {
+	samples   []profileSample
+	locations map[uint64][]uint64 // location ID -> function IDs, innermost first
+	funcs     map[uint64]uint64   // function ID -> name string index
+	strings   []string
+}
+
+type profileSample struct {
+	locations []uint64 // innermost first
+	value     int64    // last sample value, i.e. CPU time
+}
+
+// parseProfile decodes a gzipped pprof profile. See profile.proto in the pprof
+// repository for the format.
+func parseProfile(data []byte) (*profileData, error) {
+	if len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = ioutil.ReadAll(zr); err != nil {
+			return nil, err
+		}
+	}
+	p := &profileData{locations: make(map[uint64][]uint64), funcs: make(map[uint64]uint64)}
+	err := decodeProto(data, func(field int, v uint64, b []byte) error {
+		switch field {
+		case 2: // sample
+			var s profileSample
+			err := decodeProto(b, func(field int, v uint64, b []byte) error {
+				switch field {
+				case 1: // location_id
+					return decodeRepeated(v, b, func(id uint64) { s.locations = append(s.locations, id) })
+				case 2: // value
+					return decodeRepeated(v, b, func(x uint64) { s.value = int64(x) })
+				}
+				return nil
+			})
+			p.samples = append(p.samples, s)
+			return err
+		case 4: // location
+			var (
+				id    uint64
+				funcs []uint64
+			)
+			err := decodeProto(b, func(field int, v uint64, b []byte) error {
+				switch field {
+				case 1: // id
+					id = v
+				case 4: // line
+					return decodeProto(b, func(field int, v uint64, b []byte) error {
+						if field == 1 { // function_id
+							funcs = append(funcs, v)
+						}
+						return nil
+					})
+				}
+				return nil
+			})
+			p.locations[id] = funcs
+			return err
+		case 5: // function
+			var id, name uint64
+			err := decodeProto(b, func(field int, v uint64, b []byte) error {
+				switch field {
+				case 1: // id
+					id = v
+				case 2: // name
+					name = v
+				}
+				return nil
+			})
+			p.funcs[id] = name
+			return err
+		case 6: // string_table
+			p.strings = append(p.strings, string(b))
+		}
+		return nil
+	})
+	return p, err
+}
+
+var errProtoTruncated = errors.New("truncated protobuf message")
+
+// decodeProto calls fn for every field of a protobuf message. For varint
+// fields, v is the value. For length-delimited fields, b is the content.
+// Fixed-size fields are skipped.
+func decodeProto(data []byte, fn func(field int, v uint64, b []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errProtoTruncated
+		}
+		data = data[n:]
+		var (
+			v uint64
+			b []byte
+		)
+		switch key & 7 {
+		case 0: // varint
+			if v, n = binary.Uvarint(data); n <= 0 {
+				return errProtoTruncated
+			}
+			data = data[n:]
+		case 1: // 64-bit
+			if len(data) < 8 {
+				return errProtoTruncated
+			}
+			data = data[8:]
+			continue
+		case 2: // length-delimited
+			l, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < l {
+				return errProtoTruncated
+			}
+			b, data = data[n:n+int(l)], data[n+int(l):]
+		case 5: // 32-bit
+			if len(data) < 4 {
+				return errProtoTruncated
+			}
+			data = data[4:]
+			continue
+		default:
+			return errors.New("invalid protobuf wire type")
+		}
+		if err := fn(int(key>>3), v, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeRepeated handles a repeated integer field, which may be packed.
+func decodeRepeated(v uint64, b []byte, fn func(uint64)) error {
+	if b == nil {
+		fn(v)
+		return nil
+	}
+	for len(b) > 0 {
+		x, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errProtoTruncated
+		}
+		fn(x)
+		b = b[n:]
+	}
+	return nil
+}
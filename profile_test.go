@@ -0,0 +1,45 @@
+package bench
+
+import (
+	"bytes"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+//go:noinline
+func profileSpin(d time.Duration) (sum uint64) {
+	var buf [64]byte
+	for end := time.Now().Add(d); time.Now().Before(end); {
+		for i := 0; i < 1000; i++ {
+			sum += xxhash.Sum64(buf[:])
+			buf[0]++
+		}
+	}
+	return sum
+}
+
+func TestCPUShares(t *testing.T) {
+	var buf bytes.Buffer
+	if err := pprof.StartCPUProfile(&buf); err != nil {
+		t.Skip("can't profile:", err)
+	}
+	profileSpin(300 * time.Millisecond)
+	pprof.StopCPUProfile()
+
+	shares, err := CPUShares(buf.Bytes(), map[string][]string{
+		"hash":  {"github.com/cespare/xxhash"},
+		"bench": {"github.com/fjl/goleveldb-bench"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shares["hash"]+shares["bench"] < 0.8 {
+		t.Fatalf("too little CPU attributed to test code: %v", shares)
+	}
+	if shares["hash"] < shares["bench"] {
+		t.Errorf("hashing should dominate: %v", shares)
+	}
+}
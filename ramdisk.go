@@ -0,0 +1,10 @@
+package bench
+
+// Ramdisk is a memory-backed directory for test databases.
+type Ramdisk struct {
+	Dir     string // directory to place databases in
+	mounted bool   // true if the tmpfs was mounted by OpenRamdisk
+}
+
+// RamdiskTag is added to the tags of tests running on a ramdisk.
+const RamdiskTag = "storage"
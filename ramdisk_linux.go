@@ -0,0 +1,85 @@
+package bench
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const tmpfsMagic = 0x01021994
+
+// OpenRamdisk provides a tmpfs of at least the given size. If dir is already a
+// tmpfs mount, it is used directly. Otherwise a new tmpfs is mounted at
+// dir/ramdisk, which requires root privileges.
+func OpenRamdisk(dir string, size uint64) (*Ramdisk, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return nil, err
+	}
+	if st.Type == tmpfsMagic {
+		if avail := st.Bavail * uint64(st.Bsize); avail < size {
+			return nil, fmt.Errorf("tmpfs at %s has only %d bytes available, need %d", dir, avail, size)
+		}
+		return &Ramdisk{Dir: dir}, nil
+	}
+
+	// Check that the mount won't exhaust memory.
+	avail, err := memAvailable()
+	if err != nil {
+		return nil, err
+	}
+	if size > avail {
+		return nil, fmt.Errorf("ramdisk size %d exceeds available memory (%d bytes)", size, avail)
+	}
+	mp := filepath.Join(dir, "ramdisk")
+	if err := os.MkdirAll(mp, 0755); err != nil {
+		return nil, err
+	}
+	// Don't hide existing files below the mount.
+	if files, err := ioutil.ReadDir(mp); err != nil {
+		return nil, err
+	} else if len(files) > 0 {
+		return nil, fmt.Errorf("ramdisk mount point %s is not empty", mp)
+	}
+	if err := syscall.Mount("tmpfs", mp, "tmpfs", 0, "size="+strconv.FormatUint(size, 10)); err != nil {
+		return nil, fmt.Errorf("can't mount tmpfs at %s: %v", mp, err)
+	}
+	log.Printf("mounted %d byte tmpfs at %s", size, mp)
+	return &Ramdisk{Dir: mp, mounted: true}, nil
+}
+
+// Close unmounts the ramdisk if it was mounted by OpenRamdisk.
+func (r *Ramdisk) Close() error {
+	if !r.mounted {
+		return nil
+	}
+	if err := syscall.Unmount(r.Dir, 0); err != nil {
+		return err
+	}
+	r.mounted = false
+	return os.Remove(r.Dir)
+}
+
+// memAvailable returns the amount of memory available for new allocations.
+func memAvailable() (uint64, error) {
+	fd, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	defer fd.Close()
+	sc := bufio.NewScanner(fd)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) >= 2 && fields[0] == "MemAvailable:" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			return kb * 1024, err
+		}
+	}
+	return 0, fmt.Errorf("MemAvailable not found in /proc/meminfo")
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package bench
+
+import "errors"
+
+// OpenRamdisk is only supported on Linux.
+func OpenRamdisk(dir string, size uint64) (*Ramdisk, error) {
+	return nil, errors.New("ramdisk is only supported on linux")
+}
+
+// Close does nothing.
+func (r *Ramdisk) Close() error {
+	return nil
+}
@@ -4,7 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"math/rand"
+	"log"
 	"sync"
 	"time"
 )
@@ -13,6 +13,22 @@ type ReadConfig struct {
 	Size     uint64 `json:"size"`     // testing dataset size(pre-constructed)
 	KeySize  uint64 `json:"keysize"`  // size of each testing key
 	DataSize uint64 `json:"datasize"` // size of each testing value
+	Entropy  string `json:"entropy"`  // random source for keys and values
+	Tags     Tags   `json:"tags,omitempty"`
+
+	// Detectors are the names of the detectors run over the metric stream.
+	Detectors []string       `json:"detectors,omitempty"`
+	Watchdog  WatchdogConfig `json:"watchdog"`
+
+	// SampleMemory enables logging of Go runtime memory statistics whenever
+	// progress is logged.
+	SampleMemory bool `json:"samplememory,omitempty"`
+
+	// ReportInterval is the distance between progress events.
+	ReportInterval ReportInterval `json:"reportinterval"`
+
+	// Live receives the metrics of the run for live monitoring.
+	Live *Live `json:"-"`
 
 	LogPercent bool   `json:"-"`
 	TestName   string `json:"-"`
@@ -23,18 +39,39 @@ type ReadEnv struct {
 
 	// generating keys and values
 	key, value []byte
-	rand       *rand.Rand
+	rand       io.Reader
 	log        *json.Encoder
+	traceOut   io.Writer
+	trace      *TraceWriter
+	latOut     io.Writer
+	latLog     *latencyLog
+	csvOut     io.Writer
+	csv        *progressCSV
+	influxOut  io.Writer
+	influx     *progressInflux
+	dbDir      string
 	kw         io.Writer
 	kr         io.Reader
 	resetKey   func()
 	keych      chan [][]byte
+	detectors  *detectorSet
+	options    interface{} // database options logged before the start
+	ioFn       func() (uint64, error)
+	ioStart    int64 // storage bytes read before the read phase, -1 if unknown
+	levelsFn   func() ([]uint64, error)
+	levelStart []uint64 // storage bytes read per level before the read phase
+	stats      statsSampler
+	gcStart    gcSnapshot
+	device     deviceCounter
 
 	// reporting
 	mu                  sync.Mutex
 	startTime, lastTime time.Duration
 	read, lastRead      uint64
+	reads, lastReads    uint64
 	lastReadPercent     int
+	latency             histogram
+	intervalLatency     intervalLatency
 
 	written, lastWritten uint64
 	lastWrittenPercent   int
@@ -50,18 +87,32 @@ func NewReadEnv(log io.Writer, kr io.Reader, kw io.Writer, resetKey func(), cfg
 		key:      make([]byte, cfg.KeySize),
 		value:    make([]byte, cfg.DataSize),
 		keych:    make(chan [][]byte, 100),
+		ioStart:  -1,
 	}
 }
 
+// Config returns the configuration of the environment.
+func (env *ReadEnv) Config() ReadConfig {
+	return env.cfg
+}
+
+// Record enables recording of all generated operations to a trace file.
+// It must be called before Run.
+func (env *ReadEnv) Record(w io.Writer) {
+	env.traceOut = w
+}
+
 // Run calls write repeatedly with random keys and values.
 // The write function should perform a database write and call LegacyWriteProgress when
 // data has actually been flushed to disk.
 func (env *ReadEnv) Run(write func(key, value string, lastCall bool) error, read func(key string) error) error {
-	env.start()
+	if err := env.start(); err != nil {
+		return err
+	}
+	defer env.finish()
 
 	var (
 		err      error
-		keypool  [][]byte
 		wg       sync.WaitGroup
 		shutdown = make(chan struct{})
 		result   = make(chan [][]byte, 100)
@@ -72,47 +123,32 @@ func (env *ReadEnv) Run(write func(key, value string, lastCall bool) error, read
 	}()
 
 	// Stage one, construct the test dataset
-	if env.kw != nil {
-		wg.Add(1)
-		go env.writeKey(&wg)
-	stageOne:
-		for {
-			env.rand.Read(env.key)
-			env.rand.Read(env.value)
-
-			env.written += env.cfg.DataSize
-			end := env.written >= env.cfg.Size
-			err = write(string(env.key), string(env.value), end)
-			if err != nil || end {
-				if err == nil {
-					keypool = append(keypool, copyBytes(env.key))
-				}
-				if len(keypool) > 0 {
-					env.keych <- keypool
-				}
-				close(env.keych)
-				break stageOne
-			}
-			keypool = append(keypool, copyBytes(env.key))
-			if len(keypool) > 1024 {
-				env.keych <- keypool
-				keypool = make([][]byte, 0)
-			}
-			env.logWritePercentage()
-		}
-		if err != nil {
-			return err
-		}
+	if err := env.fill(write); err != nil {
+		return err
 	}
+	env.markStorageRead()
 
 	// Stage two, read bench
+	env.mu.Lock()
+	env.lastTime = mononow()
+	env.mu.Unlock()
 	wg.Add(1)
 	go env.readKey(result, shutdown, &wg)
 
 stageTwo:
 	for keybatch := range result {
 		for _, key := range keybatch {
+			env.record(TraceGet, key, 0)
+			begin := mononow()
 			err = read(string(key))
+			d := mononow() - begin
+			env.latency.add(d)
+			env.intervalLatency.add(d)
+			env.latLog.add(begin-env.startTime, "get", d)
+			env.cfg.Live.latency(d)
+			if err == nil {
+				err = env.detectors.err()
+			}
 			if err != nil {
 				break stageTwo
 			}
@@ -124,6 +160,115 @@ stageTwo:
 	return nil
 }
 
+// RunScan constructs the test dataset like Run, then calls scan once. The scan
+// function should iterate the database and call Progress.
+func (env *ReadEnv) RunScan(write func(key, value string, lastCall bool) error, scan func() error) error {
+	if err := env.start(); err != nil {
+		return err
+	}
+	defer env.finish()
+
+	if err := env.fill(write); err != nil {
+		return err
+	}
+	env.markStorageRead()
+	// The amount of data scanned isn't known up front.
+	env.cfg.LogPercent = false
+	env.mu.Lock()
+	env.lastTime = mononow()
+	env.mu.Unlock()
+	return scan()
+}
+
+// StorageReadFunc sets the function returning the number of bytes the database
+// has read from storage. The amount read during the read phase is logged in the
+// result.
+func (env *ReadEnv) StorageReadFunc(fn func() (uint64, error)) {
+	env.ioFn = fn
+}
+
+// StorageLevelReadsFunc sets the function returning the number of bytes the
+// database has read from the tables of each level. The amounts read during the
+// read phase are logged in the result.
+func (env *ReadEnv) StorageLevelReadsFunc(fn func() ([]uint64, error)) {
+	env.levelsFn = fn
+}
+
+// markStorageRead records the storage reads at the start of the read phase.
+func (env *ReadEnv) markStorageRead() {
+	env.ioStart = env.storageRead()
+	env.levelStart = env.levelReads()
+}
+
+// levelReads returns the bytes read from storage per level, or nil if unknown.
+func (env *ReadEnv) levelReads() []uint64 {
+	if env.levelsFn == nil {
+		return nil
+	}
+	reads, err := env.levelsFn()
+	if err != nil {
+		log.Printf("can't get storage reads per level: %v", err)
+		return nil
+	}
+	return reads
+}
+
+// storageRead returns the bytes read from storage, or -1 if unknown.
+func (env *ReadEnv) storageRead() int64 {
+	if env.ioFn == nil {
+		return -1
+	}
+	n, err := env.ioFn()
+	if err != nil {
+		log.Printf("can't get storage reads: %v", err)
+		return -1
+	}
+	return int64(n)
+}
+
+// fill writes the test dataset if the environment has a key writer.
+func (env *ReadEnv) fill(write func(key, value string, lastCall bool) error) error {
+	if env.kw == nil {
+		return nil
+	}
+	var (
+		err     error
+		keypool [][]byte
+		wg      sync.WaitGroup
+	)
+	wg.Add(1)
+	go env.writeKey(&wg)
+	defer wg.Wait()
+	for {
+		env.rand.Read(env.key)
+		env.rand.Read(env.value)
+		env.record(TracePut, env.key, env.cfg.DataSize)
+
+		env.written += env.cfg.DataSize
+		end := env.written >= env.cfg.Size
+		err = write(string(env.key), string(env.value), end)
+		if err == nil {
+			err = env.detectors.err()
+		}
+		if err != nil || end {
+			if err == nil {
+				keypool = append(keypool, copyBytes(env.key))
+			}
+			if len(keypool) > 0 {
+				env.keych <- keypool
+			}
+			close(env.keych)
+			return err
+		}
+		keypool = append(keypool, copyBytes(env.key))
+		if len(keypool) > 1024 {
+			env.keych <- keypool
+			keypool = make([][]byte, 0)
+		}
+		env.logWritePercentage()
+	}
+}
+
 func (env *ReadEnv) writeKey(wg *sync.WaitGroup) {
 	defer wg.Done()
 
@@ -167,10 +312,109 @@ func (env *ReadEnv) readKey(result chan [][]byte, shutdown chan struct{}, wg *sy
 	}
 }
 
-func (env *ReadEnv) start() {
-	env.rand = rand.New(rand.NewSource(0x1334))
+func (env *ReadEnv) start() (err error) {
+	if env.rand, err = NewEntropy(env.cfg.Entropy, generatorSeed); err != nil {
+		return err
+	}
+	if env.traceOut != nil {
+		header := TraceHeader{Entropy: env.cfg.Entropy, Seed: generatorSeed}
+		if env.trace, err = NewTraceWriter(env.traceOut, header); err != nil {
+			return err
+		}
+	}
+	if env.latOut != nil {
+		if env.latLog, err = newLatencyLog(env.latOut); err != nil {
+			return err
+		}
+	}
+	if env.csvOut != nil && env.csv == nil {
+		// Later runs of the test continue the table.
+		if env.csv, err = newProgressCSV(env.csvOut); err != nil {
+			return err
+		}
+	}
+	if env.influxOut != nil {
+		env.influx = newProgressInflux(env.influxOut, env.cfg.TestName, env.cfg.Tags, time.Now())
+	}
+	header := LogHeader{Test: env.cfg.TestName, Tags: env.cfg.Tags, TimerOverhead: TimerOverhead()}
+	header.System, header.Config = systemHeader(env.dbDir, env.cfg)
+	if err := writeHeader(env.log, header); err != nil {
+		return err
+	}
+	if err := env.writePendingOptions(); err != nil {
+		return err
+	}
+	if env.detectors, err = newDetectorSet(env.cfg.Detectors, env.cfg.Watchdog, env.cfg.TestName, env.log); err != nil {
+		return err
+	}
+	env.gcStart = readGC()
+	env.device.begin(env.dbDir)
 	env.startTime = mononow()
 	env.lastTime = env.startTime
+	env.detectors.startMemory(&env.mu, func() (time.Duration, uint64) {
+		return mononow() - env.startTime, env.read
+	})
+	env.cfg.Live.reset(env.cfg.TestName, env.cfg.Tags, env.cfg.Size)
+	env.stats.start(&env.mu, func() time.Duration { return mononow() - env.startTime }, func(c CompactionStats) {
+		writeCompactionStats(env.log, c)
+		env.cfg.Live.compaction(c)
+	})
+	return nil
+}
+
+// Unsupported logs a run that can't be performed with the given configuration,
+// so it shows up in reports.
+func (env *ReadEnv) Unsupported(reason string) error {
+	if err := writeHeader(env.log, LogHeader{Test: env.cfg.TestName, Tags: env.cfg.Tags}); err != nil {
+		return err
+	}
+	return writeResult(env.log, RunResult{Unsupported: reason})
+}
+
+func (env *ReadEnv) finish() {
+	env.flushProgress()
+	env.stats.stopSampling()
+	env.detectors.stopMemory()
+	result := RunResult{Reads: env.reads, ReadBytes: env.read, Latency: env.latency.percentiles(), GC: readGC().since(env.gcStart)}
+	result.Device = env.device.since()
+	if env.ioStart >= 0 {
+		if end := env.storageRead(); end >= env.ioStart {
+			result.StorageRead = uint64(end - env.ioStart)
+		}
+	}
+	if env.levelStart != nil {
+		result.StorageReadLevels = env.levelReads()
+		for i := range result.StorageReadLevels {
+			// Tables compacted away during the read phase are no longer
+			// counted, which can make a level shrink.
+			if i < len(env.levelStart) {
+				if start := env.levelStart[i]; start < result.StorageReadLevels[i] {
+					result.StorageReadLevels[i] -= start
+				} else {
+					result.StorageReadLevels[i] = 0
+				}
+			}
+		}
+	}
+	if env.trace != nil {
+		if err := env.trace.Flush(); err != nil {
+			result.Error = fmt.Sprintf("can't write trace: %v", err)
+		}
+	}
+	writeResult(env.log, result)
+	if err := env.latLog.close(); err != nil {
+		log.Printf("can't write latency log: %v", err)
+	}
+	env.latLog = nil
+	if err := env.csv.flush(); err != nil {
+		log.Printf("can't write progress table: %v", err)
+	}
+}
+
+func (env *ReadEnv) record(op TraceOp, key []byte, valueSize uint64) {
+	if env.trace != nil {
+		env.trace.Write(TraceEvent{Op: op, Time: mononow() - env.startTime, Key: key, ValueSize: valueSize})
+	}
 }
 
 // Progress writes a JSON progress event to the environment's output writer.
@@ -179,14 +423,49 @@ func (env *ReadEnv) Progress(w int) {
 	env.mu.Lock()
 	defer env.mu.Unlock()
 	env.read += uint64(w)
-	d := now - env.lastTime
-	dw := env.read - env.lastRead
-	if dw > 0 && dw > emitInterval {
-		p := Progress{Processed: env.read, Delta: dw, Duration: d}
-		env.log.Encode(&p)
-		env.logReadPercentage()
-		env.lastTime = now
-		env.lastRead = env.read
+	env.reads++
+	if env.cfg.ReportInterval.due(env.read-env.lastRead, now-env.lastTime) {
+		env.logProgress(now)
+	}
+}
+
+// logProgress writes a progress event covering the reads since the previous
+// one. It must be called with mu held.
+func (env *ReadEnv) logProgress(now time.Duration) {
+	n := env.reads - env.lastReads
+	p := Progress{
+		Processed: env.read,
+		Delta:     env.read - env.lastRead,
+		Duration:  now - env.lastTime,
+		Time:      now - env.startTime,
+		Entries:   n,
+		Commits:   n,
+		P99:       env.intervalLatency.p99(),
+	}
+	writeProgress(env.log, &p)
+	env.detectors.observe(Metric{Time: now - env.startTime, Offset: env.read, Progress: &p})
+	env.cfg.Live.progress(p, env.reads, env.reads)
+	env.csv.add(p)
+	if err := env.influx.add(p); err != nil {
+		log.Printf("can't write influx progress sample: %v", err)
+		env.influx = nil
+	}
+	if env.cfg.SampleMemory {
+		sampleMemory(env.log, now-env.startTime, env.read)
+	}
+	env.logReadPercentage()
+	env.lastTime = now
+	env.lastRead = env.read
+	env.lastReads = env.reads
+}
+
+// flushProgress logs the reads after the last progress event. This only
+// happens for time intervals, where the tail of the run can be long.
+func (env *ReadEnv) flushProgress() {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	if env.cfg.ReportInterval.Time > 0 && env.read > env.lastRead {
+		env.logProgress(mononow())
 	}
 }
 
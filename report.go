@@ -2,6 +2,8 @@ package bench
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"os"
@@ -13,9 +15,221 @@ import (
 )
 
 type Progress struct {
-	Processed uint64        `json:"processed"` // total bytes read or written so far
-	Delta     uint64        `json:"delta"`     // bytes written since last event
-	Duration  time.Duration `json:"duration"`  // time in ns since last event
+	Processed uint64        `json:"processed"`      // total bytes read or written so far
+	Delta     uint64        `json:"delta"`          // bytes written since last event
+	Duration  time.Duration `json:"duration"`       // time in ns since last event
+	Time      time.Duration `json:"time,omitempty"` // time in ns since the start of the run
+
+	// Operation counts since last event. An entry is a single key/value pair,
+	// a commit is a single database write, which may contain many entries.
+	Entries uint64 `json:"entries,omitempty"`
+	Commits uint64 `json:"commits,omitempty"`
+
+	// P99 is the 99th percentile latency of operations since last event.
+	P99 time.Duration `json:"p99,omitempty"`
+}
+
+// LogHeader is the first entry of a test log.
+type LogHeader struct {
+	Test string `json:"test"`
+	Tags Tags   `json:"tags,omitempty"`
+
+	// TimerOverhead is the calibrated cost of timing one operation.
+	TimerOverhead time.Duration `json:"timeroverhead,omitempty"`
+
+	// Encoder is the name of the key/value encoder of the run.
+	Encoder string `json:"encoder,omitempty"`
+
+	// System describes the machine, Config is the WriteConfig or ReadConfig
+	// of the run.
+	System *SystemInfo     `json:"system,omitempty"`
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+// RunResult is the last entry of a test log.
+type RunResult struct {
+	Ops        uint64 `json:"ops"`        // number of generated write operations
+	UniqueKeys uint64 `json:"uniquekeys"` // estimated unless keys are guaranteed unique
+
+	// Key count verification results.
+	CountedKeys    uint64 `json:"countedkeys,omitempty"`
+	KeyDiscrepancy int64  `json:"keydiscrepancy,omitempty"` // counted - expected
+
+	// Value verification results.
+	Verified   uint64 `json:"verified,omitempty"`
+	Mismatches uint64 `json:"mismatches,omitempty"`
+	Missing    uint64 `json:"missing,omitempty"`
+
+	Deletes uint64 `json:"deletes,omitempty"` // number of delete operations
+
+	// Read test results. StorageRead is the amount of data read from storage
+	// during the read phase, which grows with block cache misses.
+	// StorageReadLevels splits the table reads by level.
+	Reads             uint64   `json:"reads,omitempty"`
+	ReadBytes         uint64   `json:"readbytes,omitempty"` // bytes returned by reads
+	StorageRead       uint64   `json:"storageread,omitempty"`
+	StorageReadLevels []uint64 `json:"storagereadlevels,omitempty"`
+
+	// Logical bytes written and the physical size of the database at the end.
+	PutBytes uint64 `json:"putbytes,omitempty"` // key and value bytes of all writes
+	DiskSize uint64 `json:"disksize,omitempty"` // zero if unknown
+
+	// StorageWrites are the bytes written to storage during the run.
+	StorageWrites *StorageIO `json:"storagewrites,omitempty"`
+
+	// Device is the I/O of the block device holding the database during the
+	// run, nil if unknown.
+	Device *DeviceIO `json:"device,omitempty"`
+
+	Stalls []StallBucket `json:"stalls,omitempty"` // stall histogram
+
+	Latency *LatencyPercentiles `json:"latency,omitempty"` // duration of single calls of the operation function
+
+	GC *GCStats `json:"gc,omitempty"` // garbage collections during the run
+
+	Durable *LatencyStats `json:"durable,omitempty"` // latency until writes were synced
+
+	Acks *AckResult `json:"acks,omitempty"` // acknowledgement check of concurrent writers
+
+	Unsupported string `json:"unsupported,omitempty"` // reason why the run was skipped
+	Error       string `json:"error,omitempty"`       // error that ended the run early
+}
+
+// LogSchema is the version of the test log format. Test logs are
+// newline-delimited JSON. Every line is an event object with its type in the
+// "event" field and the event data under a key named after the type:
+//
+//	run-start   first line, "header" is the LogHeader, "schema" the version
+//	progress    Progress fields at the top level
+//	stall       "stall": Stall
+//	compaction  "compaction": CompactionStats
+//	disk        "disk": DiskUsage
+//	memory      "memory": MemoryUsage
+//	window      "window": Window
+//	annotation  "annotation": Annotation
+//	options     "options": database options
+//	run-end     last line, "result" is the RunResult
+//
+// Logs written before versioning (schema 0) have the same data keys but no
+// event types. Readers skip events of unknown type. The version is increased
+// when existing events change incompatibly.
+const LogSchema = 1
+
+// Event types of the test log.
+const (
+	eventRunStart   = "run-start"
+	eventProgress   = "progress"
+	eventStall      = "stall"
+	eventCompaction = "compaction"
+	eventDisk       = "disk"
+	eventMemory     = "memory"
+	eventWindow     = "window"
+	eventAnnotation = "annotation"
+	eventOptions    = "options"
+	eventRunEnd     = "run-end"
+)
+
+// logEntry is a line in a test log. Lines are either progress events, the
+// header or the result.
+type logEntry struct {
+	Event  string `json:"event,omitempty"`
+	Schema int    `json:"schema,omitempty"`
+
+	Progress
+	Header *LogHeader `json:"header,omitempty"`
+	Result *RunResult `json:"result,omitempty"`
+	Stall  *Stall     `json:"stall,omitempty"`
+	Disk   *DiskUsage `json:"disk,omitempty"`
+	Window *Window    `json:"window,omitempty"`
+
+	Memory *MemoryUsage `json:"memory,omitempty"`
+
+	Compaction *CompactionStats `json:"compaction,omitempty"`
+
+	Annotation *Annotation `json:"annotation,omitempty"`
+
+	Options json.RawMessage `json:"options,omitempty"`
+}
+
+// writeHeader writes the log header.
+func writeHeader(enc *json.Encoder, h LogHeader) error {
+	return enc.Encode(struct {
+		Event  string    `json:"event"`
+		Schema int       `json:"schema"`
+		Header LogHeader `json:"header"`
+	}{eventRunStart, LogSchema, h})
+}
+
+// writeProgress writes a progress event.
+func writeProgress(enc *json.Encoder, p *Progress) error {
+	return enc.Encode(struct {
+		Event string `json:"event"`
+		*Progress
+	}{eventProgress, p})
+}
+
+// writeResult writes the result entry.
+func writeResult(enc *json.Encoder, r RunResult) error {
+	return enc.Encode(struct {
+		Event  string    `json:"event"`
+		Result RunResult `json:"result"`
+	}{eventRunEnd, r})
+}
+
+// writeStall writes a stall event.
+func writeStall(enc *json.Encoder, s Stall) error {
+	return enc.Encode(struct {
+		Event string `json:"event"`
+		Stall Stall  `json:"stall"`
+	}{eventStall, s})
+}
+
+// writeDiskUsage writes a disk usage sample.
+func writeDiskUsage(enc *json.Encoder, d DiskUsage) error {
+	return enc.Encode(struct {
+		Event string    `json:"event"`
+		Disk  DiskUsage `json:"disk"`
+	}{eventDisk, d})
+}
+
+// writeMemoryUsage writes a memory usage sample.
+func writeMemoryUsage(enc *json.Encoder, m MemoryUsage) error {
+	return enc.Encode(struct {
+		Event  string      `json:"event"`
+		Memory MemoryUsage `json:"memory"`
+	}{eventMemory, m})
+}
+
+// writeCompactionStats writes a sample of the compaction statistics.
+func writeCompactionStats(enc *json.Encoder, c CompactionStats) error {
+	return enc.Encode(struct {
+		Event      string          `json:"event"`
+		Compaction CompactionStats `json:"compaction"`
+	}{eventCompaction, c})
+}
+
+// writeWindow writes a measurement window.
+func writeWindow(enc *json.Encoder, w Window) error {
+	return enc.Encode(struct {
+		Event  string `json:"event"`
+		Window Window `json:"window"`
+	}{eventWindow, w})
+}
+
+// writeAnnotation writes a detector annotation.
+func writeAnnotation(enc *json.Encoder, a Annotation) error {
+	return enc.Encode(struct {
+		Event      string     `json:"event"`
+		Annotation Annotation `json:"annotation"`
+	}{eventAnnotation, a})
+}
+
+// writeOptions writes the database options.
+func writeOptions(enc *json.Encoder, o interface{}) error {
+	return enc.Encode(struct {
+		Event   string      `json:"event"`
+		Options interface{} `json:"options"`
+	}{eventOptions, o})
 }
 
 // BPS returns the 'write/read speed' in bytes/s.
@@ -27,43 +241,141 @@ func mononow() time.Duration {
 	return time.Duration(monotime.Now())
 }
 
+// EntryLatency returns the amortized time per entry. It returns zero for events
+// without operation counts.
+func (ev Progress) EntryLatency() time.Duration {
+	if ev.Entries == 0 {
+		return 0
+	}
+	return ev.Duration / time.Duration(ev.Entries)
+}
+
+// CommitLatency returns the amortized time per commit. It returns zero for events
+// without operation counts.
+func (ev Progress) CommitLatency() time.Duration {
+	if ev.Commits == 0 {
+		return 0
+	}
+	return ev.Duration / time.Duration(ev.Commits)
+}
+
 // ReadProgress reads JSON progress events in a file.
 func ReadProgress(file string) ([]Progress, error) {
+	r, err := readLog(file)
+	if r == nil {
+		return nil, err
+	}
+	return r.Events, err
+}
+
+// ReadHeader reads the header of a test log. It fails for files which don't
+// start with a header, like other JSON files and logs of older versions.
+func ReadHeader(file string) (*LogHeader, error) {
 	fd, err := os.Open(file)
 	if err != nil {
 		return nil, err
 	}
-	var pp []Progress
-	dec := json.NewDecoder(fd)
+	defer fd.Close()
+	var e logEntry
+	if err := json.NewDecoder(fd).Decode(&e); err != nil {
+		return nil, fmt.Errorf("not a test log: %v", err)
+	}
+	if e.Event != eventRunStart || e.Header == nil {
+		return nil, errors.New("not a test log: no header")
+	}
+	return e.Header, nil
+}
+
+// readLog reads a test log. Logs written by older versions of the tool have no
+// header and result, these fields are nil in that case.
+func readLog(file string) (*Report, error) {
+	fd, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	return decodeLog(fd, strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)))
+}
+
+// decodeLog reads the test log of the named test from a stream.
+func decodeLog(log io.Reader, name string) (*Report, error) {
+	var (
+		r   = &Report{Name: name}
+		dec = json.NewDecoder(log)
+	)
 	for {
-		var p Progress
-		if err := dec.Decode(&p); err == io.EOF {
+		var e logEntry
+		if err := dec.Decode(&e); err == io.EOF {
 			break
 		} else if err != nil {
-			return pp, err
+			return r, err
+		}
+		switch {
+		case e.Schema > LogSchema:
+			return r, fmt.Errorf("log schema version %d is newer than supported version %d", e.Schema, LogSchema)
+		case e.Header != nil:
+			r.Header = e.Header
+			r.Tags = e.Header.Tags
+		case e.Result != nil:
+			r.Result = e.Result
+		case e.Stall != nil:
+			r.Stalls = append(r.Stalls, *e.Stall)
+		case e.Disk != nil:
+			r.Disk = append(r.Disk, *e.Disk)
+		case e.Window != nil:
+			r.Windows = append(r.Windows, *e.Window)
+		case e.Memory != nil:
+			r.Memory = append(r.Memory, *e.Memory)
+		case e.Compaction != nil:
+			r.Compactions = append(r.Compactions, *e.Compaction)
+		case e.Annotation != nil:
+			r.Annotations = append(r.Annotations, *e.Annotation)
+		case e.Options != nil:
+			r.Options = e.Options
+		case e.Event == "" || e.Event == eventProgress:
+			r.Events = append(r.Events, e.Progress)
 		}
-		pp = append(pp, p)
 	}
-	return pp, nil
+	return r, nil
 }
 
 type Report struct {
-	Name   string
-	Events []Progress
+	Name    string
+	Tags    Tags
+	Header  *LogHeader
+	Result  *RunResult
+	Events  []Progress
+	Stalls  []Stall
+	Disk    []DiskUsage
+	Windows []Window
+	Memory  []MemoryUsage
+
+	Annotations []Annotation
+	Compactions []CompactionStats
+
+	// Options are the database options the run was started with, as logged by
+	// LogOptions. If the database was opened more than once, these are the
+	// options of the last open.
+	Options json.RawMessage
+}
+
+// Label returns the report name with tags appended.
+func (r Report) Label() string {
+	if len(r.Tags) == 0 {
+		return r.Name
+	}
+	return r.Name + " (" + r.Tags.String() + ")"
 }
 
 // MustReadReports reads all given progress event files.
 func MustReadReports(files []string) []Report {
 	var reports []Report
 	for _, file := range files {
-		p, err := ReadProgress(file)
+		r, err := readLog(file)
 		if err != nil {
 			log.Fatalf("%s: %v", file, err)
 		}
-		reports = append(reports, Report{
-			Events: p,
-			Name:   strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)),
-		})
+		reports = append(reports, *r)
 	}
 	return reports
 }
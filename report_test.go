@@ -0,0 +1,153 @@
+package bench
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadLogHeader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bench-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "test.json")
+	content := `{"header":{"test":"batch-100kb","tags":{"fs":"ext4","machine":"nuc1"}}}
+{"processed":512100,"delta":512100,"duration":118889143}
+{"processed":1024200,"delta":512100,"duration":108786231}
+`
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reports := MustReadReports([]string{file})
+	wantTags := Tags{"fs": "ext4", "machine": "nuc1"}
+	if !reflect.DeepEqual(reports[0].Tags, wantTags) {
+		t.Errorf("wrong tags %v", reports[0].Tags)
+	}
+	if len(reports[0].Events) != 2 {
+		t.Errorf("got %d events, want 2", len(reports[0].Events))
+	}
+	if label := reports[0].Label(); label != "test (fs=ext4 machine=nuc1)" {
+		t.Errorf("wrong label %q", label)
+	}
+}
+
+func TestReadLogOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bench-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "test.json")
+	content := `{"header":{"test":"nobatch"}}
+{"options":{"NoSync":false}}
+{"processed":512100,"delta":512100,"duration":118889143}
+{"options":{"NoSync":true}}
+`
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := MustReadReports([]string{file})[0]
+	if string(r.Options) != `{"NoSync":true}` {
+		t.Errorf("wrong options %s", r.Options)
+	}
+	if len(r.Events) != 1 {
+		t.Errorf("got %d events, want 1", len(r.Events))
+	}
+}
+
+func TestReadLogCompaction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bench-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "test.json")
+	content := `{"header":{"test":"nobatch"}}
+{"compaction":{"time":1000000000,"levels":[{"tables":2,"size":100,"read":0,"write":100,"duration":5},{"tables":1,"size":50,"read":150,"write":50,"duration":7}]}}
+{"processed":512100,"delta":512100,"duration":118889143}
+`
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := MustReadReports([]string{file})[0]
+	if len(r.Compactions) != 1 {
+		t.Fatalf("got %d compaction samples, want 1", len(r.Compactions))
+	}
+	read, write, d := r.Compactions[0].Totals()
+	if read != 150 || write != 150 || d != 12 {
+		t.Errorf("wrong totals: read %d, write %d, duration %d", read, write, d)
+	}
+}
+
+func TestPeakTables(t *testing.T) {
+	r := Report{Compactions: []CompactionStats{
+		{Time: 1 * time.Second, Levels: []LevelStats{{Tables: 2}, {Tables: 1}}},
+		{Time: 2 * time.Second, Levels: []LevelStats{{Tables: 9}, {Tables: 3}, {Tables: 1}}},
+		{Time: 3 * time.Second, Levels: []LevelStats{{Tables: 1}, {Tables: 5}, {Tables: 1}}},
+	}}
+	tables, l0 := r.PeakTables()
+	if !reflect.DeepEqual(tables, []int{9, 5, 1}) {
+		t.Errorf("wrong peak tables %v", tables)
+	}
+	if l0 != 2*time.Second {
+		t.Errorf("wrong level 0 peak time %v", l0)
+	}
+}
+
+func TestLogSchema(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bench-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	writeHeader(enc, LogHeader{Test: "nobatch"})
+	writeProgress(enc, &Progress{Processed: 10, Delta: 10, Duration: time.Second})
+	writeStall(enc, Stall{Duration: time.Millisecond})
+	buf.WriteString(`{"event":"future-event","future":{"processed":1}}` + "\n")
+	writeResult(enc, RunResult{Ops: 1})
+	want := []string{
+		`{"event":"run-start","schema":1,"header":{"test":"nobatch"}}`,
+		`{"event":"progress","processed":10,"delta":10,"duration":1000000000}`,
+	}
+	lines := strings.Split(buf.String(), "\n")
+	for i, w := range want {
+		if lines[i] != w {
+			t.Errorf("line %d:\ngot  %s\nwant %s", i, lines[i], w)
+		}
+	}
+
+	file := filepath.Join(dir, "test.json")
+	if err := ioutil.WriteFile(file, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := readLog(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Events) != 1 || len(r.Stalls) != 1 || r.Result == nil || r.Result.Ops != 1 {
+		t.Errorf("wrong report %+v", r)
+	}
+
+	newer := fmt.Sprintf(`{"event":"run-start","schema":%d,"header":{"test":"nobatch"}}`, LogSchema+1)
+	if err := ioutil.WriteFile(file, []byte(newer+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readLog(file); err == nil {
+		t.Error("no error for newer schema version")
+	}
+}
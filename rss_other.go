@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package bench
+
+// readRSS is only supported on Linux.
+func readRSS() (uint64, bool) {
+	return 0, false
+}
@@ -26,3 +26,19 @@ func ParseSize(s string) (uint64, error) {
 	}
 	return v, nil
 }
+
+// FormatSize formats a size for humans, using the largest unit accepted by
+// ParseSize that keeps the value at or above one.
+func FormatSize(v uint64) string {
+	units := []struct {
+		name string
+		size uint64
+	}{{"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10}}
+	for _, u := range units {
+		if v >= u.size {
+			s := strconv.FormatFloat(float64(v)/float64(u.size), 'f', 1, 64)
+			return strings.TrimSuffix(s, ".0") + u.name
+		}
+	}
+	return strconv.FormatUint(v, 10) + "b"
+}
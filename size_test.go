@@ -28,3 +28,21 @@ func TestParseSize(t *testing.T) {
 		}
 	}
 }
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		v   uint64
+		out string
+	}{
+		{0, "0b"},
+		{82, "82b"},
+		{82 * 1024, "82kb"},
+		{1536 * 1024, "1.5mb"},
+		{20 * 1024 * 1024 * 1024, "20gb"},
+	}
+	for _, test := range tests {
+		if s := FormatSize(test.v); s != test.out {
+			t.Errorf("%d: got %q, want %q", test.v, s, test.out)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package bench
+
+import (
+	"fmt"
+	"time"
+)
+
+// Stall is a write operation that took longer than the configured threshold.
+type Stall struct {
+	Offset   uint64        `json:"offset"`   // bytes processed when the stall occurred
+	Duration time.Duration `json:"duration"` // duration of the operation
+}
+
+// StallBucket is a bucket of the stall histogram. It counts stalls with a
+// duration in [Min, 2*Min), except for the last bucket which has no upper bound.
+type StallBucket struct {
+	Min   time.Duration `json:"min"`
+	Count int           `json:"count"`
+}
+
+const stallBuckets = 8
+
+func (b StallBucket) String() string {
+	return fmt.Sprintf(">=%v: %d", b.Min, b.Count)
+}
+
+// stallHistogram collects stalls into power-of-two buckets above the threshold.
+type stallHistogram []StallBucket
+
+func newStallHistogram(threshold time.Duration) stallHistogram {
+	h := make(stallHistogram, stallBuckets)
+	for i := range h {
+		h[i].Min = threshold << uint(i)
+	}
+	return h
+}
+
+func (h stallHistogram) add(d time.Duration) {
+	for i := len(h) - 1; i >= 0; i-- {
+		if d >= h[i].Min {
+			h[i].Count++
+			return
+		}
+	}
+}
+
+// StallCount returns the number of stalls in the result's stall histogram.
+func (r *RunResult) StallCount() (n int) {
+	for _, b := range r.Stalls {
+		n += b.Count
+	}
+	return n
+}
+
+// opDone records the latency of an operation started at begin, and logs a stall
+// if a write took longer than the stall threshold. It may be called concurrently.
+func (env *WriteEnv) opDone(op string, begin time.Duration) {
+	d := mononow() - begin
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	env.latency.add(d)
+	env.intervalLatency.add(d)
+	env.latLog.add(begin-env.startTime, op, d)
+	env.cfg.Live.latency(d)
+	if env.cfg.StallThreshold <= 0 || d < env.cfg.StallThreshold || !isWrite(op) {
+		return
+	}
+	env.stalls.add(d)
+	env.cfg.Live.stall()
+	s := Stall{Offset: env.written, Duration: d}
+	writeStall(env.out, s)
+	env.detectors.observe(Metric{Time: mononow() - env.startTime, Offset: env.written, Stall: &s})
+}
+
+// isWrite reports whether op modifies the database. Reads are never stalls.
+func isWrite(op string) bool {
+	switch op {
+	case "read", "get", "scan":
+		return false
+	}
+	return true
+}
+
+// TimeWrites makes the test responsible for timing its writes. Run no longer
+// measures the write function, and the test reports every database write through
+// TimeWrite instead. Tests which hand writes to other goroutines need this, or
+// their latencies would only cover the handoff.
+func (env *WriteEnv) TimeWrites() {
+	env.selfTimed = true
+}
+
+// TimeWrite calls write, which should perform a single database write, and
+// records its latency. It may be called concurrently.
+func (env *WriteEnv) TimeWrite(write func() error) error {
+	begin := mononow()
+	err := write()
+	env.opDone("put", begin)
+	return err
+}
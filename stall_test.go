@@ -0,0 +1,78 @@
+package bench
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestStallHistogram(t *testing.T) {
+	h := newStallHistogram(100 * time.Millisecond)
+	for _, d := range []time.Duration{
+		100 * time.Millisecond,
+		199 * time.Millisecond,
+		200 * time.Millisecond,
+		time.Second,
+		time.Hour,
+	} {
+		h.add(d)
+	}
+	want := []int{2, 1, 0, 1, 0, 0, 0, 1}
+	for i, b := range h {
+		if b.Count != want[i] {
+			t.Errorf("bucket %v: got count %d, want %d", b.Min, b.Count, want[i])
+		}
+	}
+}
+
+func TestReadsAreNotStalls(t *testing.T) {
+	var (
+		out bytes.Buffer
+		cfg = WriteConfig{Size: 100000, KeySize: 16, DataSize: 100, StallThreshold: time.Nanosecond}
+		env = NewWriteEnv(&out, cfg)
+	)
+	err := env.RunWorkload(Workload{Read: 1}, WorkloadOps{
+		Put: func(key, value []byte) error { return nil },
+		Get: func(key []byte) ([]byte, error) { time.Sleep(time.Microsecond); return nil, nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := lastResult(t, &out).StallCount(); n != 0 {
+		t.Errorf("%d reads counted as stalls", n)
+	}
+}
+
+func TestTimeWrites(t *testing.T) {
+	var (
+		out   bytes.Buffer
+		cfg   = WriteConfig{Size: 10000, KeySize: 16, DataSize: 100, StallThreshold: time.Millisecond}
+		env   = NewWriteEnv(&out, cfg)
+		queue = make(chan string, 1)
+		done  = make(chan error)
+	)
+	go func() {
+		for range queue {
+			env.TimeWrite(func() error {
+				time.Sleep(2 * time.Millisecond)
+				return nil
+			})
+		}
+		done <- nil
+	}()
+	env.TimeWrites()
+	err := env.Run(func(key, value string, lastCall bool) error {
+		queue <- key
+		if lastCall {
+			close(queue)
+			return <-done
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, ops := lastResult(t, &out).StallCount(), 10000/100; n != ops {
+		t.Errorf("got %d stalls, want one for each of the %d writes", n, ops)
+	}
+}
@@ -0,0 +1,60 @@
+package bench
+
+import "fmt"
+
+// StorageIO counts bytes transferred to or from the files of a database by
+// file type.
+type StorageIO struct {
+	Journal  uint64 `json:"journal"`
+	Table    uint64 `json:"table"`
+	Manifest uint64 `json:"manifest"`
+	Other    uint64 `json:"other,omitempty"`
+}
+
+// Total returns the bytes of all file types.
+func (s StorageIO) Total() uint64 {
+	return s.Journal + s.Table + s.Manifest + s.Other
+}
+
+func (s StorageIO) sub(o StorageIO) StorageIO {
+	return StorageIO{
+		Journal:  s.Journal - o.Journal,
+		Table:    s.Table - o.Table,
+		Manifest: s.Manifest - o.Manifest,
+		Other:    s.Other - o.Other,
+	}
+}
+
+func (s StorageIO) String() string {
+	mb := func(n uint64) float64 { return float64(n) / 1024 / 1024 }
+	str := fmt.Sprintf("journal %.3f mb, tables %.3f mb, manifest %.3f mb", mb(s.Journal), mb(s.Table), mb(s.Manifest))
+	if s.Other > 0 {
+		str += fmt.Sprintf(", other %.3f mb", mb(s.Other))
+	}
+	return str
+}
+
+// StorageWriteFunc sets the function returning the bytes the database has
+// written to storage. The amount written during the run is logged in the
+// result.
+func (env *WriteEnv) StorageWriteFunc(fn func() StorageIO) {
+	env.writeIOFn = fn
+}
+
+// WriteAmplification returns the ratio of bytes written to storage to the key
+// and value bytes written. It returns zero if either is unknown.
+func (r *RunResult) WriteAmplification() float64 {
+	if r.StorageWrites == nil || r.PutBytes == 0 {
+		return 0
+	}
+	return float64(r.StorageWrites.Total()) / float64(r.PutBytes)
+}
+
+// ReadAmplification returns the ratio of bytes read from storage to the bytes
+// returned by reads. It returns zero if either is unknown.
+func (r *RunResult) ReadAmplification() float64 {
+	if r.StorageRead == 0 || r.ReadBytes == 0 {
+		return 0
+	}
+	return float64(r.StorageRead) / float64(r.ReadBytes)
+}
@@ -0,0 +1,47 @@
+package bench
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStorageWrites(t *testing.T) {
+	var (
+		out     bytes.Buffer
+		cfg     = WriteConfig{Size: 1000, KeySize: 8, DataSize: 100}
+		env     = NewWriteEnv(&out, cfg)
+		written = StorageIO{Journal: 500, Manifest: 10} // before the run
+	)
+	env.StorageWriteFunc(func() StorageIO { return written })
+	err := env.Run(func(key, value string, lastCall bool) error {
+		written.Journal += uint64(len(key) + len(value))
+		written.Table += 2 * uint64(len(key)+len(value))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := lastResult(t, &out)
+	if result.StorageWrites == nil {
+		t.Fatal("no storage writes in result")
+	}
+	want := StorageIO{Journal: 1080, Table: 2160}
+	if *result.StorageWrites != want {
+		t.Errorf("wrong storage writes %+v, want %+v", *result.StorageWrites, want)
+	}
+	if amp := result.WriteAmplification(); amp != 3 {
+		t.Errorf("wrong write amplification %v", amp)
+	}
+}
+
+func TestReadAmplification(t *testing.T) {
+	r := RunResult{Reads: 10, ReadBytes: 1000, StorageRead: 4500}
+	if amp := r.ReadAmplification(); amp != 4.5 {
+		t.Errorf("wrong read amplification %v", amp)
+	}
+	r.ReadBytes = 0
+	if amp := r.ReadAmplification(); amp != 0 {
+		t.Errorf("read amplification %v without read bytes", amp)
+	}
+}
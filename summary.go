@@ -0,0 +1,95 @@
+package bench
+
+import (
+	"math"
+	"time"
+
+	"github.com/gonum/stat"
+)
+
+// Summary contains aggregate statistics of a single report.
+type Summary struct {
+	Name      string
+	Tags      Tags
+	Events    int
+	TotalTime float64 // seconds
+	TotalSize uint64  // bytes
+	MeanBPS   float64
+	StdBPS    float64
+	Entries   uint64
+	Commits   uint64
+
+	TimerOverhead time.Duration // per operation, from the log header
+}
+
+// Summarize computes summary statistics of a report.
+func Summarize(r Report) Summary {
+	s := Summary{Name: r.Name, Tags: r.Tags, Events: len(r.Events)}
+	if r.Header != nil {
+		s.TimerOverhead = r.Header.TimerOverhead
+	}
+	var bps []float64
+	for _, ev := range r.Events {
+		bps = append(bps, ev.BPS())
+		s.TotalTime += float64(ev.Duration) / float64(time.Second)
+		s.TotalSize += ev.Delta
+		s.Entries += ev.Entries
+		s.Commits += ev.Commits
+	}
+	s.MeanBPS, s.StdBPS = stat.MeanStdDev(bps, nil)
+	return s
+}
+
+// BPS returns the overall throughput of the run in bytes/s.
+func (s Summary) BPS() float64 {
+	return float64(s.TotalSize) / s.TotalTime
+}
+
+// EntryLatency returns the amortized time per entry.
+func (s Summary) EntryLatency() time.Duration {
+	if s.Entries == 0 {
+		return 0
+	}
+	return time.Duration(s.TotalTime * float64(time.Second) / float64(s.Entries))
+}
+
+// CommitLatency returns the amortized time per commit.
+func (s Summary) CommitLatency() time.Duration {
+	if s.Commits == 0 {
+		return 0
+	}
+	return time.Duration(s.TotalTime * float64(time.Second) / float64(s.Commits))
+}
+
+// CorrectedEntryLatency returns the amortized time per entry minus the timer
+// overhead, which the harness incurs about once for every entry.
+func (s Summary) CorrectedEntryLatency() time.Duration {
+	l := s.EntryLatency() - s.TimerOverhead
+	if l < 0 {
+		return 0
+	}
+	return l
+}
+
+// Outliers reports which values deviate from the others by more than z
+// standard deviations. Each value is compared against the mean and standard
+// deviation of the remaining values, so a single outlier can't hide itself by
+// inflating the deviation. At least three values are required.
+func Outliers(values []float64, z float64) []bool {
+	out := make([]bool, len(values))
+	if len(values) < 3 {
+		return out
+	}
+	rest := make([]float64, 0, len(values)-1)
+	for i, v := range values {
+		rest = append(rest[:0], values[:i]...)
+		rest = append(rest, values[i+1:]...)
+		mean, std := stat.MeanStdDev(rest, nil)
+		if std == 0 {
+			out[i] = v != mean
+			continue
+		}
+		out[i] = math.Abs(v-mean)/std > z
+	}
+	return out
+}
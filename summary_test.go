@@ -0,0 +1,36 @@
+package bench
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOutliers(t *testing.T) {
+	tests := []struct {
+		values []float64
+		want   []bool
+	}{
+		{[]float64{10, 50}, []bool{false, false}},
+		{[]float64{100, 101, 99, 100}, []bool{false, false, false, false}},
+		{[]float64{100, 102, 98, 101, 60}, []bool{false, false, false, false, true}},
+		{[]float64{5, 5, 5, 9}, []bool{false, false, false, true}},
+	}
+	for _, test := range tests {
+		got := Outliers(test.values, 3)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %v, want %v", test.values, got, test.want)
+		}
+	}
+}
+
+func TestCorrectedEntryLatency(t *testing.T) {
+	s := Summary{TotalTime: 1, Entries: 1000, TimerOverhead: 100 * time.Nanosecond}
+	if l := s.CorrectedEntryLatency(); l != 999900*time.Nanosecond {
+		t.Errorf("wrong corrected latency %v", l)
+	}
+	s.TimerOverhead = time.Second
+	if l := s.CorrectedEntryLatency(); l != 0 {
+		t.Errorf("corrected latency %v should be clamped to zero", l)
+	}
+}
@@ -0,0 +1,85 @@
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"runtime"
+	"sync"
+)
+
+// SystemInfo describes the machine a test ran on.
+type SystemInfo struct {
+	GoVersion  string `json:"goversion"`
+	OS         string `json:"os"`
+	Arch       string `json:"arch"`
+	CPU        string `json:"cpu,omitempty"` // model name
+	Cores      int    `json:"cores"`
+	Memory     uint64 `json:"memory,omitempty"` // total RAM in bytes
+	Kernel     string `json:"kernel,omitempty"`
+	Filesystem string `json:"filesystem,omitempty"` // of the database directory
+}
+
+func (si SystemInfo) String() string {
+	s := fmt.Sprintf("%s %s/%s, %d cores", si.GoVersion, si.OS, si.Arch, si.Cores)
+	if si.CPU != "" {
+		s += " " + si.CPU
+	}
+	if si.Memory > 0 {
+		s += fmt.Sprintf(", %.1f gb RAM", float64(si.Memory)/1024/1024/1024)
+	}
+	if si.Kernel != "" {
+		s += ", kernel " + si.Kernel
+	}
+	if si.Filesystem != "" {
+		s += ", " + si.Filesystem
+	}
+	return s
+}
+
+var (
+	sysInfoOnce sync.Once
+	sysInfo     SystemInfo
+)
+
+// CollectSystemInfo returns information about the machine and the filesystem
+// holding dir. Information that isn't available on the platform is left empty.
+func CollectSystemInfo(dir string) SystemInfo {
+	sysInfoOnce.Do(func() {
+		sysInfo = SystemInfo{
+			GoVersion: runtime.Version(),
+			OS:        runtime.GOOS,
+			Arch:      runtime.GOARCH,
+			Cores:     runtime.NumCPU(),
+		}
+		sysInfo.CPU, sysInfo.Memory, sysInfo.Kernel = platformInfo()
+	})
+	si := sysInfo
+	if dir != "" {
+		si.Filesystem = filesystemType(dir)
+	}
+	return si
+}
+
+// systemHeader returns the system information and configuration for the log
+// header.
+func systemHeader(dir string, cfg interface{}) (*SystemInfo, json.RawMessage) {
+	si := CollectSystemInfo(dir)
+	enc, err := json.Marshal(cfg)
+	if err != nil {
+		log.Printf("can't encode config: %v", err)
+	}
+	return &si, enc
+}
+
+// DatabaseDir sets the database directory of the run. Its filesystem is
+// recorded in the log header.
+func (env *WriteEnv) DatabaseDir(dir string) {
+	env.dbDir = dir
+}
+
+// DatabaseDir sets the database directory of the run. Its filesystem is
+// recorded in the log header.
+func (env *ReadEnv) DatabaseDir(dir string) {
+	env.dbDir = dir
+}
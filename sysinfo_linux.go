@@ -0,0 +1,77 @@
+package bench
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// platformInfo reads the CPU model, RAM size and kernel version from /proc.
+func platformInfo() (cpu string, mem uint64, kernel string) {
+	if f, err := os.Open("/proc/cpuinfo"); err == nil {
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			if k, v, ok := procField(s.Text()); ok && k == "model name" {
+				cpu = v
+				break
+			}
+		}
+		f.Close()
+	}
+	if f, err := os.Open("/proc/meminfo"); err == nil {
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			if k, v, ok := procField(s.Text()); ok && k == "MemTotal" {
+				kb, _ := strconv.ParseUint(strings.TrimSuffix(v, " kB"), 10, 64)
+				mem = kb * 1024
+				break
+			}
+		}
+		f.Close()
+	}
+	if data, err := ioutil.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		kernel = strings.TrimSpace(string(data))
+	}
+	return cpu, mem, kernel
+}
+
+// procField splits a "key: value" line of a /proc file.
+func procField(line string) (key, value string, ok bool) {
+	i := strings.IndexByte(line, ':')
+	if i < 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:]), true
+}
+
+// filesystemType returns the type of the filesystem mounted closest above dir.
+func filesystemType(dir string) string {
+	path, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	if p, err := filepath.EvalSymlinks(path); err == nil {
+		path = p
+	}
+	f, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	var best, fstype string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		mp := fields[1]
+		if len(mp) >= len(best) && (path == mp || strings.HasPrefix(path, strings.TrimSuffix(mp, "/")+"/")) {
+			best, fstype = mp, fields[2]
+		}
+	}
+	return fstype
+}
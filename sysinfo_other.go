@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package bench
+
+// platformInfo is only supported on Linux.
+func platformInfo() (cpu string, mem uint64, kernel string) {
+	return "", 0, ""
+}
+
+// filesystemType is only supported on Linux.
+func filesystemType(dir string) string {
+	return ""
+}
@@ -0,0 +1,50 @@
+package bench
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"testing"
+)
+
+func TestHeaderSystemInfo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bench-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		out bytes.Buffer
+		cfg = WriteConfig{Size: 1000, KeySize: 8, DataSize: 100, Tags: Tags{"fs": "test"}}
+		env = NewWriteEnv(&out, cfg)
+	)
+	env.DatabaseDir(dir)
+	err = env.Run(func(key, value string, lastCall bool) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := testReport(t, &out).Header
+	if h == nil || h.System == nil {
+		t.Fatal("no system info in header")
+	}
+	si := h.System
+	if si.GoVersion != runtime.Version() || si.OS != runtime.GOOS || si.Cores != runtime.NumCPU() {
+		t.Errorf("wrong system info %+v", si)
+	}
+	if runtime.GOOS == "linux" && si.Filesystem == "" {
+		t.Error("filesystem type missing")
+	}
+	var logged WriteConfig
+	if err := json.Unmarshal(h.Config, &logged); err != nil {
+		t.Fatal(err)
+	}
+	if logged.Size != cfg.Size || logged.DataSize != cfg.DataSize || logged.Tags["fs"] != "test" {
+		t.Errorf("wrong config %+v", logged)
+	}
+}
@@ -0,0 +1,35 @@
+package bench
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Tags are key=value labels attached to a test run. Tags implements flag.Value,
+// every use of the flag adds a tag.
+type Tags map[string]string
+
+func (t Tags) String() string {
+	keys := make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		keys[i] = k + "=" + t[k]
+	}
+	return strings.Join(keys, " ")
+}
+
+func (t *Tags) Set(s string) error {
+	eq := strings.IndexByte(s, '=')
+	if eq < 1 {
+		return fmt.Errorf("invalid tag %q, want key=value", s)
+	}
+	if *t == nil {
+		*t = make(Tags)
+	}
+	(*t)[s[:eq]] = s[eq+1:]
+	return nil
+}
@@ -0,0 +1,41 @@
+package bench
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	calibrationRounds = 20
+	calibrationCalls  = 1000
+)
+
+var (
+	calibrateOnce sync.Once
+	timerOverhead time.Duration
+)
+
+// TimerOverhead returns the cost of timing a single operation, i.e. of the two
+// clock reads before and after it. The value is calibrated on first use and
+// is the minimum over several rounds, so scheduling noise doesn't inflate it.
+func TimerOverhead() time.Duration {
+	calibrateOnce.Do(func() {
+		timerOverhead = calibrateTimer()
+	})
+	return timerOverhead
+}
+
+func calibrateTimer() time.Duration {
+	best := time.Duration(-1)
+	for r := 0; r < calibrationRounds; r++ {
+		start := mononow()
+		for i := 0; i < calibrationCalls; i++ {
+			mononow()
+		}
+		d := (mononow() - start) / calibrationCalls
+		if best < 0 || d < best {
+			best = d
+		}
+	}
+	return 2 * best
+}
@@ -0,0 +1,185 @@
+package bench
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// generatorSeed is the seed of the random source used for key/value generation.
+const generatorSeed = 0x1334
+
+const (
+	traceMagic   = "ldbtrace"
+	traceVersion = 1
+)
+
+// TraceOp is the type of a recorded operation.
+type TraceOp byte
+
+const (
+	TracePut TraceOp = iota + 1
+	TraceGet
+)
+
+func (op TraceOp) String() string {
+	switch op {
+	case TracePut:
+		return "put"
+	case TraceGet:
+		return "get"
+	default:
+		return fmt.Sprintf("op(%d)", byte(op))
+	}
+}
+
+// TraceHeader describes the generator that produced a trace. Values are not
+// stored in the trace, they can be regenerated from the entropy source.
+//
+// If ChunkSize is non-zero, the trace was generated by the generator pool and
+// the entropy source is reseeded with Seed+i for every chunk i of ChunkSize
+// operations.
+type TraceHeader struct {
+	Entropy   string
+	Seed      int64
+	ChunkSize uint64
+}
+
+// TraceEvent is a single recorded operation.
+type TraceEvent struct {
+	Op        TraceOp
+	Time      time.Duration // time since start of the run
+	Key       []byte
+	ValueSize uint64
+}
+
+// TraceWriter writes operations to a trace file.
+//
+// The trace format is a header followed by one record per operation. Each record
+// is the op byte, the time delta to the previous record, the key and the value
+// size, all integers encoded as uvarint.
+type TraceWriter struct {
+	w    *bufio.Writer
+	last time.Duration
+	buf  [binary.MaxVarintLen64]byte
+}
+
+// NewTraceWriter writes the trace header to w.
+func NewTraceWriter(w io.Writer, h TraceHeader) (*TraceWriter, error) {
+	t := &TraceWriter{w: bufio.NewWriter(w)}
+	t.w.WriteString(traceMagic)
+	t.w.WriteByte(traceVersion)
+	t.uvarint(uint64(len(h.Entropy)))
+	t.w.WriteString(h.Entropy)
+	n := binary.PutVarint(t.buf[:], h.Seed)
+	t.w.Write(t.buf[:n])
+	err := t.uvarint(h.ChunkSize)
+	return t, err
+}
+
+// Write adds an event to the trace. Errors are sticky: after a failed write, all
+// further calls of Write and Flush return the error.
+func (t *TraceWriter) Write(ev TraceEvent) error {
+	delta := ev.Time - t.last
+	if delta < 0 {
+		delta = 0
+	}
+	t.last += delta
+	t.w.WriteByte(byte(ev.Op))
+	t.uvarint(uint64(delta))
+	t.uvarint(uint64(len(ev.Key)))
+	t.w.Write(ev.Key)
+	return t.uvarint(ev.ValueSize)
+}
+
+// Flush writes any buffered records to the underlying writer.
+func (t *TraceWriter) Flush() error {
+	return t.w.Flush()
+}
+
+func (t *TraceWriter) uvarint(v uint64) error {
+	n := binary.PutUvarint(t.buf[:], v)
+	_, err := t.w.Write(t.buf[:n])
+	return err
+}
+
+// TraceReader reads operations from a trace file.
+type TraceReader struct {
+	r      *bufio.Reader
+	header TraceHeader
+	time   time.Duration
+}
+
+// NewTraceReader reads the trace header from r.
+func NewTraceReader(r io.Reader) (*TraceReader, error) {
+	t := &TraceReader{r: bufio.NewReader(r)}
+	magic := make([]byte, len(traceMagic)+1)
+	if _, err := io.ReadFull(t.r, magic); err != nil {
+		return nil, fmt.Errorf("can't read trace header: %v", err)
+	}
+	if string(magic[:len(traceMagic)]) != traceMagic {
+		return nil, errors.New("not a trace file")
+	}
+	if v := magic[len(traceMagic)]; v != traceVersion {
+		return nil, fmt.Errorf("unsupported trace version %d", v)
+	}
+	name, err := t.bytes()
+	if err != nil {
+		return nil, fmt.Errorf("can't read trace header: %v", err)
+	}
+	t.header.Entropy = string(name)
+	if t.header.Seed, err = binary.ReadVarint(t.r); err != nil {
+		return nil, fmt.Errorf("can't read trace header: %v", err)
+	}
+	if t.header.ChunkSize, err = binary.ReadUvarint(t.r); err != nil {
+		return nil, fmt.Errorf("can't read trace header: %v", err)
+	}
+	return t, nil
+}
+
+// Header returns the trace header.
+func (t *TraceReader) Header() TraceHeader {
+	return t.header
+}
+
+// Next reads the next event. It returns io.EOF at the end of the trace.
+func (t *TraceReader) Next() (ev TraceEvent, err error) {
+	op, err := t.r.ReadByte()
+	if err != nil {
+		return ev, err
+	}
+	ev.Op = TraceOp(op)
+	delta, err := binary.ReadUvarint(t.r)
+	if err != nil {
+		return ev, unexpectedEOF(err)
+	}
+	t.time += time.Duration(delta)
+	ev.Time = t.time
+	if ev.Key, err = t.bytes(); err != nil {
+		return ev, unexpectedEOF(err)
+	}
+	if ev.ValueSize, err = binary.ReadUvarint(t.r); err != nil {
+		return ev, unexpectedEOF(err)
+	}
+	return ev, nil
+}
+
+func (t *TraceReader) bytes() ([]byte, error) {
+	n, err := binary.ReadUvarint(t.r)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, n)
+	_, err = io.ReadFull(t.r, b)
+	return b, err
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,82 @@
+package bench
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTraceRoundtrip(t *testing.T) {
+	header := TraceHeader{Entropy: "pcg", Seed: -3, ChunkSize: 1024}
+	events := []TraceEvent{
+		{Op: TracePut, Time: 10 * time.Microsecond, Key: []byte("key1"), ValueSize: 100},
+		{Op: TracePut, Time: 25 * time.Microsecond, Key: []byte("key2"), ValueSize: 1 << 20},
+		{Op: TraceGet, Time: 2 * time.Second, Key: []byte("key1"), ValueSize: 0},
+	}
+
+	var buf bytes.Buffer
+	w, err := NewTraceWriter(&buf, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range events {
+		w.Write(ev)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewTraceReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Header() != header {
+		t.Errorf("wrong header %+v", r.Header())
+	}
+	for i, want := range events {
+		ev, err := r.Next()
+		if err != nil {
+			t.Fatalf("event %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(ev, want) {
+			t.Errorf("event %d: got %+v, want %+v", i, ev, want)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("expected EOF at end of trace, got %v", err)
+	}
+}
+
+// failWriter fails all writes after the first n bytes.
+type failWriter struct{ n int }
+
+func (w *failWriter) Write(b []byte) (int, error) {
+	if len(b) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errors.New("disk full")
+	}
+	w.n -= len(b)
+	return len(b), nil
+}
+
+func TestRecordError(t *testing.T) {
+	var (
+		out bytes.Buffer
+		cfg = WriteConfig{Size: 1 << 20, KeySize: 16, DataSize: 100}
+		env = NewWriteEnv(&out, cfg)
+	)
+	env.Record(&failWriter{n: 10000})
+	err := env.Run(func(key, value string, lastCall bool) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := lastResult(t, &out); result.Error != "can't write trace: disk full" {
+		t.Errorf("wrong result error %q", result.Error)
+	}
+}
@@ -0,0 +1,77 @@
+package bench
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// putUniqueKey overwrites the first eight bytes of key with a permutation of
+// counter. Different counters always produce different keys, while the keys remain
+// randomly distributed.
+func putUniqueKey(key []byte, counter uint64) {
+	binary.BigEndian.PutUint64(key, permute64(counter))
+}
+
+// permute64 is the splitmix64 finalizer, which is a bijection on uint64.
+func permute64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+const hllPrecision = 16
+
+// keyCounter estimates the number of distinct keys using HyperLogLog.
+type keyCounter struct {
+	registers [1 << hllPrecision]uint8
+}
+
+func (c *keyCounter) add(key []byte) {
+	h := xxhash.Sum64(key)
+	idx := h >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(h<<hllPrecision|1<<(hllPrecision-1)) + 1)
+	if rank > c.registers[idx] {
+		c.registers[idx] = rank
+	}
+}
+
+// count returns the estimated number of distinct keys added.
+func (c *keyCounter) count() uint64 {
+	const m = float64(1 << hllPrecision)
+	var (
+		sum   float64
+		zeros int
+	)
+	for _, r := range c.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	alpha := 0.7213 / (1 + 1.079/m)
+	est := alpha * m * m / sum
+	if est <= 2.5*m && zeros > 0 {
+		// Small range correction.
+		est = m * math.Log(m/float64(zeros))
+	}
+	return uint64(est + 0.5)
+}
+
+// uniqueKeyCounter returns the counter embedded into key by putUniqueKey.
+func uniqueKeyCounter(key []byte) uint64 {
+	return unpermute64(binary.BigEndian.Uint64(key))
+}
+
+// unpermute64 is the inverse of permute64.
+func unpermute64(x uint64) uint64 {
+	x ^= x>>31 ^ x>>62
+	x *= 0x319642b2d24d8ec3 // inverse of 0x94d049bb133111eb
+	x ^= x>>27 ^ x>>54
+	x *= 0x96de1b173f119089 // inverse of 0xbf58476d1ce4e5b9
+	x ^= x>>30 ^ x>>60
+	return x - 0x9e3779b97f4a7c15
+}
@@ -0,0 +1,46 @@
+package bench
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestKeyCounter(t *testing.T) {
+	for _, n := range []uint64{100, 10000, 1000000} {
+		var c keyCounter
+		key := make([]byte, 32)
+		for i := uint64(0); i < n; i++ {
+			putUniqueKey(key, i)
+			c.add(key)
+			c.add(key) // duplicates must not count
+		}
+		est := c.count()
+		if diff := math.Abs(float64(est)-float64(n)) / float64(n); diff > 0.03 {
+			t.Errorf("n=%d: estimate %d off by %.1f%%", n, est, diff*100)
+		}
+	}
+}
+
+func TestPutUniqueKey(t *testing.T) {
+	seen := make(map[uint64]bool)
+	key := make([]byte, 8)
+	for i := uint64(0); i < 100000; i++ {
+		putUniqueKey(key, i)
+		k := binary.BigEndian.Uint64(key)
+		if seen[k] {
+			t.Fatalf("duplicate key for counter %d", i)
+		}
+		seen[k] = true
+	}
+}
+
+func TestUniqueKeyCounter(t *testing.T) {
+	key := make([]byte, 32)
+	for _, i := range []uint64{0, 1, 2, 1000, 1 << 40, math.MaxUint64} {
+		putUniqueKey(key, i)
+		if c := uniqueKeyCounter(key); c != i {
+			t.Errorf("counter %d: got %d", i, c)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package bench
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"time"
+)
+
+// maxVerifyLog is the number of mismatches logged individually.
+const maxVerifyLog = 10
+
+// GetFunc sets the function used to read back values at the end of a run. It
+// must return ErrNotFound if the key doesn't exist. Verification is enabled by
+// setting cfg.Verify.
+func (env *WriteEnv) GetFunc(fn func(key []byte) ([]byte, error)) {
+	env.getFn = fn
+}
+
+// ErrNotFound is returned by the GetFunc for missing keys.
+var ErrNotFound = errors.New("not found")
+
+// regenerate calls fn for all keys and values generated by the last run. The
+// order of keys may differ from the run when concurrent generators were used.
+func (env *WriteEnv) regenerate(fn func(key, value []byte) error) error {
+	if env.cfg.Generators == 0 {
+		if err := env.resetRand(); err != nil {
+			return err
+		}
+		return env.generate(func(key, value []byte, end bool) error {
+			return fn(key, value)
+		})
+	}
+	gen, err := newGenerator(env.poolConfig())
+	if err != nil {
+		return err
+	}
+	for {
+		key, value, ok := gen.next()
+		if !ok {
+			return gen.stop()
+		}
+		key, value = env.cfg.Encoder.encode(gen.counter(), key, value)
+		if err := fn(key, value); err != nil {
+			gen.stop()
+			return err
+		}
+	}
+}
+
+// storesGenerated reports whether the database contains exactly the generated
+// keys and values after the run.
+func (env *WriteEnv) storesGenerated(result *RunResult) bool {
+	return env.generated && !env.cfg.Transformed && result.Deletes == 0 && result.Error == ""
+}
+
+// verifyValues reads back every generated key and compares the stored value
+// against the generator output.
+func (env *WriteEnv) verifyValues(result *RunResult) {
+	if !env.cfg.Verify || env.getFn == nil {
+		return
+	}
+	if !env.storesGenerated(result) {
+		log.Printf("can't verify values: test doesn't store generated values")
+		return
+	}
+	if !Reproducible(env.cfg.Entropy) {
+		log.Printf("can't verify values: entropy source %q can't regenerate them", env.cfg.Entropy)
+		return
+	}
+	begin := time.Now()
+	// Values which don't match might have been overwritten by a later write of
+	// the same key. They are collected and checked again after the first pass.
+	mismatched := make(map[string][]byte)
+	err := env.regenerate(func(key, value []byte) error {
+		stored, err := env.getFn(key)
+		switch {
+		case err == ErrNotFound:
+			result.Missing++
+			if result.Missing <= maxVerifyLog {
+				log.Printf("verify: key %x is missing", key)
+			}
+		case err != nil:
+			return err
+		case !bytes.Equal(stored, value):
+			mismatched[string(key)] = stored
+		default:
+			result.Verified++
+		}
+		return nil
+	})
+	if err == nil && len(mismatched) > 0 {
+		err = env.regenerate(func(key, value []byte) error {
+			if stored, ok := mismatched[string(key)]; ok && bytes.Equal(stored, value) {
+				delete(mismatched, string(key))
+				result.Verified++
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		log.Printf("can't verify values: %v", err)
+		return
+	}
+	for key := range mismatched {
+		result.Mismatches++
+		if result.Mismatches <= maxVerifyLog {
+			log.Printf("verify: value of key %x doesn't match", key)
+		}
+	}
+	log.Printf("verified %d values in %v, %d mismatches, %d missing",
+		result.Verified, time.Since(begin), result.Mismatches, result.Missing)
+}
@@ -0,0 +1,88 @@
+package bench
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestVerifyValues(t *testing.T) {
+	for _, generators := range []int{0, 2} {
+		var (
+			out   bytes.Buffer
+			store = make(map[string]string)
+			keys  []string
+			cfg   = WriteConfig{Size: 100000, KeySize: 8, DataSize: 100, Generators: generators, Verify: true}
+			env   = NewWriteEnv(&out, cfg)
+		)
+		env.GetFunc(func(key []byte) ([]byte, error) {
+			v, ok := store[string(key)]
+			if !ok {
+				return nil, ErrNotFound
+			}
+			return []byte(v), nil
+		})
+		err := env.Run(func(key, value string, lastCall bool) error {
+			store[key] = value
+			keys = append(keys, key)
+			if lastCall {
+				// Damage the store.
+				store[keys[0]] = "corrupt"
+				delete(store, keys[1])
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result := lastResult(t, &out)
+		if result.Verified != uint64(len(keys)-2) || result.Mismatches != 1 || result.Missing != 1 {
+			t.Errorf("generators=%d: wrong result: %d verified, %d mismatches, %d missing",
+				generators, result.Verified, result.Mismatches, result.Missing)
+		}
+	}
+}
+
+func TestRunErrorResult(t *testing.T) {
+	var (
+		out bytes.Buffer
+		cfg = WriteConfig{Size: 100000, KeySize: 8, DataSize: 100, Verify: true}
+		env = NewWriteEnv(&out, cfg)
+		n   = 0
+	)
+	env.GetFunc(func(key []byte) ([]byte, error) {
+		t.Fatal("partial run was verified")
+		return nil, nil
+	})
+	err := env.Run(func(key, value string, lastCall bool) error {
+		if n++; n == 10 {
+			return errors.New("disk full")
+		}
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if result := lastResult(t, &out); result.Error != "disk full" || result.Ops != 10 {
+		t.Errorf("wrong result: error %q, %d ops", result.Error, result.Ops)
+	}
+}
+
+// testReport decodes the log of a test run.
+func testReport(t *testing.T, log io.Reader) *Report {
+	r, err := decodeLog(log, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func lastResult(t *testing.T, log io.Reader) *RunResult {
+	r := testReport(t, log)
+	if r.Result == nil {
+		t.Fatal("no result in log")
+	}
+	return r.Result
+}
@@ -0,0 +1,123 @@
+package bench
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultWatchdogWindow is the default period over which the memory watchdog
+// measures the growth of the RSS.
+const DefaultWatchdogWindow = 5 * time.Minute
+
+// WatchdogConfig configures the memory watchdog, which catches memory leaks in
+// long runs. It samples the resident set size (RSS) of the process once per
+// second and triggers when the RSS exceeds Limit, or when it grows faster than
+// Slope over the last Window. When triggered, it writes a heap profile and
+// annotates the log. With Abort, the run also ends with ErrWatchdog.
+type WatchdogConfig struct {
+	Limit  uint64        `json:"limit,omitempty"`  // bytes
+	Slope  uint64        `json:"slope,omitempty"`  // bytes per second
+	Window time.Duration `json:"window,omitempty"` // DefaultWatchdogWindow if zero
+	Abort  bool          `json:"abort,omitempty"`
+
+	// ProfileDir is the directory of heap profiles. They are named after the
+	// test, <test>.heap.
+	ProfileDir string `json:"-"`
+}
+
+func (c WatchdogConfig) enabled() bool {
+	return c.Limit > 0 || c.Slope > 0
+}
+
+// ErrWatchdog is the error of runs aborted by the memory watchdog.
+var ErrWatchdog = errors.New("aborted by memory watchdog")
+
+// watchdog is the detector implementing the memory watchdog.
+type watchdog struct {
+	cfg     WatchdogConfig
+	test    string
+	samples []rssSample // within the window
+	fired   bool
+	aborted int32 // atomic
+}
+
+type rssSample struct {
+	time time.Duration
+	rss  uint64
+}
+
+func newWatchdog(cfg WatchdogConfig, test string) *watchdog {
+	if cfg.Window == 0 {
+		cfg.Window = DefaultWatchdogWindow
+	}
+	return &watchdog{cfg: cfg, test: test}
+}
+
+func (w *watchdog) Observe(m Metric, annotate func(string)) {
+	if m.Memory == nil || w.fired {
+		return
+	}
+	s := rssSample{m.Time, m.Memory.RSS}
+	w.samples = append(w.samples, s)
+	for len(w.samples) > 1 && s.time-w.samples[1].time >= w.cfg.Window {
+		w.samples = w.samples[1:]
+	}
+
+	var reason string
+	if w.cfg.Limit > 0 && s.rss > w.cfg.Limit {
+		reason = fmt.Sprintf("RSS %s exceeds the limit of %s", FormatSize(s.rss), FormatSize(w.cfg.Limit))
+	} else if w.cfg.Slope > 0 && s.time-w.samples[0].time >= w.cfg.Window {
+		if slope := rssSlope(w.samples); slope > float64(w.cfg.Slope) {
+			reason = fmt.Sprintf("RSS %s grows by %s/s over %v", FormatSize(s.rss), FormatSize(uint64(slope)), w.cfg.Window)
+		}
+	}
+	if reason == "" {
+		return
+	}
+	w.fired = true
+	if file, err := w.writeProfile(); err != nil {
+		log.Printf("can't write heap profile: %v", err)
+	} else {
+		reason += ", heap profile written to " + file
+	}
+	if w.cfg.Abort {
+		atomic.StoreInt32(&w.aborted, 1)
+		reason += ", aborting"
+	}
+	log.Printf("memory watchdog: %s", reason)
+	annotate(reason)
+}
+
+func (w *watchdog) writeProfile() (string, error) {
+	file := filepath.Join(w.cfg.ProfileDir, w.test+".heap")
+	fd, err := os.Create(file)
+	if err != nil {
+		return "", err
+	}
+	defer fd.Close()
+	runtime.GC() // the profile shows the state as of the last GC
+	return file, pprof.WriteHeapProfile(fd)
+}
+
+// rssSlope returns the least squares slope of the samples in bytes per second.
+func rssSlope(samples []rssSample) float64 {
+	var sumT, sumR, sumTT, sumTR float64
+	for _, s := range samples {
+		t, r := s.time.Seconds(), float64(s.rss)
+		sumT, sumR = sumT+t, sumR+r
+		sumTT, sumTR = sumTT+t*t, sumTR+t*r
+	}
+	n := float64(len(samples))
+	div := n*sumTT - sumT*sumT
+	if div == 0 {
+		return 0
+	}
+	return (n*sumTR - sumT*sumR) / div
+}
@@ -0,0 +1,79 @@
+package bench
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRSSSlope(t *testing.T) {
+	var samples []rssSample
+	for i := 0; i < 10; i++ {
+		samples = append(samples, rssSample{time.Duration(i) * time.Second, uint64(1000 + 300*i)})
+	}
+	if s := rssSlope(samples); math.Abs(s-300) > 1e-6 {
+		t.Errorf("wrong slope %f", s)
+	}
+}
+
+func TestWatchdogSlope(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watchdog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w := newWatchdog(WatchdogConfig{Slope: 1 << 20, Window: 10 * time.Second, ProfileDir: dir}, "leak")
+	var metrics []Metric
+	for i := 0; i <= 30; i++ {
+		rss := uint64(100 << 20)
+		if i > 15 {
+			rss += uint64(i-15) * 2 << 20 // grows by 2mb/s
+		}
+		metrics = append(metrics, Metric{Time: time.Duration(i) * time.Second, Memory: &MemorySample{RSS: rss}})
+	}
+	msgs := observeAll(w, metrics)
+	if len(msgs) != 1 || !strings.Contains(msgs[0], "grows by") {
+		t.Fatalf("wrong annotations %q", msgs)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "leak.heap")); err != nil {
+		t.Error("missing heap profile:", err)
+	}
+}
+
+func TestWatchdogAbort(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watchdog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		out bytes.Buffer
+		cfg = WriteConfig{
+			Size:     1 << 40,
+			KeySize:  8,
+			DataSize: 100,
+			TestName: "abort",
+			Watchdog: WatchdogConfig{Limit: 1, Abort: true, ProfileDir: dir},
+		}
+		env = NewWriteEnv(&out, cfg)
+	)
+	err = env.Run(func(key, value string, lastCall bool) error {
+		return nil
+	})
+	if err != ErrWatchdog {
+		t.Fatalf("wrong error %v", err)
+	}
+	if result := lastResult(t, &out); result.Error != ErrWatchdog.Error() {
+		t.Errorf("wrong result error %q", result.Error)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "abort.heap")); err != nil {
+		t.Error("missing heap profile:", err)
+	}
+}
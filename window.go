@@ -0,0 +1,106 @@
+package bench
+
+import "time"
+
+// Window is a period of a run during which some background activity, like
+// pruning or compaction, was going on.
+type Window struct {
+	Name     string        `json:"name"`
+	Start    uint64        `json:"start"` // bytes processed when the window opened
+	End      uint64        `json:"end"`   // bytes processed when it closed
+	Time     time.Duration `json:"time"`  // since the start of the run
+	Duration time.Duration `json:"duration"`
+}
+
+// Window opens a measurement window. The window is logged when the returned
+// function is called. It is safe to call from any goroutine.
+func (env *WriteEnv) Window(name string) (end func()) {
+	env.mu.Lock()
+	w := Window{Name: name, Start: env.written, Time: mononow() - env.startTime}
+	env.mu.Unlock()
+	return func() {
+		env.mu.Lock()
+		defer env.mu.Unlock()
+		w.End = env.written
+		w.Duration = mononow() - env.startTime - w.Time
+		writeWindow(env.out, w)
+	}
+}
+
+// Window opens a measurement window like WriteEnv.Window.
+func (env *ReadEnv) Window(name string) (end func()) {
+	env.mu.Lock()
+	w := Window{Name: name, Start: env.read, Time: mononow() - env.startTime}
+	env.mu.Unlock()
+	return func() {
+		env.mu.Lock()
+		defer env.mu.Unlock()
+		w.End = env.read
+		w.Duration = mononow() - env.startTime - w.Time
+		writeWindow(env.log, w)
+	}
+}
+
+// WindowBPS returns the throughput during all windows of the given name and
+// during the rest of the run outside of any window, based on the progress
+// events.
+func (r Report) WindowBPS(name string) (inside, outside float64) {
+	in, out := r.splitWindows(name)
+	return windowBPS(in), windowBPS(out)
+}
+
+// WindowLatency is like WindowBPS, but returns the amortized latency per entry.
+func (r Report) WindowLatency(name string) (inside, outside time.Duration) {
+	in, out := r.splitWindows(name)
+	return in.EntryLatency(), out.EntryLatency()
+}
+
+// splitWindows sums up the progress events inside windows of the given name,
+// and outside of any window.
+func (r Report) splitWindows(name string) (in, out Progress) {
+	for _, ev := range r.Events {
+		var acc *Progress
+		for _, w := range r.Windows {
+			if ev.Processed > w.Start && ev.Processed <= w.End {
+				if w.Name == name {
+					acc = &in
+					break
+				}
+				acc = &Progress{} // in another window
+			}
+		}
+		if acc == nil {
+			acc = &out
+		}
+		acc.Delta += ev.Delta
+		acc.Duration += ev.Duration
+		acc.Entries += ev.Entries
+		acc.Commits += ev.Commits
+	}
+	return in, out
+}
+
+func windowBPS(p Progress) float64 {
+	if p.Duration == 0 {
+		return 0
+	}
+	return p.BPS()
+}
+
+// ReclaimTime returns the time from the end of window w until the database got
+// smaller than it was when the window opened. It returns false if that didn't
+// happen while the database size was being sampled.
+func (r Report) ReclaimTime(w Window) (time.Duration, bool) {
+	var (
+		before uint64
+		end    = w.Time + w.Duration
+	)
+	for _, d := range r.Disk {
+		if d.Time <= w.Time {
+			before = d.Size
+		} else if d.Time >= end && d.Size < before {
+			return d.Time - end, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,266 @@
+package bench
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Workload describes a mix of operations in the style of YCSB. Proportions are
+// relative to each other and need not add up to one.
+type Workload struct {
+	Read          float64 `json:"read"`
+	Update        float64 `json:"update"`
+	Insert        float64 `json:"insert"`
+	Scan          float64 `json:"scan"`
+	ReadModify    float64 `json:"readmodifywrite"`
+	Mutate        float64 `json:"mutate,omitempty"` // read, modify MutateSize bytes, write back
+	Distribution  string  `json:"distribution"`     // uniform, zipfian or latest
+	MaxScanLength int     `json:"maxscanlength,omitempty"`
+	MutateSize    int     `json:"mutatesize,omitempty"`
+
+	// ValueSize overrides the configured value size if set.
+	ValueSize uint64 `json:"valuesize,omitempty"`
+}
+
+// Workloads contains the YCSB core workload presets and other mixes.
+var Workloads = map[string]Workload{
+	"ycsb-a": {Read: 0.5, Update: 0.5, Distribution: "zipfian"},
+	"ycsb-b": {Read: 0.95, Update: 0.05, Distribution: "zipfian"},
+	"ycsb-c": {Read: 1, Distribution: "zipfian"},
+	"ycsb-d": {Read: 0.95, Insert: 0.05, Distribution: "latest"},
+	"ycsb-e": {Scan: 0.95, Insert: 0.05, Distribution: "zipfian", MaxScanLength: 100},
+	"ycsb-f": {Read: 0.5, ReadModify: 0.5, Distribution: "zipfian"},
+
+	// Partial updates of large values, which leveldb must rewrite as a whole.
+	"value-mutation": {Mutate: 1, Distribution: "uniform", MutateSize: 64, ValueSize: 64 * 1024},
+}
+
+// WorkloadNames returns the names of all workload presets.
+func WorkloadNames() (n []string) {
+	for name := range Workloads {
+		n = append(n, name)
+	}
+	sort.Strings(n)
+	return n
+}
+
+// WorkloadOps are the database operations used by RunWorkload.
+type WorkloadOps struct {
+	Put  func(key, value []byte) error
+	Get  func(key []byte) ([]byte, error)
+	Scan func(start []byte, n int) (size int, err error)
+}
+
+// RunWorkload loads cfg.Size bytes of values into the database, then performs
+// the same number of operations according to the workload. Progress is
+// reported for the second phase only.
+func (env *WriteEnv) RunWorkload(w Workload, ops WorkloadOps) (err error) {
+	if err := env.start(); err != nil {
+		return err
+	}
+	defer func() { env.finish(err) }()
+	logPercent := env.cfg.LogPercent
+	env.cfg.LogPercent = false
+
+	records := uint64(1)
+	if env.cfg.DataSize > 0 && env.cfg.Size > env.cfg.DataSize {
+		records = env.cfg.Size / env.cfg.DataSize
+	}
+	log.Printf("loading %d records", records)
+	for i := uint64(0); i < records; i++ {
+		key := env.workloadKey(i)
+		if err := ops.Put(key, env.workloadValue()); err != nil {
+			return err
+		}
+		if err := env.detectors.err(); err != nil {
+			return err
+		}
+		env.countKey(key)
+	}
+
+	var (
+		rng      = rand.New(rand.NewSource(generatorSeed))
+		zipf     = newZipfian(rng, records)
+		total    = w.Read + w.Update + w.Insert + w.Scan + w.ReadModify + w.Mutate
+		lastPct  int
+		maxScan  = w.MaxScanLength
+		mutate   = w.MutateSize
+		inserted = records
+	)
+	if total == 0 {
+		return fmt.Errorf("workload has no operations")
+	}
+	if maxScan == 0 {
+		maxScan = 100
+	}
+	if mutate == 0 {
+		mutate = 1
+	}
+	// pick chooses an existing record according to the key distribution.
+	pick := func() uint64 {
+		switch w.Distribution {
+		case "zipfian":
+			return zipf.next(inserted)
+		case "latest":
+			return inserted - 1 - zipf.next(inserted)
+		default:
+			return uint64(rng.Int63n(int64(inserted)))
+		}
+	}
+	env.startTime = mononow()
+	env.lastTime = env.startTime
+	for i := uint64(0); i < records; i++ {
+		var (
+			op    string
+			size  int
+			err   error
+			p     = rng.Float64() * total
+			begin = mononow()
+		)
+		switch {
+		case p < w.Read:
+			op = "read"
+			var v []byte
+			v, err = ops.Get(env.workloadKey(pick()))
+			size = len(v)
+		case p < w.Read+w.Update:
+			op = "update"
+			size = int(env.cfg.DataSize)
+			key := env.workloadKey(pick())
+			err = ops.Put(key, env.workloadValue())
+			env.countKey(key)
+		case p < w.Read+w.Update+w.Insert:
+			op = "insert"
+			size = int(env.cfg.DataSize)
+			key := env.workloadKey(inserted)
+			err = ops.Put(key, env.workloadValue())
+			env.countKey(key)
+			inserted++
+		case p < w.Read+w.Update+w.Insert+w.Scan:
+			op = "scan"
+			size, err = ops.Scan(env.workloadKey(pick()), 1+rng.Intn(maxScan))
+		case p < w.Read+w.Update+w.Insert+w.Scan+w.ReadModify:
+			op = "readmodifywrite"
+			key := env.workloadKey(pick())
+			var v []byte
+			if v, err = ops.Get(key); err == nil {
+				size = len(v) + int(env.cfg.DataSize)
+				err = ops.Put(key, env.workloadValue())
+				env.countKey(key)
+			}
+		default:
+			op = "mutate"
+			key := env.workloadKey(pick())
+			var v []byte
+			if v, err = ops.Get(key); err == nil {
+				v = env.mutateValue(rng, v, mutate)
+				size = 2 * len(v)
+				err = ops.Put(key, v)
+				env.countKey(key)
+			}
+		}
+		env.opDone(op, begin)
+		if err == nil {
+			err = env.detectors.err()
+		}
+		if err != nil {
+			return err
+		}
+		env.Progress(size)
+		if pct := int(100 * (i + 1) / records); logPercent && pct > lastPct {
+			fmt.Printf("%3d%%  %s\n", pct, env.cfg.TestName)
+			lastPct = pct
+		}
+	}
+	return nil
+}
+
+// workloadKey returns the key of record i.
+func (env *WriteEnv) workloadKey(i uint64) []byte {
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[8:], i)
+	for off := 0; off < len(env.key); off += 8 {
+		binary.BigEndian.PutUint64(buf[:8], uint64(off))
+		var h [8]byte
+		binary.BigEndian.PutUint64(h[:], xxhash.Sum64(buf[:]))
+		copy(env.key[off:], h[:])
+	}
+	return env.key
+}
+
+// workloadValue returns a random value.
+func (env *WriteEnv) workloadValue() []byte {
+	env.rand.Read(env.value)
+	return env.value
+}
+
+// mutateValue copies v and overwrites n random bytes at a random offset.
+func (env *WriteEnv) mutateValue(rng *rand.Rand, v []byte, n int) []byte {
+	v = append(env.value[:0], v...)
+	if n > len(v) {
+		n = len(v)
+	}
+	off := rng.Intn(len(v) - n + 1)
+	env.rand.Read(v[off : off+n])
+	return v
+}
+
+// zipfian generates zipfian-distributed numbers in the range [0, n), with
+// smaller numbers being more popular. This is the algorithm used by YCSB, from
+// "Quickly Generating Billion-Record Synthetic Databases" by Gray et al.
+type zipfian struct {
+	rand         *rand.Rand
+	items        uint64
+	theta, alpha float64
+	zeta2, zetan float64
+	eta          float64
+}
+
+const zipfianConstant = 0.99
+
+func newZipfian(rng *rand.Rand, items uint64) *zipfian {
+	z := &zipfian{rand: rng, theta: zipfianConstant}
+	z.alpha = 1 / (1 - z.theta)
+	z.zeta2 = zeta(0, 2, z.theta, 0)
+	z.grow(items)
+	return z
+}
+
+// zeta computes the zeta constant for n items incrementally from the value at m.
+func zeta(m, n uint64, theta, sum float64) float64 {
+	for i := m; i < n; i++ {
+		sum += 1 / math.Pow(float64(i+1), theta)
+	}
+	return sum
+}
+
+func (z *zipfian) grow(items uint64) {
+	z.zetan = zeta(z.items, items, z.theta, z.zetan)
+	z.items = items
+	z.eta = (1 - math.Pow(2/float64(items), 1-z.theta)) / (1 - z.zeta2/z.zetan)
+}
+
+func (z *zipfian) next(items uint64) uint64 {
+	if items != z.items {
+		z.grow(items)
+	}
+	u := z.rand.Float64()
+	uz := u * z.zetan
+	if uz < 1 {
+		return 0
+	}
+	if uz < 1+math.Pow(0.5, z.theta) {
+		return 1 % items
+	}
+	v := uint64(float64(items) * math.Pow(z.eta*u-z.eta+1, z.alpha))
+	if v >= items {
+		v = items - 1
+	}
+	return v
+}
@@ -0,0 +1,47 @@
+package bench
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestZipfian(t *testing.T) {
+	const items = 1000
+	z := newZipfian(rand.New(rand.NewSource(1)), items)
+	counts := make([]int, items)
+	for i := 0; i < 100000; i++ {
+		v := z.next(items)
+		if v >= items {
+			t.Fatalf("value %d out of range", v)
+		}
+		counts[v]++
+	}
+	if counts[0] < counts[1] || counts[1] < counts[100] {
+		t.Errorf("distribution not skewed: %d %d %d", counts[0], counts[1], counts[100])
+	}
+}
+
+func TestWorkloadUniqueKeys(t *testing.T) {
+	var (
+		out   bytes.Buffer
+		store = make(map[string]bool)
+		cfg   = WriteConfig{Size: 1000000, KeySize: 16, DataSize: 100, UniqueKeys: true}
+		env   = NewWriteEnv(&out, cfg)
+	)
+	err := env.RunWorkload(Workload{Update: 1, Distribution: "zipfian"}, WorkloadOps{
+		Put: func(key, value []byte) error { store[string(key)] = true; return nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := lastResult(t, &out)
+	if result.UniqueKeys > result.Ops {
+		t.Fatalf("%d unique keys exceed %d ops", result.UniqueKeys, result.Ops)
+	}
+	n := uint64(len(store))
+	if diff := math.Abs(float64(result.UniqueKeys)-float64(n)) / float64(n); diff > 0.03 {
+		t.Errorf("%d unique keys reported, want about %d", result.UniqueKeys, n)
+	}
+}
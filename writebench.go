@@ -2,19 +2,87 @@ package bench
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"math/rand"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-const emitInterval = 500 * 1024 // bytes
-
 type WriteConfig struct {
 	Size     uint64 `json:"size"`     // total size of values to write
 	KeySize  uint64 `json:"keysize"`  // size of each key written
 	DataSize uint64 `json:"datasize"` // size of each value written
+	Entropy  string `json:"entropy"`  // random source for keys and values
+	Tags     Tags   `json:"tags,omitempty"`
+
+	// If MaxDataSize is larger than DataSize, value sizes are chosen
+	// uniformly between DataSize and MaxDataSize.
+	MaxDataSize uint64 `json:"maxdatasize,omitempty"`
+
+	// Generator pool settings. If Generators is zero, keys and values are
+	// generated inline by Run. A negative value starts GOMAXPROCS generators.
+	Generators int  `json:"generators"`
+	Ordered    bool `json:"ordered"` // deliver generated chunks in order
+
+	// UniqueKeys guarantees that no key is written twice by embedding a
+	// counter in the first eight bytes of every key.
+	UniqueKeys bool `json:"uniquekeys"`
+
+	// CountKeys enables counting keys by iteration after the run. With
+	// CountSample > 1, only that fraction of the key space is counted.
+	CountKeys   bool `json:"countkeys"`
+	CountSample int  `json:"countsample,omitempty"`
+
+	// Verify enables reading back all written values after the run.
+	Verify bool `json:"verify,omitempty"`
+
+	// Transformed must be set by tests which don't store generated keys and
+	// values as they are. It disables verification and manifests.
+	Transformed bool `json:"transformed,omitempty"`
+
+	// CheckAcks enables the acknowledgement check of tests with concurrent
+	// writers, which call Ack for every completed write. After the run, every
+	// generated write must have been acknowledged once and be in the database.
+	// It requires UniqueKeys.
+	CheckAcks bool `json:"checkacks,omitempty"`
+
+	// Encoder transforms the generated keys and values. Its name is logged
+	// in the header.
+	Encoder *Encoder `json:"encoder,omitempty"`
+
+	// Detectors are the names of the detectors run over the metric stream.
+	Detectors []string       `json:"detectors,omitempty"`
+	Watchdog  WatchdogConfig `json:"watchdog"`
+
+	CompactEvery uint64 `json:"compactevery,omitempty"` // bytes written between manual compactions
+
+	// Write operations taking longer than StallThreshold are logged as stalls.
+	StallThreshold time.Duration `json:"stallthreshold,omitempty"`
+
+	// SampleDisk enables sampling of the database size during the run. The
+	// size is also sampled for DiskWatch after the run, or after the delete
+	// phase of delete tests.
+	SampleDisk bool          `json:"sampledisk,omitempty"`
+	DiskWatch  time.Duration `json:"diskwatch,omitempty"`
+
+	// SampleMemory enables logging of Go runtime memory statistics whenever
+	// progress is logged.
+	SampleMemory bool `json:"samplememory,omitempty"`
+
+	// ReportInterval is the distance between progress events.
+	ReportInterval ReportInterval `json:"reportinterval"`
+
+	// Live receives the metrics of the run for live monitoring.
+	Live *Live `json:"-"`
+
+	// Trace replay settings.
+	Trace    string `json:"trace,omitempty"` // trace file to replay
+	RealTime bool   `json:"realtime"`        // replay at original speed
 
 	LogPercent bool   `json:"-"`
 	TestName   string `json:"-"`
@@ -24,63 +92,522 @@ type WriteEnv struct {
 	cfg WriteConfig
 	// generating keys and values
 	key, value []byte
-	rand       *rand.Rand
+	rand       io.Reader
+	sizeRand   *rand.Rand
 	out        *json.Encoder
+	traceOut   io.Writer
+	trace      *TraceWriter
+	latOut     io.Writer
+	latLog     *latencyLog
+	csvOut     io.Writer
+	csv        *progressCSV
+	influxOut  io.Writer
+	influx     *progressInflux
+	dbDir      string
+	ops        uint64 // generated write operations
+	uniquePuts uint64 // write operations with keys made unique by the generator
+	putBytes   uint64 // key and value bytes of generated write operations, atomic
+	deletes    uint64 // generated delete operations
+	keys       *keyCounter
+	countFn    func(start, limit []byte) (uint64, error)
+	sizeFn     func() (uint64, error)
+	estimateFn func() (uint64, error)
+	filesFn    func() (int, error)
+	writeIOFn  func() StorageIO
+	writeIO    StorageIO // storage writes before the run
+	getFn      func(key []byte) ([]byte, error)
+	keysFn     func(visit func(key []byte)) error
+	keyOrder   string      // comparer name if keys aren't in bytewise order
+	options    interface{} // database options logged before the start
+	manifest   *manifestBuilder
+	manifestW  io.Writer
+	generated  bool // keys and values were produced by generate or the pool
+	selfTimed  bool // the test times its writes, see TimeWrites
+	detectors  *detectorSet
+	diskStop   chan struct{}
+	diskDone   chan struct{}
+	diskTick   chan struct{} // progress was reported
+	stats      statsSampler
+	gcStart    gcSnapshot
+	device     deviceCounter
+	// periodic compaction
+	compactFn     func(start, limit []byte) error
+	compactCh     chan int
+	compactDone   chan struct{}
+	lastCompact   uint64
+	compactWindow int
 	// reporting
 	mu                   sync.Mutex
 	startTime, lastTime  time.Duration
 	written, lastWritten uint64
+	entries, lastEntries uint64
+	commits, lastCommits uint64
+	stalls               stallHistogram
+	latency              histogram
+	intervalLatency      intervalLatency
+	durable              latencyTally
+	acks                 AckResult
+	generatedKeys        bitset
+	ackedKeys            bitset
 	lastPercent          int
 }
 
 func NewWriteEnv(output io.Writer, cfg WriteConfig) *WriteEnv {
+	valueSize := cfg.DataSize
+	if cfg.MaxDataSize > valueSize {
+		valueSize = cfg.MaxDataSize
+	}
 	return &WriteEnv{
 		cfg:   cfg,
 		out:   json.NewEncoder(output),
 		key:   make([]byte, cfg.KeySize),
-		value: make([]byte, cfg.DataSize),
+		value: make([]byte, valueSize),
 	}
 }
 
+// Config returns the configuration of the environment.
+func (env *WriteEnv) Config() WriteConfig {
+	return env.cfg
+}
+
+// Record enables recording of all generated operations to a trace file.
+// It must be called before Run.
+func (env *WriteEnv) Record(w io.Writer) {
+	env.traceOut = w
+}
+
 // Run calls write repeatedly with random keys and values.
 // The write function should perform a database write and call LegacyWriteProgress when
 // data has actually been flushed to disk.
-func (env *WriteEnv) Run(write func(key, value string, lastCall bool) error) error {
-	env.start()
+func (env *WriteEnv) Run(write func(key, value string, lastCall bool) error) (err error) {
+	if err := env.start(); err != nil {
+		return err
+	}
+	defer func() { env.finish(err) }()
+	env.generated = true
+	if env.cfg.Generators != 0 {
+		return env.runPool(write)
+	}
+
+	return env.generate(func(key, value []byte, end bool) error {
+		env.recordPut(key, value)
+		begin := mononow()
+		err := write(string(key), string(value), end)
+		if !env.selfTimed {
+			env.opDone("put", begin)
+		}
+		return err
+	})
+}
+
+// generate calls fn with random keys and values until cfg.Size bytes of values
+// have been generated. The sequence only depends on the state of env.rand and
+// env.sizeRand.
+func (env *WriteEnv) generate(fn func(key, value []byte, end bool) error) error {
 	written := uint64(0)
-	for {
+	for i := uint64(0); ; i++ {
+		value := env.value[:env.valueSize()]
 		env.rand.Read(env.key)
-		env.rand.Read(env.value)
-		written += env.cfg.DataSize
+		env.rand.Read(value)
+		if env.cfg.UniqueKeys {
+			putUniqueKey(env.key, i)
+		}
+		written += uint64(len(value))
 		end := written >= env.cfg.Size
-		err := write(string(env.key), string(env.value), end)
-		if err != nil || end {
+		if err := env.detectors.err(); err != nil {
+			return err
+		}
+		key, value := env.cfg.Encoder.encode(i, env.key, value)
+		if err := fn(key, value, end); err != nil || end {
 			return err
 		}
 	}
 }
 
-func (env *WriteEnv) start() {
+// valueSize returns the size of the next value.
+func (env *WriteEnv) valueSize() uint64 {
+	if env.cfg.MaxDataSize <= env.cfg.DataSize {
+		return env.cfg.DataSize
+	}
+	return env.cfg.DataSize + uint64(env.sizeRand.Int63n(int64(env.cfg.MaxDataSize-env.cfg.DataSize+1)))
+}
+
+// runPool is Run with generation performed by the generator pool.
+func (env *WriteEnv) runPool(write func(key, value string, lastCall bool) error) error {
+	if env.cfg.MaxDataSize > env.cfg.DataSize {
+		return errors.New("variable value sizes are not supported by the generator pool")
+	}
+	total := env.poolTotal()
+	gen, err := newGenerator(env.poolConfig())
+	if err != nil {
+		return err
+	}
+	for i := uint64(1); ; i++ {
+		key, value, ok := gen.next()
+		if !ok {
+			break
+		}
+		key, value = env.cfg.Encoder.encode(gen.counter(), key, value)
+		env.recordPut(key, value)
+		begin := mononow()
+		err := write(string(key), string(value), i == total)
+		if !env.selfTimed {
+			env.opDone("put", begin)
+		}
+		if err == nil {
+			err = env.detectors.err()
+		}
+		if err != nil {
+			gen.stop()
+			return err
+		}
+	}
+	return gen.stop()
+}
+
+// poolTotal returns the number of pairs generated by the generator pool.
+func (env *WriteEnv) poolTotal() uint64 {
+	if env.cfg.DataSize > 0 && env.cfg.Size > env.cfg.DataSize {
+		return (env.cfg.Size + env.cfg.DataSize - 1) / env.cfg.DataSize
+	}
+	return 1
+}
+
+func (env *WriteEnv) poolConfig() genConfig {
+	return genConfig{
+		entropy:   env.cfg.Entropy,
+		seed:      generatorSeed,
+		keySize:   int(env.cfg.KeySize),
+		valueSize: int(env.cfg.DataSize),
+		total:     env.poolTotal(),
+		workers:   env.cfg.Generators,
+		ordered:   env.cfg.Ordered,
+		unique:    env.cfg.UniqueKeys,
+	}
+}
+
+// Replay calls op for every operation in the trace file configured by cfg.Trace.
+// For put operations, value is the regenerated value, which is only valid during
+// the call. If cfg.RealTime is set, operations are issued at their original time
+// offsets.
+func (env *WriteEnv) Replay(op func(ev TraceEvent, value []byte) error) (err error) {
+	fd, err := os.Open(env.cfg.Trace)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	tr, err := NewTraceReader(fd)
+	if err != nil {
+		return err
+	}
+	if err := env.start(); err != nil {
+		return err
+	}
+	defer func() { env.finish(err) }()
+	// The length of the trace isn't known up front.
+	env.cfg.LogPercent = false
+
+	var (
+		header = tr.Header()
+		puts   uint64
+		src    io.Reader
+		value  []byte
+	)
+	if !Reproducible(header.Entropy) {
+		log.Printf("trace was recorded with entropy source %q, replayed values differ from the recording", header.Entropy)
+	}
+	for {
+		ev, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if env.cfg.RealTime {
+			if wait := ev.Time - (mononow() - env.startTime); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+		value = value[:0]
+		if ev.Op == TracePut {
+			// Regenerate the value. The key is read from the source as well
+			// to keep it in sync with the original run.
+			if src == nil || (header.ChunkSize > 0 && puts%header.ChunkSize == 0) {
+				seed := header.Seed
+				if header.ChunkSize > 0 {
+					seed += int64(puts / header.ChunkSize)
+				}
+				if src, err = NewEntropy(header.Entropy, seed); err != nil {
+					return err
+				}
+			}
+			need := uint64(len(ev.Key))
+			if ev.ValueSize > need {
+				need = ev.ValueSize
+			}
+			if uint64(cap(value)) < need {
+				value = make([]byte, need)
+			}
+			value = value[:len(ev.Key)]
+			src.Read(value)
+			value = value[:ev.ValueSize]
+			src.Read(value)
+			env.countKey(ev.Key)
+			puts++
+		}
+		begin := mononow()
+		err = op(ev, value)
+		env.opDone(ev.Op.String(), begin)
+		if err == nil {
+			err = env.detectors.err()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// recordPut accounts for a generated write operation.
+func (env *WriteEnv) recordPut(key, value []byte) {
+	env.countKey(key)
+	if env.cfg.UniqueKeys {
+		env.uniquePuts++
+	}
+	atomic.AddUint64(&env.putBytes, uint64(len(key)+len(value)))
+	env.recordAck(key)
+	if env.trace != nil {
+		env.trace.Write(TraceEvent{Op: TracePut, Time: mononow() - env.startTime, Key: key, ValueSize: uint64(len(value))})
+	}
+	if env.manifest != nil {
+		env.manifest.add(key, value)
+	}
+}
+
+func (env *WriteEnv) countKey(key []byte) {
+	env.ops++
+	env.keys.add(key)
+}
+
+func (env *WriteEnv) start() (err error) {
+	if env.cfg.UniqueKeys && env.cfg.KeySize < 8 {
+		return errors.New("unique keys require a key size of at least 8 bytes")
+	}
 	env.written, env.lastWritten = 0, 0
-	env.rand = rand.New(rand.NewSource(0x1334))
+	env.entries, env.lastEntries = 0, 0
+	env.commits, env.lastCommits = 0, 0
+	env.ops, env.uniquePuts, env.keys = 0, 0, new(keyCounter)
+	env.putBytes = 0
+	env.stalls = newStallHistogram(env.cfg.StallThreshold)
+	env.deletes, env.generated = 0, false
+	env.durable = latencyTally{}
+	env.latency = histogram{}
+	env.intervalLatency.reset()
+	if err := env.startAcks(); err != nil {
+		return err
+	}
+	if err := env.resetRand(); err != nil {
+		return err
+	}
+	if env.manifestW != nil {
+		env.manifest = newManifestBuilder(env.cfg, env.keyOrder)
+	}
+	if env.traceOut != nil {
+		header := TraceHeader{Entropy: env.cfg.Entropy, Seed: generatorSeed}
+		if env.cfg.Generators != 0 {
+			if !env.cfg.Ordered {
+				return errors.New("trace recording requires ordered generation")
+			}
+			header.ChunkSize = genChunkOps
+		}
+		if env.trace, err = NewTraceWriter(env.traceOut, header); err != nil {
+			return err
+		}
+	}
+	if env.latOut != nil {
+		if env.latLog, err = newLatencyLog(env.latOut); err != nil {
+			return err
+		}
+	}
+	if env.csvOut != nil && env.csv == nil {
+		// Later runs of the test continue the table.
+		if env.csv, err = newProgressCSV(env.csvOut); err != nil {
+			return err
+		}
+	}
+	if env.influxOut != nil {
+		env.influx = newProgressInflux(env.influxOut, env.cfg.TestName, env.cfg.Tags, time.Now())
+	}
+	header := LogHeader{Test: env.cfg.TestName, Tags: env.cfg.Tags, TimerOverhead: TimerOverhead()}
+	header.System, header.Config = systemHeader(env.dbDir, env.cfg)
+	if env.cfg.Encoder != nil {
+		header.Encoder = env.cfg.Encoder.Name
+	}
+	if err := writeHeader(env.out, header); err != nil {
+		return err
+	}
+	if err := env.writePendingOptions(); err != nil {
+		return err
+	}
+	if env.detectors, err = newDetectorSet(env.cfg.Detectors, env.cfg.Watchdog, env.cfg.TestName, env.out); err != nil {
+		return err
+	}
+	env.startCompactor()
+	if env.writeIOFn != nil {
+		env.writeIO = env.writeIOFn()
+	}
+	env.gcStart = readGC()
+	env.device.begin(env.dbDir)
 	env.startTime = mononow()
 	env.lastTime = env.startTime
+	env.cfg.Live.reset(env.cfg.TestName, env.cfg.Tags, env.cfg.Size)
+	env.startDiskSampler()
+	env.stats.start(&env.mu, func() time.Duration { return mononow() - env.startTime }, func(c CompactionStats) {
+		writeCompactionStats(env.out, c)
+		env.cfg.Live.compaction(c)
+	})
+	env.detectors.startMemory(&env.mu, func() (time.Duration, uint64) {
+		return mononow() - env.startTime, env.written
+	})
+	return nil
+}
+
+// resetRand restarts the key and value sequence.
+func (env *WriteEnv) resetRand() (err error) {
+	env.rand, err = NewEntropy(env.cfg.Entropy, generatorSeed)
+	env.sizeRand = rand.New(rand.NewSource(generatorSeed))
+	return err
+}
+
+// finish writes the result. If the run ended with an error, it is recorded
+// in the result and the database isn't verified.
+func (env *WriteEnv) finish(err error) {
+	env.flushProgress()
+	env.stopCompactor()
+	env.stopDiskSampler()
+	env.stats.stopSampling()
+	env.detectors.stopMemory()
+	result := RunResult{
+		Ops:        env.ops,
+		UniqueKeys: env.keys.count(),
+		PutBytes:   env.putBytes,
+		Deletes:    env.deletes,
+		Durable:    env.durable.stats(),
+		Latency:    env.latency.percentiles(),
+		GC:         readGC().since(env.gcStart),
+		Device:     env.device.since(),
+	}
+	env.measureDiskSize(&result)
+	if env.writeIOFn != nil {
+		w := env.writeIOFn().sub(env.writeIO)
+		result.StorageWrites = &w
+	}
+	switch {
+	case env.uniquePuts == env.ops:
+		// Every write had a distinct key, the count is exact.
+		result.UniqueKeys = env.ops
+	case result.UniqueKeys > env.ops:
+		// The estimate can overshoot, but never by more than the writes.
+		result.UniqueKeys = env.ops
+	}
+	if env.cfg.StallThreshold > 0 {
+		result.Stalls = env.stalls
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	env.checkAcks(&result)
+	env.verifyCount(&result)
+	env.verifyValues(&result)
+	env.writeManifest(&result)
+	env.flushTrace(&result)
+	writeResult(env.out, result)
+	if err := env.latLog.close(); err != nil {
+		log.Printf("can't write latency log: %v", err)
+	}
+	env.latLog = nil
+	if err := env.csv.flush(); err != nil {
+		log.Printf("can't write progress table: %v", err)
+	}
+}
+
+// flushTrace completes the trace recording. A trace which couldn't be written
+// fails the run, so the recording isn't mistaken for a complete one.
+func (env *WriteEnv) flushTrace(result *RunResult) {
+	if env.trace == nil {
+		return
+	}
+	if err := env.trace.Flush(); err != nil && result.Error == "" {
+		result.Error = fmt.Sprintf("can't write trace: %v", err)
+	}
+}
+
+// Unsupported logs a run that can't be performed with the given configuration,
+// so it shows up in reports.
+func (env *WriteEnv) Unsupported(reason string) error {
+	if err := writeHeader(env.out, LogHeader{Test: env.cfg.TestName, Tags: env.cfg.Tags}); err != nil {
+		return err
+	}
+	return writeResult(env.out, RunResult{Unsupported: reason})
 }
 
 // LegacyWriteProgress writes a JSON progress event to the environment's output writer.
+// Every call counts as a single committed entry.
 func (env *WriteEnv) Progress(w int) {
+	env.ProgressBatch(w, 1)
+}
+
+// ProgressBatch is like Progress, but for a committed batch of n entries.
+func (env *WriteEnv) ProgressBatch(w, n int) {
 	now := mononow()
 	env.mu.Lock()
 	defer env.mu.Unlock()
 	env.written += uint64(w)
-	d := now - env.lastTime
+	env.entries += uint64(n)
+	env.commits++
+	env.maybeCompact()
+	if env.cfg.ReportInterval.due(env.written-env.lastWritten, now-env.lastTime) {
+		env.logProgress(now)
+	}
+}
+
+// logProgress writes a progress event covering the data written since the
+// previous one. It must be called with mu held.
+func (env *WriteEnv) logProgress(now time.Duration) {
 	dw := env.written - env.lastWritten
-	if dw > 0 && dw > emitInterval {
-		p := Progress{Processed: env.written, Delta: dw, Duration: d}
-		env.out.Encode(&p)
-		env.logPercentage()
-		env.lastTime = now
-		env.lastWritten = env.written
+	p := Progress{
+		Processed: env.written,
+		Delta:     dw,
+		Duration:  now - env.lastTime,
+		Time:      now - env.startTime,
+		Entries:   env.entries - env.lastEntries,
+		Commits:   env.commits - env.lastCommits,
+		P99:       env.intervalLatency.p99(),
+	}
+	writeProgress(env.out, &p)
+	env.detectors.observe(Metric{Time: now - env.startTime, Offset: env.written, Progress: &p})
+	env.cfg.Live.progress(p, env.entries, env.commits)
+	env.csv.add(p)
+	if err := env.influx.add(p); err != nil {
+		log.Printf("can't write influx progress sample: %v", err)
+		env.influx = nil
+	}
+	env.triggerDiskSample()
+	if env.cfg.SampleMemory {
+		sampleMemory(env.out, now-env.startTime, env.written)
+	}
+	env.logPercentage()
+	env.lastTime = now
+	env.lastWritten = env.written
+	env.lastEntries, env.lastCommits = env.entries, env.commits
+}
+
+// flushProgress logs the data written after the last progress event. This only
+// happens for time intervals, where the tail of the run can be long.
+func (env *WriteEnv) flushProgress() {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	if env.cfg.ReportInterval.Time > 0 && env.written > env.lastWritten {
+		env.logProgress(mononow())
 	}
 }
 